@@ -1,7 +1,9 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"expvar"
 	"fmt"
 	"log"
 	"net"
@@ -9,14 +11,27 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	// Calendar quotas load IANA time zones, which minimal images don't ship
+	_ "time/tzdata"
+
+	peerpb "github.com/AaronBrownDev/distributed-rate-limiter/gen/ratelimiter/peer/v1"
 	pb "github.com/AaronBrownDev/distributed-rate-limiter/gen/ratelimiter/v1"
 	grpcDelivery "github.com/AaronBrownDev/distributed-rate-limiter/internal/delivery/grpc"
 	httpDelivery "github.com/AaronBrownDev/distributed-rate-limiter/internal/delivery/http"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/breaker"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/cluster"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/local"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/memory"
 	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/redis"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/shard"
 	"github.com/AaronBrownDev/distributed-rate-limiter/internal/usecase"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	goredis "github.com/redis/go-redis/v9"
 	"google.golang.org/grpc"
 )
 
@@ -28,25 +43,68 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	// Get Redis configuration from environment
-	redisHost := os.Getenv("REDIS_HOST")
-	redisPort := os.Getenv("REDIS_PORT")
-
-	// Build Redis connection address
-	redisAddress := fmt.Sprintf("%s:%s", redisHost, redisPort)
-	keyPrefix := "ratelimit:"
-
-	// Initialize Redis storage
-	redisStorage, err := redis.NewRedisStorage(ctx, redisAddress, keyPrefix)
+	// Initialize storage backend
+	rateLimitStorage, err := newStorage(ctx, os.Getenv("STORAGE_BACKEND"))
 	if err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
+		log.Printf("Failed to initialize storage: %v", err)
+		exitCode = 1
+		return
 	}
 	defer func() {
-		log.Println("Redis connection closed...")
-		redisStorage.Close()
+		log.Println("Storage closed...")
+		rateLimitStorage.Close()
 	}()
-	// Create rate limiter service
-	rateLimitService := usecase.NewRateLimiterService(redisStorage)
+
+	// Answer calls forwarded by other cluster nodes
+	if clusterStorage, ok := rateLimitStorage.(*cluster.ClusterStorage); ok {
+		peerServer, err := startPeerServer(clusterStorage, os.Getenv("CLUSTER_ADDR"))
+		if err != nil {
+			log.Printf("Failed to start peer server: %v", err)
+			exitCode = 1
+			return
+		}
+		defer func() {
+			log.Println("Shutting down peer server...")
+			peerServer.GracefulStop()
+		}()
+	}
+
+	// Answer checks by their failure mode while a remote backend is unavailable. Policies and overrides are cached
+	// by the service, so they are read from the backend directly.
+	backend := rateLimitStorage
+	if _, ok := backend.(*memory.MemoryStorage); !ok {
+		breakerStorage, err := newBreakerStorage(backend, storage.FailureMode(os.Getenv("STORAGE_FAILURE_MODE")))
+		if err != nil {
+			log.Printf("Failed to initialize storage: %v", err)
+			exitCode = 1
+			return
+		}
+		rateLimitStorage = breakerStorage
+	}
+
+	// Create rate limiter service, with server-side policies, overrides and concurrency leases if the backend can store them
+	var opts []usecase.Option
+	if policyStorage, ok := backend.(storage.PolicyStorage); ok {
+		opts = append(opts, usecase.WithPolicies(policyStorage))
+	}
+	if overrideStorage, ok := backend.(storage.OverrideStorage); ok {
+		opts = append(opts, usecase.WithOverrides(overrideStorage))
+	}
+	if _, ok := backend.(storage.LeaseStorage); ok {
+		opts = append(opts, usecase.WithLeases(rateLimitStorage.(storage.LeaseStorage)))
+	}
+	rateLimitService := usecase.NewRateLimiterService(rateLimitStorage, opts...)
+
+	// Load policies defined in config
+	if policiesFile := os.Getenv("POLICIES_FILE"); policiesFile != "" {
+		count, err := loadPolicies(ctx, policiesFile, rateLimitService)
+		if err != nil {
+			log.Printf("Failed to load policies: %v", err)
+			exitCode = 1
+			return
+		}
+		log.Printf("Loaded %d policies from %s", count, policiesFile)
+	}
 
 	// Get gRPC port from environment or use default
 	grpcPort := 50051
@@ -103,11 +161,168 @@ func main() {
 	log.Println("Shutdown signal received")
 }
 
+// newStorage creates the storage backend selected by name.
+// An empty name selects Redis.
+func newStorage(ctx context.Context, backend string) (storage.RateLimitStorage, error) {
+	switch backend {
+	case "", "redis":
+		// Spread keys over standalone servers listed as name=host:port in REDIS_SHARDS
+		if redisShards := os.Getenv("REDIS_SHARDS"); redisShards != "" {
+			return newShardedRedisStorage(ctx, redisShards, storage.FailureMode(os.Getenv("REDIS_SHARD_FAILURE_MODE")))
+		}
+
+		// Get Redis configuration from environment
+		redisHost := os.Getenv("REDIS_HOST")
+		redisPort := os.Getenv("REDIS_PORT")
+
+		// Build Redis connection options. REDIS_ADDRS lists Cluster nodes or Sentinels instead of a single server,
+		// REDIS_MASTER_NAME selects Sentinel failover and REDIS_CLUSTER forces Cluster mode for a single address.
+		options := &goredis.UniversalOptions{
+			Addrs:      []string{fmt.Sprintf("%s:%s", redisHost, redisPort)},
+			MasterName: os.Getenv("REDIS_MASTER_NAME"),
+		}
+		if redisAddrs := os.Getenv("REDIS_ADDRS"); redisAddrs != "" {
+			options.Addrs = strings.Split(redisAddrs, ",")
+		}
+		if redisCluster := os.Getenv("REDIS_CLUSTER"); redisCluster != "" {
+			isCluster, err := strconv.ParseBool(redisCluster)
+			if err != nil {
+				return nil, fmt.Errorf("invalid REDIS_CLUSTER: %w", err)
+			}
+			options.IsClusterMode = isCluster
+		}
+		keyPrefix := "ratelimit:"
+
+		redisStorage, err := redis.NewUniversalRedisStorage(ctx, options, keyPrefix)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+		}
+		log.Printf("Using Redis storage at %v", options.Addrs)
+		return redisStorage, nil
+	case "memory":
+		log.Println("Using in-memory storage")
+		return memory.NewMemoryStorage(), nil
+	case "cluster":
+		// This node's address as other nodes reach it, e.g. "10.0.0.7:7946"
+		self := os.Getenv("CLUSTER_ADDR")
+		_, peerPort, err := net.SplitHostPort(self)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLUSTER_ADDR: %w", err)
+		}
+
+		// Members are listed in CLUSTER_PEERS or resolved from CLUSTER_DNS
+		var discovery cluster.Discovery
+		if dnsHost := os.Getenv("CLUSTER_DNS"); dnsHost != "" {
+			discovery = cluster.DNSDiscovery{Host: dnsHost, Port: peerPort}
+		} else {
+			discovery = cluster.StaticDiscovery(strings.Split(os.Getenv("CLUSTER_PEERS"), ","))
+		}
+
+		clusterStorage, err := cluster.NewClusterStorage(ctx, self, discovery)
+		if err != nil {
+			return nil, fmt.Errorf("failed to join cluster: %w", err)
+		}
+		log.Printf("Using cluster storage as %s with members %v", self, clusterStorage.Members())
+		return clusterStorage, nil
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q", backend)
+	}
+}
+
+// newShardedRedisStorage connects to every shard in shards, a comma-separated list of name=host:port, and routes keys
+// over them. Every shard answers as failureMode says while it is unhealthy.
+func newShardedRedisStorage(ctx context.Context, shards string, failureMode storage.FailureMode) (*shard.ShardedStorage, error) {
+	if failureMode != "" && (!failureMode.Valid() || failureMode == storage.FailLocal) {
+		return nil, fmt.Errorf("invalid REDIS_SHARD_FAILURE_MODE %q", failureMode)
+	}
+	keyPrefix := "ratelimit:"
+
+	var redisShards []shard.Shard
+	closeShards := func() {
+		for _, redisShard := range redisShards {
+			redisShard.Storage.Close()
+		}
+	}
+	for entry := range strings.SplitSeq(shards, ",") {
+		name, addr, ok := strings.Cut(entry, "=")
+		if !ok {
+			closeShards()
+			return nil, fmt.Errorf("invalid REDIS_SHARDS entry %q, want name=host:port", entry)
+		}
+
+		redisStorage, err := redis.NewRedisStorage(ctx, addr, keyPrefix)
+		if err != nil {
+			closeShards()
+			return nil, fmt.Errorf("failed to connect to Redis shard %s at %s: %w", name, addr, err)
+		}
+		redisShards = append(redisShards, shard.Shard{Name: name, Storage: redisStorage, FailureMode: failureMode})
+	}
+
+	shardedStorage, err := shard.NewShardedStorage(redisShards)
+	if err != nil {
+		closeShards()
+		return nil, err
+	}
+	// Per-shard health is served at /debug/vars
+	expvar.Publish("ratelimiter_shards", expvar.Func(func() any { return shardedStorage.Health() }))
+
+	log.Printf("Using sharded Redis storage with %d shards", len(redisShards))
+	return shardedStorage, nil
+}
+
+// newBreakerStorage wraps next in a circuit breaker. Checks whose policy has no failure mode are answered as
+// failureMode says while next is unavailable, and with an error if it is empty.
+func newBreakerStorage(next storage.RateLimitStorage, failureMode storage.FailureMode) (*breaker.BreakerStorage, error) {
+	if failureMode != "" && !failureMode.Valid() {
+		return nil, fmt.Errorf("invalid STORAGE_FAILURE_MODE %q", failureMode)
+	}
+
+	fallback, err := newLocalStorage(next)
+	if err != nil {
+		return nil, err
+	}
+	breakerStorage, err := breaker.NewBreakerStorage(next, breaker.WithFailureMode(failureMode), breaker.WithFallback(fallback))
+	if err != nil {
+		fallback.Close()
+		return nil, err
+	}
+	// The circuit state is served at /debug/vars
+	expvar.Publish("ratelimiter_breaker", expvar.Func(func() any { return breakerStorage.State() }))
+
+	log.Printf("Using circuit breaker with failure mode %q", cmp.Or(failureMode, storage.FailError))
+	return breakerStorage, nil
+}
+
+// newLocalStorage creates the storage that enforces this node's share of "local" limits while next is unavailable.
+// Limits are shared by LOCAL_FALLBACK_NODES nodes, by the cluster members in cluster mode, or else by this node alone.
+// LOCAL_FALLBACK_RECOVERY is "reconcile" (the default) or "discard".
+func newLocalStorage(next storage.RateLimitStorage) (*local.LocalStorage, error) {
+	nodes := func() int { return 1 }
+	if clusterStorage, ok := next.(*cluster.ClusterStorage); ok {
+		nodes = func() int { return len(clusterStorage.Members()) }
+	}
+	if envNodes := os.Getenv("LOCAL_FALLBACK_NODES"); envNodes != "" {
+		n, err := strconv.Atoi(envNodes)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid LOCAL_FALLBACK_NODES %q", envNodes)
+		}
+		nodes = func() int { return n }
+	}
+
+	localStorage, err := local.NewLocalStorage(local.WithNodes(nodes), local.WithRecovery(local.Recovery(os.Getenv("LOCAL_FALLBACK_RECOVERY"))))
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOCAL_FALLBACK_RECOVERY: %w", err)
+	}
+	return localStorage, nil
+}
+
 // startAPIServer creates and starts the HTTP server.
 func startAPIServer(rateLimitService *usecase.RateLimiterService, port int) (*http.Server, error) {
 	handler := httpDelivery.NewHandler(rateLimitService)
 	mux := http.NewServeMux()
 	handler.RegisterRoutes(mux)
+	// Counters such as ratelimiter_shadow_decisions, shard health as ratelimiter_shards and the circuit as ratelimiter_breaker
+	mux.Handle("/debug/vars", expvar.Handler())
 
 	server := &http.Server{
 		Handler: mux,
@@ -130,10 +345,37 @@ func startAPIServer(rateLimitService *usecase.RateLimiterService, port int) (*ht
 	return server, nil
 }
 
+// startPeerServer creates and starts the gRPC server other cluster nodes forward calls to, on the port of addr.
+func startPeerServer(clusterStorage *cluster.ClusterStorage, addr string) (*grpc.Server, error) {
+	peerServer := grpc.NewServer()
+	peerpb.RegisterPeerServiceServer(peerServer, cluster.NewPeerServer(clusterStorage))
+
+	// Listen on every interface, since addr is the address other nodes use
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CLUSTER_ADDR: %w", err)
+	}
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on port %s: %w", port, err)
+	}
+
+	// Start server in goroutine
+	go func() {
+		log.Printf("Peer server listening on :%s", port)
+		if err := peerServer.Serve(listener); err != nil {
+			log.Printf("Peer server error: %v", err)
+		}
+	}()
+
+	return peerServer, nil
+}
+
 // startGRPCServer creates and starts the gRPC server.
 func startGRPCServer(rateLimitService *usecase.RateLimiterService, port int) (*grpc.Server, error) {
 	grpcServer := grpc.NewServer()
 	pb.RegisterRateLimiterServiceServer(grpcServer, grpcDelivery.NewServer(rateLimitService))
+	rlsv3.RegisterRateLimitServiceServer(grpcServer, grpcDelivery.NewEnvoyServer(rateLimitService))
 
 	// Create listener to detect port binding errors early
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
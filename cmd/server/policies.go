@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/usecase"
+)
+
+// policyConfig is one entry of the policies file, e.g.
+//
+//	{"policies": [{"name": "api.write", "key_pattern": "write:*", "algorithm": "token_bucket", "limit": 100, "window_seconds": 60}]}
+//
+// A fixed window quota can reset on the calendar instead, e.g.
+//
+//	{"name": "api.monthly", "key_pattern": "org:*", "limit": 100000, "period": "month", "timezone": "America/New_York"}
+//
+// and further tiers can be enforced on top of the limit, e.g.
+//
+//	{"name": "api.read", "limit": 20, "window_seconds": 1, "tiers": [{"limit": 1000, "window_seconds": 3600}]}
+//
+// A failure mode of "open", "closed" or "local" decides how checks are answered while storage is unavailable.
+type policyConfig struct {
+	Name       string `json:"name"`
+	KeyPattern string `json:"key_pattern"`
+	limitConfig
+	Tiers       []limitConfig `json:"tiers"`
+	Shadow      bool          `json:"shadow"`
+	FailureMode string        `json:"failure_mode"`
+}
+
+// limitConfig is the limit of a policy or one of its tiers.
+type limitConfig struct {
+	Algorithm     string `json:"algorithm"`
+	Limit         int64  `json:"limit"`
+	WindowSeconds int64  `json:"window_seconds"`
+	RefillRate    int64  `json:"refill_rate"`
+	Period        string `json:"period"`
+	Timezone      string `json:"timezone"`
+}
+
+// toLimit builds the storage.Limit the config describes.
+func (c limitConfig) toLimit() storage.Limit {
+	return storage.Limit{
+		Algorithm:  storage.Algorithm(c.Algorithm),
+		Limit:      c.Limit,
+		Window:     time.Duration(c.WindowSeconds) * time.Second,
+		RefillRate: c.RefillRate,
+		Period:     storage.Period(c.Period),
+		Timezone:   c.Timezone,
+	}
+}
+
+// loadPolicies saves every policy in the JSON file at path, replacing stored policies with the same name.
+func loadPolicies(ctx context.Context, path string, rateLimitService *usecase.RateLimiterService) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var config struct {
+		Policies []policyConfig `json:"policies"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	for _, p := range config.Policies {
+		policy := storage.Policy{
+			Name:        p.Name,
+			KeyPattern:  p.KeyPattern,
+			Limit:       p.toLimit(),
+			Shadow:      p.Shadow,
+			FailureMode: storage.FailureMode(p.FailureMode),
+		}
+		for _, tier := range p.Tiers {
+			policy.Tiers = append(policy.Tiers, tier.toLimit())
+		}
+		if err := rateLimitService.SavePolicy(ctx, policy); err != nil {
+			return 0, fmt.Errorf("policy %q: %w", p.Name, err)
+		}
+	}
+	return len(config.Policies), nil
+}
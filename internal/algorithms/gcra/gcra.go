@@ -0,0 +1,120 @@
+package gcra
+
+import (
+	"sync"
+	"time"
+)
+
+// GCRA represents a thread-safe generic cell rate algorithm limiter.
+// It only tracks the theoretical arrival time (TAT) of the next request: requests are
+// spaced emissionInterval apart, and up to burst of them may arrive early at once.
+type GCRA struct {
+	emissionInterval time.Duration // Time each unit of cost occupies
+	burst            int64         // Max cost that can be spent at once
+	tat              time.Time     // Theoretical arrival time
+	mutex            sync.Mutex    // Mutex for thread safety
+}
+
+// NewGCRA returns a new GCRA that allows limit cost per window, all of which may be spent at once.
+func NewGCRA(limit int64, window time.Duration) *GCRA {
+	return &GCRA{
+		emissionInterval: EmissionInterval(limit, window),
+		burst:            limit,
+	}
+}
+
+// EmissionInterval returns the spacing between requests for limit per window, never less than 1ns.
+func EmissionInterval(limit int64, window time.Duration) time.Duration {
+	interval := window / time.Duration(limit)
+	if interval <= 0 {
+		return 1
+	}
+	return interval
+}
+
+// AllowAt checks if a request of the given cost is allowed at the given time and records it if so.
+// Returns whether it was allowed, the remaining cost that could still be spent at once,
+// and how long to wait before the same request would be allowed.
+func (g *GCRA) AllowAt(cost int64, now time.Time) (bool, int64, time.Duration) {
+	// Locks the mutex for thread safety
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	tat := g.tat
+	if tat.Before(now) {
+		tat = now
+	}
+
+	// The request is allowed if it would not push the TAT further ahead than the burst tolerance
+	newTAT := tat.Add(g.emissionInterval * time.Duration(cost))
+	allowAt := newTAT.Add(-g.tolerance())
+	if allowAt.After(now) {
+		return false, g.remaining(tat, now), allowAt.Sub(now)
+	}
+
+	g.tat = newTAT
+	return true, g.remaining(newTAT, now), 0
+}
+
+// RemainingAt returns the cost that could be spent at once at the given time.
+func (g *GCRA) RemainingAt(now time.Time) int64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	tat := g.tat
+	if tat.Before(now) {
+		tat = now
+	}
+	return g.remaining(tat, now)
+}
+
+// RefundAt gives back cost recorded earlier by moving the TAT back, but never before the given time.
+// Returns the remaining cost that could be spent at once.
+func (g *GCRA) RefundAt(cost int64, now time.Time) int64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	tat := g.tat.Add(-g.emissionInterval * time.Duration(cost))
+	if tat.Before(now) {
+		tat = now
+	}
+	g.tat = tat
+	return g.remaining(tat, now)
+}
+
+// FullAt returns when the whole burst will be available again.
+func (g *GCRA) FullAt(now time.Time) time.Time {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.tat.Before(now) {
+		return now
+	}
+	return g.tat
+}
+
+// Clone returns an independent copy of the limiter in its current state.
+func (g *GCRA) Clone() *GCRA {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return &GCRA{
+		emissionInterval: g.emissionInterval,
+		burst:            g.burst,
+		tat:              g.tat,
+	}
+}
+
+// tolerance is how far ahead of now the TAT may be.
+func (g *GCRA) tolerance() time.Duration {
+	return g.emissionInterval * time.Duration(g.burst)
+}
+
+// remaining converts the slack between the tolerance and the TAT into whole units of cost.
+func (g *GCRA) remaining(tat, now time.Time) int64 {
+	slack := g.tolerance() - tat.Sub(now)
+	if slack <= 0 {
+		return 0
+	}
+	return int64(slack / g.emissionInterval)
+}
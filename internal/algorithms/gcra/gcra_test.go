@@ -0,0 +1,144 @@
+package gcra
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGCRA_AllowAt(t *testing.T) {
+	type allowCall struct {
+		cost           int64
+		at             time.Duration
+		wantedBool     bool
+		wantedRemain   int64
+		wantedRetry    time.Duration
+		wantedFullAtIn time.Duration
+	}
+
+	tests := []struct {
+		name   string
+		limit  int64
+		window time.Duration
+		calls  []allowCall
+	}{
+		{
+			name:   "Burst up to limit",
+			limit:  5,
+			window: 5 * time.Second,
+			calls: []allowCall{
+				{cost: 1, at: 0, wantedBool: true, wantedRemain: 4, wantedFullAtIn: time.Second},
+				{cost: 4, at: 0, wantedBool: true, wantedRemain: 0, wantedFullAtIn: 5 * time.Second},
+				{cost: 1, at: 0, wantedBool: false, wantedRemain: 0, wantedRetry: time.Second, wantedFullAtIn: 5 * time.Second},
+			},
+		},
+		{
+			name:   "Exact retry after for weighted cost",
+			limit:  10,
+			window: 10 * time.Second,
+			calls: []allowCall{
+				{cost: 10, at: 0, wantedBool: true, wantedRemain: 0, wantedFullAtIn: 10 * time.Second},
+				{cost: 3, at: 500 * time.Millisecond, wantedBool: false, wantedRemain: 0, wantedRetry: 2500 * time.Millisecond, wantedFullAtIn: 9500 * time.Millisecond},
+				{cost: 3, at: 3 * time.Second, wantedBool: true, wantedRemain: 0, wantedFullAtIn: 10 * time.Second},
+			},
+		},
+		{
+			name:   "Emission interval restores capacity",
+			limit:  4,
+			window: 4 * time.Second,
+			calls: []allowCall{
+				{cost: 4, at: 0, wantedBool: true, wantedRemain: 0, wantedFullAtIn: 4 * time.Second},
+				{cost: 1, at: 2500 * time.Millisecond, wantedBool: true, wantedRemain: 1, wantedFullAtIn: 2500 * time.Millisecond},
+				{cost: 1, at: time.Minute, wantedBool: true, wantedRemain: 3, wantedFullAtIn: time.Second},
+			},
+		},
+		{
+			name:   "Cost above limit is never allowed",
+			limit:  2,
+			window: time.Second,
+			calls: []allowCall{
+				{cost: 3, at: 0, wantedBool: false, wantedRemain: 2, wantedRetry: 500 * time.Millisecond, wantedFullAtIn: 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Unix(0, 0)
+			g := NewGCRA(tt.limit, tt.window)
+
+			for i, call := range tt.calls {
+				now := start.Add(call.at)
+
+				allowed, remaining, retryAfter := g.AllowAt(call.cost, now)
+				if allowed != call.wantedBool {
+					t.Errorf("call %d: got allowed=%v, want %v", i, allowed, call.wantedBool)
+				}
+				if remaining != call.wantedRemain {
+					t.Errorf("call %d: got remaining=%d, want %d", i, remaining, call.wantedRemain)
+				}
+				if retryAfter != call.wantedRetry {
+					t.Errorf("call %d: got retryAfter=%v, want %v", i, retryAfter, call.wantedRetry)
+				}
+				if got := g.RemainingAt(now); got != call.wantedRemain {
+					t.Errorf("call %d: got RemainingAt=%d, want %d", i, got, call.wantedRemain)
+				}
+				if got, want := g.FullAt(now), now.Add(call.wantedFullAtIn); !got.Equal(want) {
+					t.Errorf("call %d: got FullAt=%v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestGCRA_EmissionInterval(t *testing.T) {
+	if got := EmissionInterval(10, time.Second); got != 100*time.Millisecond {
+		t.Errorf("got %v, want 100ms", got)
+	}
+	if got := EmissionInterval(10, 5*time.Nanosecond); got != 1 {
+		t.Errorf("got %v, want 1ns minimum", got)
+	}
+}
+
+func TestGCRA_Clone(t *testing.T) {
+	start := time.Now()
+	original := NewGCRA(5, 5*time.Second)
+	original.AllowAt(2, start)
+
+	clone := original.Clone()
+	clone.AllowAt(3, start)
+
+	if got := original.RemainingAt(start); got != 3 {
+		t.Errorf("original RemainingAt() = %d, want 3", got)
+	}
+	if got := clone.RemainingAt(start); got != 0 {
+		t.Errorf("clone RemainingAt() = %d, want 0", got)
+	}
+}
+
+func TestGCRA_RefundAt(t *testing.T) {
+	start := time.Now()
+
+	tests := []struct {
+		name          string
+		spent         int64
+		refund        int64
+		at            time.Duration
+		wantRemaining int64
+	}{
+		{name: "Partial refund", spent: 4, refund: 2, wantRemaining: 3},
+		{name: "Refund capped at burst", spent: 1, refund: 10, wantRemaining: 5},
+		{name: "Refund after TAT passed", spent: 5, refund: 5, at: 10 * time.Second, wantRemaining: 5},
+		{name: "Refund after partial recovery", spent: 5, refund: 1, at: 2 * time.Second, wantRemaining: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGCRA(5, 5*time.Second)
+			g.AllowAt(tt.spent, start)
+
+			if got := g.RefundAt(tt.refund, start.Add(tt.at)); got != tt.wantRemaining {
+				t.Errorf("RefundAt() = %d, want %d", got, tt.wantRemaining)
+			}
+		})
+	}
+}
@@ -17,12 +17,17 @@ type TokenBucket struct {
 
 // NewTokenBucket returns a new TokenBucket that corresponds with the provided arguments.
 func NewTokenBucket(capacity, refillRate int64, refillPeriod time.Duration) *TokenBucket {
+	return NewTokenBucketAt(capacity, refillRate, refillPeriod, time.Now())
+}
+
+// NewTokenBucketAt returns a new full TokenBucket whose refill periods start at the given time.
+func NewTokenBucketAt(capacity, refillRate int64, refillPeriod time.Duration, start time.Time) *TokenBucket {
 	return &TokenBucket{
 		capacity:     capacity,
 		tokens:       capacity,
 		refillRate:   refillRate,
 		refillPeriod: refillPeriod,
-		lastRefill:   time.Now(),
+		lastRefill:   start,
 		mutex:        sync.Mutex{},
 	}
 }
@@ -31,12 +36,89 @@ func NewTokenBucket(capacity, refillRate int64, refillPeriod time.Duration) *Tok
 // Returns true and remaining tokens if valid request
 // else returns false and current tokens.
 func (tb *TokenBucket) Allow(tokensRequest int64) (bool, int64) {
+	return tb.AllowAt(tokensRequest, time.Now())
+}
+
+// AllowAt is Allow evaluated at the given time.
+func (tb *TokenBucket) AllowAt(tokensRequest int64, now time.Time) (bool, int64) {
 	// Locks the mutex for thread safety
 	tb.mutex.Lock()
 	defer tb.mutex.Unlock()
 
+	tb.refill(now)
+
+	if tb.tokens-tokensRequest < 0 {
+		return false, tb.tokens
+	} else {
+		tb.tokens -= tokensRequest
+		return true, tb.tokens
+	}
+}
+
+// TokensAt returns the tokens available at the given time without consuming any.
+func (tb *TokenBucket) TokensAt(now time.Time) int64 {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+
+	tb.refill(now)
+	return tb.tokens
+}
+
+// RefundAt puts tokens back in the bucket at the given time, never above capacity.
+// Returns the tokens now available.
+func (tb *TokenBucket) RefundAt(tokensRefunded int64, now time.Time) int64 {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+
+	tb.refill(now)
+	tb.tokens = min(tb.capacity, tb.tokens+tokensRefunded)
+	return tb.tokens
+}
+
+// FullAt returns when the bucket will be back at capacity if no more tokens are taken.
+func (tb *TokenBucket) FullAt(now time.Time) time.Time {
+	return tb.AvailableAt(tb.capacity, now)
+}
+
+// AvailableAt returns when the bucket will hold the requested tokens if no more tokens are taken.
+// Requests above capacity are capped at capacity.
+func (tb *TokenBucket) AvailableAt(tokensRequest int64, now time.Time) time.Time {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+
+	tb.refill(now)
+
+	missing := min(tokensRequest, tb.capacity) - tb.tokens
+	if missing <= 0 {
+		return now
+	}
+
+	// Round up to whole refill periods
+	periods := (missing + tb.refillRate - 1) / tb.refillRate
+	return tb.lastRefill.Add(tb.refillPeriod * time.Duration(periods))
+}
+
+// Clone returns an independent copy of the bucket in its current state.
+func (tb *TokenBucket) Clone() *TokenBucket {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+
+	return &TokenBucket{
+		capacity:     tb.capacity,
+		tokens:       tb.tokens,
+		refillRate:   tb.refillRate,
+		refillPeriod: tb.refillPeriod,
+		lastRefill:   tb.lastRefill,
+	}
+}
+
+// refill adds the tokens earned since the last refill. Callers must hold the mutex.
+func (tb *TokenBucket) refill(now time.Time) {
 	// Finds how much time has passed since last refill
-	elapsedTime := time.Since(tb.lastRefill)
+	elapsedTime := now.Sub(tb.lastRefill)
+	if elapsedTime < 0 {
+		return
+	}
 
 	// Compute how many tokens to add based on elapsed time
 	increments := elapsedTime / tb.refillPeriod
@@ -53,13 +135,6 @@ func (tb *TokenBucket) Allow(tokensRequest int64) (bool, int64) {
 	} else {
 		tb.tokens += newTokens
 	}
-
-	if tb.tokens-tokensRequest < 0 {
-		return false, tb.tokens
-	} else {
-		tb.tokens -= tokensRequest
-		return true, tb.tokens
-	}
 }
 
 // getCurrentTokens is a helper function for getting the current amount of tokens in the bucket
@@ -136,3 +136,86 @@ func TestTokenBucket_Allow(t *testing.T) {
 		})
 	}
 }
+
+func TestTokenBucket_AllowAt(t *testing.T) {
+	start := time.Unix(0, 0)
+	tb := NewTokenBucketAt(5, 2, time.Second, start)
+
+	tests := []struct {
+		name          string
+		requestTokens int64
+		at            time.Duration
+		wantedBool    bool
+		wantedTokens  int64
+		wantedFullAt  time.Duration
+	}{
+		{name: "Drain bucket", requestTokens: 5, at: 0, wantedBool: true, wantedTokens: 0, wantedFullAt: 3 * time.Second},
+		{name: "Reject before refill", requestTokens: 1, at: 999 * time.Millisecond, wantedBool: false, wantedTokens: 0, wantedFullAt: 3 * time.Second},
+		{name: "Partial refill", requestTokens: 1, at: 1500 * time.Millisecond, wantedBool: true, wantedTokens: 1, wantedFullAt: 3 * time.Second},
+		{name: "Refill caps at capacity", requestTokens: 1, at: time.Minute, wantedBool: true, wantedTokens: 4, wantedFullAt: time.Minute + time.Second},
+		{name: "Time going backwards adds nothing", requestTokens: 1, at: 30 * time.Second, wantedBool: true, wantedTokens: 3, wantedFullAt: time.Minute + time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := start.Add(tt.at)
+
+			success, tokens := tb.AllowAt(tt.requestTokens, now)
+			if success != tt.wantedBool {
+				t.Errorf("got success=%v, want %v", success, tt.wantedBool)
+			}
+			if tokens != tt.wantedTokens {
+				t.Errorf("got tokens=%d, want %d", tokens, tt.wantedTokens)
+			}
+			if got := tb.TokensAt(now); got != tt.wantedTokens {
+				t.Errorf("got TokensAt=%d, want %d", got, tt.wantedTokens)
+			}
+			if got, want := tb.FullAt(now), start.Add(tt.wantedFullAt); !got.Equal(want) {
+				t.Errorf("got FullAt=%v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestTokenBucket_Clone(t *testing.T) {
+	start := time.Now()
+	original := NewTokenBucketAt(5, 1, time.Second, start)
+	original.AllowAt(2, start)
+
+	clone := original.Clone()
+	clone.AllowAt(3, start)
+
+	if got := original.TokensAt(start); got != 3 {
+		t.Errorf("original TokensAt() = %d, want 3", got)
+	}
+	if got := clone.TokensAt(start); got != 0 {
+		t.Errorf("clone TokensAt() = %d, want 0", got)
+	}
+}
+
+func TestTokenBucket_RefundAt(t *testing.T) {
+	start := time.Now()
+
+	tests := []struct {
+		name       string
+		taken      int64
+		refund     int64
+		at         time.Duration
+		wantTokens int64
+	}{
+		{name: "Partial refund", taken: 4, refund: 2, wantTokens: 3},
+		{name: "Refund capped at capacity", taken: 1, refund: 10, wantTokens: 5},
+		{name: "Refund after refill", taken: 5, refund: 2, at: 2 * time.Second, wantTokens: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := NewTokenBucketAt(5, 1, time.Second, start)
+			tb.AllowAt(tt.taken, start)
+
+			if got := tb.RefundAt(tt.refund, start.Add(tt.at)); got != tt.wantTokens {
+				t.Errorf("RefundAt() = %d, want %d", got, tt.wantTokens)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/usecase"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	ratelimitv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// EnvoyServer implements Envoy's rate limit service (envoy.service.ratelimit.v3.RateLimitService).
+//
+// Each descriptor becomes the key "<domain>:<key>=<value>:..." and is checked like any other key,
+// so server-side policies select limits through their key patterns (e.g. "edge:remote_address=*").
+// A limit override sent by Envoy is used when no policy matches. Descriptors with neither are not limited.
+type EnvoyServer struct {
+	rls *usecase.RateLimiterService
+	rlsv3.UnimplementedRateLimitServiceServer
+}
+
+// NewEnvoyServer creates a new Envoy rate limit service with the provided rate limiter service.
+func NewEnvoyServer(usecaseServer *usecase.RateLimiterService) *EnvoyServer {
+	return &EnvoyServer{
+		rls: usecaseServer,
+	}
+}
+
+// ShouldRateLimit checks every descriptor and reports OVER_LIMIT if any of them is over its limit.
+func (s *EnvoyServer) ShouldRateLimit(ctx context.Context, req *rlsv3.RateLimitRequest) (*rlsv3.RateLimitResponse, error) {
+	response := &rlsv3.RateLimitResponse{
+		OverallCode: rlsv3.RateLimitResponse_OK,
+		Statuses:    make([]*rlsv3.RateLimitResponse_DescriptorStatus, 0, len(req.GetDescriptors())),
+	}
+
+	// The most restrictive limited descriptor drives the response headers
+	var tightest *usecase.Result
+	for _, descriptor := range req.GetDescriptors() {
+		result, err := s.checkDescriptor(ctx, req.GetDomain(), descriptor, req.GetHitsAddend())
+		if err != nil {
+			return nil, handleError(err)
+		}
+
+		descriptorStatus := toDescriptorStatus(result)
+		response.Statuses = append(response.Statuses, descriptorStatus)
+		if descriptorStatus.Code == rlsv3.RateLimitResponse_OVER_LIMIT {
+			response.OverallCode = rlsv3.RateLimitResponse_OVER_LIMIT
+		}
+		if result != nil && (tightest == nil || result.Remaining < tightest.Remaining) {
+			tightest = result
+		}
+	}
+
+	if tightest != nil {
+		response.ResponseHeadersToAdd = rateLimitHeaders(tightest)
+	}
+	return response, nil
+}
+
+// checkDescriptor consumes the descriptor's hits, or only reads its status when Envoy sends zero hits.
+// It returns a nil result if the descriptor has no limit.
+func (s *EnvoyServer) checkDescriptor(ctx context.Context, domain string, descriptor *ratelimitv3.RateLimitDescriptor, hitsAddend uint32) (*usecase.Result, error) {
+	key := descriptorKey(domain, descriptor)
+	limit := toOverrideLimit(descriptor.GetLimit())
+
+	// Envoy sends one hit unless the request or descriptor says otherwise
+	cost := int64(max(1, hitsAddend))
+	if descriptor.GetHitsAddend() != nil {
+		cost = int64(descriptor.GetHitsAddend().GetValue())
+	}
+
+	var result *usecase.Result
+	var err error
+	if cost == 0 {
+		result, err = s.rls.GetStatus(ctx, usecase.StatusRequest{Key: key, Limit: limit})
+	} else {
+		result, err = s.rls.CheckRateLimit(ctx, usecase.CheckRequest{Key: key, Limit: limit, Cost: cost})
+	}
+
+	// Neither a policy nor an override applies, so the descriptor isn't limited
+	if errors.Is(err, usecase.ErrInvalidLimit) || errors.Is(err, usecase.ErrInvalidWindow) {
+		return nil, nil
+	}
+	return result, err
+}
+
+// descriptorKey joins the domain and descriptor entries into a rate limit key.
+func descriptorKey(domain string, descriptor *ratelimitv3.RateLimitDescriptor) string {
+	var key strings.Builder
+	key.WriteString(domain)
+	for _, entry := range descriptor.GetEntries() {
+		key.WriteString(":")
+		key.WriteString(entry.GetKey())
+		key.WriteString("=")
+		key.WriteString(entry.GetValue())
+	}
+	return key.String()
+}
+
+// envoyUnits maps Envoy's rate limit units to windows. Months and years use fixed lengths, as lyft/ratelimit does.
+var envoyUnits = map[typev3.RateLimitUnit]time.Duration{
+	typev3.RateLimitUnit_SECOND: time.Second,
+	typev3.RateLimitUnit_MINUTE: time.Minute,
+	typev3.RateLimitUnit_HOUR:   time.Hour,
+	typev3.RateLimitUnit_DAY:    24 * time.Hour,
+	typev3.RateLimitUnit_MONTH:  30 * 24 * time.Hour,
+	typev3.RateLimitUnit_YEAR:   365 * 24 * time.Hour,
+}
+
+// toOverrideLimit builds a fixed window limit from Envoy's per-descriptor override. A missing override gives an empty limit.
+func toOverrideLimit(override *ratelimitv3.RateLimitDescriptor_RateLimitOverride) storage.Limit {
+	if override == nil {
+		return storage.Limit{}
+	}
+
+	return storage.Limit{
+		Algorithm: storage.FixedWindow,
+		Limit:     int64(override.GetRequestsPerUnit()),
+		Window:    envoyUnits[override.GetUnit()],
+	}
+}
+
+// responseUnits maps windows back to the units Envoy reports in current_limit. Other windows are reported as UNKNOWN.
+var responseUnits = map[time.Duration]rlsv3.RateLimitResponse_RateLimit_Unit{
+	time.Second:         rlsv3.RateLimitResponse_RateLimit_SECOND,
+	time.Minute:         rlsv3.RateLimitResponse_RateLimit_MINUTE,
+	time.Hour:           rlsv3.RateLimitResponse_RateLimit_HOUR,
+	24 * time.Hour:      rlsv3.RateLimitResponse_RateLimit_DAY,
+	7 * 24 * time.Hour:  rlsv3.RateLimitResponse_RateLimit_WEEK,
+	30 * 24 * time.Hour: rlsv3.RateLimitResponse_RateLimit_MONTH,
+}
+
+// periodUnits maps calendar periods to the units Envoy reports in current_limit.
+var periodUnits = map[storage.Period]rlsv3.RateLimitResponse_RateLimit_Unit{
+	storage.Daily:   rlsv3.RateLimitResponse_RateLimit_DAY,
+	storage.Weekly:  rlsv3.RateLimitResponse_RateLimit_WEEK,
+	storage.Monthly: rlsv3.RateLimitResponse_RateLimit_MONTH,
+}
+
+// responseUnit returns the unit Envoy reports for a limit, from its calendar period if it has one.
+func responseUnit(limit storage.Limit) rlsv3.RateLimitResponse_RateLimit_Unit {
+	if limit.Period != "" {
+		return periodUnits[limit.Period]
+	}
+	return responseUnits[limit.Window]
+}
+
+// toDescriptorStatus converts a result into Envoy's per-descriptor status. A nil result is an unlimited descriptor.
+func toDescriptorStatus(result *usecase.Result) *rlsv3.RateLimitResponse_DescriptorStatus {
+	if result == nil {
+		return &rlsv3.RateLimitResponse_DescriptorStatus{Code: rlsv3.RateLimitResponse_OK}
+	}
+
+	code := rlsv3.RateLimitResponse_OK
+	if !result.Allowed {
+		code = rlsv3.RateLimitResponse_OVER_LIMIT
+	}
+
+	return &rlsv3.RateLimitResponse_DescriptorStatus{
+		Code: code,
+		CurrentLimit: &rlsv3.RateLimitResponse_RateLimit{
+			Name:            result.Policy.Name,
+			RequestsPerUnit: uint32(min(result.Limit, math.MaxUint32)),
+			Unit:            responseUnit(result.Policy.Limits()[result.Tier]),
+		},
+		LimitRemaining:     uint32(min(result.Remaining, math.MaxUint32)),
+		DurationUntilReset: durationpb.New(max(0, time.Until(result.ResetAt))),
+	}
+}
+
+// rateLimitHeaders returns the same X-RateLimit headers the HTTP API sends.
+func rateLimitHeaders(result *usecase.Result) []*corev3.HeaderValue {
+	headers := []*corev3.HeaderValue{
+		{Key: "X-RateLimit-Limit", Value: strconv.FormatInt(result.Limit, 10)},
+		{Key: "X-RateLimit-Remaining", Value: strconv.FormatInt(result.Remaining, 10)},
+		{Key: "X-RateLimit-Reset", Value: strconv.FormatInt(result.ResetAt.Unix(), 10)},
+	}
+	if !result.Allowed {
+		retryAfterSeconds := int64(math.Ceil(result.RetryAfter.Seconds()))
+		headers = append(headers, &corev3.HeaderValue{Key: "Retry-After", Value: strconv.FormatInt(retryAfterSeconds, 10)})
+	}
+	return headers
+}
@@ -0,0 +1,154 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/memory"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/usecase"
+	ratelimitv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func descriptor(entries ...string) *ratelimitv3.RateLimitDescriptor {
+	d := &ratelimitv3.RateLimitDescriptor{}
+	for i := 0; i+1 < len(entries); i += 2 {
+		d.Entries = append(d.Entries, &ratelimitv3.RateLimitDescriptor_Entry{Key: entries[i], Value: entries[i+1]})
+	}
+	return d
+}
+
+func TestEnvoyServer_ShouldRateLimit(t *testing.T) {
+	ctx := context.Background()
+
+	ms := memory.NewMemoryStorage()
+	t.Cleanup(func() { ms.Close() })
+	err := ms.SavePolicy(ctx, storage.Policy{
+		Name:       "per-ip",
+		KeyPattern: "edge:remote_address=*",
+		Limit:      storage.Limit{Algorithm: storage.FixedWindow, Limit: 2, Window: time.Minute},
+	})
+	if err != nil {
+		t.Fatalf("SavePolicy: %v", err)
+	}
+	server := NewEnvoyServer(usecase.NewRateLimiterService(ms, usecase.WithPolicies(ms)))
+
+	override := descriptor("path", "/login")
+	override.Limit = &ratelimitv3.RateLimitDescriptor_RateLimitOverride{RequestsPerUnit: 1, Unit: typev3.RateLimitUnit_HOUR}
+
+	peek := descriptor("remote_address", "10.0.0.2")
+	peek.HitsAddend = wrapperspb.UInt64(0)
+
+	tests := []struct {
+		name          string
+		descriptors   []*ratelimitv3.RateLimitDescriptor
+		wantOverall   rlsv3.RateLimitResponse_Code
+		wantCodes     []rlsv3.RateLimitResponse_Code
+		wantRemaining []uint32
+		wantHeaders   bool
+	}{
+		{
+			name:          "policy matches descriptor key",
+			descriptors:   []*ratelimitv3.RateLimitDescriptor{descriptor("remote_address", "10.0.0.1")},
+			wantOverall:   rlsv3.RateLimitResponse_OK,
+			wantCodes:     []rlsv3.RateLimitResponse_Code{rlsv3.RateLimitResponse_OK},
+			wantRemaining: []uint32{1},
+			wantHeaders:   true,
+		},
+		{
+			name:          "unlimited descriptor is OK",
+			descriptors:   []*ratelimitv3.RateLimitDescriptor{descriptor("user", "alice")},
+			wantOverall:   rlsv3.RateLimitResponse_OK,
+			wantCodes:     []rlsv3.RateLimitResponse_Code{rlsv3.RateLimitResponse_OK},
+			wantRemaining: []uint32{0},
+			wantHeaders:   false,
+		},
+		{
+			name:          "override used without policy",
+			descriptors:   []*ratelimitv3.RateLimitDescriptor{override},
+			wantOverall:   rlsv3.RateLimitResponse_OK,
+			wantCodes:     []rlsv3.RateLimitResponse_Code{rlsv3.RateLimitResponse_OK},
+			wantRemaining: []uint32{0},
+			wantHeaders:   true,
+		},
+		{
+			name:          "any descriptor over limit",
+			descriptors:   []*ratelimitv3.RateLimitDescriptor{descriptor("remote_address", "10.0.0.1"), override},
+			wantOverall:   rlsv3.RateLimitResponse_OVER_LIMIT,
+			wantCodes:     []rlsv3.RateLimitResponse_Code{rlsv3.RateLimitResponse_OK, rlsv3.RateLimitResponse_OVER_LIMIT},
+			wantRemaining: []uint32{0, 0},
+			wantHeaders:   true,
+		},
+		{
+			name:          "zero hits only reads status",
+			descriptors:   []*ratelimitv3.RateLimitDescriptor{peek, peek},
+			wantOverall:   rlsv3.RateLimitResponse_OK,
+			wantCodes:     []rlsv3.RateLimitResponse_Code{rlsv3.RateLimitResponse_OK, rlsv3.RateLimitResponse_OK},
+			wantRemaining: []uint32{2, 2},
+			wantHeaders:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := server.ShouldRateLimit(ctx, &rlsv3.RateLimitRequest{Domain: "edge", Descriptors: tt.descriptors})
+			if err != nil {
+				t.Fatalf("ShouldRateLimit: %v", err)
+			}
+
+			if response.OverallCode != tt.wantOverall {
+				t.Errorf("OverallCode = %v, want %v", response.OverallCode, tt.wantOverall)
+			}
+			if len(response.Statuses) != len(tt.wantCodes) {
+				t.Fatalf("len(Statuses) = %d, want %d", len(response.Statuses), len(tt.wantCodes))
+			}
+			for i, status := range response.Statuses {
+				if status.Code != tt.wantCodes[i] {
+					t.Errorf("Statuses[%d].Code = %v, want %v", i, status.Code, tt.wantCodes[i])
+				}
+				if status.LimitRemaining != tt.wantRemaining[i] {
+					t.Errorf("Statuses[%d].LimitRemaining = %d, want %d", i, status.LimitRemaining, tt.wantRemaining[i])
+				}
+			}
+			if gotHeaders := len(response.ResponseHeadersToAdd) > 0; gotHeaders != tt.wantHeaders {
+				t.Errorf("headers present = %v, want %v", gotHeaders, tt.wantHeaders)
+			}
+		})
+	}
+}
+
+func TestEnvoyServer_CurrentLimit(t *testing.T) {
+	ctx := context.Background()
+
+	ms := memory.NewMemoryStorage()
+	t.Cleanup(func() { ms.Close() })
+	if err := ms.SavePolicy(ctx, storage.Policy{
+		Name:       "per-user",
+		KeyPattern: "api:user=*",
+		Limit:      storage.Limit{Limit: 10, Window: time.Minute},
+	}); err != nil {
+		t.Fatalf("SavePolicy: %v", err)
+	}
+	server := NewEnvoyServer(usecase.NewRateLimiterService(ms, usecase.WithPolicies(ms)))
+
+	response, err := server.ShouldRateLimit(ctx, &rlsv3.RateLimitRequest{
+		Domain:      "api",
+		Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor("user", "alice")},
+		HitsAddend:  3,
+	})
+	if err != nil {
+		t.Fatalf("ShouldRateLimit: %v", err)
+	}
+
+	current := response.Statuses[0].CurrentLimit
+	if current.Name != "per-user" || current.RequestsPerUnit != 10 || current.Unit != rlsv3.RateLimitResponse_RateLimit_MINUTE {
+		t.Errorf("CurrentLimit = %v, want per-user 10/MINUTE", current)
+	}
+	if remaining := response.Statuses[0].LimitRemaining; remaining != 7 {
+		t.Errorf("LimitRemaining = %d, want 7", remaining)
+	}
+}
@@ -0,0 +1,77 @@
+package grpc
+
+import (
+	"context"
+	"math"
+	"time"
+
+	pb "github.com/AaronBrownDev/distributed-rate-limiter/gen/ratelimiter/v1"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/usecase"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Acquire grants a concurrency lease if the key has room for another in-flight request.
+func (s *Server) Acquire(ctx context.Context, req *pb.AcquireRequest) (*pb.AcquireResponse, error) {
+	lease, err := s.rls.Acquire(ctx, usecase.LeaseRequest{
+		Key:    req.Key,
+		Policy: req.Policy,
+		Limit:  toLeaseLimit(req.Limit, req.LeaseSeconds),
+	})
+	if err != nil {
+		return nil, handleError(err)
+	}
+
+	response := &pb.AcquireResponse{
+		Acquired:  lease.Allowed,
+		LeaseId:   lease.ID,
+		Remaining: lease.Remaining,
+		Limit:     lease.Limit,
+		// Round up so clients never retry too early
+		RetryAfterSeconds: int64(math.Ceil(lease.RetryAfter.Seconds())),
+		RetryAfterMs:      lease.RetryAfter.Milliseconds(),
+		Override:          pbOverrideActions[lease.Override],
+	}
+	if lease.Allowed {
+		response.ExpiresAt = timestamppb.New(lease.ExpiresAt)
+	}
+	return response, nil
+}
+
+// Heartbeat extends a held lease.
+func (s *Server) Heartbeat(ctx context.Context, req *pb.HeartbeatRequest) (*pb.HeartbeatResponse, error) {
+	lease, err := s.rls.Heartbeat(ctx, usecase.LeaseRequest{
+		Key:     req.Key,
+		Policy:  req.Policy,
+		Limit:   toLeaseLimit(req.Limit, req.LeaseSeconds),
+		LeaseID: req.LeaseId,
+	})
+	if err != nil {
+		return nil, handleError(err)
+	}
+
+	return &pb.HeartbeatResponse{
+		Remaining: lease.Remaining,
+		Limit:     lease.Limit,
+		ExpiresAt: timestamppb.New(lease.ExpiresAt),
+		Override:  pbOverrideActions[lease.Override],
+	}, nil
+}
+
+// Release frees a held lease.
+func (s *Server) Release(ctx context.Context, req *pb.ReleaseRequest) (*pb.ReleaseResponse, error) {
+	if err := s.rls.Release(ctx, req.Key, req.LeaseId); err != nil {
+		return nil, handleError(err)
+	}
+
+	return &pb.ReleaseResponse{}, nil
+}
+
+// toLeaseLimit builds the concurrency limit sent with a lease request.
+func toLeaseLimit(limit, leaseSeconds int64) storage.Limit {
+	return storage.Limit{
+		Algorithm: storage.Concurrency,
+		Limit:     limit,
+		Window:    time.Duration(leaseSeconds) * time.Second,
+	}
+}
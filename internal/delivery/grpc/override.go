@@ -0,0 +1,95 @@
+package grpc
+
+import (
+	"context"
+
+	pb "github.com/AaronBrownDev/distributed-rate-limiter/gen/ratelimiter/v1"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/usecase"
+)
+
+// SaveOverride creates or replaces an override.
+func (s *Server) SaveOverride(ctx context.Context, req *pb.SaveOverrideRequest) (*pb.SaveOverrideResponse, error) {
+	override, err := toOverride(req.GetOverride())
+	if err != nil {
+		return nil, handleError(err)
+	}
+
+	if err := s.rls.SaveOverride(ctx, override); err != nil {
+		return nil, handleError(err)
+	}
+
+	return &pb.SaveOverrideResponse{Override: fromOverride(&override)}, nil
+}
+
+// DeleteOverride removes an override.
+func (s *Server) DeleteOverride(ctx context.Context, req *pb.DeleteOverrideRequest) (*pb.DeleteOverrideResponse, error) {
+	if err := s.rls.DeleteOverride(ctx, req.Pattern); err != nil {
+		return nil, handleError(err)
+	}
+
+	return &pb.DeleteOverrideResponse{}, nil
+}
+
+// GetOverride returns an override by pattern.
+func (s *Server) GetOverride(ctx context.Context, req *pb.GetOverrideRequest) (*pb.GetOverrideResponse, error) {
+	override, err := s.rls.GetOverride(ctx, req.Pattern)
+	if err != nil {
+		return nil, handleError(err)
+	}
+
+	return &pb.GetOverrideResponse{Override: fromOverride(override)}, nil
+}
+
+// ListOverrides returns every override.
+func (s *Server) ListOverrides(ctx context.Context, req *pb.ListOverridesRequest) (*pb.ListOverridesResponse, error) {
+	overrides, err := s.rls.ListOverrides(ctx)
+	if err != nil {
+		return nil, handleError(err)
+	}
+
+	response := &pb.ListOverridesResponse{Overrides: make([]*pb.Override, 0, len(overrides))}
+	for i := range overrides {
+		response.Overrides = append(response.Overrides, fromOverride(&overrides[i]))
+	}
+	return response, nil
+}
+
+// toOverride builds a storage.Override from a protobuf override. A missing override fails validation.
+func toOverride(override *pb.Override) (storage.Override, error) {
+	action, ok := overrideActions[override.GetAction()]
+	if !ok {
+		return storage.Override{}, usecase.ErrInvalidOverrideAction
+	}
+
+	return storage.Override{
+		Pattern:    override.GetPattern(),
+		Action:     action,
+		Multiplier: override.GetMultiplier(),
+		Reason:     override.GetReason(),
+	}, nil
+}
+
+// fromOverride converts a storage.Override into its protobuf form.
+func fromOverride(override *storage.Override) *pb.Override {
+	return &pb.Override{
+		Pattern:    override.Pattern,
+		Action:     pbOverrideActions[override.Action],
+		Multiplier: override.Multiplier,
+		Reason:     override.Reason,
+	}
+}
+
+// overrideActions maps protobuf override actions to their storage equivalent
+var overrideActions = map[pb.OverrideAction]storage.OverrideAction{
+	pb.OverrideAction_OVERRIDE_ACTION_ALLOW: storage.AlwaysAllow,
+	pb.OverrideAction_OVERRIDE_ACTION_DENY:  storage.AlwaysDeny,
+	pb.OverrideAction_OVERRIDE_ACTION_SCALE: storage.ScaleLimit,
+}
+
+// pbOverrideActions maps storage override actions back to their protobuf equivalent
+var pbOverrideActions = map[storage.OverrideAction]pb.OverrideAction{
+	storage.AlwaysAllow: pb.OverrideAction_OVERRIDE_ACTION_ALLOW,
+	storage.AlwaysDeny:  pb.OverrideAction_OVERRIDE_ACTION_DENY,
+	storage.ScaleLimit:  pb.OverrideAction_OVERRIDE_ACTION_SCALE,
+}
@@ -0,0 +1,182 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+
+	pb "github.com/AaronBrownDev/distributed-rate-limiter/gen/ratelimiter/v1"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/usecase"
+)
+
+// CreatePolicy adds a server-side policy.
+func (s *Server) CreatePolicy(ctx context.Context, req *pb.CreatePolicyRequest) (*pb.CreatePolicyResponse, error) {
+	policy, err := toPolicy(req.GetPolicy())
+	if err != nil {
+		return nil, handleError(err)
+	}
+
+	created, err := s.rls.CreatePolicy(ctx, policy)
+	if err != nil {
+		return nil, handleError(err)
+	}
+
+	return &pb.CreatePolicyResponse{Policy: fromPolicy(created)}, nil
+}
+
+// UpdatePolicy replaces an existing server-side policy.
+func (s *Server) UpdatePolicy(ctx context.Context, req *pb.UpdatePolicyRequest) (*pb.UpdatePolicyResponse, error) {
+	policy, err := toPolicy(req.GetPolicy())
+	if err != nil {
+		return nil, handleError(err)
+	}
+
+	updated, err := s.rls.UpdatePolicy(ctx, policy)
+	if err != nil {
+		return nil, handleError(err)
+	}
+
+	return &pb.UpdatePolicyResponse{Policy: fromPolicy(updated)}, nil
+}
+
+// DeletePolicy removes a server-side policy.
+func (s *Server) DeletePolicy(ctx context.Context, req *pb.DeletePolicyRequest) (*pb.DeletePolicyResponse, error) {
+	if err := s.rls.DeletePolicy(ctx, req.Name); err != nil {
+		return nil, handleError(err)
+	}
+
+	return &pb.DeletePolicyResponse{}, nil
+}
+
+// GetPolicy returns a server-side policy by name.
+func (s *Server) GetPolicy(ctx context.Context, req *pb.GetPolicyRequest) (*pb.GetPolicyResponse, error) {
+	policy, err := s.rls.GetPolicy(ctx, req.Name)
+	if err != nil {
+		return nil, handleError(err)
+	}
+
+	return &pb.GetPolicyResponse{Policy: fromPolicy(policy)}, nil
+}
+
+// ListPolicies returns every server-side policy.
+func (s *Server) ListPolicies(ctx context.Context, req *pb.ListPoliciesRequest) (*pb.ListPoliciesResponse, error) {
+	policies, err := s.rls.ListPolicies(ctx)
+	if err != nil {
+		return nil, handleError(err)
+	}
+
+	response := &pb.ListPoliciesResponse{Policies: make([]*pb.Policy, 0, len(policies))}
+	for i := range policies {
+		response.Policies = append(response.Policies, fromPolicy(&policies[i]))
+	}
+	return response, nil
+}
+
+// toPolicy builds a storage.Policy from a protobuf policy. A missing policy becomes an empty one and fails validation.
+func toPolicy(policy *pb.Policy) (storage.Policy, error) {
+	limit, err := toPolicyLimit(policy.GetAlgorithm(), policy.GetLimit(), policy.GetWindowSeconds(), policy.GetRefillRate(), policy.GetPeriod(), policy.GetTimezone())
+	if err != nil {
+		return storage.Policy{}, err
+	}
+
+	var tiers []storage.Limit
+	for i, tier := range policy.GetTiers() {
+		tierLimit, err := toPolicyLimit(tier.GetAlgorithm(), tier.GetLimit(), tier.GetWindowSeconds(), tier.GetRefillRate(), tier.GetPeriod(), tier.GetTimezone())
+		if err != nil {
+			return storage.Policy{}, fmt.Errorf("tiers[%d]: %w", i, err)
+		}
+		tiers = append(tiers, tierLimit)
+	}
+
+	var failureMode storage.FailureMode
+	if policy.GetFailureMode() != pb.FailureMode_FAILURE_MODE_UNSPECIFIED {
+		var ok bool
+		if failureMode, ok = failureModes[policy.GetFailureMode()]; !ok {
+			return storage.Policy{}, usecase.ErrInvalidFailureMode
+		}
+	}
+
+	return storage.Policy{
+		Name:        policy.GetName(),
+		KeyPattern:  policy.GetKeyPattern(),
+		Limit:       limit,
+		Tiers:       tiers,
+		Shadow:      policy.GetShadow(),
+		FailureMode: failureMode,
+	}, nil
+}
+
+// toPolicyLimit builds the limit of a policy or tier, which unlike a request's limit can follow a calendar period.
+func toPolicyLimit(algorithm pb.Algorithm, limit, windowSeconds, refillRate int64, period pb.Period, timezone string) (storage.Limit, error) {
+	storageLimit, err := toLimit(algorithm, limit, windowSeconds, refillRate)
+	if err != nil {
+		return storage.Limit{}, err
+	}
+
+	if period != pb.Period_PERIOD_UNSPECIFIED {
+		storagePeriod, ok := periods[period]
+		if !ok {
+			return storage.Limit{}, usecase.ErrInvalidPeriod
+		}
+		storageLimit.Period = storagePeriod
+	}
+	storageLimit.Timezone = timezone
+	return storageLimit, nil
+}
+
+// fromPolicy converts a storage.Policy into its protobuf form.
+func fromPolicy(policy *storage.Policy) *pb.Policy {
+	response := &pb.Policy{
+		Name:          policy.Name,
+		KeyPattern:    policy.KeyPattern,
+		Algorithm:     pbAlgorithms[policy.Limit.Algorithm],
+		Limit:         policy.Limit.Limit,
+		WindowSeconds: int64(policy.Limit.Window.Seconds()),
+		RefillRate:    policy.Limit.RefillRate,
+		Period:        pbPeriods[policy.Limit.Period],
+		Timezone:      policy.Limit.Timezone,
+		Shadow:        policy.Shadow,
+		FailureMode:   pbFailureModes[policy.FailureMode],
+	}
+	for _, tier := range policy.Tiers {
+		response.Tiers = append(response.Tiers, &pb.Tier{
+			Algorithm:     pbAlgorithms[tier.Algorithm],
+			Limit:         tier.Limit,
+			WindowSeconds: int64(tier.Window.Seconds()),
+			RefillRate:    tier.RefillRate,
+			Period:        pbPeriods[tier.Period],
+			Timezone:      tier.Timezone,
+		})
+	}
+	return response
+}
+
+// periods maps protobuf periods to their storage equivalent
+var periods = map[pb.Period]storage.Period{
+	pb.Period_PERIOD_DAY:   storage.Daily,
+	pb.Period_PERIOD_WEEK:  storage.Weekly,
+	pb.Period_PERIOD_MONTH: storage.Monthly,
+}
+
+// pbPeriods maps storage periods back to their protobuf equivalent
+var pbPeriods = map[storage.Period]pb.Period{
+	storage.Daily:   pb.Period_PERIOD_DAY,
+	storage.Weekly:  pb.Period_PERIOD_WEEK,
+	storage.Monthly: pb.Period_PERIOD_MONTH,
+}
+
+// failureModes maps protobuf failure modes to their storage equivalent
+var failureModes = map[pb.FailureMode]storage.FailureMode{
+	pb.FailureMode_FAILURE_MODE_ERROR:  storage.FailError,
+	pb.FailureMode_FAILURE_MODE_OPEN:   storage.FailOpen,
+	pb.FailureMode_FAILURE_MODE_CLOSED: storage.FailClosed,
+	pb.FailureMode_FAILURE_MODE_LOCAL:  storage.FailLocal,
+}
+
+// pbFailureModes maps storage failure modes back to their protobuf equivalent
+var pbFailureModes = map[storage.FailureMode]pb.FailureMode{
+	storage.FailError:  pb.FailureMode_FAILURE_MODE_ERROR,
+	storage.FailOpen:   pb.FailureMode_FAILURE_MODE_OPEN,
+	storage.FailClosed: pb.FailureMode_FAILURE_MODE_CLOSED,
+	storage.FailLocal:  pb.FailureMode_FAILURE_MODE_LOCAL,
+}
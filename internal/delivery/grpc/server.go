@@ -3,6 +3,8 @@ package grpc
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math"
 	"time"
 
 	pb "github.com/AaronBrownDev/distributed-rate-limiter/gen/ratelimiter/v1"
@@ -28,31 +30,108 @@ func NewServer(usecaseServer *usecase.RateLimiterService) *Server {
 
 // CheckRateLimit checks if a request is allowed and consumes tokens if permitted.
 func (s *Server) CheckRateLimit(ctx context.Context, req *pb.CheckRateLimitRequest) (*pb.CheckRateLimitResponse, error) {
-	var window time.Duration = time.Duration(req.WindowSeconds) * time.Second
+	checkRequest, err := toCheckRequest(req)
+	if err != nil {
+		return nil, handleError(err)
+	}
+
+	result, err := s.rls.CheckRateLimit(ctx, checkRequest)
+	if err != nil {
+		return nil, handleError(err)
+	}
 
-	result, err := s.rls.CheckRateLimit(ctx, req.Key, req.Limit, window, req.Cost)
+	return toCheckResponse(result), nil
+}
+
+// CheckRateLimitBatch checks several keys in one call and reports whether all of them were allowed.
+// With all_or_nothing set, nothing is consumed unless every key allows the request.
+func (s *Server) CheckRateLimitBatch(ctx context.Context, req *pb.CheckRateLimitBatchRequest) (*pb.CheckRateLimitBatchResponse, error) {
+	checkRequests := make([]usecase.CheckRequest, len(req.Requests))
+	for i, r := range req.Requests {
+		checkRequest, err := toCheckRequest(r)
+		if err != nil {
+			return nil, handleError(fmt.Errorf("requests[%d]: %w", i, err))
+		}
+		checkRequests[i] = checkRequest
+	}
+
+	check := s.rls.CheckRateLimitBatch
+	if req.AllOrNothing {
+		check = s.rls.CheckRateLimitAll
+	}
+	batch, err := check(ctx, checkRequests)
 	if err != nil {
 		return nil, handleError(err)
 	}
 
-	var retryAfterSeconds int64
-	if !result.Allowed {
-		retryAfterSeconds = int64(time.Until(result.ResetAt).Seconds())
+	response := &pb.CheckRateLimitBatchResponse{
+		Allowed:     batch.Allowed,
+		Responses:   make([]*pb.CheckRateLimitResponse, len(batch.Results)),
+		DeniedIndex: int32(batch.Denied),
+	}
+	if batch.Denied >= 0 {
+		response.DeniedKey = checkRequests[batch.Denied].Key
+	}
+	for i, result := range batch.Results {
+		response.Responses[i] = toCheckResponse(result)
+	}
+	return response, nil
+}
+
+// toCheckRequest builds a usecase.CheckRequest from a protobuf request
+func toCheckRequest(req *pb.CheckRateLimitRequest) (usecase.CheckRequest, error) {
+	limit, err := toLimit(req.Algorithm, req.Limit, req.WindowSeconds, req.RefillRate)
+	if err != nil {
+		return usecase.CheckRequest{}, err
 	}
 
+	parents := make([]usecase.Parent, len(req.Parents))
+	for i, p := range req.Parents {
+		parentLimit, err := toLimit(p.Algorithm, p.Limit, p.WindowSeconds, p.RefillRate)
+		if err != nil {
+			return usecase.CheckRequest{}, fmt.Errorf("parents[%d]: %w", i, err)
+		}
+		parents[i] = usecase.Parent{Key: p.Key, Policy: p.Policy, Limit: parentLimit}
+	}
+
+	return usecase.CheckRequest{
+		Key:     req.Key,
+		Policy:  req.Policy,
+		Limit:   limit,
+		Cost:    req.Cost,
+		Parents: parents,
+		Shadow:  req.Shadow,
+	}, nil
+}
+
+// toCheckResponse builds a protobuf response from a check result
+func toCheckResponse(result *usecase.Result) *pb.CheckRateLimitResponse {
 	return &pb.CheckRateLimitResponse{
 		Allowed:           result.Allowed,
 		Remaining:         result.Remaining,
 		ResetAt:           timestamppb.New(result.ResetAt),
 		Limit:             result.Limit,
-		RetryAfterSeconds: retryAfterSeconds,
-	}, nil
+		RetryAfterSeconds: int64(math.Ceil(result.RetryAfter.Seconds())),
+		RetryAfterMs:      result.RetryAfter.Milliseconds(),
+		LimitedBy:         result.LimitedBy,
+		ShadowDenied:      result.ShadowDenied,
+		Override:          pbOverrideActions[result.Override],
+		Degraded:          result.Degraded,
+	}
 }
 
 // GetStatus retrieves the current rate limit status without consuming tokens.
 func (s *Server) GetStatus(ctx context.Context, req *pb.GetStatusRequest) (*pb.GetStatusResponse, error) {
+	limit, err := toLimit(req.Algorithm, req.Limit, req.WindowSeconds, req.RefillRate)
+	if err != nil {
+		return nil, handleError(err)
+	}
 
-	result, err := s.rls.GetStatus(ctx, req.Key, req.Limit)
+	result, err := s.rls.GetStatus(ctx, usecase.StatusRequest{
+		Key:    req.Key,
+		Policy: req.Policy,
+		Limit:  limit,
+	})
 	if err != nil {
 		return nil, handleError(err)
 	}
@@ -67,6 +146,32 @@ func (s *Server) GetStatus(ctx context.Context, req *pb.GetStatusRequest) (*pb.G
 }
 
 
+// Refund returns up to the requested amount of consumed cost to a key.
+func (s *Server) Refund(ctx context.Context, req *pb.RefundRequest) (*pb.RefundResponse, error) {
+	limit, err := toLimit(req.Algorithm, req.Limit, req.WindowSeconds, req.RefillRate)
+	if err != nil {
+		return nil, handleError(err)
+	}
+
+	result, err := s.rls.Refund(ctx, usecase.RefundRequest{
+		Key:    req.Key,
+		Policy: req.Policy,
+		Limit:  limit,
+		Amount: req.Amount,
+	})
+	if err != nil {
+		return nil, handleError(err)
+	}
+
+	return &pb.RefundResponse{
+		Allowed:   result.Allowed,
+		Current:   result.Limit - result.Remaining,
+		Remaining: result.Remaining,
+		ResetAt:   timestamppb.New(result.ResetAt),
+		Limit:     result.Limit,
+	}, nil
+}
+
 // ResetLimit clears the rate limit for the specified key.
 func (s *Server) ResetLimit(ctx context.Context, req *pb.ResetLimitRequest) (*pb.ResetLimitResponse, error) {
 
@@ -78,20 +183,96 @@ func (s *Server) ResetLimit(ctx context.Context, req *pb.ResetLimitRequest) (*pb
 	return &pb.ResetLimitResponse{}, nil
 }
 
+// algorithms maps protobuf algorithms to their storage equivalent
+var algorithms = map[pb.Algorithm]storage.Algorithm{
+	pb.Algorithm_ALGORITHM_UNSPECIFIED:            storage.FixedWindow,
+	pb.Algorithm_ALGORITHM_FIXED_WINDOW:           storage.FixedWindow,
+	pb.Algorithm_ALGORITHM_SLIDING_WINDOW_LOG:     storage.SlidingWindowLog,
+	pb.Algorithm_ALGORITHM_SLIDING_WINDOW_COUNTER: storage.SlidingWindowCounter,
+	pb.Algorithm_ALGORITHM_TOKEN_BUCKET:           storage.TokenBucket,
+	pb.Algorithm_ALGORITHM_GCRA:                   storage.GCRA,
+	pb.Algorithm_ALGORITHM_CONCURRENCY:            storage.Concurrency,
+}
+
+// pbAlgorithms maps storage algorithms back to their protobuf equivalent
+var pbAlgorithms = map[storage.Algorithm]pb.Algorithm{
+	storage.FixedWindow:          pb.Algorithm_ALGORITHM_FIXED_WINDOW,
+	storage.SlidingWindowLog:     pb.Algorithm_ALGORITHM_SLIDING_WINDOW_LOG,
+	storage.SlidingWindowCounter: pb.Algorithm_ALGORITHM_SLIDING_WINDOW_COUNTER,
+	storage.TokenBucket:          pb.Algorithm_ALGORITHM_TOKEN_BUCKET,
+	storage.GCRA:                 pb.Algorithm_ALGORITHM_GCRA,
+	storage.Concurrency:          pb.Algorithm_ALGORITHM_CONCURRENCY,
+}
+
+// toLimit builds a storage.Limit from request fields
+func toLimit(algorithm pb.Algorithm, limit, windowSeconds, refillRate int64) (storage.Limit, error) {
+	storageAlgorithm, ok := algorithms[algorithm]
+	if !ok {
+		return storage.Limit{}, usecase.ErrInvalidAlgorithm
+	}
+
+	return storage.Limit{
+		Algorithm:  storageAlgorithm,
+		Limit:      limit,
+		Window:     time.Duration(windowSeconds) * time.Second,
+		RefillRate: refillRate,
+	}, nil
+}
+
 // Organizes invalid argument errors into a hashset for handleError func
 var invalidArgs = map[error]struct{}{
-	usecase.ErrInvalidKey:    {},
-	usecase.ErrInvalidLimit:  {},
-	usecase.ErrInvalidCost:   {},
-	usecase.ErrInvalidWindow: {},
+	usecase.ErrInvalidKey:             {},
+	usecase.ErrInvalidLimit:           {},
+	usecase.ErrInvalidCost:            {},
+	usecase.ErrInvalidAmount:          {},
+	usecase.ErrInvalidWindow:          {},
+	usecase.ErrInvalidAlgorithm:       {},
+	usecase.ErrInvalidRefillRate:      {},
+	usecase.ErrInvalidPeriod:          {},
+	usecase.ErrInvalidTimezone:        {},
+	usecase.ErrInvalidPolicyName:      {},
+	usecase.ErrInvalidKeyPattern:      {},
+	usecase.ErrInvalidFailureMode:     {},
+	usecase.ErrInvalidBatchSize:       {},
+	usecase.ErrDuplicateKey:           {},
+	usecase.ErrInvalidLeaseID:         {},
+	usecase.ErrInvalidParents:         {},
+	usecase.ErrInvalidOverridePattern: {},
+	usecase.ErrInvalidOverrideAction:  {},
+	usecase.ErrInvalidMultiplier:      {},
+	usecase.ErrInvalidTiers:           {},
+	storage.ErrLimitTooHigh:           {},
+	storage.ErrKeysNotColocated:       {},
+}
+
+// isInvalidArg reports whether err is or wraps one of invalidArgs
+func isInvalidArg(err error) bool {
+	for invalidArg := range invalidArgs {
+		if errors.Is(err, invalidArg) {
+			return true
+		}
+	}
+	return false
 }
 
 // handleError is a helper function for matching the error to its appropriate gRPC error status
 func handleError(err error) error {
-	if _, ok := invalidArgs[err]; ok {
+	if isInvalidArg(err) {
 		return status.Errorf(codes.InvalidArgument, "invalid argument: %v", err)
 	} else if errors.Is(err, storage.ErrKeyNotFound) {
 		return status.Errorf(codes.NotFound, "key not found")
+	} else if errors.Is(err, storage.ErrPolicyNotFound) {
+		return status.Errorf(codes.NotFound, "policy not found")
+	} else if errors.Is(err, storage.ErrLeaseNotFound) {
+		return status.Errorf(codes.NotFound, "lease not found")
+	} else if errors.Is(err, storage.ErrOverrideNotFound) {
+		return status.Errorf(codes.NotFound, "override not found")
+	} else if errors.Is(err, storage.ErrPolicyExists) {
+		return status.Errorf(codes.AlreadyExists, "policy already exists")
+	} else if errors.Is(err, storage.ErrUnsupportedAlgorithm) || errors.Is(err, usecase.ErrPoliciesUnsupported) || errors.Is(err, usecase.ErrLeasesUnsupported) || errors.Is(err, usecase.ErrOverridesUnsupported) {
+		return status.Errorf(codes.Unimplemented, "%v", err)
+	} else if errors.Is(err, storage.ErrStorageUnavailable) {
+		return status.Errorf(codes.Unavailable, "%v", err)
 	} else {
 		return status.Errorf(codes.Internal, "internal server error: %v", err)
 	}
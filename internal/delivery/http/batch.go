@@ -0,0 +1,89 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/usecase"
+)
+
+// CheckRateLimitBatchRequest holds several checks evaluated in one call.
+type CheckRateLimitBatchRequest struct {
+	Requests []CheckRateLimitRequest `json:"requests"`
+	// AllOrNothing consumes cost on every key only if all of them allow the request.
+	AllOrNothing bool `json:"all_or_nothing,omitempty"`
+}
+
+// CheckRateLimitBatchResponse holds one response per request, in request order.
+type CheckRateLimitBatchResponse struct {
+	Allowed   bool                     `json:"allowed"`
+	Responses []CheckRateLimitResponse `json:"responses"`
+	// DeniedIndex is the index of the first denied request, or -1 if every request was allowed.
+	DeniedIndex int    `json:"denied_index"`
+	DeniedKey   string `json:"denied_key,omitempty"`
+}
+
+// CheckRateLimitBatch checks several keys in one call. It responds 429 if any key was denied.
+// With all_or_nothing set, nothing is consumed unless every key allows the request.
+func (h *Handler) CheckRateLimitBatch(w http.ResponseWriter, r *http.Request) {
+	// Check if method is POST
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	// Decode request into CheckRateLimitBatchRequest struct
+	var req CheckRateLimitBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	checkRequests := make([]usecase.CheckRequest, len(req.Requests))
+	for i, checkRequest := range req.Requests {
+		checkRequests[i] = toCheckRequest(checkRequest)
+	}
+
+	// Call service layer
+	check := h.rls.CheckRateLimitBatch
+	if req.AllOrNothing {
+		check = h.rls.CheckRateLimitAll
+	}
+	batch, err := check(r.Context(), checkRequests)
+	if err != nil {
+		handleServerError(w, err)
+		return
+	}
+
+	// Build output response, waiting for the slowest denied key before retrying
+	response := CheckRateLimitBatchResponse{
+		Allowed:     batch.Allowed,
+		Responses:   make([]CheckRateLimitResponse, len(batch.Results)),
+		DeniedIndex: batch.Denied,
+	}
+	if batch.Denied >= 0 {
+		response.DeniedKey = checkRequests[batch.Denied].Key
+	}
+	var retryAfterSeconds int64
+	for i, result := range batch.Results {
+		response.Responses[i] = toCheckResponse(result)
+		retryAfterSeconds = max(retryAfterSeconds, response.Responses[i].RetryAfterSeconds)
+	}
+
+	// Header metadata
+	w.Header().Set("Content-Type", "application/json")
+	if !response.Allowed {
+		w.Header().Set("Retry-After", strconv.FormatInt(retryAfterSeconds, 10))
+	}
+
+	// Write appropriate status code
+	if response.Allowed {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}
+
+	// Encode response
+	json.NewEncoder(w).Encode(response)
+}
@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
@@ -18,6 +20,23 @@ type CheckRateLimitRequest struct {
 	Limit         int64  `json:"limit"`
 	WindowSeconds int64  `json:"window_seconds"`
 	Cost          int64  `json:"cost"`
+	Algorithm     string `json:"algorithm,omitempty"`
+	RefillRate    int64  `json:"refill_rate,omitempty"`
+	Policy        string `json:"policy,omitempty"`
+	// Parents are the key's ancestors, nearest first. Cost is consumed from every level or from none.
+	Parents []ParentLimit `json:"parents,omitempty"`
+	// Shadow counts the request as usual but always allows it, reporting whether it would have been denied.
+	Shadow bool `json:"shadow,omitempty"`
+}
+
+// ParentLimit is one ancestor in a key's hierarchy. The limit fields are needed only when no policy applies.
+type ParentLimit struct {
+	Key           string `json:"key"`
+	Limit         int64  `json:"limit,omitempty"`
+	WindowSeconds int64  `json:"window_seconds,omitempty"`
+	Algorithm     string `json:"algorithm,omitempty"`
+	RefillRate    int64  `json:"refill_rate,omitempty"`
+	Policy        string `json:"policy,omitempty"`
 }
 
 // CheckRateLimitResponse contains the result of a rate limit check.
@@ -27,6 +46,15 @@ type CheckRateLimitResponse struct {
 	ResetAt           string `json:"reset_at"`
 	Limit             int64  `json:"limit"`
 	RetryAfterSeconds int64  `json:"retry_after_seconds"`
+	RetryAfterMs      int64  `json:"retry_after_ms"`
+	// LimitedBy is the key of the most restrictive level when the request had parents.
+	LimitedBy string `json:"limited_by,omitempty"`
+	// ShadowDenied reports that a shadow request or policy would have denied the request.
+	ShadowDenied bool `json:"shadow_denied,omitempty"`
+	// Override is the action of the override that applied to the key: "allow", "deny" or "scale".
+	Override string `json:"override,omitempty"`
+	// Degraded reports that storage was unavailable, so the policy's failure mode decided the request.
+	Degraded bool `json:"degraded,omitempty"`
 }
 
 // GetStatusResponse contains the current status of a rate limit.
@@ -38,6 +66,17 @@ type GetStatusResponse struct {
 	Limit     int64  `json:"limit"`
 }
 
+// RefundRequest represents a request to give back consumed cost. The limit fields are needed only when no policy applies.
+type RefundRequest struct {
+	Key           string `json:"key"`
+	Amount        int64  `json:"amount"`
+	Limit         int64  `json:"limit,omitempty"`
+	WindowSeconds int64  `json:"window_seconds,omitempty"`
+	Algorithm     string `json:"algorithm,omitempty"`
+	RefillRate    int64  `json:"refill_rate,omitempty"`
+	Policy        string `json:"policy,omitempty"`
+}
+
 // Handler provides HTTP request handlers for the rate limiter service.
 type Handler struct {
 	rls *usecase.RateLimiterService
@@ -65,30 +104,15 @@ func (h *Handler) CheckRateLimit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Calculate window
-	window := time.Duration(req.WindowSeconds) * time.Second
-
 	// Call service layer
-	result, err := h.rls.CheckRateLimit(r.Context(), req.Key, req.Limit, window, req.Cost)
+	result, err := h.rls.CheckRateLimit(r.Context(), toCheckRequest(req))
 	if err != nil {
 		handleServerError(w, err)
 		return
 	}
 
-	// Calculate retry timer
-	var retryAfterSeconds int64
-	if !result.Allowed {
-		retryAfterSeconds = int64(time.Until(result.ResetAt).Seconds())
-	}
-
 	// Build output response
-	response := CheckRateLimitResponse{
-		Allowed:           result.Allowed,
-		Remaining:         result.Remaining,
-		Limit:             result.Limit,
-		ResetAt:           result.ResetAt.Format(time.RFC3339),
-		RetryAfterSeconds: retryAfterSeconds,
-	}
+	response := toCheckResponse(result)
 
 	// Send response back
 
@@ -97,8 +121,9 @@ func (h *Handler) CheckRateLimit(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("X-RateLimit-Limit", strconv.FormatInt(response.Limit, 10))
 	w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(response.Remaining, 10))
 	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(result.ResetAt.Unix(), 10))
+	w.Header().Set("RateLimit-Policy", rateLimitPolicy(result.Policy))
 	if !response.Allowed {
-		w.Header().Set("Retry-After", strconv.FormatInt(retryAfterSeconds, 10))
+		w.Header().Set("Retry-After", strconv.FormatInt(response.RetryAfterSeconds, 10))
 	}
 
 	// Write appropriate status code
@@ -122,26 +147,45 @@ func (h *Handler) GetStatus(w http.ResponseWriter, r *http.Request) {
 
 	// Get parameters
 	key := r.URL.Query().Get("key")
-	limitStr := r.URL.Query().Get("limit")
 
 	// Validate parameters
 	if key == "" {
 		writeError(w, http.StatusBadRequest, "key parameter required")
 		return
 	}
-	if limitStr == "" {
-		writeError(w, http.StatusBadRequest, "limit parameter required")
+
+	// The limit is optional when a policy applies
+	limitValue, err := parseOptionalInt(r.URL.Query().Get("limit"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "limit not integer")
 		return
 	}
 
-	limit, err := strconv.ParseInt(limitStr, 10, 64)
+	// Optional parameters for algorithms other than fixed window
+	windowSeconds, err := parseOptionalInt(r.URL.Query().Get("window_seconds"))
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "limit not integer")
+		writeError(w, http.StatusBadRequest, "window_seconds not integer")
+		return
+	}
+	refillRate, err := parseOptionalInt(r.URL.Query().Get("refill_rate"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "refill_rate not integer")
 		return
 	}
 
+	limit := storage.Limit{
+		Algorithm:  storage.Algorithm(r.URL.Query().Get("algorithm")),
+		Limit:      limitValue,
+		Window:     time.Duration(windowSeconds) * time.Second,
+		RefillRate: refillRate,
+	}
+
 	// Call service layers
-	result, err := h.rls.GetStatus(r.Context(), key, limit)
+	result, err := h.rls.GetStatus(r.Context(), usecase.StatusRequest{
+		Key:    key,
+		Policy: r.URL.Query().Get("policy"),
+		Limit:  limit,
+	})
 	if err != nil {
 		handleServerError(w, err)
 		return
@@ -162,6 +206,7 @@ func (h *Handler) GetStatus(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("X-RateLimit-Limit", strconv.FormatInt(response.Limit, 10))
 	w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(response.Remaining, 10))
 	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(result.ResetAt.Unix(), 10))
+	w.Header().Set("RateLimit-Policy", rateLimitPolicy(result.Policy))
 
 	// Returns OK - always success, no consumed tokens
 	w.WriteHeader(http.StatusOK)
@@ -170,6 +215,58 @@ func (h *Handler) GetStatus(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// Refund returns up to the requested amount of consumed cost to a key and responds with its status afterwards.
+func (h *Handler) Refund(w http.ResponseWriter, r *http.Request) {
+	// Check if method is POST
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	// Decode request into RefundRequest struct
+	var req RefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	// Call service layer
+	result, err := h.rls.Refund(r.Context(), usecase.RefundRequest{
+		Key:    req.Key,
+		Policy: req.Policy,
+		Limit: storage.Limit{
+			Algorithm:  storage.Algorithm(req.Algorithm),
+			Limit:      req.Limit,
+			Window:     time.Duration(req.WindowSeconds) * time.Second,
+			RefillRate: req.RefillRate,
+		},
+		Amount: req.Amount,
+	})
+	if err != nil {
+		handleServerError(w, err)
+		return
+	}
+
+	// Build response
+	response := GetStatusResponse{
+		Allowed:   result.Allowed,
+		Current:   result.Limit - result.Remaining,
+		Remaining: result.Remaining,
+		ResetAt:   result.ResetAt.Format(time.RFC3339),
+		Limit:     result.Limit,
+	}
+
+	// Header metadata
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-RateLimit-Limit", strconv.FormatInt(response.Limit, 10))
+	w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(response.Remaining, 10))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(result.ResetAt.Unix(), 10))
+	w.Header().Set("RateLimit-Policy", rateLimitPolicy(result.Policy))
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
 // ResetLimit clears the rate limit for the specified key.
 func (h *Handler) ResetLimit(w http.ResponseWriter, r *http.Request) {
 	// Check if method is DELETE
@@ -198,8 +295,90 @@ func (h *Handler) ResetLimit(w http.ResponseWriter, r *http.Request) {
 // RegisterRoutes registers all HTTP handler routes with the provided ServeMux.
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/v1/limit/check", h.CheckRateLimit)
+	mux.HandleFunc("/v1/limit/check/batch", h.CheckRateLimitBatch)
 	mux.HandleFunc("/v1/limit/status", h.GetStatus)
+	mux.HandleFunc("/v1/limit/refund", h.Refund)
 	mux.HandleFunc("/v1/limit/reset", h.ResetLimit)
+	mux.HandleFunc("/v1/lease/acquire", h.Acquire)
+	mux.HandleFunc("/v1/lease/heartbeat", h.Heartbeat)
+	mux.HandleFunc("/v1/lease/release", h.Release)
+	mux.HandleFunc("/v1/policies", h.Policies)
+	mux.HandleFunc("/v1/policies/{name}", h.Policy)
+	mux.HandleFunc("/v1/overrides", h.Overrides)
+	mux.HandleFunc("/v1/overrides/{pattern...}", h.Override)
+}
+
+// toCheckRequest builds a usecase.CheckRequest from the JSON request.
+func toCheckRequest(req CheckRateLimitRequest) usecase.CheckRequest {
+	var parents []usecase.Parent
+	for _, p := range req.Parents {
+		parents = append(parents, usecase.Parent{
+			Key:    p.Key,
+			Policy: p.Policy,
+			Limit: storage.Limit{
+				Algorithm:  storage.Algorithm(p.Algorithm),
+				Limit:      p.Limit,
+				Window:     time.Duration(p.WindowSeconds) * time.Second,
+				RefillRate: p.RefillRate,
+			},
+		})
+	}
+
+	return usecase.CheckRequest{
+		Key:    req.Key,
+		Policy: req.Policy,
+		Limit: storage.Limit{
+			Algorithm:  storage.Algorithm(req.Algorithm),
+			Limit:      req.Limit,
+			Window:     time.Duration(req.WindowSeconds) * time.Second,
+			RefillRate: req.RefillRate,
+		},
+		Cost:    req.Cost,
+		Parents: parents,
+		Shadow:  req.Shadow,
+	}
+}
+
+// toCheckResponse builds the JSON response for a check result.
+func toCheckResponse(result *usecase.Result) CheckRateLimitResponse {
+	return CheckRateLimitResponse{
+		Allowed:   result.Allowed,
+		Remaining: result.Remaining,
+		Limit:     result.Limit,
+		ResetAt:   result.ResetAt.Format(time.RFC3339),
+		// Round up so clients never retry too early
+		RetryAfterSeconds: int64(math.Ceil(result.RetryAfter.Seconds())),
+		RetryAfterMs:      result.RetryAfter.Milliseconds(),
+		LimitedBy:         result.LimitedBy,
+		ShadowDenied:      result.ShadowDenied,
+		Override:          string(result.Override),
+		Degraded:          result.Degraded,
+	}
+}
+
+// periodSeconds gives calendar periods a nominal length for the RateLimit-Policy header.
+var periodSeconds = map[storage.Period]int64{
+	storage.Daily:   24 * 60 * 60,
+	storage.Weekly:  7 * 24 * 60 * 60,
+	storage.Monthly: 30 * 24 * 60 * 60,
+}
+
+// rateLimitPolicy formats every tier of the policy for the RateLimit-Policy header, e.g. "20;w=1, 1000;w=3600".
+// A limit without a known window is sent without w.
+func rateLimitPolicy(policy storage.Policy) string {
+	limits := policy.Limits()
+	items := make([]string, len(limits))
+	for i, limit := range limits {
+		items[i] = strconv.FormatInt(limit.Limit, 10)
+		window := int64(limit.Window.Seconds())
+		if limit.Period != "" {
+			window = periodSeconds[limit.Period]
+		}
+		if window > 0 {
+			items[i] += ";w=" + strconv.FormatInt(window, 10)
+		}
+	}
+	return strings.Join(items, ", ")
 }
 
 // writeError sends a JSON error response with the specified status code and message.
@@ -209,20 +388,68 @@ func writeError(w http.ResponseWriter, statusCode int, errorMsg string) {
 	json.NewEncoder(w).Encode(map[string]string{"error": errorMsg})
 }
 
+// parseOptionalInt parses an integer query parameter, treating an empty value as zero.
+func parseOptionalInt(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
 // invalidArgs maps usecase validation errors for quick error type checking.
 var invalidArgs = map[error]struct{}{
-	usecase.ErrInvalidKey:    {},
-	usecase.ErrInvalidLimit:  {},
-	usecase.ErrInvalidCost:   {},
-	usecase.ErrInvalidWindow: {},
+	usecase.ErrInvalidKey:             {},
+	usecase.ErrInvalidLimit:           {},
+	usecase.ErrInvalidCost:            {},
+	usecase.ErrInvalidAmount:          {},
+	usecase.ErrInvalidWindow:          {},
+	usecase.ErrInvalidAlgorithm:       {},
+	usecase.ErrInvalidRefillRate:      {},
+	usecase.ErrInvalidPeriod:          {},
+	usecase.ErrInvalidTimezone:        {},
+	usecase.ErrInvalidPolicyName:      {},
+	usecase.ErrInvalidKeyPattern:      {},
+	usecase.ErrInvalidFailureMode:     {},
+	usecase.ErrInvalidBatchSize:       {},
+	usecase.ErrDuplicateKey:           {},
+	usecase.ErrInvalidLeaseID:         {},
+	usecase.ErrInvalidParents:         {},
+	usecase.ErrInvalidOverridePattern: {},
+	usecase.ErrInvalidOverrideAction:  {},
+	usecase.ErrInvalidMultiplier:      {},
+	usecase.ErrInvalidTiers:           {},
+	storage.ErrLimitTooHigh:           {},
+	storage.ErrKeysNotColocated:       {},
+}
+
+// isInvalidArg reports whether err is or wraps one of invalidArgs.
+func isInvalidArg(err error) bool {
+	for invalidArg := range invalidArgs {
+		if errors.Is(err, invalidArg) {
+			return true
+		}
+	}
+	return false
 }
 
 // handleServerError converts internal errors to appropriate HTTP status codes.
 func handleServerError(w http.ResponseWriter, err error) {
-	if _, ok := invalidArgs[err]; ok {
+	if isInvalidArg(err) {
 		writeError(w, http.StatusBadRequest, fmt.Sprintf("bad request: %v", err))
 	} else if errors.Is(err, storage.ErrKeyNotFound) {
 		writeError(w, http.StatusNotFound, "key not found")
+	} else if errors.Is(err, storage.ErrPolicyNotFound) {
+		writeError(w, http.StatusNotFound, "policy not found")
+	} else if errors.Is(err, storage.ErrLeaseNotFound) {
+		writeError(w, http.StatusNotFound, "lease not found")
+	} else if errors.Is(err, storage.ErrOverrideNotFound) {
+		writeError(w, http.StatusNotFound, "override not found")
+	} else if errors.Is(err, storage.ErrPolicyExists) {
+		writeError(w, http.StatusConflict, "policy already exists")
+	} else if errors.Is(err, storage.ErrUnsupportedAlgorithm) || errors.Is(err, usecase.ErrPoliciesUnsupported) || errors.Is(err, usecase.ErrLeasesUnsupported) || errors.Is(err, usecase.ErrOverridesUnsupported) {
+		writeError(w, http.StatusNotImplemented, err.Error())
+	} else if errors.Is(err, storage.ErrStorageUnavailable) {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
 	} else {
 		writeError(w, http.StatusInternalServerError, fmt.Sprintf("internal server error: %v", err))
 	}
@@ -0,0 +1,149 @@
+package http
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/usecase"
+)
+
+// LeaseRequest represents a request to acquire, renew or release a concurrency lease.
+// Limit and LeaseSeconds are needed only when no policy applies, and LeaseID is ignored when acquiring.
+type LeaseRequest struct {
+	Key          string `json:"key"`
+	LeaseID      string `json:"lease_id,omitempty"`
+	Limit        int64  `json:"limit,omitempty"`
+	LeaseSeconds int64  `json:"lease_seconds,omitempty"`
+	Policy       string `json:"policy,omitempty"`
+}
+
+// LeaseResponse contains the result of acquiring or renewing a lease.
+type LeaseResponse struct {
+	Acquired          bool   `json:"acquired"`
+	LeaseID           string `json:"lease_id,omitempty"`
+	Remaining         int64  `json:"remaining"`
+	Limit             int64  `json:"limit"`
+	ExpiresAt         string `json:"expires_at,omitempty"`
+	RetryAfterSeconds int64  `json:"retry_after_seconds,omitempty"`
+	RetryAfterMs      int64  `json:"retry_after_ms,omitempty"`
+	// Override is the action of the override that applied to the key: "allow", "deny" or "scale".
+	Override string `json:"override,omitempty"`
+}
+
+// Acquire grants a concurrency lease. It responds 429 if the key already has its limit of leases in flight.
+func (h *Handler) Acquire(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeLeaseRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// Call service layer
+	lease, err := h.rls.Acquire(r.Context(), toLeaseRequest(req))
+	if err != nil {
+		handleServerError(w, err)
+		return
+	}
+
+	// Build output response
+	response := toLeaseResponse(lease)
+
+	// Header metadata
+	w.Header().Set("Content-Type", "application/json")
+	if !lease.Allowed {
+		w.Header().Set("Retry-After", strconv.FormatInt(response.RetryAfterSeconds, 10))
+		w.WriteHeader(http.StatusTooManyRequests)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// Heartbeat extends a held lease.
+func (h *Handler) Heartbeat(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeLeaseRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// Call service layer
+	lease, err := h.rls.Heartbeat(r.Context(), toLeaseRequest(req))
+	if err != nil {
+		handleServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(toLeaseResponse(lease))
+}
+
+// Release frees a held lease.
+func (h *Handler) Release(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeLeaseRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// Call service layer
+	if err := h.rls.Release(r.Context(), req.Key, req.LeaseID); err != nil {
+		handleServerError(w, err)
+		return
+	}
+
+	// Returns success - no content
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeLeaseRequest checks the method and decodes the body, writing an error response if either is wrong.
+func decodeLeaseRequest(w http.ResponseWriter, r *http.Request) (LeaseRequest, bool) {
+	// Check if method is POST
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return LeaseRequest{}, false
+	}
+
+	// Decode request into LeaseRequest struct
+	var req LeaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return LeaseRequest{}, false
+	}
+	return req, true
+}
+
+// toLeaseRequest builds a usecase.LeaseRequest from the JSON request.
+func toLeaseRequest(req LeaseRequest) usecase.LeaseRequest {
+	return usecase.LeaseRequest{
+		Key:    req.Key,
+		Policy: req.Policy,
+		Limit: storage.Limit{
+			Algorithm: storage.Concurrency,
+			Limit:     req.Limit,
+			Window:    time.Duration(req.LeaseSeconds) * time.Second,
+		},
+		LeaseID: req.LeaseID,
+	}
+}
+
+// toLeaseResponse builds the JSON response for a lease.
+func toLeaseResponse(lease *usecase.Lease) LeaseResponse {
+	response := LeaseResponse{
+		Acquired:  lease.Allowed,
+		LeaseID:   lease.ID,
+		Remaining: lease.Remaining,
+		Limit:     lease.Limit,
+		// Round up so clients never retry too early
+		RetryAfterSeconds: int64(math.Ceil(lease.RetryAfter.Seconds())),
+		RetryAfterMs:      lease.RetryAfter.Milliseconds(),
+		Override:          string(lease.Override),
+	}
+	if lease.Allowed {
+		response.ExpiresAt = lease.ExpiresAt.Format(time.RFC3339)
+	}
+	return response
+}
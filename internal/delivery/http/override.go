@@ -0,0 +1,107 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// Override is the JSON form of an override.
+type Override struct {
+	// Pattern is the exact key the override applies to, or a path.Match pattern such as "partner:*".
+	Pattern string `json:"pattern"`
+	// Action is "allow", "deny" or "scale".
+	Action string `json:"action"`
+	// Multiplier scales every limit and refill rate of a "scale" override.
+	Multiplier float64 `json:"multiplier,omitempty"`
+	Reason     string  `json:"reason,omitempty"`
+}
+
+// ListOverridesResponse contains every override.
+type ListOverridesResponse struct {
+	Overrides []Override `json:"overrides"`
+}
+
+// Overrides lists overrides on GET.
+func (h *Handler) Overrides(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	overrides, err := h.rls.ListOverrides(r.Context())
+	if err != nil {
+		handleServerError(w, err)
+		return
+	}
+
+	response := ListOverridesResponse{Overrides: make([]Override, 0, len(overrides))}
+	for i := range overrides {
+		response.Overrides = append(response.Overrides, fromOverride(&overrides[i]))
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// Override gets, saves or deletes the override whose pattern is the rest of the path.
+func (h *Handler) Override(w http.ResponseWriter, r *http.Request) {
+	pattern := r.PathValue("pattern")
+
+	switch r.Method {
+	case http.MethodGet:
+		override, err := h.rls.GetOverride(r.Context(), pattern)
+		if err != nil {
+			handleServerError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, fromOverride(override))
+	case http.MethodPut:
+		var req Override
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON")
+			return
+		}
+
+		// The path names the override; a different pattern in the body would be a rename
+		if req.Pattern != "" && req.Pattern != pattern {
+			writeError(w, http.StatusBadRequest, "override pattern does not match path")
+			return
+		}
+		req.Pattern = pattern
+
+		override := toOverride(req)
+		if err := h.rls.SaveOverride(r.Context(), override); err != nil {
+			handleServerError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, fromOverride(&override))
+	case http.MethodDelete:
+		if err := h.rls.DeleteOverride(r.Context(), pattern); err != nil {
+			handleServerError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// toOverride builds a storage.Override from its JSON form.
+func toOverride(override Override) storage.Override {
+	return storage.Override{
+		Pattern:    override.Pattern,
+		Action:     storage.OverrideAction(override.Action),
+		Multiplier: override.Multiplier,
+		Reason:     override.Reason,
+	}
+}
+
+// fromOverride converts a storage.Override into its JSON form.
+func fromOverride(override *storage.Override) Override {
+	return Override{
+		Pattern:    override.Pattern,
+		Action:     string(override.Action),
+		Multiplier: override.Multiplier,
+		Reason:     override.Reason,
+	}
+}
@@ -0,0 +1,195 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// Policy is the JSON form of a server-side policy.
+type Policy struct {
+	Name          string `json:"name"`
+	KeyPattern    string `json:"key_pattern,omitempty"`
+	Algorithm     string `json:"algorithm,omitempty"`
+	Limit         int64  `json:"limit"`
+	WindowSeconds int64  `json:"window_seconds"`
+	RefillRate    int64  `json:"refill_rate,omitempty"`
+	// Period aligns a fixed window to a calendar "day", "week" or "month" in Timezone, replacing window_seconds.
+	Period   string `json:"period,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+	// Tiers are further limits enforced together with this one, each with a different window or period.
+	Tiers []Tier `json:"tiers,omitempty"`
+	// Shadow counts requests against the policy without ever denying them.
+	Shadow bool `json:"shadow,omitempty"`
+	// FailureMode decides requests while storage is unavailable: "error", "open", "closed" or "local".
+	FailureMode string `json:"failure_mode,omitempty"`
+}
+
+// Tier is the JSON form of one extra limit of a policy.
+type Tier struct {
+	Algorithm     string `json:"algorithm,omitempty"`
+	Limit         int64  `json:"limit"`
+	WindowSeconds int64  `json:"window_seconds"`
+	RefillRate    int64  `json:"refill_rate,omitempty"`
+	Period        string `json:"period,omitempty"`
+	Timezone      string `json:"timezone,omitempty"`
+}
+
+// ListPoliciesResponse contains every server-side policy.
+type ListPoliciesResponse struct {
+	Policies []Policy `json:"policies"`
+}
+
+// Policies lists policies on GET and creates one on POST.
+func (h *Handler) Policies(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		policies, err := h.rls.ListPolicies(r.Context())
+		if err != nil {
+			handleServerError(w, err)
+			return
+		}
+
+		response := ListPoliciesResponse{Policies: make([]Policy, 0, len(policies))}
+		for i := range policies {
+			response.Policies = append(response.Policies, fromPolicy(&policies[i]))
+		}
+		writeJSON(w, http.StatusOK, response)
+	case http.MethodPost:
+		var req Policy
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON")
+			return
+		}
+
+		created, err := h.rls.CreatePolicy(r.Context(), toPolicy(req))
+		if err != nil {
+			handleServerError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, fromPolicy(created))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// Policy gets, replaces or deletes the policy named in the path.
+func (h *Handler) Policy(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	switch r.Method {
+	case http.MethodGet:
+		policy, err := h.rls.GetPolicy(r.Context(), name)
+		if err != nil {
+			handleServerError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, fromPolicy(policy))
+	case http.MethodPut:
+		var req Policy
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON")
+			return
+		}
+
+		// The path names the policy; a different name in the body would be a rename
+		if req.Name != "" && req.Name != name {
+			writeError(w, http.StatusBadRequest, "policy name does not match path")
+			return
+		}
+		req.Name = name
+
+		updated, err := h.rls.UpdatePolicy(r.Context(), toPolicy(req))
+		if err != nil {
+			handleServerError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, fromPolicy(updated))
+	case http.MethodDelete:
+		if err := h.rls.DeletePolicy(r.Context(), name); err != nil {
+			handleServerError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// toPolicy builds a storage.Policy from its JSON form.
+func toPolicy(policy Policy) storage.Policy {
+	var tiers []storage.Limit
+	for _, tier := range policy.Tiers {
+		tiers = append(tiers, toTier(tier))
+	}
+
+	return storage.Policy{
+		Name:       policy.Name,
+		KeyPattern: policy.KeyPattern,
+		Limit: toTier(Tier{
+			Algorithm:     policy.Algorithm,
+			Limit:         policy.Limit,
+			WindowSeconds: policy.WindowSeconds,
+			RefillRate:    policy.RefillRate,
+			Period:        policy.Period,
+			Timezone:      policy.Timezone,
+		}),
+		Tiers:       tiers,
+		Shadow:      policy.Shadow,
+		FailureMode: storage.FailureMode(policy.FailureMode),
+	}
+}
+
+// fromPolicy converts a storage.Policy into its JSON form.
+func fromPolicy(policy *storage.Policy) Policy {
+	limit := fromTier(policy.Limit)
+	response := Policy{
+		Name:          policy.Name,
+		KeyPattern:    policy.KeyPattern,
+		Algorithm:     limit.Algorithm,
+		Limit:         limit.Limit,
+		WindowSeconds: limit.WindowSeconds,
+		RefillRate:    limit.RefillRate,
+		Period:        limit.Period,
+		Timezone:      limit.Timezone,
+		Shadow:        policy.Shadow,
+		FailureMode:   string(policy.FailureMode),
+	}
+	for _, tier := range policy.Tiers {
+		response.Tiers = append(response.Tiers, fromTier(tier))
+	}
+	return response
+}
+
+// toTier builds a storage.Limit from the JSON form of a tier.
+func toTier(tier Tier) storage.Limit {
+	return storage.Limit{
+		Algorithm:  storage.Algorithm(tier.Algorithm),
+		Limit:      tier.Limit,
+		Window:     time.Duration(tier.WindowSeconds) * time.Second,
+		RefillRate: tier.RefillRate,
+		Period:     storage.Period(tier.Period),
+		Timezone:   tier.Timezone,
+	}
+}
+
+// fromTier converts a storage.Limit into the JSON form of a tier.
+func fromTier(limit storage.Limit) Tier {
+	return Tier{
+		Algorithm:     string(limit.Algorithm),
+		Limit:         limit.Limit,
+		WindowSeconds: int64(limit.Window.Seconds()),
+		RefillRate:    limit.RefillRate,
+		Period:        string(limit.Period),
+		Timezone:      limit.Timezone,
+	}
+}
+
+// writeJSON sends body as a JSON response with the specified status code.
+func writeJSON(w http.ResponseWriter, statusCode int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,80 @@
+package storage
+
+import "time"
+
+// Algorithm identifies how a limit is enforced.
+type Algorithm string
+
+const (
+	// FixedWindow counts cost in a window that starts at the first request and resets when it ends.
+	FixedWindow Algorithm = "fixed_window"
+	// SlidingWindowLog records every request and counts those within the last Window.
+	SlidingWindowLog Algorithm = "sliding_window_log"
+	// SlidingWindowCounter weights the previous fixed window's count by how much of it still overlaps the last Window.
+	SlidingWindowCounter Algorithm = "sliding_window_counter"
+	// TokenBucket refills RefillRate tokens every Window up to a capacity of Limit.
+	TokenBucket Algorithm = "token_bucket"
+	// GCRA (generic cell rate algorithm) spaces requests Window/Limit apart and allows bursts of up to Limit.
+	GCRA Algorithm = "gcra"
+	// Concurrency caps the number of leases held on a key at once, each expiring after Window unless renewed.
+	// It is enforced through LeaseStorage rather than CheckAndUpdate.
+	Concurrency Algorithm = "concurrency"
+)
+
+// MaxSlidingLogLimit is the largest limit SlidingWindowLog accepts.
+// The log keeps one entry per unit of cost in the window, so this bounds its memory per key.
+// Use SlidingWindowCounter for higher limits.
+const MaxSlidingLogLimit = 10000
+
+// Algorithms lists every supported rate limit algorithm. Concurrency is not one of them.
+var Algorithms = []Algorithm{
+	FixedWindow,
+	SlidingWindowLog,
+	SlidingWindowCounter,
+	TokenBucket,
+	GCRA,
+}
+
+// Valid reports whether a is a known algorithm, including Concurrency.
+func (a Algorithm) Valid() bool {
+	if a == Concurrency {
+		return true
+	}
+	for _, known := range Algorithms {
+		if a == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Limit describes the limit applied to a key and the algorithm that enforces it.
+type Limit struct {
+	// Algorithm selects how the limit is enforced. Empty means FixedWindow.
+	Algorithm Algorithm
+	// Limit is the maximum cost allowed per Window.
+	// For TokenBucket it is the bucket capacity, for GCRA the burst size and for Concurrency the number of leases.
+	Limit int64
+	// Window is the length of the rate limit window.
+	// For TokenBucket it is the refill period and for Concurrency how long a lease lasts without a heartbeat.
+	Window time.Duration
+	// RefillRate is the number of tokens a TokenBucket regains every Window.
+	// Zero means Limit.
+	RefillRate int64
+	// Period aligns a FixedWindow to calendar days, weeks or months instead of starting it at the first request.
+	// Window is then ignored. Empty means no alignment.
+	Period Period
+	// Timezone is the IANA time zone whose calendar Period follows, e.g. "America/New_York". Empty means UTC.
+	Timezone string
+	// FailureMode is how the check is answered while the storage is unavailable, taken from the policy's FailureMode.
+	// Empty means the default of the storage. It isn't stored with the limit.
+	FailureMode FailureMode
+}
+
+// Refill returns the number of tokens a TokenBucket regains every Window.
+func (l Limit) Refill() int64 {
+	if l.RefillRate > 0 {
+		return l.RefillRate
+	}
+	return l.Limit
+}
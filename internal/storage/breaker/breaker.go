@@ -0,0 +1,309 @@
+// Package breaker keeps rate limiting available while its storage is not.
+//
+// BreakerStorage wraps a storage with a per-call timeout and a circuit breaker. When a call fails or times out,
+// or while the circuit is open after repeated failures, the check is answered as the FailureMode of its limit says:
+// with an error, allowed, denied, or counted in the process's own memory. Such results are marked Degraded.
+// After a cooldown the circuit lets a single call through to probe whether the storage has recovered.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/memory"
+)
+
+const (
+	// DefaultTimeout is how long a storage call may take when no WithTimeout option is given.
+	DefaultTimeout = 250 * time.Millisecond
+	// DefaultFailureThreshold is how many consecutive failures open the circuit when no WithFailureThreshold option is given.
+	DefaultFailureThreshold = 5
+	// DefaultCooldown is how long the circuit stays open before probing when no WithCooldown option is given.
+	DefaultCooldown = 5 * time.Second
+)
+
+// Fallback counts the checks of FailLocal limits while the storage is unavailable.
+type Fallback interface {
+	storage.RateLimitStorage
+	storage.BatchStorage
+	storage.LeaseStorage
+}
+
+// Recoverer is implemented by fallbacks that hand their counts back to the storage once it recovers.
+type Recoverer interface {
+	// Recover is called with the storage after the circuit closes.
+	Recover(ctx context.Context, next storage.RateLimitStorage) error
+}
+
+// BreakerStorage implements storage.RateLimitStorage, storage.BatchStorage and storage.LeaseStorage in front of
+// another storage, answering checks by their failure mode while that storage is unavailable.
+// Leases return storage.ErrUnsupportedAlgorithm if the wrapped storage can't hold them.
+type BreakerStorage struct {
+	next     storage.RateLimitStorage
+	fallback Fallback
+
+	mode      storage.FailureMode
+	timeout   time.Duration
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mutex    sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	// recovering is set while a goroutine hands the fallback's counts back, and recoverPending until it has started
+	// on the latest closing of the circuit
+	recovering     bool
+	recoverPending bool
+	recoveries     sync.WaitGroup
+	closed         bool
+}
+
+// Option configures a BreakerStorage.
+type Option func(*BreakerStorage)
+
+// WithFailureMode sets how checks whose limit has no FailureMode are answered. The default is storage.FailError.
+func WithFailureMode(mode storage.FailureMode) Option {
+	return func(bs *BreakerStorage) {
+		if mode != "" {
+			bs.mode = mode
+		}
+	}
+}
+
+// WithTimeout sets how long a storage call may take before it counts as a failure.
+func WithTimeout(d time.Duration) Option {
+	return func(bs *BreakerStorage) {
+		if d > 0 {
+			bs.timeout = d
+		}
+	}
+}
+
+// WithFailureThreshold sets how many consecutive failures open the circuit.
+func WithFailureThreshold(n int) Option {
+	return func(bs *BreakerStorage) {
+		if n > 0 {
+			bs.threshold = n
+		}
+	}
+}
+
+// WithCooldown sets how long the circuit stays open before a call is let through to probe the storage.
+func WithCooldown(d time.Duration) Option {
+	return func(bs *BreakerStorage) {
+		if d > 0 {
+			bs.cooldown = d
+		}
+	}
+}
+
+// WithFallback replaces the in-memory storage that counts FailLocal checks. It is closed with the BreakerStorage,
+// and if it is a Recoverer it is recovered whenever the circuit closes.
+func WithFallback(fallback Fallback) Option {
+	return func(bs *BreakerStorage) {
+		if fallback != nil {
+			bs.fallback = fallback
+		}
+	}
+}
+
+// WithClock replaces time.Now as the source of the current time.
+func WithClock(now func() time.Time) Option {
+	return func(bs *BreakerStorage) {
+		if now != nil {
+			bs.now = now
+		}
+	}
+}
+
+// NewBreakerStorage wraps next with a circuit breaker.
+func NewBreakerStorage(next storage.RateLimitStorage, opts ...Option) (*BreakerStorage, error) {
+	bs := &BreakerStorage{
+		next:      next,
+		mode:      storage.FailError,
+		timeout:   DefaultTimeout,
+		threshold: DefaultFailureThreshold,
+		cooldown:  DefaultCooldown,
+		now:       time.Now,
+		state:     Closed,
+	}
+	for _, opt := range opts {
+		opt(bs)
+	}
+	if !bs.mode.Valid() {
+		return nil, errors.New("unsupported failure mode " + string(bs.mode))
+	}
+	if bs.fallback == nil {
+		bs.fallback = memory.NewMemoryStorage(memory.WithClock(bs.now))
+	}
+	return bs, nil
+}
+
+// CheckAndUpdate checks and updates key on the storage.
+func (bs *BreakerStorage) CheckAndUpdate(ctx context.Context, key string, limit storage.Limit, cost int64) (*storage.Result, error) {
+	result, err := call(ctx, bs, func(ctx context.Context) (*storage.Result, error) {
+		return bs.next.CheckAndUpdate(ctx, key, limit, cost)
+	})
+	if !unavailable(err) {
+		return result, err
+	}
+	return bs.failResult(limit, err, func() (*storage.Result, error) {
+		return bs.fallback.CheckAndUpdate(ctx, key, limit, cost)
+	})
+}
+
+// CheckAndUpdateAll checks every key and consumes cost on all of them only if every check is allowed.
+// While the storage is unavailable, a FailClosed check denies the others and FailLocal checks are only counted
+// in memory if none is denied.
+func (bs *BreakerStorage) CheckAndUpdateAll(ctx context.Context, checks []storage.Check) ([]*storage.Result, error) {
+	results, err := call(ctx, bs, func(ctx context.Context) ([]*storage.Result, error) {
+		return bs.next.CheckAndUpdateAll(ctx, checks)
+	})
+	if !unavailable(err) {
+		return results, err
+	}
+	return bs.failAll(ctx, checks, err)
+}
+
+// CheckAndUpdateBatch checks every key on its own, in one call if the storage supports batches.
+func (bs *BreakerStorage) CheckAndUpdateBatch(ctx context.Context, checks []storage.Check) ([]*storage.Result, error) {
+	results, err := call(ctx, bs, func(ctx context.Context) ([]*storage.Result, error) {
+		return bs.checkBatch(ctx, checks)
+	})
+	if !unavailable(err) {
+		return results, err
+	}
+	return bs.failBatch(ctx, checks, err)
+}
+
+// checkBatch checks every key on the storage, one at a time if it doesn't support batches.
+func (bs *BreakerStorage) checkBatch(ctx context.Context, checks []storage.Check) ([]*storage.Result, error) {
+	if batch, ok := bs.next.(storage.BatchStorage); ok {
+		return batch.CheckAndUpdateBatch(ctx, checks)
+	}
+
+	results := make([]*storage.Result, len(checks))
+	for i, check := range checks {
+		result, err := bs.next.CheckAndUpdate(ctx, check.Key, check.Limit, check.Cost)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+// GetStatus reads key on the storage.
+func (bs *BreakerStorage) GetStatus(ctx context.Context, key string, limit storage.Limit) (*storage.Result, error) {
+	result, err := call(ctx, bs, func(ctx context.Context) (*storage.Result, error) {
+		return bs.next.GetStatus(ctx, key, limit)
+	})
+	if !unavailable(err) {
+		return result, err
+	}
+	return bs.failResult(limit, err, func() (*storage.Result, error) {
+		return bs.fallback.GetStatus(ctx, key, limit)
+	})
+}
+
+// Refund returns consumed cost to key on the storage.
+func (bs *BreakerStorage) Refund(ctx context.Context, key string, limit storage.Limit, amount int64) (*storage.Result, error) {
+	result, err := call(ctx, bs, func(ctx context.Context) (*storage.Result, error) {
+		return bs.next.Refund(ctx, key, limit, amount)
+	})
+	if !unavailable(err) {
+		return result, err
+	}
+	return bs.failResult(limit, err, func() (*storage.Result, error) {
+		return bs.fallback.Refund(ctx, key, limit, amount)
+	})
+}
+
+// Reset clears key on the storage and in memory. It has no failure mode, so it fails while the storage is unavailable.
+func (bs *BreakerStorage) Reset(ctx context.Context, key string) error {
+	// The in-memory counts only matter while the storage is unavailable
+	_ = bs.fallback.Reset(ctx, key)
+
+	_, err := call(ctx, bs, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, bs.next.Reset(ctx, key)
+	})
+	return err
+}
+
+// AcquireLease grants a lease on key on the storage.
+// A lease granted by FailOpen isn't held anywhere, so it can be renewed while the storage is unavailable but not released.
+func (bs *BreakerStorage) AcquireLease(ctx context.Context, key string, limit storage.Limit) (*storage.Lease, error) {
+	leases, ok := bs.next.(storage.LeaseStorage)
+	if !ok {
+		return nil, storage.ErrUnsupportedAlgorithm
+	}
+	lease, err := call(ctx, bs, func(ctx context.Context) (*storage.Lease, error) {
+		return leases.AcquireLease(ctx, key, limit)
+	})
+	if !unavailable(err) {
+		return lease, err
+	}
+	return bs.failLease(limit, err, func() (*storage.Lease, error) {
+		return bs.fallback.AcquireLease(ctx, key, limit)
+	})
+}
+
+// RenewLease extends a lease on key on the storage. While the storage is unavailable, FailOpen renews any lease
+// and FailLocal only those it granted itself.
+func (bs *BreakerStorage) RenewLease(ctx context.Context, key, leaseID string, limit storage.Limit) (*storage.Lease, error) {
+	leases, ok := bs.next.(storage.LeaseStorage)
+	if !ok {
+		return nil, storage.ErrUnsupportedAlgorithm
+	}
+	lease, err := call(ctx, bs, func(ctx context.Context) (*storage.Lease, error) {
+		return leases.RenewLease(ctx, key, leaseID, limit)
+	})
+	if !unavailable(err) {
+		return lease, err
+	}
+	switch bs.modeOf(limit) {
+	case storage.FailOpen:
+		now := bs.now()
+		return &storage.Lease{Result: bs.openResult(limit, now), ID: leaseID, ExpiresAt: now.Add(limit.Window)}, nil
+	case storage.FailLocal:
+		return degradedLease(bs.fallback.RenewLease(ctx, key, leaseID, limit))
+	default:
+		return nil, err
+	}
+}
+
+// ReleaseLease frees a lease on key on the storage. While the storage is unavailable, only leases granted
+// by FailLocal can be released.
+func (bs *BreakerStorage) ReleaseLease(ctx context.Context, key, leaseID string) error {
+	leases, ok := bs.next.(storage.LeaseStorage)
+	if !ok {
+		return storage.ErrUnsupportedAlgorithm
+	}
+	_, err := call(ctx, bs, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, leases.ReleaseLease(ctx, key, leaseID)
+	})
+	if !unavailable(err) {
+		return err
+	}
+
+	// Without the limit the failure mode isn't known, so free the lease if FailLocal granted it
+	if bs.fallback.ReleaseLease(ctx, key, leaseID) == nil {
+		return nil
+	}
+	return err
+}
+
+// Close waits for the fallback's counts to be handed back, then closes the storage and the in-memory fallback.
+func (bs *BreakerStorage) Close() error {
+	bs.mutex.Lock()
+	bs.closed = true
+	bs.mutex.Unlock()
+	bs.recoveries.Wait()
+
+	return errors.Join(bs.next.Close(), bs.fallback.Close())
+}
@@ -0,0 +1,375 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/local"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/memory"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/storagetest"
+)
+
+func TestBreakerStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) (storage.RateLimitStorage, storagetest.Clock) {
+		clock := storagetest.NewFakeClock(time.Unix(0, 0))
+		bs, err := NewBreakerStorage(memory.NewMemoryStorage(memory.WithClock(clock.Now)), WithClock(clock.Now))
+		if err != nil {
+			t.Fatalf("NewBreakerStorage() error = %v", err)
+		}
+		return bs, clock
+	})
+}
+
+func TestBreakerStorage_FailureMode(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          storage.FailureMode
+		limitMode     storage.FailureMode
+		wantErr       error
+		wantAllowed   []bool
+		wantRemaining []int64
+	}{
+		{name: "error", mode: storage.FailError, wantErr: storage.ErrStorageUnavailable},
+		{name: "default is error", wantErr: storage.ErrStorageUnavailable},
+		{name: "open", mode: storage.FailOpen, wantAllowed: []bool{true, true, true}, wantRemaining: []int64{2, 2, 2}},
+		{name: "closed", mode: storage.FailClosed, wantAllowed: []bool{false, false, false}, wantRemaining: []int64{0, 0, 0}},
+		{name: "local", mode: storage.FailLocal, wantAllowed: []bool{true, true, false}, wantRemaining: []int64{1, 0, 0}},
+		{name: "limit overrides default", mode: storage.FailError, limitMode: storage.FailOpen, wantAllowed: []bool{true, true, true}, wantRemaining: []int64{2, 2, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := storagetest.NewFakeClock(time.Unix(0, 0))
+			down := newFailingStorage(clock, errors.New("connection refused"))
+			bs, err := NewBreakerStorage(down, WithFailureMode(tt.mode), WithClock(clock.Now))
+			if err != nil {
+				t.Fatalf("NewBreakerStorage() error = %v", err)
+			}
+			defer bs.Close()
+
+			limit := storage.Limit{Limit: 2, Window: time.Minute, FailureMode: tt.limitMode}
+			for i := range 3 {
+				result, err := bs.CheckAndUpdate(context.Background(), "user:1", limit, 1)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("CheckAndUpdate() #%d error = %v, want %v", i, err, tt.wantErr)
+				}
+				if err != nil {
+					continue
+				}
+				if result.Allowed != tt.wantAllowed[i] || result.Remaining != tt.wantRemaining[i] || !result.Degraded {
+					t.Errorf("CheckAndUpdate() #%d = %+v, want allowed %v, remaining %d and degraded", i, result, tt.wantAllowed[i], tt.wantRemaining[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerStorage_Circuit(t *testing.T) {
+	clock := storagetest.NewFakeClock(time.Unix(0, 0))
+	down := newFailingStorage(clock, errors.New("connection refused"))
+	bs, err := NewBreakerStorage(down, WithFailureMode(storage.FailOpen), WithFailureThreshold(3), WithCooldown(time.Second), WithClock(clock.Now))
+	if err != nil {
+		t.Fatalf("NewBreakerStorage() error = %v", err)
+	}
+	defer bs.Close()
+
+	limit := storage.Limit{Limit: 10, Window: time.Minute}
+	check := func() {
+		t.Helper()
+		if _, err := bs.CheckAndUpdate(context.Background(), "user:1", limit, 1); err != nil {
+			t.Fatalf("CheckAndUpdate() error = %v", err)
+		}
+	}
+
+	// Consecutive failures open the circuit, after which the storage isn't called
+	for range 3 {
+		check()
+	}
+	if got := bs.State(); got != Open {
+		t.Fatalf("State() after 3 failures = %v, want %v", got, Open)
+	}
+	check()
+	if got := down.callCount(); got != 3 {
+		t.Errorf("storage called %d times, want 3", got)
+	}
+
+	// After the cooldown a failed probe opens the circuit again
+	clock.Sleep(time.Second)
+	check()
+	if got := bs.State(); got != Open {
+		t.Errorf("State() after a failed probe = %v, want %v", got, Open)
+	}
+	if got := down.callCount(); got != 4 {
+		t.Errorf("storage called %d times, want 4", got)
+	}
+
+	// A successful probe closes it
+	down.setErr(nil)
+	clock.Sleep(time.Second)
+	result, err := bs.CheckAndUpdate(context.Background(), "user:1", limit, 1)
+	if err != nil || result.Degraded || result.Remaining != 9 {
+		t.Errorf("CheckAndUpdate() after recovery = %+v, %v, want 9 remaining and not degraded", result, err)
+	}
+	if got := bs.State(); got != Closed {
+		t.Errorf("State() after recovery = %v, want %v", got, Closed)
+	}
+}
+
+func TestBreakerStorage_Recover(t *testing.T) {
+	clock := storagetest.NewFakeClock(time.Unix(0, 0))
+	down := newFailingStorage(clock, errors.New("connection refused"))
+	fallback, err := local.NewLocalStorage(local.WithClock(clock.Now))
+	if err != nil {
+		t.Fatalf("NewLocalStorage() error = %v", err)
+	}
+	bs, err := NewBreakerStorage(down, WithFailureMode(storage.FailLocal), WithFailureThreshold(1), WithFallback(fallback), WithClock(clock.Now))
+	if err != nil {
+		t.Fatalf("NewBreakerStorage() error = %v", err)
+	}
+	defer bs.Close()
+
+	ctx := context.Background()
+	limit := storage.Limit{Limit: 10, Window: time.Minute}
+	for range 3 {
+		if _, err := bs.CheckAndUpdate(ctx, "user:1", limit, 1); err != nil {
+			t.Fatalf("CheckAndUpdate() error = %v", err)
+		}
+	}
+
+	// The probe that closes the circuit is counted, and the 3 local checks are handed back after it
+	down.setErr(nil)
+	clock.Sleep(DefaultCooldown)
+	if _, err := bs.CheckAndUpdate(ctx, "user:1", limit, 1); err != nil {
+		t.Fatalf("CheckAndUpdate() error = %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		status, err := down.MemoryStorage.GetStatus(ctx, "user:1", limit)
+		if err != nil {
+			t.Fatalf("GetStatus() error = %v", err)
+		}
+		if status.Remaining == 6 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("storage has %d remaining after recovery, want 6", status.Remaining)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBreakerStorage_RecoverOnce(t *testing.T) {
+	clock := storagetest.NewFakeClock(time.Unix(0, 0))
+	down := newFailingStorage(clock, errors.New("connection refused"))
+	fallback := &blockingRecoverer{MemoryStorage: memory.NewMemoryStorage(), started: make(chan struct{}, 2), release: make(chan struct{})}
+	bs, err := NewBreakerStorage(down, WithFailureMode(storage.FailOpen), WithFailureThreshold(1), WithFallback(fallback), WithClock(clock.Now))
+	if err != nil {
+		t.Fatalf("NewBreakerStorage() error = %v", err)
+	}
+
+	ctx := context.Background()
+	limit := storage.Limit{Limit: 10, Window: time.Minute}
+	flap := func() {
+		t.Helper()
+		down.setErr(errors.New("connection refused"))
+		if _, err := bs.CheckAndUpdate(ctx, "user:1", limit, 1); err != nil {
+			t.Fatalf("CheckAndUpdate() error = %v", err)
+		}
+		down.setErr(nil)
+		clock.Sleep(DefaultCooldown)
+		if _, err := bs.CheckAndUpdate(ctx, "user:1", limit, 1); err != nil {
+			t.Fatalf("CheckAndUpdate() error = %v", err)
+		}
+	}
+
+	// The circuit closes again while the first recovery is running, so a second one runs after it rather than alongside
+	flap()
+	<-fallback.started
+	flap()
+	close(fallback.release)
+
+	// Close waits for both before closing the storage
+	if err := bs.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	fallback.mutex.Lock()
+	defer fallback.mutex.Unlock()
+	if fallback.calls != 2 || fallback.maxRunning != 1 {
+		t.Errorf("Recover() called %d times with up to %d at once, want 2 one at a time", fallback.calls, fallback.maxRunning)
+	}
+}
+
+func TestBreakerStorage_RequestErrors(t *testing.T) {
+	clock := storagetest.NewFakeClock(time.Unix(0, 0))
+	down := newFailingStorage(clock, storage.ErrUnsupportedAlgorithm)
+	bs, err := NewBreakerStorage(down, WithFailureMode(storage.FailOpen), WithFailureThreshold(1), WithClock(clock.Now))
+	if err != nil {
+		t.Fatalf("NewBreakerStorage() error = %v", err)
+	}
+	defer bs.Close()
+
+	// The storage answered, so the error is returned as it is and the circuit stays closed
+	_, err = bs.CheckAndUpdate(context.Background(), "user:1", storage.Limit{Limit: 10, Window: time.Minute}, 1)
+	if !errors.Is(err, storage.ErrUnsupportedAlgorithm) {
+		t.Errorf("CheckAndUpdate() error = %v, want %v", err, storage.ErrUnsupportedAlgorithm)
+	}
+	if got := bs.State(); got != Closed {
+		t.Errorf("State() = %v, want %v", got, Closed)
+	}
+}
+
+func TestBreakerStorage_Timeout(t *testing.T) {
+	down := newFailingStorage(storagetest.NewFakeClock(time.Unix(0, 0)), nil)
+	down.block = true
+	bs, err := NewBreakerStorage(down, WithFailureMode(storage.FailClosed), WithTimeout(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewBreakerStorage() error = %v", err)
+	}
+	defer bs.Close()
+
+	result, err := bs.CheckAndUpdate(context.Background(), "user:1", storage.Limit{Limit: 10, Window: time.Minute}, 1)
+	if err != nil || result.Allowed || !result.Degraded {
+		t.Errorf("CheckAndUpdate() = %+v, %v, want denied and degraded", result, err)
+	}
+}
+
+func TestBreakerStorage_CheckAndUpdateAll(t *testing.T) {
+	tests := []struct {
+		name        string
+		modes       []storage.FailureMode
+		wantErr     error
+		wantAllowed []bool
+		// wantLocal is what remains of the FailLocal check's limit in memory afterwards
+		wantLocal int64
+	}{
+		{name: "open and local", modes: []storage.FailureMode{storage.FailOpen, storage.FailLocal}, wantAllowed: []bool{true, true}, wantLocal: 9},
+		{name: "closed denies local", modes: []storage.FailureMode{storage.FailClosed, storage.FailLocal}, wantAllowed: []bool{false, true}, wantLocal: 10},
+		{name: "error fails all", modes: []storage.FailureMode{storage.FailOpen, storage.FailError}, wantErr: storage.ErrStorageUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := storagetest.NewFakeClock(time.Unix(0, 0))
+			bs, err := NewBreakerStorage(newFailingStorage(clock, errors.New("connection refused")), WithClock(clock.Now))
+			if err != nil {
+				t.Fatalf("NewBreakerStorage() error = %v", err)
+			}
+			defer bs.Close()
+
+			checks := make([]storage.Check, len(tt.modes))
+			for i, mode := range tt.modes {
+				checks[i] = storage.Check{Key: storage.SubKey("user:1", string(mode)), Limit: storage.Limit{Limit: 10, Window: time.Minute, FailureMode: mode}, Cost: 1}
+			}
+
+			results, err := bs.CheckAndUpdateAll(context.Background(), checks)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CheckAndUpdateAll() error = %v, want %v", err, tt.wantErr)
+			}
+			for i, result := range results {
+				if result.Allowed != tt.wantAllowed[i] || !result.Degraded {
+					t.Errorf("CheckAndUpdateAll()[%d] = %+v, want allowed %v and degraded", i, result, tt.wantAllowed[i])
+				}
+			}
+
+			// Nothing is counted in memory unless every check was allowed
+			if err != nil {
+				return
+			}
+			status, err := bs.fallback.GetStatus(context.Background(), checks[1].Key, checks[1].Limit)
+			if err != nil {
+				t.Fatalf("GetStatus() error = %v", err)
+			}
+			if status.Remaining != tt.wantLocal {
+				t.Errorf("local check has %d remaining, want %d", status.Remaining, tt.wantLocal)
+			}
+		})
+	}
+}
+
+// failingStorage is a memory storage whose checks fail with err, or block until the context is done.
+type failingStorage struct {
+	*memory.MemoryStorage
+
+	mutex sync.Mutex
+	err   error
+	block bool
+	calls int
+}
+
+func newFailingStorage(clock *storagetest.FakeClock, err error) *failingStorage {
+	return &failingStorage{MemoryStorage: memory.NewMemoryStorage(memory.WithClock(clock.Now)), err: err}
+}
+
+func (fs *failingStorage) CheckAndUpdate(ctx context.Context, key string, limit storage.Limit, cost int64) (*storage.Result, error) {
+	if err := fs.fail(ctx); err != nil {
+		return nil, err
+	}
+	return fs.MemoryStorage.CheckAndUpdate(ctx, key, limit, cost)
+}
+
+func (fs *failingStorage) CheckAndUpdateAll(ctx context.Context, checks []storage.Check) ([]*storage.Result, error) {
+	if err := fs.fail(ctx); err != nil {
+		return nil, err
+	}
+	return fs.MemoryStorage.CheckAndUpdateAll(ctx, checks)
+}
+
+// fail counts the call and returns the error it should fail with, if any.
+func (fs *failingStorage) fail(ctx context.Context) error {
+	fs.mutex.Lock()
+	fs.calls++
+	err, block := fs.err, fs.block
+	fs.mutex.Unlock()
+
+	if block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return err
+}
+
+func (fs *failingStorage) setErr(err error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	fs.err = err
+}
+
+func (fs *failingStorage) callCount() int {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	return fs.calls
+}
+
+// blockingRecoverer is a memory fallback whose recoveries block until release is closed.
+type blockingRecoverer struct {
+	*memory.MemoryStorage
+	started chan struct{}
+	release chan struct{}
+
+	mutex      sync.Mutex
+	running    int
+	maxRunning int
+	calls      int
+}
+
+func (br *blockingRecoverer) Recover(ctx context.Context, next storage.RateLimitStorage) error {
+	br.mutex.Lock()
+	br.calls++
+	br.running++
+	br.maxRunning = max(br.maxRunning, br.running)
+	br.mutex.Unlock()
+
+	br.started <- struct{}{}
+	<-br.release
+
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+	br.running--
+	return nil
+}
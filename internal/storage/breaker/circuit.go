@@ -0,0 +1,206 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// State is the state of the circuit in front of the storage.
+type State string
+
+const (
+	// Closed sends every call to the storage.
+	Closed State = "closed"
+	// Open answers every call by its failure mode without calling the storage, until the cooldown has passed.
+	Open State = "open"
+	// HalfOpen lets a single probe through to the storage. Other calls are answered as if the circuit were open.
+	HalfOpen State = "half-open"
+)
+
+// outcome is what a call to the storage says about its health.
+type outcome int
+
+const (
+	// succeeded means the storage answered, even if with an error about the request itself.
+	succeeded outcome = iota
+	// failed means the storage failed or timed out.
+	failed
+	// ignored means the call says nothing about the storage, e.g. because the caller gave up.
+	ignored
+)
+
+// errOpen is returned by call while the circuit is open.
+var errOpen = fmt.Errorf("%w: circuit open", storage.ErrStorageUnavailable)
+
+// requestErrors are errors about the request rather than the storage. The storage answered, so they don't trip the circuit.
+var requestErrors = []error{
+	storage.ErrKeyNotFound,
+	storage.ErrUnsupportedAlgorithm,
+	storage.ErrLimitTooHigh,
+	storage.ErrKeysNotColocated,
+	storage.ErrLeaseNotFound,
+}
+
+// State reports the state of the circuit.
+func (bs *BreakerStorage) State() State {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+
+	return bs.state
+}
+
+// allow reports whether a call may go to the storage. Once the cooldown has passed, the first call is let through
+// as a probe and the circuit is half-open until it returns.
+func (bs *BreakerStorage) allow() bool {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+
+	switch bs.state {
+	case Closed:
+		return true
+	case Open:
+		if bs.now().Sub(bs.openedAt) < bs.cooldown {
+			return false
+		}
+		bs.state = HalfOpen
+		return true
+	default:
+		// A probe is already in flight
+		return false
+	}
+}
+
+// record updates the circuit with the outcome of a call that allow let through.
+func (bs *BreakerStorage) record(result outcome, err error) {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+
+	switch result {
+	case succeeded:
+		if bs.state != Closed {
+			log.Printf("breaker: storage recovered, closing circuit")
+			bs.startRecovery()
+		}
+		bs.state, bs.failures = Closed, 0
+	case failed:
+		bs.failures++
+		if bs.state == HalfOpen || bs.failures >= bs.threshold {
+			if bs.state == Closed {
+				log.Printf("breaker: opening circuit after %d failures: %v", bs.failures, err)
+			}
+			bs.state, bs.openedAt = Open, bs.now()
+		}
+	case ignored:
+		// Let the next call probe instead
+		if bs.state == HalfOpen {
+			bs.state = Open
+		}
+	}
+}
+
+// startRecovery hands the fallback's counts back to the storage in the background. Only one recovery runs at a
+// time: if one is already running, it runs again once it is done. The caller must hold the mutex.
+func (bs *BreakerStorage) startRecovery() {
+	if _, ok := bs.fallback.(Recoverer); !ok || bs.closed {
+		return
+	}
+	bs.recoverPending = true
+	if bs.recovering {
+		return
+	}
+
+	bs.recovering = true
+	bs.recoveries.Add(1)
+	go func() {
+		defer bs.recoveries.Done()
+		for bs.takeRecovery() {
+			bs.recover()
+		}
+	}()
+}
+
+// takeRecovery reports whether a recovery is pending and the circuit is still closed, marking it started.
+// Otherwise the recovery goroutine stops, and the next time the circuit closes starts another.
+func (bs *BreakerStorage) takeRecovery() bool {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+
+	if !bs.recoverPending || bs.state != Closed {
+		bs.recovering = false
+		return false
+	}
+	bs.recoverPending = false
+	return true
+}
+
+// recover hands the fallback's counts back to the storage.
+func (bs *BreakerStorage) recover() {
+	ctx, cancel := context.WithTimeout(context.Background(), bs.cooldown)
+	defer cancel()
+	if err := bs.fallback.(Recoverer).Recover(ctx, bs.next); err != nil {
+		log.Printf("breaker: failed to recover local counts: %v", err)
+	}
+}
+
+// call runs fn against the storage with the call timeout, unless the circuit is open.
+// Errors that mean the storage is unavailable wrap storage.ErrStorageUnavailable.
+func call[T any](ctx context.Context, bs *BreakerStorage, fn func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+	if !bs.allow() {
+		return zero, errOpen
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, bs.timeout)
+	defer cancel()
+	value, err := fn(callCtx)
+
+	switch {
+	case err == nil || isRequestError(err):
+		bs.record(succeeded, nil)
+		return value, err
+	case ctx.Err() != nil:
+		// The caller gave up, so the storage may well be fine
+		bs.record(ignored, nil)
+		return zero, err
+	case errors.Is(err, storage.ErrStorageUnavailable):
+		// The storage answered that part of it is unavailable, e.g. an unhealthy shard, which says nothing about the rest
+		bs.record(succeeded, nil)
+		return zero, err
+	default:
+		bs.record(failed, err)
+		return zero, fmt.Errorf("%w: %v", storage.ErrStorageUnavailable, err)
+	}
+}
+
+// isRequestError reports whether err is about the request rather than the storage.
+func isRequestError(err error) bool {
+	for _, requestErr := range requestErrors {
+		if errors.Is(err, requestErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// unavailable reports whether err means the storage couldn't answer, so the failure mode decides instead.
+func unavailable(err error) bool {
+	return errors.Is(err, storage.ErrStorageUnavailable)
+}
+
+// retryAfter is how long until the circuit lets a probe through, or the cooldown while it is closed.
+func (bs *BreakerStorage) retryAfter(now time.Time) time.Duration {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+
+	if bs.state == Open {
+		if wait := bs.openedAt.Add(bs.cooldown).Sub(now); wait > 0 {
+			return wait
+		}
+	}
+	return bs.cooldown
+}
@@ -0,0 +1,162 @@
+package breaker
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// modeOf returns the failure mode of limit, or the storage's default if it has none.
+func (bs *BreakerStorage) modeOf(limit storage.Limit) storage.FailureMode {
+	if limit.FailureMode != "" {
+		return limit.FailureMode
+	}
+	return bs.mode
+}
+
+// openResult allows a check without counting it.
+func (bs *BreakerStorage) openResult(limit storage.Limit, now time.Time) storage.Result {
+	return storage.Result{Allowed: true, Remaining: limit.Limit, ResetAt: now, Limit: limit.Limit, Degraded: true}
+}
+
+// closedResult denies a check until the circuit is due to probe the storage again.
+func (bs *BreakerStorage) closedResult(limit storage.Limit, now time.Time) storage.Result {
+	retryAfter := bs.retryAfter(now)
+	return storage.Result{Allowed: false, Remaining: 0, ResetAt: now.Add(retryAfter), Limit: limit.Limit, RetryAfter: retryAfter, Degraded: true}
+}
+
+// failResult answers a check the storage couldn't as the failure mode of limit says, calling local for FailLocal.
+func (bs *BreakerStorage) failResult(limit storage.Limit, err error, local func() (*storage.Result, error)) (*storage.Result, error) {
+	switch bs.modeOf(limit) {
+	case storage.FailOpen:
+		result := bs.openResult(limit, bs.now())
+		return &result, nil
+	case storage.FailClosed:
+		result := bs.closedResult(limit, bs.now())
+		return &result, nil
+	case storage.FailLocal:
+		result, err := local()
+		if err != nil {
+			return nil, err
+		}
+		result.Degraded = true
+		return result, nil
+	default:
+		return nil, err
+	}
+}
+
+// failAll answers an all-or-nothing check the storage couldn't. A FailError check fails them all, and a FailClosed
+// check denies them all, so FailLocal checks are then only read.
+func (bs *BreakerStorage) failAll(ctx context.Context, checks []storage.Check, err error) ([]*storage.Result, error) {
+	modes := make([]storage.FailureMode, len(checks))
+	for i, check := range checks {
+		modes[i] = bs.modeOf(check.Limit)
+		if modes[i] == storage.FailError {
+			return nil, err
+		}
+	}
+	denied := slices.Contains(modes, storage.FailClosed)
+
+	now := bs.now()
+	results := make([]*storage.Result, len(checks))
+	var local []int
+	for i, check := range checks {
+		switch modes[i] {
+		case storage.FailOpen:
+			result := bs.openResult(check.Limit, now)
+			results[i] = &result
+		case storage.FailClosed:
+			result := bs.closedResult(check.Limit, now)
+			results[i] = &result
+		case storage.FailLocal:
+			if !denied {
+				local = append(local, i)
+				continue
+			}
+			result, err := bs.fallback.GetStatus(ctx, check.Key, check.Limit)
+			if err != nil {
+				return nil, err
+			}
+			result.Allowed = result.Remaining >= check.Cost
+			results[i] = result
+		}
+	}
+
+	if len(local) > 0 {
+		localResults, err := bs.fallback.CheckAndUpdateAll(ctx, storage.Pick(checks, local))
+		if err != nil {
+			return nil, err
+		}
+		for j, result := range localResults {
+			results[local[j]] = result
+		}
+	}
+	return degradedResults(results), nil
+}
+
+// failBatch answers every check of a batch the storage couldn't on its own. A FailError check fails the batch.
+func (bs *BreakerStorage) failBatch(ctx context.Context, checks []storage.Check, err error) ([]*storage.Result, error) {
+	now := bs.now()
+	results := make([]*storage.Result, len(checks))
+	var local []int
+	for i, check := range checks {
+		switch bs.modeOf(check.Limit) {
+		case storage.FailOpen:
+			result := bs.openResult(check.Limit, now)
+			results[i] = &result
+		case storage.FailClosed:
+			result := bs.closedResult(check.Limit, now)
+			results[i] = &result
+		case storage.FailLocal:
+			local = append(local, i)
+		default:
+			return nil, err
+		}
+	}
+
+	if len(local) > 0 {
+		localResults, err := bs.fallback.CheckAndUpdateBatch(ctx, storage.Pick(checks, local))
+		if err != nil {
+			return nil, err
+		}
+		for j, result := range localResults {
+			results[local[j]] = result
+		}
+	}
+	return degradedResults(results), nil
+}
+
+// failLease answers a lease request the storage couldn't. A lease granted by FailOpen isn't held anywhere.
+func (bs *BreakerStorage) failLease(limit storage.Limit, err error, local func() (*storage.Lease, error)) (*storage.Lease, error) {
+	now := bs.now()
+	switch bs.modeOf(limit) {
+	case storage.FailOpen:
+		return &storage.Lease{Result: bs.openResult(limit, now), ID: storage.NewLeaseID(), ExpiresAt: now.Add(limit.Window)}, nil
+	case storage.FailClosed:
+		return &storage.Lease{Result: bs.closedResult(limit, now)}, nil
+	case storage.FailLocal:
+		return degradedLease(local())
+	default:
+		return nil, err
+	}
+}
+
+// degradedResults marks every result as decided without the storage.
+func degradedResults(results []*storage.Result) []*storage.Result {
+	for _, result := range results {
+		result.Degraded = true
+	}
+	return results
+}
+
+// degradedLease marks a lease granted by the fallback as decided without the storage.
+func degradedLease(lease *storage.Lease, err error) (*storage.Lease, error) {
+	if err != nil {
+		return nil, err
+	}
+	lease.Degraded = true
+	return lease, nil
+}
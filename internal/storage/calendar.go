@@ -0,0 +1,46 @@
+package storage
+
+import "time"
+
+// Period is a calendar period a FixedWindow can be aligned to instead of starting at the first request.
+type Period string
+
+const (
+	// Daily windows start at midnight.
+	Daily Period = "day"
+	// Weekly windows start at midnight on Monday.
+	Weekly Period = "week"
+	// Monthly windows start at midnight on the first of the month.
+	Monthly Period = "month"
+)
+
+// Periods lists every supported calendar period.
+var Periods = []Period{Daily, Weekly, Monthly}
+
+// Valid reports whether p is a known period.
+func (p Period) Valid() bool {
+	for _, known := range Periods {
+		if p == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Bounds returns the start and end of the period containing t, following the calendar of t's location.
+// Periods spanning a daylight saving change are an hour shorter or longer than usual.
+func (p Period) Bounds(t time.Time) (start, end time.Time) {
+	year, month, day := t.Date()
+	switch p {
+	case Weekly:
+		// Weekday counts from Sunday, weeks start on Monday
+		start = time.Date(year, month, day-(int(t.Weekday())+6)%7, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(0, 0, 7)
+	case Monthly:
+		start = time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(0, 1, 0)
+	default:
+		start = time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(0, 0, 1)
+	}
+}
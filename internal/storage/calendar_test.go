@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeriod_Bounds(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		period    Period
+		at        time.Time
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{
+			name:      "day",
+			period:    Daily,
+			at:        time.Date(2026, 10, 17, 15, 4, 5, 0, time.UTC),
+			wantStart: time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "week starts on Monday",
+			period:    Weekly,
+			at:        time.Date(2026, 10, 18, 23, 0, 0, 0, time.UTC),
+			wantStart: time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "month across a year end",
+			period:    Monthly,
+			at:        time.Date(2026, 12, 31, 23, 59, 59, 0, time.UTC),
+			wantStart: time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "day in another time zone",
+			period:    Daily,
+			at:        time.Date(2026, 10, 17, 2, 0, 0, 0, time.UTC).In(newYork),
+			wantStart: time.Date(2026, 10, 16, 0, 0, 0, 0, newYork),
+			wantEnd:   time.Date(2026, 10, 17, 0, 0, 0, 0, newYork),
+		},
+		{
+			name:      "day with a daylight saving change",
+			period:    Daily,
+			at:        time.Date(2026, 11, 1, 12, 0, 0, 0, newYork),
+			wantStart: time.Date(2026, 11, 1, 0, 0, 0, 0, newYork),
+			wantEnd:   time.Date(2026, 11, 2, 0, 0, 0, 0, newYork),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := tt.period.Bounds(tt.at)
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("Bounds() = %v, %v, want %v, %v", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
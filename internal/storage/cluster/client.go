@@ -0,0 +1,205 @@
+package cluster
+
+import (
+	"context"
+
+	pb "github.com/AaronBrownDev/distributed-rate-limiter/gen/ratelimiter/peer/v1"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// peerClient forwards storage, policy and override calls to another node's PeerServer.
+type peerClient struct {
+	conn   *grpc.ClientConn
+	client pb.PeerServiceClient
+}
+
+// newPeerClient returns a client for the node at addr. It connects on first use.
+func newPeerClient(addr string) (*peerClient, error) {
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	return &peerClient{conn: conn, client: pb.NewPeerServiceClient(conn)}, nil
+}
+
+// CheckAndUpdate checks and updates key on the peer.
+func (pc *peerClient) CheckAndUpdate(ctx context.Context, key string, limit storage.Limit, cost int64) (*storage.Result, error) {
+	response, err := pc.client.CheckAndUpdate(ctx, &pb.CheckAndUpdateRequest{
+		Check: &pb.Check{Key: key, Limit: fromLimit(limit), Cost: cost},
+	})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return toResult(response.GetResult()), nil
+}
+
+// CheckAndUpdateAll checks every key on the peer and updates them together.
+func (pc *peerClient) CheckAndUpdateAll(ctx context.Context, checks []storage.Check) ([]*storage.Result, error) {
+	response, err := pc.client.CheckAndUpdateAll(ctx, &pb.CheckAndUpdateAllRequest{Checks: fromChecks(checks)})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return toResults(response.GetResults()), nil
+}
+
+// CheckAndUpdateBatch checks every key on the peer on its own.
+func (pc *peerClient) CheckAndUpdateBatch(ctx context.Context, checks []storage.Check) ([]*storage.Result, error) {
+	response, err := pc.client.CheckAndUpdateBatch(ctx, &pb.CheckAndUpdateBatchRequest{Checks: fromChecks(checks)})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return toResults(response.GetResults()), nil
+}
+
+// GetStatus reads key on the peer.
+func (pc *peerClient) GetStatus(ctx context.Context, key string, limit storage.Limit) (*storage.Result, error) {
+	response, err := pc.client.GetStatus(ctx, &pb.GetStatusRequest{Key: key, Limit: fromLimit(limit)})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return toResult(response.GetResult()), nil
+}
+
+// Refund returns consumed cost to key on the peer.
+func (pc *peerClient) Refund(ctx context.Context, key string, limit storage.Limit, amount int64) (*storage.Result, error) {
+	response, err := pc.client.Refund(ctx, &pb.RefundRequest{Key: key, Limit: fromLimit(limit), Amount: amount})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return toResult(response.GetResult()), nil
+}
+
+// Reset clears key on the peer.
+func (pc *peerClient) Reset(ctx context.Context, key string) error {
+	if _, err := pc.client.Reset(ctx, &pb.ResetRequest{Key: key}); err != nil {
+		return fromStatus(err)
+	}
+	return nil
+}
+
+// AcquireLease grants a lease on key on the peer.
+func (pc *peerClient) AcquireLease(ctx context.Context, key string, limit storage.Limit) (*storage.Lease, error) {
+	response, err := pc.client.AcquireLease(ctx, &pb.AcquireLeaseRequest{Key: key, Limit: fromLimit(limit)})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return toLease(response.GetLease()), nil
+}
+
+// RenewLease extends a lease on key on the peer.
+func (pc *peerClient) RenewLease(ctx context.Context, key, leaseID string, limit storage.Limit) (*storage.Lease, error) {
+	response, err := pc.client.RenewLease(ctx, &pb.RenewLeaseRequest{Key: key, LeaseId: leaseID, Limit: fromLimit(limit)})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return toLease(response.GetLease()), nil
+}
+
+// ReleaseLease frees a lease on key on the peer.
+func (pc *peerClient) ReleaseLease(ctx context.Context, key, leaseID string) error {
+	if _, err := pc.client.ReleaseLease(ctx, &pb.ReleaseLeaseRequest{Key: key, LeaseId: leaseID}); err != nil {
+		return fromStatus(err)
+	}
+	return nil
+}
+
+// SavePolicy creates or replaces the policy on the peer.
+func (pc *peerClient) SavePolicy(ctx context.Context, policy storage.Policy) error {
+	if _, err := pc.client.SavePolicy(ctx, &pb.SavePolicyRequest{Policy: fromPolicy(policy)}); err != nil {
+		return fromStatus(err)
+	}
+	return nil
+}
+
+// CreatePolicy adds the policy on the peer unless one with the same name exists.
+func (pc *peerClient) CreatePolicy(ctx context.Context, policy storage.Policy) error {
+	if _, err := pc.client.CreatePolicy(ctx, &pb.CreatePolicyRequest{Policy: fromPolicy(policy)}); err != nil {
+		return fromStatus(err)
+	}
+	return nil
+}
+
+// UpdatePolicy replaces the policy with the same name on the peer.
+func (pc *peerClient) UpdatePolicy(ctx context.Context, policy storage.Policy) error {
+	if _, err := pc.client.UpdatePolicy(ctx, &pb.UpdatePolicyRequest{Policy: fromPolicy(policy)}); err != nil {
+		return fromStatus(err)
+	}
+	return nil
+}
+
+// GetPolicy reads the named policy on the peer.
+func (pc *peerClient) GetPolicy(ctx context.Context, name string) (*storage.Policy, error) {
+	response, err := pc.client.GetPolicy(ctx, &pb.GetPolicyRequest{Name: name})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	policy := toPolicy(response.GetPolicy())
+	return &policy, nil
+}
+
+// DeletePolicy removes the named policy on the peer.
+func (pc *peerClient) DeletePolicy(ctx context.Context, name string) error {
+	if _, err := pc.client.DeletePolicy(ctx, &pb.DeletePolicyRequest{Name: name}); err != nil {
+		return fromStatus(err)
+	}
+	return nil
+}
+
+// ListPolicies returns every policy on the peer ordered by name.
+func (pc *peerClient) ListPolicies(ctx context.Context) ([]storage.Policy, error) {
+	response, err := pc.client.ListPolicies(ctx, &pb.ListPoliciesRequest{})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	policies := make([]storage.Policy, len(response.GetPolicies()))
+	for i, policy := range response.GetPolicies() {
+		policies[i] = toPolicy(policy)
+	}
+	return policies, nil
+}
+
+// SaveOverride creates or replaces the override on the peer.
+func (pc *peerClient) SaveOverride(ctx context.Context, override storage.Override) error {
+	if _, err := pc.client.SaveOverride(ctx, &pb.SaveOverrideRequest{Override: fromOverride(override)}); err != nil {
+		return fromStatus(err)
+	}
+	return nil
+}
+
+// GetOverride reads the override with the pattern on the peer.
+func (pc *peerClient) GetOverride(ctx context.Context, pattern string) (*storage.Override, error) {
+	response, err := pc.client.GetOverride(ctx, &pb.GetOverrideRequest{Pattern: pattern})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	override := toOverride(response.GetOverride())
+	return &override, nil
+}
+
+// DeleteOverride removes the override with the pattern on the peer.
+func (pc *peerClient) DeleteOverride(ctx context.Context, pattern string) error {
+	if _, err := pc.client.DeleteOverride(ctx, &pb.DeleteOverrideRequest{Pattern: pattern}); err != nil {
+		return fromStatus(err)
+	}
+	return nil
+}
+
+// ListOverrides returns every override on the peer ordered by pattern.
+func (pc *peerClient) ListOverrides(ctx context.Context) ([]storage.Override, error) {
+	response, err := pc.client.ListOverrides(ctx, &pb.ListOverridesRequest{})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	overrides := make([]storage.Override, len(response.GetOverrides()))
+	for i, override := range response.GetOverrides() {
+		overrides[i] = toOverride(override)
+	}
+	return overrides, nil
+}
+
+// Close closes the connection to the peer.
+func (pc *peerClient) Close() error {
+	return pc.conn.Close()
+}
@@ -0,0 +1,551 @@
+// Package cluster runs the rate limiter as a cluster of nodes that share state without a central backend.
+//
+// Every key is owned by one node, chosen by a consistent-hash Ring over the cluster's members. The owner keeps the
+// key's state in its own memory, so a check costs a memory operation on the owner. Other nodes forward calls for
+// the key to the owner through the internal PeerService, which the owner answers without forwarding again.
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/memory"
+)
+
+// DefaultRefreshInterval is how often members are rediscovered when no WithRefreshInterval option is given.
+const DefaultRefreshInterval = 10 * time.Second
+
+// settingsKey is the key whose owner decides every write to the policies and overrides of the cluster.
+const settingsKey = "{ratelimiter:settings}"
+
+// ClusterStorage implements storage.RateLimitStorage, storage.BatchStorage, storage.PolicyStorage,
+// storage.OverrideStorage and storage.LeaseStorage by routing every key to the node that owns it.
+// Policies and overrides are written on the owner of one key and copied to every other member, so the next owner
+// of that key already has them if the current one goes away.
+type ClusterStorage struct {
+	self            string
+	discovery       Discovery
+	replicas        int
+	refreshInterval time.Duration
+	memoryOpts      []memory.Option
+	local           *memory.MemoryStorage
+
+	mutex sync.RWMutex
+	ring  *Ring
+	peers map[string]*peerClient
+	// outdated are the members that still need a copy of this node's policies and overrides
+	outdated map[string]bool
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// node is the storage of one member: this node's memory or a client of a peer.
+type node interface {
+	storage.RateLimitStorage
+	storage.BatchStorage
+	storage.LeaseStorage
+	storage.PolicyStorage
+	storage.OverrideStorage
+}
+
+// Option configures a ClusterStorage.
+type Option func(*ClusterStorage)
+
+// WithReplicas sets the number of points each node gets on the ring. Every node must use the same number.
+func WithReplicas(n int) Option {
+	return func(cs *ClusterStorage) {
+		if n > 0 {
+			cs.replicas = n
+		}
+	}
+}
+
+// WithRefreshInterval sets how often members are rediscovered.
+func WithRefreshInterval(d time.Duration) Option {
+	return func(cs *ClusterStorage) {
+		if d > 0 {
+			cs.refreshInterval = d
+		}
+	}
+}
+
+// WithMemoryOptions configures the memory storage holding the keys this node owns.
+func WithMemoryOptions(opts ...memory.Option) Option {
+	return func(cs *ClusterStorage) {
+		cs.memoryOpts = append(cs.memoryOpts, opts...)
+	}
+}
+
+// NewClusterStorage joins the cluster as the node at self, which must be the address other nodes reach its
+// PeerServer on and must be listed by discovery in the same form. It fails if discovery finds no members.
+func NewClusterStorage(ctx context.Context, self string, discovery Discovery, opts ...Option) (*ClusterStorage, error) {
+	cs := &ClusterStorage{
+		self:            self,
+		discovery:       discovery,
+		replicas:        DefaultReplicas,
+		refreshInterval: DefaultRefreshInterval,
+		peers:           make(map[string]*peerClient),
+		outdated:        make(map[string]bool),
+		stop:            make(chan struct{}),
+		done:            make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(cs)
+	}
+
+	cs.local = memory.NewMemoryStorage(cs.memoryOpts...)
+
+	if err := cs.refresh(ctx); err != nil {
+		cs.local.Close()
+		return nil, fmt.Errorf("failed to discover cluster members: %w", err)
+	}
+
+	go cs.refreshLoop()
+
+	return cs, nil
+}
+
+// CheckAndUpdate checks and updates key on the node that owns it.
+func (cs *ClusterStorage) CheckAndUpdate(ctx context.Context, key string, limit storage.Limit, cost int64) (*storage.Result, error) {
+	return cs.owner(key).CheckAndUpdate(ctx, key, limit, cost)
+}
+
+// CheckAndUpdateAll checks every key and consumes cost on all of them only if every check is allowed.
+// Every key must be owned by the same node, e.g. by sharing a hash tag, or it returns storage.ErrKeysNotColocated.
+func (cs *ClusterStorage) CheckAndUpdateAll(ctx context.Context, checks []storage.Check) ([]*storage.Result, error) {
+	groups := cs.group(checks)
+
+	// Keys on different nodes can't be updated atomically
+	if len(groups) > 1 {
+		return nil, storage.ErrKeysNotColocated
+	}
+	for _, group := range groups {
+		return group.node.CheckAndUpdateAll(ctx, checks)
+	}
+	return cs.local.CheckAndUpdateAll(ctx, checks)
+}
+
+// CheckAndUpdateBatch checks every key on its own, sending one call to each node that owns any of them.
+func (cs *ClusterStorage) CheckAndUpdateBatch(ctx context.Context, checks []storage.Check) ([]*storage.Result, error) {
+	groups := cs.group(checks)
+
+	results := make([]*storage.Result, len(checks))
+	errs := make([]error, 0, len(groups))
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, group := range groups {
+		wg.Go(func() {
+			groupResults, err := group.node.CheckAndUpdateBatch(ctx, storage.Pick(checks, group.indices))
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			for i, result := range groupResults {
+				results[group.indices[i]] = result
+			}
+		})
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return results, nil
+}
+
+// GetStatus reads key on the node that owns it.
+func (cs *ClusterStorage) GetStatus(ctx context.Context, key string, limit storage.Limit) (*storage.Result, error) {
+	return cs.owner(key).GetStatus(ctx, key, limit)
+}
+
+// Refund returns consumed cost to key on the node that owns it.
+func (cs *ClusterStorage) Refund(ctx context.Context, key string, limit storage.Limit, amount int64) (*storage.Result, error) {
+	return cs.owner(key).Refund(ctx, key, limit, amount)
+}
+
+// Reset clears key on the node that owns it.
+func (cs *ClusterStorage) Reset(ctx context.Context, key string) error {
+	return cs.owner(key).Reset(ctx, key)
+}
+
+// AcquireLease grants a lease on key on the node that owns it.
+func (cs *ClusterStorage) AcquireLease(ctx context.Context, key string, limit storage.Limit) (*storage.Lease, error) {
+	return cs.owner(key).AcquireLease(ctx, key, limit)
+}
+
+// RenewLease extends a lease on key on the node that owns it.
+func (cs *ClusterStorage) RenewLease(ctx context.Context, key, leaseID string, limit storage.Limit) (*storage.Lease, error) {
+	return cs.owner(key).RenewLease(ctx, key, leaseID, limit)
+}
+
+// ReleaseLease frees a lease on key on the node that owns it.
+func (cs *ClusterStorage) ReleaseLease(ctx context.Context, key, leaseID string) error {
+	return cs.owner(key).ReleaseLease(ctx, key, leaseID)
+}
+
+// SavePolicy creates or replaces the policy on the node that owns policies and copies it to every other member.
+func (cs *ClusterStorage) SavePolicy(ctx context.Context, policy storage.Policy) error {
+	return cs.replicate(ctx, func(n node) error {
+		return n.SavePolicy(ctx, policy)
+	}, nil)
+}
+
+// CreatePolicy adds the policy on the node that owns policies unless one with the same name exists, and copies it
+// to every other member.
+func (cs *ClusterStorage) CreatePolicy(ctx context.Context, policy storage.Policy) error {
+	return cs.replicate(ctx, func(n node) error {
+		return n.CreatePolicy(ctx, policy)
+	}, func(n node) error {
+		return n.SavePolicy(ctx, policy)
+	})
+}
+
+// UpdatePolicy replaces the policy with the same name on the node that owns policies and copies it to every other member.
+func (cs *ClusterStorage) UpdatePolicy(ctx context.Context, policy storage.Policy) error {
+	return cs.replicate(ctx, func(n node) error {
+		return n.UpdatePolicy(ctx, policy)
+	}, func(n node) error {
+		return n.SavePolicy(ctx, policy)
+	})
+}
+
+// GetPolicy reads the named policy on the node that owns policies.
+func (cs *ClusterStorage) GetPolicy(ctx context.Context, name string) (*storage.Policy, error) {
+	return cs.owner(settingsKey).GetPolicy(ctx, name)
+}
+
+// DeletePolicy removes the named policy on the node that owns policies and on every other member.
+func (cs *ClusterStorage) DeletePolicy(ctx context.Context, name string) error {
+	return cs.replicate(ctx, func(n node) error {
+		return n.DeletePolicy(ctx, name)
+	}, func(n node) error {
+		return ignore(n.DeletePolicy(ctx, name), storage.ErrPolicyNotFound)
+	})
+}
+
+// ListPolicies returns every policy on the node that owns policies, ordered by name.
+func (cs *ClusterStorage) ListPolicies(ctx context.Context) ([]storage.Policy, error) {
+	return cs.owner(settingsKey).ListPolicies(ctx)
+}
+
+// SaveOverride creates or replaces the override on the node that owns overrides and copies it to every other member.
+func (cs *ClusterStorage) SaveOverride(ctx context.Context, override storage.Override) error {
+	return cs.replicate(ctx, func(n node) error {
+		return n.SaveOverride(ctx, override)
+	}, nil)
+}
+
+// GetOverride reads the override with the pattern on the node that owns overrides.
+func (cs *ClusterStorage) GetOverride(ctx context.Context, pattern string) (*storage.Override, error) {
+	return cs.owner(settingsKey).GetOverride(ctx, pattern)
+}
+
+// DeleteOverride removes the override with the pattern on the node that owns overrides and on every other member.
+func (cs *ClusterStorage) DeleteOverride(ctx context.Context, pattern string) error {
+	return cs.replicate(ctx, func(n node) error {
+		return n.DeleteOverride(ctx, pattern)
+	}, func(n node) error {
+		return ignore(n.DeleteOverride(ctx, pattern), storage.ErrOverrideNotFound)
+	})
+}
+
+// ListOverrides returns every override on the node that owns overrides, ordered by pattern.
+func (cs *ClusterStorage) ListOverrides(ctx context.Context) ([]storage.Override, error) {
+	return cs.owner(settingsKey).ListOverrides(ctx)
+}
+
+// Members returns every node currently on the ring in sorted order.
+func (cs *ClusterStorage) Members() []string {
+	cs.mutex.RLock()
+	defer cs.mutex.RUnlock()
+
+	return cs.ring.Nodes()
+}
+
+// Close stops rediscovering members, disconnects from every peer and drops the keys this node owns.
+func (cs *ClusterStorage) Close() error {
+	cs.closeOnce.Do(func() {
+		close(cs.stop)
+		<-cs.done
+
+		cs.mutex.Lock()
+		defer cs.mutex.Unlock()
+		for _, peer := range cs.peers {
+			peer.Close()
+		}
+		cs.local.Close()
+	})
+	return nil
+}
+
+// owner returns the node that owns key.
+func (cs *ClusterStorage) owner(key string) node {
+	cs.mutex.RLock()
+	defer cs.mutex.RUnlock()
+
+	return cs.nodeLocked(cs.ring.Owner(key))
+}
+
+// nodeLocked returns the storage of the named member. The caller must hold the mutex.
+func (cs *ClusterStorage) nodeLocked(member string) node {
+	if member == cs.self {
+		return cs.local
+	}
+	return cs.peers[member]
+}
+
+// replicate applies a write to policies or overrides on the node that owns them, which decides whether it succeeds,
+// and then copies it to every other member in parallel with mirror, or with write itself when mirror is nil.
+// A member the copy fails on is brought up to date from this node on the next refresh.
+func (cs *ClusterStorage) replicate(ctx context.Context, write, mirror func(n node) error) error {
+	if mirror == nil {
+		mirror = write
+	}
+
+	cs.mutex.RLock()
+	owner := cs.ring.Owner(settingsKey)
+	replicas := make(map[string]node, len(cs.peers))
+	for _, member := range cs.ring.Nodes() {
+		if member != owner {
+			replicas[member] = cs.nodeLocked(member)
+		}
+	}
+	ownerNode := cs.nodeLocked(owner)
+	cs.mutex.RUnlock()
+
+	if err := write(ownerNode); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for member, replica := range replicas {
+		wg.Go(func() {
+			if err := mirror(replica); err != nil {
+				log.Printf("cluster: failed to copy policies and overrides to %s, retrying on the next refresh: %v", member, err)
+				cs.mutex.Lock()
+				cs.outdated[member] = true
+				cs.mutex.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+	return nil
+}
+
+// ignore returns nil if err is target, so removing what a member doesn't have counts as done.
+func ignore(err, target error) error {
+	if errors.Is(err, target) {
+		return nil
+	}
+	return err
+}
+
+// checkGroup is the checks owned by one node.
+type checkGroup struct {
+	node    node
+	indices []int
+}
+
+// group splits checks by the node that owns their key, keeping each group in check order.
+func (cs *ClusterStorage) group(checks []storage.Check) map[string]*checkGroup {
+	cs.mutex.RLock()
+	defer cs.mutex.RUnlock()
+
+	groups := make(map[string]*checkGroup)
+	for i, check := range checks {
+		member := cs.ring.Owner(check.Key)
+		group, ok := groups[member]
+		if !ok {
+			group = &checkGroup{node: cs.nodeLocked(member)}
+			groups[member] = group
+		}
+		group.indices = append(group.indices, i)
+	}
+	return groups
+}
+
+// refresh rediscovers members and rebuilds the ring if they changed.
+// An empty member list is ignored, so a failed lookup doesn't leave the cluster without owners.
+func (cs *ClusterStorage) refresh(ctx context.Context) error {
+	members, err := cs.discovery.Members(ctx)
+	if err != nil {
+		return err
+	}
+	if len(members) == 0 {
+		if cs.ring == nil {
+			return errors.New("no members found")
+		}
+		return nil
+	}
+
+	if err := cs.rebuild(NewRing(members, cs.replicas)); err != nil {
+		return err
+	}
+	if err := cs.syncSettings(ctx); err != nil {
+		return fmt.Errorf("failed to copy policies and overrides: %w", err)
+	}
+	return nil
+}
+
+// rebuild replaces the ring if its members changed, connecting to members that joined and disconnecting from those
+// that left. Members that joined need a copy of the policies and overrides, which this node sends them if it would
+// own the settings key among the members that stayed: the current owner if it stayed, otherwise its successor,
+// which already has every write.
+func (cs *ClusterStorage) rebuild(ring *Ring) error {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	if cs.ring != nil && slices.Equal(cs.ring.Nodes(), ring.Nodes()) {
+		return nil
+	}
+
+	// Connect to members that joined and disconnect from those that left
+	peers := make(map[string]*peerClient, len(ring.Nodes()))
+	for _, member := range ring.Nodes() {
+		if member == cs.self {
+			continue
+		}
+		if peer, ok := cs.peers[member]; ok {
+			peers[member] = peer
+			continue
+		}
+		peer, err := newPeerClient(member)
+		if err != nil {
+			for joined, peer := range peers {
+				if _, ok := cs.peers[joined]; !ok {
+					peer.Close()
+				}
+			}
+			return fmt.Errorf("failed to connect to %s: %w", member, err)
+		}
+		peers[member] = peer
+	}
+	for member, peer := range cs.peers {
+		if _, ok := peers[member]; !ok {
+			peer.Close()
+			delete(cs.outdated, member)
+		}
+	}
+
+	// A node that just started has nothing to copy yet
+	if cs.ring != nil {
+		var stayed, joined []string
+		for _, member := range ring.Nodes() {
+			if slices.Contains(cs.ring.Nodes(), member) {
+				stayed = append(stayed, member)
+			} else {
+				joined = append(joined, member)
+			}
+		}
+		if NewRing(stayed, cs.replicas).Owner(settingsKey) == cs.self {
+			for _, member := range joined {
+				cs.outdated[member] = true
+			}
+		}
+	}
+	cs.ring, cs.peers = ring, peers
+	return nil
+}
+
+// syncSettings makes the policies and overrides of every outdated member match this node's, removing those this
+// node doesn't have. A member that fails stays outdated and is tried again on the next refresh.
+func (cs *ClusterStorage) syncSettings(ctx context.Context) error {
+	cs.mutex.RLock()
+	outdated := make(map[string]node, len(cs.outdated))
+	for member := range cs.outdated {
+		outdated[member] = cs.nodeLocked(member)
+	}
+	cs.mutex.RUnlock()
+
+	var errs []error
+	for member, replica := range outdated {
+		if err := cs.copySettings(ctx, replica); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", member, err))
+			continue
+		}
+		cs.mutex.Lock()
+		delete(cs.outdated, member)
+		cs.mutex.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// copySettings replaces the policies and overrides of replica with this node's.
+func (cs *ClusterStorage) copySettings(ctx context.Context, replica node) error {
+	policies, err := cs.local.ListPolicies(ctx)
+	if err != nil {
+		return err
+	}
+	stale, err := replica.ListPolicies(ctx)
+	if err != nil {
+		return err
+	}
+	for _, policy := range stale {
+		if slices.ContainsFunc(policies, func(p storage.Policy) bool { return p.Name == policy.Name }) {
+			continue
+		}
+		if err := ignore(replica.DeletePolicy(ctx, policy.Name), storage.ErrPolicyNotFound); err != nil {
+			return fmt.Errorf("policy %q: %w", policy.Name, err)
+		}
+	}
+	for _, policy := range policies {
+		if err := replica.SavePolicy(ctx, policy); err != nil {
+			return fmt.Errorf("policy %q: %w", policy.Name, err)
+		}
+	}
+
+	overrides, err := cs.local.ListOverrides(ctx)
+	if err != nil {
+		return err
+	}
+	staleOverrides, err := replica.ListOverrides(ctx)
+	if err != nil {
+		return err
+	}
+	for _, override := range staleOverrides {
+		if slices.ContainsFunc(overrides, func(o storage.Override) bool { return o.Pattern == override.Pattern }) {
+			continue
+		}
+		if err := ignore(replica.DeleteOverride(ctx, override.Pattern), storage.ErrOverrideNotFound); err != nil {
+			return fmt.Errorf("override %q: %w", override.Pattern, err)
+		}
+	}
+	for _, override := range overrides {
+		if err := replica.SaveOverride(ctx, override); err != nil {
+			return fmt.Errorf("override %q: %w", override.Pattern, err)
+		}
+	}
+	return nil
+}
+
+// refreshLoop periodically rediscovers members until Close is called.
+func (cs *ClusterStorage) refreshLoop() {
+	defer close(cs.done)
+
+	ticker := time.NewTicker(cs.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), cs.refreshInterval)
+			if err := cs.refresh(ctx); err != nil {
+				log.Printf("cluster: failed to refresh members, keeping %v: %v", cs.Members(), err)
+			}
+			cancel()
+		case <-cs.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	pb "github.com/AaronBrownDev/distributed-rate-limiter/gen/ratelimiter/peer/v1"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/memory"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/storagetest"
+	"google.golang.org/grpc"
+)
+
+func TestClusterStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) (storage.RateLimitStorage, storagetest.Clock) {
+		clock := storagetest.NewFakeClock(time.Unix(0, 0))
+		// Each test's keys share a hash tag, so they are owned by this node or forwarded to a peer
+		return newTestCluster(t, 3, clock)[0], clock
+	})
+}
+
+func TestClusterStorage_SharedAcrossNodes(t *testing.T) {
+	ctx := context.Background()
+	clock := storagetest.NewFakeClock(time.Unix(0, 0))
+	nodes := newTestCluster(t, 3, clock)
+	limit := storage.Limit{Algorithm: storage.FixedWindow, Limit: 30, Window: time.Minute}
+
+	for i := range 10 {
+		key := fmt.Sprintf("user:%d", i)
+
+		// Every node consumes from the owner's counter
+		for _, cs := range nodes {
+			if _, err := cs.CheckAndUpdate(ctx, key, limit, 10); err != nil {
+				t.Fatalf("CheckAndUpdate() error = %v", err)
+			}
+		}
+		for _, cs := range nodes {
+			result, err := cs.CheckAndUpdate(ctx, key, limit, 1)
+			if err != nil {
+				t.Fatalf("CheckAndUpdate() error = %v", err)
+			}
+			if result.Allowed {
+				t.Errorf("CheckAndUpdate(%s) allowed after the limit was used up through every node", key)
+			}
+		}
+	}
+}
+
+func TestClusterStorage_CheckAndUpdateAll(t *testing.T) {
+	nodes := newTestCluster(t, 3, storagetest.NewFakeClock(time.Unix(0, 0)))
+	cs := nodes[0]
+	limit := storage.Limit{Algorithm: storage.FixedWindow, Limit: 10, Window: time.Minute}
+
+	// Find two keys owned by different nodes
+	other := ""
+	for i := range 100 {
+		key := fmt.Sprintf("user:%d", i)
+		if cs.ring.Owner(key) != cs.ring.Owner("user:0") {
+			other = key
+			break
+		}
+	}
+
+	tests := []struct {
+		name    string
+		keys    []string
+		wantErr error
+	}{
+		{name: "keys with a shared hash tag", keys: []string{"{org:1}", "{org:1}:user:7"}},
+		{name: "keys on different nodes", keys: []string{"user:0", other}, wantErr: storage.ErrKeysNotColocated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks := make([]storage.Check, len(tt.keys))
+			for i, key := range tt.keys {
+				checks[i] = storage.Check{Key: key, Limit: limit, Cost: 1}
+			}
+
+			_, err := cs.CheckAndUpdateAll(context.Background(), checks)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckAndUpdateAll() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClusterStorage_Policies(t *testing.T) {
+	ctx := context.Background()
+	nodes := newTestCluster(t, 3, storagetest.NewFakeClock(time.Unix(0, 0)))
+	policy := storage.Policy{
+		Name:       "api.write",
+		KeyPattern: "user:*",
+		Limit:      storage.Limit{Algorithm: storage.FixedWindow, Limit: 100, Period: storage.Daily, Timezone: "America/New_York"},
+		Tiers:      []storage.Limit{{Algorithm: storage.TokenBucket, Limit: 10, Window: time.Second, RefillRate: 5}},
+		Shadow:     true,
+	}
+	override := storage.Override{Pattern: "user:7", Action: storage.ScaleLimit, Multiplier: 2.5, Reason: "partner"}
+
+	// Writes through any node are seen by every other node
+	for i, cs := range nodes {
+		writer := nodes[(i+1)%len(nodes)]
+		if err := writer.SavePolicy(ctx, policy); err != nil {
+			t.Fatalf("SavePolicy() error = %v", err)
+		}
+		if err := writer.SaveOverride(ctx, override); err != nil {
+			t.Fatalf("SaveOverride() error = %v", err)
+		}
+
+		policies, err := cs.ListPolicies(ctx)
+		if err != nil || len(policies) != 1 || !reflect.DeepEqual(policies[0], policy) {
+			t.Errorf("node %d ListPolicies() = %+v, %v, want [%+v]", i, policies, err, policy)
+		}
+		got, err := cs.GetOverride(ctx, override.Pattern)
+		if err != nil || *got != override {
+			t.Errorf("node %d GetOverride() = %+v, %v, want %+v", i, got, err, override)
+		}
+
+		if err := cs.DeletePolicy(ctx, policy.Name); err != nil {
+			t.Fatalf("DeletePolicy() error = %v", err)
+		}
+		if _, err := writer.GetPolicy(ctx, policy.Name); !errors.Is(err, storage.ErrPolicyNotFound) {
+			t.Errorf("GetPolicy() after delete error = %v, want %v", err, storage.ErrPolicyNotFound)
+		}
+		if err := cs.DeleteOverride(ctx, override.Pattern); err != nil {
+			t.Fatalf("DeleteOverride() error = %v", err)
+		}
+	}
+
+	// Errors keep their identity across nodes
+	if err := nodes[0].CreatePolicy(ctx, policy); err != nil {
+		t.Fatalf("CreatePolicy() error = %v", err)
+	}
+	for _, cs := range nodes {
+		if err := cs.CreatePolicy(ctx, policy); !errors.Is(err, storage.ErrPolicyExists) {
+			t.Errorf("CreatePolicy() error = %v, want %v", err, storage.ErrPolicyExists)
+		}
+		if err := cs.DeleteOverride(ctx, "user:8"); !errors.Is(err, storage.ErrOverrideNotFound) {
+			t.Errorf("DeleteOverride() error = %v, want %v", err, storage.ErrOverrideNotFound)
+		}
+	}
+}
+
+func TestClusterStorage_OwnerLost(t *testing.T) {
+	ctx := context.Background()
+	nodes := newTestCluster(t, 3, storagetest.NewFakeClock(time.Unix(0, 0)))
+	policy := storage.Policy{Name: "api.write", Limit: storage.Limit{Limit: 10, Window: time.Minute}}
+	override := storage.Override{Pattern: "user:7", Action: storage.AlwaysDeny}
+	if err := nodes[0].SavePolicy(ctx, policy); err != nil {
+		t.Fatalf("SavePolicy() error = %v", err)
+	}
+	if err := nodes[0].SaveOverride(ctx, override); err != nil {
+		t.Fatalf("SaveOverride() error = %v", err)
+	}
+
+	// The owner drops out of the ring of the others without handing anything over
+	var owner *ClusterStorage
+	var rest []string
+	for _, cs := range nodes {
+		if cs.ring.Owner(settingsKey) == cs.self {
+			owner = cs
+		} else {
+			rest = append(rest, cs.self)
+		}
+	}
+	for _, cs := range nodes {
+		if cs != owner {
+			if err := cs.rebuild(NewRing(rest, DefaultReplicas)); err != nil {
+				t.Fatalf("rebuild() error = %v", err)
+			}
+		}
+	}
+	for _, cs := range nodes {
+		if cs == owner {
+			continue
+		}
+		if _, err := cs.GetPolicy(ctx, policy.Name); err != nil {
+			t.Errorf("GetPolicy() on the new owner error = %v", err)
+		}
+		if _, err := cs.GetOverride(ctx, override.Pattern); err != nil {
+			t.Errorf("GetOverride() on the new owner error = %v", err)
+		}
+	}
+
+	// It comes back without its memory and gets a copy, dropping what was deleted while it was gone
+	if err := owner.local.DeletePolicy(ctx, policy.Name); err != nil {
+		t.Fatalf("DeletePolicy() error = %v", err)
+	}
+	if err := owner.local.DeleteOverride(ctx, override.Pattern); err != nil {
+		t.Fatalf("DeleteOverride() error = %v", err)
+	}
+	if err := owner.local.SavePolicy(ctx, storage.Policy{Name: "stale", Limit: policy.Limit}); err != nil {
+		t.Fatalf("SavePolicy() error = %v", err)
+	}
+	for _, cs := range nodes {
+		if cs == owner {
+			continue
+		}
+		if err := cs.rebuild(NewRing(append(rest, owner.self), DefaultReplicas)); err != nil {
+			t.Fatalf("rebuild() error = %v", err)
+		}
+		if err := cs.syncSettings(ctx); err != nil {
+			t.Fatalf("syncSettings() error = %v", err)
+		}
+	}
+	policies, err := owner.ListPolicies(ctx)
+	if err != nil || len(policies) != 1 || policies[0].Name != policy.Name {
+		t.Errorf("ListPolicies() on the returning owner = %+v, %v, want only %s", policies, err, policy.Name)
+	}
+	if _, err := owner.GetOverride(ctx, override.Pattern); err != nil {
+		t.Errorf("GetOverride() on the returning owner error = %v", err)
+	}
+}
+
+func TestClusterStorage_Refresh(t *testing.T) {
+	clock := storagetest.NewFakeClock(time.Unix(0, 0))
+	discovery := StaticDiscovery{"127.0.0.1:1", "127.0.0.1:2"}
+	cs, err := NewClusterStorage(context.Background(), "127.0.0.1:1", &discovery, WithMemoryOptions(memory.WithClock(clock.Now)))
+	if err != nil {
+		t.Fatalf("NewClusterStorage() error = %v", err)
+	}
+	defer cs.Close()
+
+	// A member leaves and its client is closed
+	discovery = StaticDiscovery{"127.0.0.1:1"}
+	if err := cs.refresh(context.Background()); err != nil {
+		t.Fatalf("refresh() error = %v", err)
+	}
+	if got := cs.Members(); len(got) != 1 || len(cs.peers) != 0 {
+		t.Errorf("Members() = %v with %d peers, want only 127.0.0.1:1", got, len(cs.peers))
+	}
+
+	// An empty lookup keeps the last members
+	discovery = StaticDiscovery{}
+	if err := cs.refresh(context.Background()); err != nil {
+		t.Fatalf("refresh() error = %v", err)
+	}
+	if got := cs.Members(); len(got) != 1 {
+		t.Errorf("Members() = %v, want the last members kept", got)
+	}
+}
+
+// newTestCluster starts n nodes on local ports that share clock and are closed when the test ends.
+func newTestCluster(t *testing.T, n int, clock *storagetest.FakeClock) []*ClusterStorage {
+	t.Helper()
+
+	listeners := make([]net.Listener, n)
+	members := make(StaticDiscovery, n)
+	for i := range listeners {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("failed to listen: %v", err)
+		}
+		listeners[i], members[i] = lis, lis.Addr().String()
+	}
+
+	nodes := make([]*ClusterStorage, n)
+	for i, lis := range listeners {
+		cs, err := NewClusterStorage(context.Background(), members[i], members, WithMemoryOptions(memory.WithClock(clock.Now)))
+		if err != nil {
+			t.Fatalf("NewClusterStorage() error = %v", err)
+		}
+		server := grpc.NewServer()
+		pb.RegisterPeerServiceServer(server, NewPeerServer(cs))
+		go server.Serve(lis)
+
+		t.Cleanup(func() {
+			server.Stop()
+			cs.Close()
+		})
+		nodes[i] = cs
+	}
+	return nodes
+}
@@ -0,0 +1,51 @@
+package cluster
+
+import (
+	"context"
+	"net"
+	"slices"
+)
+
+// Discovery finds the address of every node in the cluster, including this one.
+// Addresses must be in the same form as the node's own address, e.g. "10.0.0.7:7946".
+// Other membership sources, such as gossip, plug in by implementing it.
+type Discovery interface {
+	Members(ctx context.Context) ([]string, error)
+}
+
+// StaticDiscovery is a fixed list of node addresses.
+type StaticDiscovery []string
+
+// Members returns the configured addresses.
+func (sd StaticDiscovery) Members(ctx context.Context) ([]string, error) {
+	return slices.Clone(sd), nil
+}
+
+// DNSDiscovery finds nodes by resolving a host name to one address per node, such as a Kubernetes headless service.
+type DNSDiscovery struct {
+	// Host is resolved on every refresh.
+	Host string
+	// Port is the peer port every node listens on.
+	Port string
+	// Resolver looks up Host. Nil means net.DefaultResolver.
+	Resolver *net.Resolver
+}
+
+// Members resolves Host and pairs every address with Port.
+func (dd DNSDiscovery) Members(ctx context.Context) ([]string, error) {
+	resolver := dd.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	hosts, err := resolver.LookupHost(ctx, dd.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]string, len(hosts))
+	for i, host := range hosts {
+		members[i] = net.JoinHostPort(host, dd.Port)
+	}
+	return members, nil
+}
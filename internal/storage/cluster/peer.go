@@ -0,0 +1,388 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+
+	pb "github.com/AaronBrownDev/distributed-rate-limiter/gen/ratelimiter/peer/v1"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// PeerServer answers storage, policy and override calls forwarded by other nodes from this node's own memory.
+type PeerServer struct {
+	pb.UnimplementedPeerServiceServer
+	local node
+}
+
+// NewPeerServer returns the peer service of cs, to be registered on a gRPC server listening on cs's address.
+func NewPeerServer(cs *ClusterStorage) *PeerServer {
+	return &PeerServer{local: cs.local}
+}
+
+// CheckAndUpdate checks and updates one key.
+func (ps *PeerServer) CheckAndUpdate(ctx context.Context, req *pb.CheckAndUpdateRequest) (*pb.CheckAndUpdateResponse, error) {
+	check := toCheck(req.GetCheck())
+	result, err := ps.local.CheckAndUpdate(ctx, check.Key, check.Limit, check.Cost)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.CheckAndUpdateResponse{Result: fromResult(result)}, nil
+}
+
+// CheckAndUpdateAll checks every key and updates them together.
+func (ps *PeerServer) CheckAndUpdateAll(ctx context.Context, req *pb.CheckAndUpdateAllRequest) (*pb.CheckAndUpdateAllResponse, error) {
+	results, err := ps.local.CheckAndUpdateAll(ctx, toChecks(req.GetChecks()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.CheckAndUpdateAllResponse{Results: fromResults(results)}, nil
+}
+
+// CheckAndUpdateBatch checks every key on its own.
+func (ps *PeerServer) CheckAndUpdateBatch(ctx context.Context, req *pb.CheckAndUpdateBatchRequest) (*pb.CheckAndUpdateBatchResponse, error) {
+	results, err := ps.local.CheckAndUpdateBatch(ctx, toChecks(req.GetChecks()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.CheckAndUpdateBatchResponse{Results: fromResults(results)}, nil
+}
+
+// GetStatus reads a key without consuming it.
+func (ps *PeerServer) GetStatus(ctx context.Context, req *pb.GetStatusRequest) (*pb.GetStatusResponse, error) {
+	result, err := ps.local.GetStatus(ctx, req.GetKey(), toLimit(req.GetLimit()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.GetStatusResponse{Result: fromResult(result)}, nil
+}
+
+// Refund returns consumed cost to a key.
+func (ps *PeerServer) Refund(ctx context.Context, req *pb.RefundRequest) (*pb.RefundResponse, error) {
+	result, err := ps.local.Refund(ctx, req.GetKey(), toLimit(req.GetLimit()), req.GetAmount())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.RefundResponse{Result: fromResult(result)}, nil
+}
+
+// Reset clears a key.
+func (ps *PeerServer) Reset(ctx context.Context, req *pb.ResetRequest) (*pb.ResetResponse, error) {
+	if err := ps.local.Reset(ctx, req.GetKey()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.ResetResponse{}, nil
+}
+
+// AcquireLease grants a concurrency lease on a key.
+func (ps *PeerServer) AcquireLease(ctx context.Context, req *pb.AcquireLeaseRequest) (*pb.AcquireLeaseResponse, error) {
+	lease, err := ps.local.AcquireLease(ctx, req.GetKey(), toLimit(req.GetLimit()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.AcquireLeaseResponse{Lease: fromLease(lease)}, nil
+}
+
+// RenewLease extends a held lease.
+func (ps *PeerServer) RenewLease(ctx context.Context, req *pb.RenewLeaseRequest) (*pb.RenewLeaseResponse, error) {
+	lease, err := ps.local.RenewLease(ctx, req.GetKey(), req.GetLeaseId(), toLimit(req.GetLimit()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.RenewLeaseResponse{Lease: fromLease(lease)}, nil
+}
+
+// ReleaseLease frees a held lease.
+func (ps *PeerServer) ReleaseLease(ctx context.Context, req *pb.ReleaseLeaseRequest) (*pb.ReleaseLeaseResponse, error) {
+	if err := ps.local.ReleaseLease(ctx, req.GetKey(), req.GetLeaseId()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.ReleaseLeaseResponse{}, nil
+}
+
+// SavePolicy creates or replaces a policy.
+func (ps *PeerServer) SavePolicy(ctx context.Context, req *pb.SavePolicyRequest) (*pb.SavePolicyResponse, error) {
+	if err := ps.local.SavePolicy(ctx, toPolicy(req.GetPolicy())); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.SavePolicyResponse{}, nil
+}
+
+// CreatePolicy adds a policy unless one with the same name exists.
+func (ps *PeerServer) CreatePolicy(ctx context.Context, req *pb.CreatePolicyRequest) (*pb.CreatePolicyResponse, error) {
+	if err := ps.local.CreatePolicy(ctx, toPolicy(req.GetPolicy())); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.CreatePolicyResponse{}, nil
+}
+
+// UpdatePolicy replaces an existing policy.
+func (ps *PeerServer) UpdatePolicy(ctx context.Context, req *pb.UpdatePolicyRequest) (*pb.UpdatePolicyResponse, error) {
+	if err := ps.local.UpdatePolicy(ctx, toPolicy(req.GetPolicy())); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.UpdatePolicyResponse{}, nil
+}
+
+// GetPolicy reads a policy by name.
+func (ps *PeerServer) GetPolicy(ctx context.Context, req *pb.GetPolicyRequest) (*pb.GetPolicyResponse, error) {
+	policy, err := ps.local.GetPolicy(ctx, req.GetName())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.GetPolicyResponse{Policy: fromPolicy(*policy)}, nil
+}
+
+// DeletePolicy removes a policy.
+func (ps *PeerServer) DeletePolicy(ctx context.Context, req *pb.DeletePolicyRequest) (*pb.DeletePolicyResponse, error) {
+	if err := ps.local.DeletePolicy(ctx, req.GetName()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.DeletePolicyResponse{}, nil
+}
+
+// ListPolicies lists every policy.
+func (ps *PeerServer) ListPolicies(ctx context.Context, req *pb.ListPoliciesRequest) (*pb.ListPoliciesResponse, error) {
+	policies, err := ps.local.ListPolicies(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	response := &pb.ListPoliciesResponse{Policies: make([]*pb.Policy, len(policies))}
+	for i, policy := range policies {
+		response.Policies[i] = fromPolicy(policy)
+	}
+	return response, nil
+}
+
+// SaveOverride creates or replaces an override.
+func (ps *PeerServer) SaveOverride(ctx context.Context, req *pb.SaveOverrideRequest) (*pb.SaveOverrideResponse, error) {
+	if err := ps.local.SaveOverride(ctx, toOverride(req.GetOverride())); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.SaveOverrideResponse{}, nil
+}
+
+// GetOverride reads an override by pattern.
+func (ps *PeerServer) GetOverride(ctx context.Context, req *pb.GetOverrideRequest) (*pb.GetOverrideResponse, error) {
+	override, err := ps.local.GetOverride(ctx, req.GetPattern())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.GetOverrideResponse{Override: fromOverride(*override)}, nil
+}
+
+// DeleteOverride removes an override.
+func (ps *PeerServer) DeleteOverride(ctx context.Context, req *pb.DeleteOverrideRequest) (*pb.DeleteOverrideResponse, error) {
+	if err := ps.local.DeleteOverride(ctx, req.GetPattern()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.DeleteOverrideResponse{}, nil
+}
+
+// ListOverrides lists every override.
+func (ps *PeerServer) ListOverrides(ctx context.Context, req *pb.ListOverridesRequest) (*pb.ListOverridesResponse, error) {
+	overrides, err := ps.local.ListOverrides(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	response := &pb.ListOverridesResponse{Overrides: make([]*pb.Override, len(overrides))}
+	for i, override := range overrides {
+		response.Overrides[i] = fromOverride(override)
+	}
+	return response, nil
+}
+
+// peerErrors are the storage errors that keep their identity when forwarded, with the code they are sent as.
+var peerErrors = map[error]codes.Code{
+	storage.ErrKeyNotFound:          codes.NotFound,
+	storage.ErrLeaseNotFound:        codes.NotFound,
+	storage.ErrUnsupportedAlgorithm: codes.Unimplemented,
+	storage.ErrLimitTooHigh:         codes.InvalidArgument,
+	storage.ErrPolicyNotFound:       codes.NotFound,
+	storage.ErrPolicyExists:         codes.AlreadyExists,
+	storage.ErrOverrideNotFound:     codes.NotFound,
+}
+
+// toStatus converts a storage error into a gRPC status carrying its message.
+func toStatus(err error) error {
+	for peerErr, code := range peerErrors {
+		if errors.Is(err, peerErr) {
+			return status.Error(code, peerErr.Error())
+		}
+	}
+	return status.FromContextError(err).Err()
+}
+
+// fromStatus turns a status sent by toStatus back into the storage error it came from.
+// Other errors, such as an unreachable peer, are returned as they are.
+func fromStatus(err error) error {
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	for peerErr, code := range peerErrors {
+		if s.Code() == code && s.Message() == peerErr.Error() {
+			return peerErr
+		}
+	}
+	return err
+}
+
+// toLimit builds a storage.Limit from its protobuf form.
+func toLimit(limit *pb.Limit) storage.Limit {
+	return storage.Limit{
+		Algorithm:  storage.Algorithm(limit.GetAlgorithm()),
+		Limit:      limit.GetLimit(),
+		Window:     limit.GetWindow().AsDuration(),
+		RefillRate: limit.GetRefillRate(),
+		Period:     storage.Period(limit.GetPeriod()),
+		Timezone:   limit.GetTimezone(),
+	}
+}
+
+// fromLimit converts a storage.Limit into its protobuf form. The limits of checks have their calendar period
+// already mapped onto the window, so only policies send one.
+func fromLimit(limit storage.Limit) *pb.Limit {
+	return &pb.Limit{
+		Algorithm:  string(limit.Algorithm),
+		Limit:      limit.Limit,
+		Window:     durationpb.New(limit.Window),
+		RefillRate: limit.RefillRate,
+		Period:     string(limit.Period),
+		Timezone:   limit.Timezone,
+	}
+}
+
+// toCheck builds a storage.Check from its protobuf form.
+func toCheck(check *pb.Check) storage.Check {
+	return storage.Check{Key: check.GetKey(), Limit: toLimit(check.GetLimit()), Cost: check.GetCost()}
+}
+
+// toChecks builds storage checks from their protobuf form.
+func toChecks(checks []*pb.Check) []storage.Check {
+	converted := make([]storage.Check, len(checks))
+	for i, check := range checks {
+		converted[i] = toCheck(check)
+	}
+	return converted
+}
+
+// fromChecks converts storage checks into their protobuf form.
+func fromChecks(checks []storage.Check) []*pb.Check {
+	converted := make([]*pb.Check, len(checks))
+	for i, check := range checks {
+		converted[i] = &pb.Check{Key: check.Key, Limit: fromLimit(check.Limit), Cost: check.Cost}
+	}
+	return converted
+}
+
+// toResult builds a storage.Result from its protobuf form.
+func toResult(result *pb.Result) *storage.Result {
+	return &storage.Result{
+		Allowed:    result.GetAllowed(),
+		Remaining:  result.GetRemaining(),
+		ResetAt:    result.GetResetAt().AsTime(),
+		Limit:      result.GetLimit(),
+		RetryAfter: result.GetRetryAfter().AsDuration(),
+	}
+}
+
+// fromResult converts a storage.Result into its protobuf form.
+func fromResult(result *storage.Result) *pb.Result {
+	return &pb.Result{
+		Allowed:    result.Allowed,
+		Remaining:  result.Remaining,
+		ResetAt:    timestamppb.New(result.ResetAt),
+		Limit:      result.Limit,
+		RetryAfter: durationpb.New(result.RetryAfter),
+	}
+}
+
+// toResults builds storage results from their protobuf form.
+func toResults(results []*pb.Result) []*storage.Result {
+	converted := make([]*storage.Result, len(results))
+	for i, result := range results {
+		converted[i] = toResult(result)
+	}
+	return converted
+}
+
+// fromResults converts storage results into their protobuf form.
+func fromResults(results []*storage.Result) []*pb.Result {
+	converted := make([]*pb.Result, len(results))
+	for i, result := range results {
+		converted[i] = fromResult(result)
+	}
+	return converted
+}
+
+// toLease builds a storage.Lease from its protobuf form.
+func toLease(lease *pb.Lease) *storage.Lease {
+	return &storage.Lease{
+		Result:    *toResult(lease.GetResult()),
+		ID:        lease.GetId(),
+		ExpiresAt: lease.GetExpiresAt().AsTime(),
+	}
+}
+
+// fromLease converts a storage.Lease into its protobuf form.
+func fromLease(lease *storage.Lease) *pb.Lease {
+	return &pb.Lease{
+		Result:    fromResult(&lease.Result),
+		Id:        lease.ID,
+		ExpiresAt: timestamppb.New(lease.ExpiresAt),
+	}
+}
+
+// toPolicy builds a storage.Policy from its protobuf form.
+func toPolicy(policy *pb.Policy) storage.Policy {
+	converted := storage.Policy{
+		Name:        policy.GetName(),
+		KeyPattern:  policy.GetKeyPattern(),
+		Limit:       toLimit(policy.GetLimit()),
+		Shadow:      policy.GetShadow(),
+		FailureMode: storage.FailureMode(policy.GetFailureMode()),
+	}
+	for _, tier := range policy.GetTiers() {
+		converted.Tiers = append(converted.Tiers, toLimit(tier))
+	}
+	return converted
+}
+
+// fromPolicy converts a storage.Policy into its protobuf form.
+func fromPolicy(policy storage.Policy) *pb.Policy {
+	converted := &pb.Policy{
+		Name:        policy.Name,
+		KeyPattern:  policy.KeyPattern,
+		Limit:       fromLimit(policy.Limit),
+		Shadow:      policy.Shadow,
+		FailureMode: string(policy.FailureMode),
+	}
+	for _, tier := range policy.Tiers {
+		converted.Tiers = append(converted.Tiers, fromLimit(tier))
+	}
+	return converted
+}
+
+// toOverride builds a storage.Override from its protobuf form.
+func toOverride(override *pb.Override) storage.Override {
+	return storage.Override{
+		Pattern:    override.GetPattern(),
+		Action:     storage.OverrideAction(override.GetAction()),
+		Multiplier: override.GetMultiplier(),
+		Reason:     override.GetReason(),
+	}
+}
+
+// fromOverride converts a storage.Override into its protobuf form.
+func fromOverride(override storage.Override) *pb.Override {
+	return &pb.Override{
+		Pattern:    override.Pattern,
+		Action:     string(override.Action),
+		Multiplier: override.Multiplier,
+		Reason:     override.Reason,
+	}
+}
@@ -0,0 +1,69 @@
+package cluster
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"github.com/cespare/xxhash/v2"
+)
+
+// DefaultReplicas is the number of points each node gets on the ring when no WithReplicas option is given.
+// More points spread keys more evenly at the cost of a larger ring.
+const DefaultReplicas = 128
+
+// Ring assigns every key to one node by consistent hashing, so adding or removing a node only moves the keys that
+// node gains or loses. Every node builds the same ring from the same members.
+type Ring struct {
+	nodes  []string
+	points []point
+}
+
+// point is one of a node's positions on the ring.
+type point struct {
+	hash uint64
+	node string
+}
+
+// NewRing places replicas points for each node on the ring. Duplicate nodes are ignored.
+func NewRing(nodes []string, replicas int) *Ring {
+	nodes = slices.Compact(slices.Sorted(slices.Values(nodes)))
+	ring := &Ring{nodes: nodes, points: make([]point, 0, len(nodes)*replicas)}
+	for _, node := range nodes {
+		for i := 0; i < replicas; i++ {
+			ring.points = append(ring.points, point{hash: xxhash.Sum64String(node + "#" + strconv.Itoa(i)), node: node})
+		}
+	}
+	slices.SortFunc(ring.points, func(a, b point) int {
+		// Break hash collisions by node so every member orders the ring the same way
+		if a.hash != b.hash {
+			return cmp.Compare(a.hash, b.hash)
+		}
+		return strings.Compare(a.node, b.node)
+	})
+	return ring
+}
+
+// Owner returns the node that owns key: the first point at or after the key's hash, wrapping around.
+// Keys with the same storage.HashTag have the same owner. It returns "" for an empty ring.
+func (r *Ring) Owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+
+	hash := xxhash.Sum64String(storage.HashTag(key))
+	i, _ := slices.BinarySearchFunc(r.points, hash, func(p point, hash uint64) int {
+		return cmp.Compare(p.hash, hash)
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].node
+}
+
+// Nodes returns every node on the ring in sorted order.
+func (r *Ring) Nodes() []string {
+	return r.nodes
+}
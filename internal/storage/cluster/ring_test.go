@@ -0,0 +1,88 @@
+package cluster
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRing_Owner(t *testing.T) {
+	nodes := []string{"10.0.0.1:7946", "10.0.0.2:7946", "10.0.0.3:7946"}
+
+	tests := []struct {
+		name string
+		a    string
+		b    string
+	}{
+		{name: "same key", a: "user:1", b: "user:1"},
+		{name: "same hash tag", a: "{org:1}:user:1", b: "{org:1}:user:2"},
+		{name: "hash tag and bare key", a: "{user:1}:1h0m0s", b: "user:1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring := NewRing(nodes, DefaultReplicas)
+			if ring.Owner(tt.a) != ring.Owner(tt.b) {
+				t.Errorf("Owner(%q) = %s, Owner(%q) = %s, want the same node", tt.a, ring.Owner(tt.a), tt.b, ring.Owner(tt.b))
+			}
+		})
+	}
+}
+
+func TestRing_SameOnEveryNode(t *testing.T) {
+	// Every member builds the ring from discovery results in its own order
+	a := NewRing([]string{"n1", "n2", "n3"}, DefaultReplicas)
+	b := NewRing([]string{"n3", "n1", "n2", "n1"}, DefaultReplicas)
+
+	for i := range 1000 {
+		key := fmt.Sprintf("user:%d", i)
+		if a.Owner(key) != b.Owner(key) {
+			t.Fatalf("Owner(%q) = %s and %s, want the same node", key, a.Owner(key), b.Owner(key))
+		}
+	}
+}
+
+func TestRing_Balance(t *testing.T) {
+	nodes := []string{"n1", "n2", "n3", "n4"}
+	ring := NewRing(nodes, DefaultReplicas)
+
+	const keys = 40000
+	counts := make(map[string]int)
+	for i := range keys {
+		counts[ring.Owner(fmt.Sprintf("user:%d", i))]++
+	}
+
+	// Each node should own roughly a quarter of the keys
+	for _, node := range nodes {
+		if share := float64(counts[node]) / keys; share < 0.15 || share > 0.35 {
+			t.Errorf("node %s owns %.2f of the keys, want about 0.25", node, share)
+		}
+	}
+}
+
+func TestRing_MinimalMovement(t *testing.T) {
+	before := NewRing([]string{"n1", "n2", "n3"}, DefaultReplicas)
+	after := NewRing([]string{"n1", "n2", "n3", "n4"}, DefaultReplicas)
+
+	const keys = 10000
+	moved := 0
+	for i := range keys {
+		key := fmt.Sprintf("user:%d", i)
+		if before.Owner(key) == after.Owner(key) {
+			continue
+		}
+		// Keys only move to the node that joined
+		if after.Owner(key) != "n4" {
+			t.Fatalf("key %s moved from %s to %s, want n4", key, before.Owner(key), after.Owner(key))
+		}
+		moved++
+	}
+	if share := float64(moved) / keys; share > 0.4 {
+		t.Errorf("%.2f of the keys moved, want about 0.25", share)
+	}
+}
+
+func TestRing_Empty(t *testing.T) {
+	if got := NewRing(nil, DefaultReplicas).Owner("user:1"); got != "" {
+		t.Errorf("Owner() = %q, want empty", got)
+	}
+}
@@ -5,4 +5,20 @@ import "errors"
 var (
 	// ErrKeyNotFound will be returned when a given identifier does not have a corresponding value
 	ErrKeyNotFound = errors.New("key not found")
+	// ErrUnsupportedAlgorithm will be returned when a backend does not implement the requested algorithm
+	ErrUnsupportedAlgorithm = errors.New("algorithm not supported by storage backend")
+	// ErrLimitTooHigh will be returned when a limit exceeds what the algorithm can track, e.g. MaxSlidingLogLimit
+	ErrLimitTooHigh = errors.New("limit too high for algorithm")
+	// ErrKeysNotColocated will be returned when keys checked together are stored on different nodes, shards or Redis Cluster slots
+	ErrKeysNotColocated = errors.New("keys checked together must share a hash tag")
+	// ErrStorageUnavailable will be returned when the storage, or the shard holding a key, is unavailable and set to FailError
+	ErrStorageUnavailable = errors.New("storage unavailable")
+	// ErrPolicyNotFound will be returned when no policy has the given name
+	ErrPolicyNotFound = errors.New("policy not found")
+	// ErrPolicyExists will be returned when creating a policy whose name is already taken
+	ErrPolicyExists = errors.New("policy already exists")
+	// ErrOverrideNotFound will be returned when no override has the given pattern
+	ErrOverrideNotFound = errors.New("override not found")
+	// ErrLeaseNotFound will be returned when a lease was never granted, has been released or has expired
+	ErrLeaseNotFound = errors.New("lease not found")
 )
@@ -0,0 +1,28 @@
+package storage
+
+// FailureMode decides how a check is answered while the storage behind it is unavailable.
+type FailureMode string
+
+const (
+	// FailError returns ErrStorageUnavailable.
+	FailError FailureMode = "error"
+	// FailOpen allows every check without counting it, favouring availability over enforcement.
+	FailOpen FailureMode = "open"
+	// FailClosed denies every check, favouring protection of what is behind the limiter.
+	FailClosed FailureMode = "closed"
+	// FailLocal counts checks in the process's own memory, approximating the shared limit.
+	FailLocal FailureMode = "local"
+)
+
+// FailureModes lists every supported failure mode.
+var FailureModes = []FailureMode{FailError, FailOpen, FailClosed, FailLocal}
+
+// Valid reports whether m is a known failure mode.
+func (m FailureMode) Valid() bool {
+	for _, known := range FailureModes {
+		if m == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package storage
+
+import "strings"
+
+// HashTag returns the part of key that decides where a clustered backend stores it. Like Redis Cluster, when key
+// contains a non-empty "{...}" only the text between the first "{" and the next "}" is used, so "{org:1}:user:7"
+// and "{org:1}" are stored together. Otherwise the whole key is used.
+func HashTag(key string) string {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return key
+	}
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return key
+	}
+	return key[start+1 : start+1+end]
+}
+
+// SubKey returns the key something derived from key is counted under, such as a tier or a calendar period.
+// It keeps key's hash tag, wrapping key in "{...}" when it has none, so "user:1" and "{user:1}:1h0m0s" are stored
+// together and can be checked atomically.
+func SubKey(key, suffix string) string {
+	if HashTag(key) == key && !strings.Contains(key, "}") {
+		key = "{" + key + "}"
+	}
+	return key + ":" + suffix
+}
@@ -0,0 +1,49 @@
+package storage
+
+import "testing"
+
+func TestHashTag(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		want string
+	}{
+		{name: "no tag", key: "user:1", want: "user:1"},
+		{name: "tag", key: "{org:1}:user:7", want: "org:1"},
+		{name: "first tag wins", key: "{a}{b}", want: "a"},
+		{name: "empty tag", key: "{}user:1", want: "{}user:1"},
+		{name: "unclosed tag", key: "user:{1", want: "user:{1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HashTag(tt.key); got != tt.want {
+				t.Errorf("HashTag(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubKey(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		want string
+	}{
+		{name: "wraps key without tag", key: "user:1", want: "{user:1}:1h0m0s"},
+		{name: "keeps existing tag", key: "{org:1}:user:7", want: "{org:1}:user:7:1h0m0s"},
+		{name: "nested sub key", key: "{user:1}:1h0m0s", want: "{user:1}:1h0m0s:1h0m0s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SubKey(tt.key, "1h0m0s")
+			if got != tt.want {
+				t.Errorf("SubKey(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+			if HashTag(got) != HashTag(tt.key) {
+				t.Errorf("HashTag(%q) = %q, want %q", got, HashTag(got), HashTag(tt.key))
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"time"
+)
+
+// Lease reports the outcome of acquiring or renewing a concurrency lease.
+// Allowed is whether the lease is held and Remaining is how many more leases the key can grant.
+// When a lease is denied, RetryAfter is how long until the oldest lease expires unless it is released or renewed first.
+type Lease struct {
+	Result
+	// ID identifies the lease for RenewLease and ReleaseLease. Empty if the lease was denied.
+	ID string
+	// ExpiresAt is when the lease is reclaimed unless it is renewed first.
+	ExpiresAt time.Time
+}
+
+// LeaseStorage is implemented by backends that can cap the number of in-flight requests per key.
+// Leases are kept apart from rate limit state, so a key can have both a rate limit and a concurrency limit.
+// Every lease expires limit.Window after it was acquired or last renewed, so leases held by crashed clients are reclaimed.
+type LeaseStorage interface {
+	// AcquireLease grants a lease on key if fewer than limit.Limit unexpired leases are held.
+	AcquireLease(ctx context.Context, key string, limit Limit) (*Lease, error)
+	// RenewLease extends a lease to limit.Window from now. It returns ErrLeaseNotFound if the lease is not held.
+	RenewLease(ctx context.Context, key, leaseID string, limit Limit) (*Lease, error)
+	// ReleaseLease frees a lease for another request. It returns ErrLeaseNotFound if the lease is not held.
+	ReleaseLease(ctx context.Context, key, leaseID string) error
+}
+
+// NewLeaseID returns a random lease ID that other clients cannot guess.
+func NewLeaseID() string {
+	return rand.Text()
+}
@@ -0,0 +1,314 @@
+// Package local approximates shared rate limits on a single node while the shared storage is unavailable.
+//
+// Every node enforces its share of each limit, limit / nodes, in an in-process token bucket, so together the
+// nodes allow about as much as the shared limit would. Once the shared storage recovers, what was consumed
+// locally is either charged to it or discarded, as the Recovery says.
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/memory"
+)
+
+// Recovery decides what happens to local counts once the shared storage recovers.
+type Recovery string
+
+const (
+	// Reconcile charges the shared storage with what was consumed locally in the current window, as far as it has
+	// room, then starts over locally.
+	Reconcile Recovery = "reconcile"
+	// Discard drops local counts, so every key goes on from its shared state.
+	Discard Recovery = "discard"
+)
+
+// Recoveries lists every supported recovery.
+var Recoveries = []Recovery{Reconcile, Discard}
+
+// minPrune is how many keys must have been consumed before stale usage is pruned.
+const minPrune = 1024
+
+// LocalStorage implements storage.RateLimitStorage, storage.BatchStorage and storage.LeaseStorage by enforcing
+// this node's share of every limit in memory. Every algorithm is approximated by a token bucket holding the share
+// and refilling it every window, and concurrency limits hold their share of leases. Every node gets at least 1.
+// Results report the share as their Limit.
+type LocalStorage struct {
+	counts   *memory.MemoryStorage
+	nodes    func() int
+	recovery Recovery
+	now      func() time.Time
+
+	mutex   sync.Mutex
+	usage   map[string]*usage
+	pruneAt int
+}
+
+// usage is what was consumed locally from one key's shared limit.
+type usage struct {
+	limit    storage.Limit
+	consumed int64
+	since    time.Time
+}
+
+// Option configures a LocalStorage.
+type Option func(*LocalStorage)
+
+// WithNodes sets how many nodes share every limit, read again on every check so it can follow cluster membership.
+// The default is 1.
+func WithNodes(nodes func() int) Option {
+	return func(ls *LocalStorage) {
+		if nodes != nil {
+			ls.nodes = nodes
+		}
+	}
+}
+
+// WithRecovery sets what happens to local counts once the shared storage recovers. The default is Reconcile.
+func WithRecovery(recovery Recovery) Option {
+	return func(ls *LocalStorage) {
+		if recovery != "" {
+			ls.recovery = recovery
+		}
+	}
+}
+
+// WithClock replaces time.Now as the source of the current time.
+func WithClock(now func() time.Time) Option {
+	return func(ls *LocalStorage) {
+		if now != nil {
+			ls.now = now
+		}
+	}
+}
+
+// NewLocalStorage returns an empty LocalStorage.
+func NewLocalStorage(opts ...Option) (*LocalStorage, error) {
+	ls := &LocalStorage{
+		nodes:    func() int { return 1 },
+		recovery: Reconcile,
+		now:      time.Now,
+		usage:    make(map[string]*usage),
+		pruneAt:  minPrune,
+	}
+	for _, opt := range opts {
+		opt(ls)
+	}
+	if !slices.Contains(Recoveries, ls.recovery) {
+		return nil, fmt.Errorf("unsupported recovery %q", ls.recovery)
+	}
+	ls.counts = memory.NewMemoryStorage(memory.WithClock(ls.now))
+	return ls, nil
+}
+
+// CheckAndUpdate takes cost from this node's share of key's limit.
+func (ls *LocalStorage) CheckAndUpdate(ctx context.Context, key string, limit storage.Limit, cost int64) (*storage.Result, error) {
+	result, err := ls.counts.CheckAndUpdate(ctx, key, ls.share(limit), cost)
+	if err != nil {
+		return nil, err
+	}
+	if result.Allowed {
+		ls.consume(key, limit, cost)
+	}
+	return result, nil
+}
+
+// CheckAndUpdateAll takes cost from this node's share of every key's limit only if every share has room.
+func (ls *LocalStorage) CheckAndUpdateAll(ctx context.Context, checks []storage.Check) ([]*storage.Result, error) {
+	results, err := ls.counts.CheckAndUpdateAll(ctx, ls.shares(checks))
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range results {
+		if !result.Allowed {
+			return results, nil
+		}
+	}
+	for _, check := range checks {
+		ls.consume(check.Key, check.Limit, check.Cost)
+	}
+	return results, nil
+}
+
+// CheckAndUpdateBatch takes cost from this node's share of every key's limit on its own.
+func (ls *LocalStorage) CheckAndUpdateBatch(ctx context.Context, checks []storage.Check) ([]*storage.Result, error) {
+	results, err := ls.counts.CheckAndUpdateBatch(ctx, ls.shares(checks))
+	if err != nil {
+		return nil, err
+	}
+	for i, result := range results {
+		if result.Allowed {
+			ls.consume(checks[i].Key, checks[i].Limit, checks[i].Cost)
+		}
+	}
+	return results, nil
+}
+
+// GetStatus reads this node's share of key's limit.
+func (ls *LocalStorage) GetStatus(ctx context.Context, key string, limit storage.Limit) (*storage.Result, error) {
+	return ls.counts.GetStatus(ctx, key, ls.share(limit))
+}
+
+// Refund returns consumed cost to this node's share of key's limit.
+func (ls *LocalStorage) Refund(ctx context.Context, key string, limit storage.Limit, amount int64) (*storage.Result, error) {
+	result, err := ls.counts.Refund(ctx, key, ls.share(limit), amount)
+	if err != nil {
+		return nil, err
+	}
+	ls.consume(key, limit, -amount)
+	return result, nil
+}
+
+// Reset clears key locally.
+func (ls *LocalStorage) Reset(ctx context.Context, key string) error {
+	ls.mutex.Lock()
+	delete(ls.usage, key)
+	ls.mutex.Unlock()
+
+	return ls.counts.Reset(ctx, key)
+}
+
+// AcquireLease grants a lease on key if fewer than this node's share of leases are held.
+func (ls *LocalStorage) AcquireLease(ctx context.Context, key string, limit storage.Limit) (*storage.Lease, error) {
+	return ls.counts.AcquireLease(ctx, key, ls.leaseShare(limit))
+}
+
+// RenewLease extends a lease granted by this node.
+func (ls *LocalStorage) RenewLease(ctx context.Context, key, leaseID string, limit storage.Limit) (*storage.Lease, error) {
+	return ls.counts.RenewLease(ctx, key, leaseID, ls.leaseShare(limit))
+}
+
+// ReleaseLease frees a lease granted by this node.
+func (ls *LocalStorage) ReleaseLease(ctx context.Context, key, leaseID string) error {
+	return ls.counts.ReleaseLease(ctx, key, leaseID)
+}
+
+// Recover hands the local counts back to the shared storage next as the Recovery says, and starts over locally.
+// Leases aren't handed back; they expire on their own.
+func (ls *LocalStorage) Recover(ctx context.Context, next storage.RateLimitStorage) error {
+	ls.mutex.Lock()
+	usages := ls.usage
+	ls.usage, ls.pruneAt = make(map[string]*usage), minPrune
+	ls.mutex.Unlock()
+
+	now := ls.now()
+	var errs []error
+	var consumed, charged int64
+	short := 0
+	for key, u := range usages {
+		_ = ls.counts.Reset(ctx, key)
+		if ls.recovery != Reconcile || u.consumed <= 0 || !u.current(now) {
+			continue
+		}
+		n, err := reconcile(ctx, next, key, u)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("key %s: %w", key, err))
+			continue
+		}
+		if n < u.consumed {
+			consumed, charged, short = consumed+u.consumed, charged+n, short+1
+		}
+	}
+	if short > 0 {
+		log.Printf("local: charged only %d of %d consumed locally on %d keys, as their shared limits had no more room", charged, consumed, short)
+	}
+	return errors.Join(errs...)
+}
+
+// Close releases the local counts.
+func (ls *LocalStorage) Close() error {
+	return ls.counts.Close()
+}
+
+// reconcile charges key on the shared storage with what was consumed locally, as far as its limit has room, and
+// returns how much it charged. Live traffic can take the room between reading and charging the key, in which case
+// the charge is denied and what is left is charged instead. The charge goes through CheckAndUpdateAll, which
+// consumes nothing when denied, where CheckAndUpdate on a fixed window would still count it.
+func reconcile(ctx context.Context, next storage.RateLimitStorage, key string, u *usage) (int64, error) {
+	status, err := next.GetStatus(ctx, key, u.limit)
+	if err != nil {
+		return 0, err
+	}
+	charge := min(u.consumed, status.Remaining)
+	for range 2 {
+		if charge <= 0 {
+			return 0, nil
+		}
+		results, err := next.CheckAndUpdateAll(ctx, []storage.Check{{Key: key, Limit: u.limit, Cost: charge}})
+		if err != nil {
+			return 0, err
+		}
+		if results[0].Allowed {
+			return charge, nil
+		}
+		charge = min(charge, results[0].Remaining)
+	}
+	return 0, nil
+}
+
+// share returns the token bucket enforcing this node's share of limit.
+func (ls *LocalStorage) share(limit storage.Limit) storage.Limit {
+	nodes := int64(max(1, ls.nodes()))
+	return storage.Limit{
+		Algorithm:  storage.TokenBucket,
+		Limit:      max(1, limit.Limit/nodes),
+		Window:     limit.Window,
+		RefillRate: max(1, limit.Refill()/nodes),
+	}
+}
+
+// shares returns checks against this node's share of their limits.
+func (ls *LocalStorage) shares(checks []storage.Check) []storage.Check {
+	shared := make([]storage.Check, len(checks))
+	for i, check := range checks {
+		shared[i] = storage.Check{Key: check.Key, Limit: ls.share(check.Limit), Cost: check.Cost}
+	}
+	return shared
+}
+
+// leaseShare returns limit with this node's share of its leases.
+func (ls *LocalStorage) leaseShare(limit storage.Limit) storage.Limit {
+	limit.Limit = max(1, limit.Limit/int64(max(1, ls.nodes())))
+	return limit
+}
+
+// consume records cost taken from key's shared limit, or given back if negative.
+func (ls *LocalStorage) consume(key string, limit storage.Limit, cost int64) {
+	now := ls.now()
+
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+
+	u, ok := ls.usage[key]
+	if !ok || u.limit != limit || !u.current(now) {
+		// Cost consumed in an earlier window no longer counts against the shared limit
+		u = &usage{limit: limit, since: now}
+		ls.usage[key] = u
+	}
+	u.consumed = max(0, u.consumed+cost)
+
+	if len(ls.usage) >= ls.pruneAt {
+		ls.prune(now)
+	}
+}
+
+// prune drops usage from earlier windows. Callers must hold the mutex.
+func (ls *LocalStorage) prune(now time.Time) {
+	for key, u := range ls.usage {
+		if !u.current(now) {
+			delete(ls.usage, key)
+		}
+	}
+	ls.pruneAt = max(minPrune, 2*len(ls.usage))
+}
+
+// current reports whether the usage started within the last window of its limit.
+func (u *usage) current(now time.Time) bool {
+	return now.Sub(u.since) < u.limit.Window
+}
@@ -0,0 +1,198 @@
+package local
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/memory"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/storagetest"
+)
+
+func TestLocalStorage_Share(t *testing.T) {
+	tests := []struct {
+		name        string
+		nodes       int
+		limit       storage.Limit
+		wantAllowed int
+	}{
+		{name: "single node", nodes: 1, limit: storage.Limit{Limit: 8, Window: time.Minute}, wantAllowed: 8},
+		{name: "split between nodes", nodes: 4, limit: storage.Limit{Limit: 8, Window: time.Minute}, wantAllowed: 2},
+		{name: "rounds down", nodes: 3, limit: storage.Limit{Limit: 8, Window: time.Minute}, wantAllowed: 2},
+		{name: "at least one", nodes: 10, limit: storage.Limit{Limit: 8, Window: time.Minute}, wantAllowed: 1},
+		{name: "no nodes counts as one", nodes: 0, limit: storage.Limit{Limit: 8, Window: time.Minute}, wantAllowed: 8},
+		{name: "any algorithm", nodes: 2, limit: storage.Limit{Algorithm: storage.SlidingWindowLog, Limit: 8, Window: time.Minute}, wantAllowed: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := storagetest.NewFakeClock(time.Unix(0, 0))
+			ls, err := NewLocalStorage(WithNodes(func() int { return tt.nodes }), WithClock(clock.Now))
+			if err != nil {
+				t.Fatalf("NewLocalStorage() error = %v", err)
+			}
+			defer ls.Close()
+
+			allowed := 0
+			for range 10 {
+				result, err := ls.CheckAndUpdate(context.Background(), "user:1", tt.limit, 1)
+				if err != nil {
+					t.Fatalf("CheckAndUpdate() error = %v", err)
+				}
+				if result.Allowed {
+					allowed++
+				}
+			}
+			if allowed != tt.wantAllowed {
+				t.Errorf("allowed %d of 10, want %d", allowed, tt.wantAllowed)
+			}
+
+			// The share refills every window
+			clock.Sleep(tt.limit.Window)
+			result, err := ls.CheckAndUpdate(context.Background(), "user:1", tt.limit, 1)
+			if err != nil || !result.Allowed {
+				t.Errorf("CheckAndUpdate() after a window = %+v, %v, want allowed", result, err)
+			}
+		})
+	}
+}
+
+func TestLocalStorage_Recover(t *testing.T) {
+	limit := storage.Limit{Limit: 10, Window: time.Minute}
+
+	tests := []struct {
+		name string
+		// shared is the cost already consumed on the shared storage, local the cost consumed locally,
+		// and live the cost live traffic consumes on the shared storage while it is being reconciled
+		shared, local, live int64
+		recovery            Recovery
+		elapsed             time.Duration
+		// wantConsumed is the cost the shared storage holds afterwards, which never goes over the limit
+		wantConsumed int64
+	}{
+		{name: "reconcile", local: 3, recovery: Reconcile, wantConsumed: 3},
+		{name: "reconcile as far as there is room", shared: 8, local: 3, recovery: Reconcile, wantConsumed: 10},
+		{name: "reconcile what live traffic left", shared: 5, local: 4, live: 3, recovery: Reconcile, wantConsumed: 10},
+		{name: "reconcile skips earlier windows", local: 3, recovery: Reconcile, elapsed: time.Minute, wantConsumed: 0},
+		{name: "discard", local: 3, recovery: Discard, wantConsumed: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			clock := storagetest.NewFakeClock(time.Unix(0, 0))
+			shared := memory.NewMemoryStorage(memory.WithClock(clock.Now))
+			defer shared.Close()
+			ls, err := NewLocalStorage(WithRecovery(tt.recovery), WithClock(clock.Now))
+			if err != nil {
+				t.Fatalf("NewLocalStorage() error = %v", err)
+			}
+			defer ls.Close()
+
+			if tt.shared > 0 {
+				if _, err := shared.CheckAndUpdate(ctx, "user:1", limit, tt.shared); err != nil {
+					t.Fatalf("CheckAndUpdate() on shared error = %v", err)
+				}
+			}
+			for range tt.local {
+				if _, err := ls.CheckAndUpdate(ctx, "user:1", limit, 1); err != nil {
+					t.Fatalf("CheckAndUpdate() error = %v", err)
+				}
+			}
+			// A refund gives back what it returns
+			if _, err := ls.CheckAndUpdate(ctx, "user:1", limit, 1); err != nil {
+				t.Fatalf("CheckAndUpdate() error = %v", err)
+			}
+			if _, err := ls.Refund(ctx, "user:1", limit, 1); err != nil {
+				t.Fatalf("Refund() error = %v", err)
+			}
+			clock.Sleep(tt.elapsed)
+
+			if err := ls.Recover(ctx, &liveStorage{MemoryStorage: shared, live: tt.live}); err != nil {
+				t.Fatalf("Recover() error = %v", err)
+			}
+
+			status, err := shared.GetStatus(ctx, "user:1", limit)
+			if err != nil {
+				t.Fatalf("GetStatus() error = %v", err)
+			}
+			if want := limit.Limit - tt.wantConsumed; status.Remaining != want {
+				t.Errorf("shared remaining = %d, want %d", status.Remaining, want)
+			}
+			// Remaining stops at zero, so refunding what should be consumed tells whether more was
+			if tt.wantConsumed > 0 {
+				status, err := shared.Refund(ctx, "user:1", limit, tt.wantConsumed)
+				if err != nil || status.Remaining != limit.Limit {
+					t.Errorf("shared Refund(%d) = %+v, %v, want %d remaining", tt.wantConsumed, status, err, limit.Limit)
+				}
+			}
+
+			// Local counts start over either way
+			status, err = ls.GetStatus(ctx, "user:1", limit)
+			if err != nil || status.Remaining != limit.Limit {
+				t.Errorf("local GetStatus() after Recover() = %+v, %v, want %d remaining", status, err, limit.Limit)
+			}
+		})
+	}
+}
+
+func TestLocalStorage_CheckAndUpdateAll(t *testing.T) {
+	clock := storagetest.NewFakeClock(time.Unix(0, 0))
+	shared := memory.NewMemoryStorage(memory.WithClock(clock.Now))
+	defer shared.Close()
+	ls, err := NewLocalStorage(WithNodes(func() int { return 2 }), WithClock(clock.Now))
+	if err != nil {
+		t.Fatalf("NewLocalStorage() error = %v", err)
+	}
+	defer ls.Close()
+
+	ctx := context.Background()
+	checks := []storage.Check{
+		{Key: "{user:1}", Limit: storage.Limit{Limit: 10, Window: time.Minute}, Cost: 2},
+		{Key: "{user:1}:1h0m0s", Limit: storage.Limit{Limit: 4, Window: time.Hour}, Cost: 2},
+	}
+
+	// The second check's share of 2 only has room once
+	for i, wantAllowed := range []bool{true, false} {
+		results, err := ls.CheckAndUpdateAll(ctx, checks)
+		if err != nil {
+			t.Fatalf("CheckAndUpdateAll() #%d error = %v", i, err)
+		}
+		if got := results[1].Allowed; got != wantAllowed {
+			t.Errorf("CheckAndUpdateAll() #%d allowed = %v, want %v", i, got, wantAllowed)
+		}
+	}
+
+	// Only the allowed check is reconciled
+	if err := ls.Recover(ctx, shared); err != nil {
+		t.Fatalf("Recover() error = %v", err)
+	}
+	for i, want := range []int64{8, 2} {
+		status, err := shared.GetStatus(ctx, checks[i].Key, checks[i].Limit)
+		if err != nil {
+			t.Fatalf("GetStatus() error = %v", err)
+		}
+		if status.Remaining != want {
+			t.Errorf("shared remaining of %s = %d, want %d", checks[i].Key, status.Remaining, want)
+		}
+	}
+}
+
+// liveStorage is a shared storage where live traffic consumes live on a key right after it is first read.
+type liveStorage struct {
+	*memory.MemoryStorage
+	live int64
+}
+
+func (ls *liveStorage) GetStatus(ctx context.Context, key string, limit storage.Limit) (*storage.Result, error) {
+	status, err := ls.MemoryStorage.GetStatus(ctx, key, limit)
+	if err != nil || ls.live == 0 {
+		return status, err
+	}
+	if _, err := ls.MemoryStorage.CheckAndUpdate(ctx, key, limit, ls.live); err != nil {
+		return nil, err
+	}
+	ls.live = 0
+	return status, nil
+}
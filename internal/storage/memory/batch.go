@@ -0,0 +1,114 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// CheckAndUpdateBatch checks every key in order. There is no round trip to save in memory,
+// so it exists to give callers the same batch semantics as the other backends.
+func (ms *MemoryStorage) CheckAndUpdateBatch(ctx context.Context, checks []storage.Check) ([]*storage.Result, error) {
+	results := make([]*storage.Result, len(checks))
+	for i, check := range checks {
+		result, err := ms.CheckAndUpdate(ctx, check.Key, check.Limit, check.Cost)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+// CheckAndUpdateAll checks every key while holding all of their shards, keeping the updates only if every check
+// is allowed. Each key's state is copied before its check so a denied batch can be rolled back.
+func (ms *MemoryStorage) CheckAndUpdateAll(ctx context.Context, checks []storage.Check) ([]*storage.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	now := ms.now()
+	unlock := ms.lockShards(checks)
+	defer unlock()
+
+	// Run every check, saving each key's state first
+	saved := make([]savedEntry, len(checks))
+	results := make([]*storage.Result, len(checks))
+	allowed := true
+	for i, check := range checks {
+		s := ms.getShard(check.Key)
+		saved[i] = save(s, check.Key)
+
+		result, err := s.check(check.Key, check.Limit, check.Cost, now)
+		if err != nil {
+			restore(saved[:i+1])
+			return nil, err
+		}
+		results[i] = result
+		allowed = allowed && result.Allowed
+	}
+	if allowed {
+		return results, nil
+	}
+
+	restore(saved)
+
+	// Report each key's unchanged state along with its own verdict
+	for i, check := range checks {
+		status, err := ms.getShard(check.Key).status(check.Key, check.Limit, now)
+		if err != nil {
+			return nil, err
+		}
+		status.Allowed, status.RetryAfter = results[i].Allowed, results[i].RetryAfter
+		results[i] = status
+	}
+	return results, nil
+}
+
+// lockShards locks the shard of every check once, in index order so concurrent callers can't deadlock.
+// It returns a function that unlocks them.
+func (ms *MemoryStorage) lockShards(checks []storage.Check) func() {
+	indexes := make([]int, 0, len(checks))
+	for _, check := range checks {
+		indexes = append(indexes, ms.shardIndex(check.Key))
+	}
+	slices.Sort(indexes)
+	indexes = slices.Compact(indexes)
+
+	for _, i := range indexes {
+		ms.shards[i].mutex.Lock()
+	}
+	return func() {
+		for _, i := range indexes {
+			ms.shards[i].mutex.Unlock()
+		}
+	}
+}
+
+// savedEntry is a copy of a key's state taken before it was checked.
+type savedEntry struct {
+	shard *shard
+	key   string
+	entry entry
+}
+
+// save copies the state of key. Callers must hold the shard mutex.
+func save(s *shard, key string) savedEntry {
+	saved := savedEntry{shard: s, key: key}
+	if e, ok := s.entries[key]; ok {
+		saved.entry = e.clone()
+	}
+	return saved
+}
+
+// restore puts saved state back, newest first. Callers must hold the shard mutexes.
+func restore(saved []savedEntry) {
+	for i := len(saved) - 1; i >= 0; i-- {
+		if saved[i].entry == nil {
+			delete(saved[i].shard.entries, saved[i].key)
+		} else {
+			saved[i].shard.entries[saved[i].key] = saved[i].entry
+		}
+	}
+}
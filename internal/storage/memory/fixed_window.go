@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// windowEntry is a fixed-window counter.
+type windowEntry struct {
+	count     int64
+	expiresAt time.Time
+}
+
+// expired reports whether the window has ended at the given time.
+func (e *windowEntry) expired(now time.Time) bool {
+	return !now.Before(e.expiresAt)
+}
+
+// clone returns a copy of the counter.
+func (e *windowEntry) clone() entry {
+	c := *e
+	return &c
+}
+
+// checkFixedWindow increments the window counter by cost. Callers must hold the shard mutex.
+func (s *shard) checkFixedWindow(key string, limit storage.Limit, cost int64, now time.Time) *storage.Result {
+	// Start a new window if the key is missing, expired or used by another algorithm
+	e, ok := s.entries[key].(*windowEntry)
+	if !ok || e.expired(now) {
+		e = &windowEntry{expiresAt: now.Add(limit.Window)}
+		s.entries[key] = e
+	}
+
+	// Like Redis INCRBY, denied requests are still counted
+	e.count += cost
+
+	return buildFixedWindowResult(e.count, limit.Limit, e.expiresAt, now)
+}
+
+// statusFixedWindow reads the window counter without modifying it. Callers must hold the shard mutex.
+func (s *shard) statusFixedWindow(key string, limit storage.Limit, now time.Time) *storage.Result {
+	e, ok := s.entries[key].(*windowEntry)
+	if !ok || e.expired(now) {
+		// Key doesn't exist so just give default result
+		return buildFixedWindowResult(0, limit.Limit, now, now)
+	}
+
+	return buildFixedWindowResult(e.count, limit.Limit, e.expiresAt, now)
+}
+
+// refundFixedWindow takes up to amount off the window counter, leaving an expired window alone.
+// Callers must hold the shard mutex.
+func (s *shard) refundFixedWindow(key string, limit storage.Limit, amount int64, now time.Time) *storage.Result {
+	if e, ok := s.entries[key].(*windowEntry); ok && !e.expired(now) {
+		e.count -= min(amount, e.count)
+	}
+
+	return s.statusFixedWindow(key, limit, now)
+}
+
+// buildFixedWindowResult converts a counter value and its window end into a storage.Result.
+func buildFixedWindowResult(count, limit int64, resetAt, now time.Time) *storage.Result {
+	allowed := count <= limit
+
+	// Calculate remaining tokens
+	remaining := limit - count
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	// A denied request has to wait for the next window
+	var retryAfter time.Duration
+	if !allowed {
+		retryAfter = resetAt.Sub(now)
+	}
+
+	return &storage.Result{
+		Allowed:    allowed,
+		Remaining:  remaining,
+		ResetAt:    resetAt,
+		Limit:      limit,
+		RetryAfter: retryAfter,
+	}
+}
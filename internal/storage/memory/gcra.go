@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/algorithms/gcra"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// gcraEntry is a GCRA limiter along with the limit it was created for.
+type gcraEntry struct {
+	limiter *gcra.GCRA
+	limit   storage.Limit
+}
+
+// expired reports whether the theoretical arrival time has passed, at which point the limiter is equivalent to a new one.
+func (e *gcraEntry) expired(now time.Time) bool {
+	return !now.Before(e.limiter.FullAt(now))
+}
+
+// clone returns a copy of the limiter in its current state.
+func (e *gcraEntry) clone() entry {
+	return &gcraEntry{limiter: e.limiter.Clone(), limit: e.limit}
+}
+
+// checkGCRA records a request of the given cost if it conforms. Callers must hold the shard mutex.
+func (s *shard) checkGCRA(key string, limit storage.Limit, cost int64, now time.Time) *storage.Result {
+	// Start a new limiter if the key is missing, used by another algorithm or configured differently
+	e, ok := s.entries[key].(*gcraEntry)
+	if !ok || e.limit != limit {
+		e = &gcraEntry{
+			limiter: gcra.NewGCRA(limit.Limit, limit.Window),
+			limit:   limit,
+		}
+		s.entries[key] = e
+	}
+
+	allowed, remaining, retryAfter := e.limiter.AllowAt(cost, now)
+
+	return &storage.Result{
+		Allowed:    allowed,
+		Remaining:  remaining,
+		ResetAt:    e.limiter.FullAt(now),
+		Limit:      limit.Limit,
+		RetryAfter: retryAfter,
+	}
+}
+
+// statusGCRA reads the remaining burst without recording a request. Callers must hold the shard mutex.
+func (s *shard) statusGCRA(key string, limit storage.Limit, now time.Time) *storage.Result {
+	e, ok := s.entries[key].(*gcraEntry)
+	if !ok || e.limit != limit {
+		// Key doesn't exist so the whole burst is available
+		return &storage.Result{
+			Allowed:   true,
+			Remaining: limit.Limit,
+			ResetAt:   now,
+			Limit:     limit.Limit,
+		}
+	}
+
+	remaining := e.limiter.RemainingAt(now)
+
+	return &storage.Result{
+		Allowed:   remaining > 0,
+		Remaining: remaining,
+		ResetAt:   e.limiter.FullAt(now),
+		Limit:     limit.Limit,
+	}
+}
+
+// refundGCRA moves the theoretical arrival time back by amount requests, never before now.
+// Callers must hold the shard mutex.
+func (s *shard) refundGCRA(key string, limit storage.Limit, amount int64, now time.Time) *storage.Result {
+	// A missing limiter already has its whole burst
+	if e, ok := s.entries[key].(*gcraEntry); ok && e.limit == limit {
+		e.limiter.RefundAt(amount, now)
+	}
+
+	return s.statusGCRA(key, limit, now)
+}
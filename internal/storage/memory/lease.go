@@ -0,0 +1,135 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// leaseSet maps the ID of every lease held on a key to when it expires.
+type leaseSet map[string]time.Time
+
+// trim drops expired leases and returns how many are still held.
+func (l leaseSet) trim(now time.Time) int64 {
+	for id, expiresAt := range l {
+		if !now.Before(expiresAt) {
+			delete(l, id)
+		}
+	}
+	return int64(len(l))
+}
+
+// oldest returns when the first lease expires.
+func (l leaseSet) oldest() time.Time {
+	var oldest time.Time
+	for _, expiresAt := range l {
+		if oldest.IsZero() || expiresAt.Before(oldest) {
+			oldest = expiresAt
+		}
+	}
+	return oldest
+}
+
+// AcquireLease grants a lease on key if fewer than limit.Limit unexpired leases are held
+func (ms *MemoryStorage) AcquireLease(ctx context.Context, key string, limit storage.Limit) (*storage.Lease, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	now := ms.now()
+	s := ms.getShard(key)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	leases, ok := s.leases[key]
+	if !ok {
+		leases = make(leaseSet)
+		s.leases[key] = leases
+	}
+	held := leases.trim(now)
+
+	// A denied lease has to wait for the oldest one to be released or expire
+	if held >= limit.Limit {
+		return &storage.Lease{
+			Result: storage.Result{
+				Allowed:    false,
+				Remaining:  0,
+				ResetAt:    leases.oldest(),
+				Limit:      limit.Limit,
+				RetryAfter: leases.oldest().Sub(now),
+			},
+		}, nil
+	}
+
+	id := storage.NewLeaseID()
+	leases[id] = now.Add(limit.Window)
+
+	return &storage.Lease{
+		Result: storage.Result{
+			Allowed:   true,
+			Remaining: limit.Limit - held - 1,
+			ResetAt:   leases.oldest(),
+			Limit:     limit.Limit,
+		},
+		ID:        id,
+		ExpiresAt: leases[id],
+	}, nil
+}
+
+// RenewLease extends a held lease to limit.Window from now
+func (ms *MemoryStorage) RenewLease(ctx context.Context, key, leaseID string, limit storage.Limit) (*storage.Lease, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	now := ms.now()
+	s := ms.getShard(key)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	leases := s.leases[key]
+	held := leases.trim(now)
+	if _, ok := leases[leaseID]; !ok {
+		return nil, storage.ErrLeaseNotFound
+	}
+	leases[leaseID] = now.Add(limit.Window)
+
+	return &storage.Lease{
+		Result: storage.Result{
+			Allowed:   true,
+			Remaining: max(0, limit.Limit-held),
+			ResetAt:   leases.oldest(),
+			Limit:     limit.Limit,
+		},
+		ID:        leaseID,
+		ExpiresAt: leases[leaseID],
+	}, nil
+}
+
+// ReleaseLease frees a held lease
+func (ms *MemoryStorage) ReleaseLease(ctx context.Context, key, leaseID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	now := ms.now()
+	s := ms.getShard(key)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	leases := s.leases[key]
+	leases.trim(now)
+	if _, ok := leases[leaseID]; !ok {
+		return storage.ErrLeaseNotFound
+	}
+	delete(leases, leaseID)
+
+	if len(leases) == 0 {
+		delete(s.leases, key)
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package memory
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+const (
+	// DefaultShards is the number of lock stripes used when no WithShards option is given.
+	DefaultShards = 32
+	// DefaultCleanupInterval is how often expired keys are removed when no WithCleanupInterval option is given.
+	DefaultCleanupInterval = time.Minute
+)
+
+// MemoryStorage implements storage.RateLimitStorage, storage.BatchStorage, storage.PolicyStorage,
+// storage.OverrideStorage and storage.LeaseStorage in process memory.
+// Keys are spread over mutex-striped shards to reduce lock contention.
+type MemoryStorage struct {
+	shards          []*shard
+	cleanupInterval time.Duration
+	now             func() time.Time
+
+	policyMutex sync.RWMutex
+	policies    map[string]storage.Policy
+
+	overrideMutex sync.RWMutex
+	overrides     map[string]storage.Override
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// shard is a single lock stripe of the key space.
+type shard struct {
+	mutex   sync.Mutex
+	entries map[string]entry
+	leases  map[string]leaseSet
+}
+
+// entry is the per-key state of one algorithm.
+type entry interface {
+	// expired reports whether the entry no longer affects future requests and can be dropped.
+	expired(now time.Time) bool
+	// clone returns a copy that later requests to the original don't change.
+	clone() entry
+}
+
+// Option configures a MemoryStorage.
+type Option func(*MemoryStorage)
+
+// WithShards sets the number of lock stripes.
+func WithShards(n int) Option {
+	return func(ms *MemoryStorage) {
+		if n > 0 {
+			ms.shards = make([]*shard, n)
+		}
+	}
+}
+
+// WithCleanupInterval sets how often expired keys are removed.
+func WithCleanupInterval(d time.Duration) Option {
+	return func(ms *MemoryStorage) {
+		if d > 0 {
+			ms.cleanupInterval = d
+		}
+	}
+}
+
+// WithClock replaces time.Now as the source of the current time.
+func WithClock(now func() time.Time) Option {
+	return func(ms *MemoryStorage) {
+		if now != nil {
+			ms.now = now
+		}
+	}
+}
+
+// NewMemoryStorage returns a MemoryStorage and starts its background cleanup.
+func NewMemoryStorage(opts ...Option) *MemoryStorage {
+	ms := &MemoryStorage{
+		shards:          make([]*shard, DefaultShards),
+		cleanupInterval: DefaultCleanupInterval,
+		now:             time.Now,
+		policies:        make(map[string]storage.Policy),
+		overrides:       make(map[string]storage.Override),
+		stop:            make(chan struct{}),
+		done:            make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(ms)
+	}
+	for i := range ms.shards {
+		ms.shards[i] = &shard{entries: make(map[string]entry), leases: make(map[string]leaseSet)}
+	}
+
+	go ms.cleanupLoop()
+
+	return ms
+}
+
+// CheckAndUpdate checks if a request is allowed and updates the state for the key
+func (ms *MemoryStorage) CheckAndUpdate(ctx context.Context, key string, limit storage.Limit, cost int64) (*storage.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	now := ms.now()
+	s := ms.getShard(key)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.check(key, limit, cost, now)
+}
+
+// GetStatus checks current status without modifying the state for the key
+func (ms *MemoryStorage) GetStatus(ctx context.Context, key string, limit storage.Limit) (*storage.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	now := ms.now()
+	s := ms.getShard(key)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.status(key, limit, now)
+}
+
+// Refund returns up to amount of consumed cost to the key within its current window
+func (ms *MemoryStorage) Refund(ctx context.Context, key string, limit storage.Limit, amount int64) (*storage.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	now := ms.now()
+	s := ms.getShard(key)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.refund(key, limit, amount, now)
+}
+
+// Reset clears the rate limiter for an identifier
+func (ms *MemoryStorage) Reset(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	now := ms.now()
+	s := ms.getShard(key)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		return storage.ErrKeyNotFound
+	}
+	delete(s.entries, key)
+
+	// An expired key is gone as far as callers are concerned
+	if e.expired(now) {
+		return storage.ErrKeyNotFound
+	}
+	return nil
+}
+
+// Close stops the background cleanup. It is safe to call more than once.
+func (ms *MemoryStorage) Close() error {
+	ms.closeOnce.Do(func() {
+		close(ms.stop)
+		<-ms.done
+	})
+	return nil
+}
+
+// cleanupLoop periodically removes expired keys until Close is called.
+func (ms *MemoryStorage) cleanupLoop() {
+	defer close(ms.done)
+
+	ticker := time.NewTicker(ms.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ms.removeExpired()
+		case <-ms.stop:
+			return
+		}
+	}
+}
+
+// removeExpired deletes every expired key and lease, locking one shard at a time.
+func (ms *MemoryStorage) removeExpired() {
+	now := ms.now()
+	for _, s := range ms.shards {
+		s.mutex.Lock()
+		for key, e := range s.entries {
+			if e.expired(now) {
+				delete(s.entries, key)
+			}
+		}
+		for key, leases := range s.leases {
+			if leases.trim(now) == 0 {
+				delete(s.leases, key)
+			}
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// check dispatches a check to the limit's algorithm. Callers must hold the shard mutex.
+func (s *shard) check(key string, limit storage.Limit, cost int64, now time.Time) (*storage.Result, error) {
+	switch limit.Algorithm {
+	case "", storage.FixedWindow:
+		return s.checkFixedWindow(key, limit, cost, now), nil
+	case storage.TokenBucket:
+		return s.checkTokenBucket(key, limit, cost, now), nil
+	case storage.GCRA:
+		return s.checkGCRA(key, limit, cost, now), nil
+	case storage.SlidingWindowLog:
+		return s.checkSlidingLog(key, limit, cost, now)
+	case storage.SlidingWindowCounter:
+		return s.checkSlidingCounter(key, limit, cost, now), nil
+	default:
+		return nil, storage.ErrUnsupportedAlgorithm
+	}
+}
+
+// status dispatches a status read to the limit's algorithm. Callers must hold the shard mutex.
+func (s *shard) status(key string, limit storage.Limit, now time.Time) (*storage.Result, error) {
+	switch limit.Algorithm {
+	case "", storage.FixedWindow:
+		return s.statusFixedWindow(key, limit, now), nil
+	case storage.TokenBucket:
+		return s.statusTokenBucket(key, limit, now), nil
+	case storage.GCRA:
+		return s.statusGCRA(key, limit, now), nil
+	case storage.SlidingWindowLog:
+		return s.statusSlidingLog(key, limit, now)
+	case storage.SlidingWindowCounter:
+		return s.statusSlidingCounter(key, limit, now), nil
+	default:
+		return nil, storage.ErrUnsupportedAlgorithm
+	}
+}
+
+// refund dispatches a refund to the limit's algorithm. Callers must hold the shard mutex.
+func (s *shard) refund(key string, limit storage.Limit, amount int64, now time.Time) (*storage.Result, error) {
+	switch limit.Algorithm {
+	case "", storage.FixedWindow:
+		return s.refundFixedWindow(key, limit, amount, now), nil
+	case storage.TokenBucket:
+		return s.refundTokenBucket(key, limit, amount, now), nil
+	case storage.GCRA:
+		return s.refundGCRA(key, limit, amount, now), nil
+	case storage.SlidingWindowLog:
+		return s.refundSlidingLog(key, limit, amount, now)
+	case storage.SlidingWindowCounter:
+		return s.refundSlidingCounter(key, limit, amount, now), nil
+	default:
+		return nil, storage.ErrUnsupportedAlgorithm
+	}
+}
+
+// getShard returns the shard responsible for key.
+func (ms *MemoryStorage) getShard(key string) *shard {
+	return ms.shards[ms.shardIndex(key)]
+}
+
+// shardIndex returns the index of the shard responsible for key.
+func (ms *MemoryStorage) shardIndex(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(ms.shards)))
+}
@@ -0,0 +1,314 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/storagetest"
+)
+
+func TestMemoryStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) (storage.RateLimitStorage, storagetest.Clock) {
+		clock := storagetest.NewFakeClock(time.Unix(0, 0))
+		return NewMemoryStorage(WithClock(clock.Now)), clock
+	})
+}
+
+func TestMemoryStorage_CheckAndUpdate(t *testing.T) {
+	type checkCall struct {
+		cost          int64
+		advance       time.Duration
+		wantAllowed   bool
+		wantRemaining int64
+	}
+
+	tests := []struct {
+		name  string
+		limit storage.Limit
+		calls []checkCall
+	}{
+		{
+			name:  "allow within limit",
+			limit: storage.Limit{Limit: 5, Window: time.Minute},
+			calls: []checkCall{
+				{cost: 3, wantAllowed: true, wantRemaining: 2},
+				{cost: 2, wantAllowed: true, wantRemaining: 0},
+			},
+		},
+		{
+			name:  "deny over limit",
+			limit: storage.Limit{Limit: 5, Window: time.Minute},
+			calls: []checkCall{
+				{cost: 5, wantAllowed: true, wantRemaining: 0},
+				{cost: 1, wantAllowed: false, wantRemaining: 0},
+			},
+		},
+		{
+			name:  "new window after expiry",
+			limit: storage.Limit{Limit: 5, Window: time.Minute},
+			calls: []checkCall{
+				{cost: 5, wantAllowed: true, wantRemaining: 0},
+				{cost: 1, wantAllowed: false, wantRemaining: 0},
+				{cost: 1, advance: time.Minute, wantAllowed: true, wantRemaining: 4},
+			},
+		},
+		{
+			name:  "denied requests still count",
+			limit: storage.Limit{Limit: 5, Window: time.Minute},
+			calls: []checkCall{
+				{cost: 6, wantAllowed: false, wantRemaining: 0},
+				{cost: 1, advance: 30 * time.Second, wantAllowed: false, wantRemaining: 0},
+			},
+		},
+		{
+			name:  "token bucket denied requests do not consume",
+			limit: storage.Limit{Algorithm: storage.TokenBucket, Limit: 5, Window: time.Minute},
+			calls: []checkCall{
+				{cost: 4, wantAllowed: true, wantRemaining: 1},
+				{cost: 2, wantAllowed: false, wantRemaining: 1},
+				{cost: 1, wantAllowed: true, wantRemaining: 0},
+			},
+		},
+		{
+			name:  "token bucket refills per period",
+			limit: storage.Limit{Algorithm: storage.TokenBucket, Limit: 10, Window: time.Second, RefillRate: 2},
+			calls: []checkCall{
+				{cost: 10, wantAllowed: true, wantRemaining: 0},
+				{cost: 1, advance: 999 * time.Millisecond, wantAllowed: false, wantRemaining: 0},
+				{cost: 3, advance: 1001 * time.Millisecond, wantAllowed: true, wantRemaining: 1},
+				{cost: 1, advance: time.Minute, wantAllowed: true, wantRemaining: 9},
+			},
+		},
+		{
+			name:  "sliding window counter weights previous window",
+			limit: storage.Limit{Algorithm: storage.SlidingWindowCounter, Limit: 10, Window: time.Minute},
+			calls: []checkCall{
+				{cost: 10, wantAllowed: true, wantRemaining: 0},
+				{cost: 1, advance: time.Minute, wantAllowed: false, wantRemaining: 0},
+				{cost: 3, advance: 30 * time.Second, wantAllowed: true, wantRemaining: 2},
+				{cost: 1, advance: 2 * time.Minute, wantAllowed: true, wantRemaining: 9},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := storagetest.NewFakeClock(time.Unix(0, 0))
+			ms := NewMemoryStorage(WithClock(clock.Now))
+			defer ms.Close()
+
+			for i, call := range tt.calls {
+				clock.Sleep(call.advance)
+
+				result, err := ms.CheckAndUpdate(context.Background(), "key", tt.limit, call.cost)
+				if err != nil {
+					t.Fatalf("call %d: unexpected error: %v", i, err)
+				}
+				if result.Allowed != call.wantAllowed {
+					t.Errorf("call %d: got allowed=%v, want %v", i, result.Allowed, call.wantAllowed)
+				}
+				if result.Remaining != call.wantRemaining {
+					t.Errorf("call %d: got remaining=%d, want %d", i, result.Remaining, call.wantRemaining)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStorage_GetStatus(t *testing.T) {
+	clock := storagetest.NewFakeClock(time.Unix(0, 0))
+	ms := NewMemoryStorage(WithClock(clock.Now))
+	defer ms.Close()
+	ctx := context.Background()
+
+	result, err := ms.GetStatus(ctx, "key", storage.Limit{Limit: 10, Window: time.Minute})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Remaining != 10 || !result.ResetAt.Equal(clock.Now()) {
+		t.Errorf("unknown key: got remaining=%d resetAt=%v, want 10 and %v", result.Remaining, result.ResetAt, clock.Now())
+	}
+
+	if _, err := ms.CheckAndUpdate(ctx, "key", storage.Limit{Limit: 10, Window: time.Minute}, 4); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	clock.Sleep(10 * time.Second)
+
+	result, err = ms.GetStatus(ctx, "key", storage.Limit{Limit: 10, Window: time.Minute})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Remaining != 6 {
+		t.Errorf("got remaining=%d, want 6", result.Remaining)
+	}
+	if want := time.Unix(60, 0); !result.ResetAt.Equal(want) {
+		t.Errorf("got resetAt=%v, want %v", result.ResetAt, want)
+	}
+}
+
+func TestMemoryStorage_Reset(t *testing.T) {
+	clock := storagetest.NewFakeClock(time.Unix(0, 0))
+	ms := NewMemoryStorage(WithClock(clock.Now))
+	defer ms.Close()
+	ctx := context.Background()
+
+	if err := ms.Reset(ctx, "key"); !errors.Is(err, storage.ErrKeyNotFound) {
+		t.Errorf("missing key: got %v, want %v", err, storage.ErrKeyNotFound)
+	}
+
+	if _, err := ms.CheckAndUpdate(ctx, "key", storage.Limit{Limit: 10, Window: time.Minute}, 10); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ms.Reset(ctx, "key"); err != nil {
+		t.Errorf("existing key: unexpected error: %v", err)
+	}
+
+	result, err := ms.CheckAndUpdate(ctx, "key", storage.Limit{Limit: 10, Window: time.Minute}, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Remaining != 9 {
+		t.Errorf("after reset: got remaining=%d, want 9", result.Remaining)
+	}
+}
+
+func TestMemoryStorage_RemoveExpired(t *testing.T) {
+	clock := storagetest.NewFakeClock(time.Unix(0, 0))
+	ms := NewMemoryStorage(WithClock(clock.Now), WithShards(4))
+	defer ms.Close()
+	ctx := context.Background()
+
+	if _, err := ms.CheckAndUpdate(ctx, "short", storage.Limit{Limit: 10, Window: time.Second}, 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := ms.CheckAndUpdate(ctx, "long", storage.Limit{Limit: 10, Window: time.Hour}, 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	clock.Sleep(time.Minute)
+	ms.removeExpired()
+
+	if _, ok := ms.getShard("short").entries["short"]; ok {
+		t.Errorf("expected expired key to be removed")
+	}
+	if _, ok := ms.getShard("long").entries["long"]; !ok {
+		t.Errorf("expected live key to be kept")
+	}
+}
+
+func TestMemoryStorage_RemoveExpiredLeases(t *testing.T) {
+	clock := storagetest.NewFakeClock(time.Unix(0, 0))
+	ms := NewMemoryStorage(WithClock(clock.Now), WithShards(4))
+	defer ms.Close()
+	ctx := context.Background()
+
+	if _, err := ms.AcquireLease(ctx, "short", storage.Limit{Algorithm: storage.Concurrency, Limit: 1, Window: time.Second}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := ms.AcquireLease(ctx, "long", storage.Limit{Algorithm: storage.Concurrency, Limit: 1, Window: time.Hour}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	clock.Sleep(time.Minute)
+	ms.removeExpired()
+
+	if _, ok := ms.getShard("short").leases["short"]; ok {
+		t.Errorf("expected expired lease set to be removed")
+	}
+	if _, ok := ms.getShard("long").leases["long"]; !ok {
+		t.Errorf("expected live lease set to be kept")
+	}
+}
+
+func TestMemoryStorage_Concurrent(t *testing.T) {
+	ms := NewMemoryStorage()
+	defer ms.Close()
+	ctx := context.Background()
+
+	var limit int64 = 1000
+	var requests int64 = 1500
+
+	var wg sync.WaitGroup
+	allowedCount := atomic.Int64{}
+
+	for i := int64(0); i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := ms.CheckAndUpdate(ctx, "key", storage.Limit{Limit: limit, Window: time.Minute}, 1)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if result.Allowed {
+				allowedCount.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowedCount.Load() != limit {
+		t.Errorf("expected exactly %d allowed, got %d", limit, allowedCount.Load())
+	}
+}
+
+func TestMemoryStorage_SlidingCounterErrorBound(t *testing.T) {
+	clock := storagetest.NewFakeClock(time.Unix(0, 0))
+	ms := NewMemoryStorage(WithClock(clock.Now))
+	defer ms.Close()
+	ctx := context.Background()
+
+	const limit = 100
+	window := time.Minute
+	counter := storage.Limit{Algorithm: storage.SlidingWindowCounter, Limit: limit, Window: window}
+	exact := storage.Limit{Algorithm: storage.SlidingWindowLog, Limit: limit, Window: window}
+
+	// Random arrivals averaging twice the limit, seeded so failures reproduce
+	rng := rand.New(rand.NewPCG(1, 2))
+	var counterAllowed, exactAllowed int64
+	var admitted []time.Time
+	var worst int64
+
+	for clock.Now().Before(time.Unix(0, 0).Add(50 * window)) {
+		clock.Sleep(time.Duration(rng.ExpFloat64() * float64(window) / (2 * limit)))
+		now := clock.Now()
+
+		approx, err := ms.CheckAndUpdate(ctx, "counter", counter, 1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		log, err := ms.CheckAndUpdate(ctx, "log", exact, 1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if log.Allowed {
+			exactAllowed++
+		}
+		if !approx.Allowed {
+			continue
+		}
+		counterAllowed++
+
+		// Count what the counter actually let through in the last window
+		admitted = append(admitted, now)
+		for len(admitted) > 0 && !admitted[0].After(now.Add(-window)) {
+			admitted = admitted[1:]
+		}
+		worst = max(worst, int64(len(admitted)))
+	}
+
+	// The counter assumes requests were spread evenly over the previous window, so it can
+	// briefly admit more than the limit; under this traffic the overshoot stays small
+	if bound := int64(limit * 1.1); worst > bound {
+		t.Errorf("counter admitted %d requests in one window, want at most %d", worst, bound)
+	}
+	if diff := float64(counterAllowed-exactAllowed) / float64(exactAllowed); diff > 0.05 || diff < -0.05 {
+		t.Errorf("counter admitted %d requests, exact log %d: off by %.1f%%, want within 5%%", counterAllowed, exactAllowed, diff*100)
+	}
+}
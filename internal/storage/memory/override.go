@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// SaveOverride creates or replaces the override with the same pattern.
+func (ms *MemoryStorage) SaveOverride(ctx context.Context, override storage.Override) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ms.overrideMutex.Lock()
+	defer ms.overrideMutex.Unlock()
+
+	ms.overrides[override.Pattern] = override
+	return nil
+}
+
+// GetOverride returns the override with the given pattern.
+func (ms *MemoryStorage) GetOverride(ctx context.Context, pattern string) (*storage.Override, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ms.overrideMutex.RLock()
+	defer ms.overrideMutex.RUnlock()
+
+	override, ok := ms.overrides[pattern]
+	if !ok {
+		return nil, storage.ErrOverrideNotFound
+	}
+	return &override, nil
+}
+
+// DeleteOverride removes the override with the given pattern.
+func (ms *MemoryStorage) DeleteOverride(ctx context.Context, pattern string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ms.overrideMutex.Lock()
+	defer ms.overrideMutex.Unlock()
+
+	if _, ok := ms.overrides[pattern]; !ok {
+		return storage.ErrOverrideNotFound
+	}
+	delete(ms.overrides, pattern)
+	return nil
+}
+
+// ListOverrides returns every override ordered by pattern.
+func (ms *MemoryStorage) ListOverrides(ctx context.Context) ([]storage.Override, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ms.overrideMutex.RLock()
+	defer ms.overrideMutex.RUnlock()
+
+	overrides := make([]storage.Override, 0, len(ms.overrides))
+	for _, override := range ms.overrides {
+		overrides = append(overrides, override)
+	}
+	slices.SortFunc(overrides, func(a, b storage.Override) int {
+		return strings.Compare(a.Pattern, b.Pattern)
+	})
+	return overrides, nil
+}
@@ -0,0 +1,109 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// SavePolicy creates or replaces the policy with the same name.
+func (ms *MemoryStorage) SavePolicy(ctx context.Context, policy storage.Policy) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ms.policyMutex.Lock()
+	defer ms.policyMutex.Unlock()
+
+	// Copy the tiers so the caller can't change the stored policy
+	policy.Tiers = slices.Clone(policy.Tiers)
+	ms.policies[policy.Name] = policy
+	return nil
+}
+
+// CreatePolicy adds the policy unless one with the same name exists.
+func (ms *MemoryStorage) CreatePolicy(ctx context.Context, policy storage.Policy) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ms.policyMutex.Lock()
+	defer ms.policyMutex.Unlock()
+
+	if _, ok := ms.policies[policy.Name]; ok {
+		return storage.ErrPolicyExists
+	}
+	policy.Tiers = slices.Clone(policy.Tiers)
+	ms.policies[policy.Name] = policy
+	return nil
+}
+
+// UpdatePolicy replaces the policy with the same name.
+func (ms *MemoryStorage) UpdatePolicy(ctx context.Context, policy storage.Policy) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ms.policyMutex.Lock()
+	defer ms.policyMutex.Unlock()
+
+	if _, ok := ms.policies[policy.Name]; !ok {
+		return storage.ErrPolicyNotFound
+	}
+	policy.Tiers = slices.Clone(policy.Tiers)
+	ms.policies[policy.Name] = policy
+	return nil
+}
+
+// GetPolicy returns the policy with the given name.
+func (ms *MemoryStorage) GetPolicy(ctx context.Context, name string) (*storage.Policy, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ms.policyMutex.RLock()
+	defer ms.policyMutex.RUnlock()
+
+	policy, ok := ms.policies[name]
+	if !ok {
+		return nil, storage.ErrPolicyNotFound
+	}
+	return &policy, nil
+}
+
+// DeletePolicy removes the policy with the given name.
+func (ms *MemoryStorage) DeletePolicy(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ms.policyMutex.Lock()
+	defer ms.policyMutex.Unlock()
+
+	if _, ok := ms.policies[name]; !ok {
+		return storage.ErrPolicyNotFound
+	}
+	delete(ms.policies, name)
+	return nil
+}
+
+// ListPolicies returns every policy ordered by name.
+func (ms *MemoryStorage) ListPolicies(ctx context.Context) ([]storage.Policy, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ms.policyMutex.RLock()
+	defer ms.policyMutex.RUnlock()
+
+	policies := make([]storage.Policy, 0, len(ms.policies))
+	for _, policy := range ms.policies {
+		policies = append(policies, policy)
+	}
+	slices.SortFunc(policies, func(a, b storage.Policy) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return policies, nil
+}
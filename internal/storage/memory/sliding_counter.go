@@ -0,0 +1,149 @@
+package memory
+
+import (
+	"math"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// counterEntry holds the counts of the current and previous fixed windows for a sliding window counter.
+type counterEntry struct {
+	start    time.Time
+	current  int64
+	previous int64
+	window   time.Duration
+}
+
+// expired reports whether neither window still counts at the given time.
+func (e *counterEntry) expired(now time.Time) bool {
+	return !now.Before(e.start.Add(2 * e.window))
+}
+
+// clone returns a copy of the counters.
+func (e *counterEntry) clone() entry {
+	c := *e
+	return &c
+}
+
+// advance rolls the counters forward to the fixed window containing now.
+func (e *counterEntry) advance(now time.Time, window time.Duration) {
+	start := now.Truncate(window)
+	if e.window == window && e.start.Equal(start) {
+		return
+	}
+
+	// The current window becomes the previous one only if they are adjacent
+	if e.window == window && e.start.Add(window).Equal(start) {
+		e.previous = e.current
+	} else {
+		e.previous = 0
+	}
+	e.current = 0
+	e.start = start
+	e.window = window
+}
+
+// count estimates the cost within the last window by weighting the previous window by how much of it still overlaps.
+func (e *counterEntry) count(now time.Time) int64 {
+	remaining := e.window - now.Sub(e.start)
+	weighted := int64(math.Floor(float64(e.previous) * float64(remaining) / float64(e.window)))
+	return weighted + e.current
+}
+
+// resetIn returns how long until neither window counts against the limit.
+func (e *counterEntry) resetIn(now time.Time) time.Duration {
+	elapsed := now.Sub(e.start)
+	switch {
+	case e.current > 0:
+		return 2*e.window - elapsed
+	case e.previous > 0:
+		return e.window - elapsed
+	default:
+		return 0
+	}
+}
+
+// retryAfter returns how long until a request of the given cost would be allowed.
+func (e *counterEntry) retryAfter(limit, cost int64, now time.Time) time.Duration {
+	elapsed := now.Sub(e.start)
+	switch {
+	case cost > limit:
+		// Never fits, so report when the counters are empty
+		return e.resetIn(now)
+	case e.current+cost <= limit:
+		// Fits in this window once enough of the previous window has slid out
+		room := float64(limit - e.current - cost)
+		return fractionOf(e.window, 1-room/float64(e.previous)) - elapsed
+	default:
+		// Has to wait for this window to become the previous one and slide out
+		room := float64(limit - cost)
+		return e.window - elapsed + fractionOf(e.window, 1-room/float64(e.current))
+	}
+}
+
+// fractionOf returns the given fraction of d rounded up.
+func fractionOf(d time.Duration, fraction float64) time.Duration {
+	return time.Duration(math.Ceil(float64(d) * fraction))
+}
+
+// checkSlidingCounter adds cost to the current window if the weighted count stays under the limit.
+// Callers must hold the shard mutex.
+func (s *shard) checkSlidingCounter(key string, limit storage.Limit, cost int64, now time.Time) *storage.Result {
+	// Start new counters if the key is missing or used by another algorithm
+	e, ok := s.entries[key].(*counterEntry)
+	if !ok {
+		e = &counterEntry{}
+		s.entries[key] = e
+	}
+	e.advance(now, limit.Window)
+
+	// Denied requests are not counted
+	count := e.count(now)
+	allowed := count+cost <= limit.Limit
+	var retryAfter time.Duration
+	if allowed {
+		e.current += cost
+		count += cost
+	} else {
+		retryAfter = e.retryAfter(limit.Limit, cost, now)
+	}
+
+	return &storage.Result{
+		Allowed:    allowed,
+		Remaining:  max(0, limit.Limit-count),
+		ResetAt:    now.Add(e.resetIn(now)),
+		Limit:      limit.Limit,
+		RetryAfter: retryAfter,
+	}
+}
+
+// statusSlidingCounter reads the weighted count without modifying it. Callers must hold the shard mutex.
+func (s *shard) statusSlidingCounter(key string, limit storage.Limit, now time.Time) *storage.Result {
+	// Work on a copy so rolling the windows forward doesn't touch stored state
+	var e counterEntry
+	if stored, ok := s.entries[key].(*counterEntry); ok {
+		e = *stored
+	}
+	e.advance(now, limit.Window)
+
+	remaining := max(0, limit.Limit-e.count(now))
+
+	return &storage.Result{
+		Allowed:   remaining > 0,
+		Remaining: remaining,
+		ResetAt:   now.Add(e.resetIn(now)),
+		Limit:     limit.Limit,
+	}
+}
+
+// refundSlidingCounter takes up to amount off the current window. The previous window has ended and is left alone.
+// Callers must hold the shard mutex.
+func (s *shard) refundSlidingCounter(key string, limit storage.Limit, amount int64, now time.Time) *storage.Result {
+	if e, ok := s.entries[key].(*counterEntry); ok {
+		e.advance(now, limit.Window)
+		e.current -= min(amount, e.current)
+	}
+
+	return s.statusSlidingCounter(key, limit, now)
+}
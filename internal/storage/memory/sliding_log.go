@@ -0,0 +1,163 @@
+package memory
+
+import (
+	"slices"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// logEntry is a single request recorded in a sliding window log.
+type logEntry struct {
+	at   time.Time
+	cost int64
+}
+
+// logState is a sliding window log ordered from oldest to newest request.
+type logState struct {
+	entries []logEntry
+	total   int64
+	window  time.Duration
+}
+
+// expired reports whether every request in the log has aged out.
+func (e *logState) expired(now time.Time) bool {
+	return len(e.entries) == 0 || !now.Before(e.entries[len(e.entries)-1].at.Add(e.window))
+}
+
+// clone returns a copy of the log that shares no requests with it.
+func (e *logState) clone() entry {
+	return &logState{entries: slices.Clone(e.entries), total: e.total, window: e.window}
+}
+
+// trim drops requests older than the window.
+func (e *logState) trim(now time.Time) {
+	cutoff := now.Add(-e.window)
+
+	i := 0
+	for i < len(e.entries) && !e.entries[i].at.After(cutoff) {
+		e.total -= e.entries[i].cost
+		i++
+	}
+	e.entries = e.entries[i:]
+}
+
+// resetAt returns when the oldest counted request ages out.
+func (e *logState) resetAt(now time.Time) time.Time {
+	if len(e.entries) == 0 {
+		return now
+	}
+	return e.entries[0].at.Add(e.window)
+}
+
+// refund removes up to amount of cost from the log, newest requests first.
+func (e *logState) refund(amount int64) {
+	for amount > 0 && len(e.entries) > 0 {
+		newest := &e.entries[len(e.entries)-1]
+		refunded := min(amount, newest.cost)
+		newest.cost -= refunded
+		e.total -= refunded
+		amount -= refunded
+
+		if newest.cost == 0 {
+			e.entries = e.entries[:len(e.entries)-1]
+		}
+	}
+}
+
+// availableAt returns when enough requests will have aged out for cost to fit under the limit.
+func (e *logState) availableAt(limit, cost int64, now time.Time) time.Time {
+	need := e.total + cost - limit
+	if need <= 0 {
+		return now
+	}
+	if cost > limit {
+		// Never fits, so report when the log is empty
+		need = e.total
+	}
+
+	var freed int64
+	for _, entry := range e.entries {
+		freed += entry.cost
+		if freed >= need {
+			return entry.at.Add(e.window)
+		}
+	}
+	return e.resetAt(now)
+}
+
+// checkSlidingLog records the request if the cost within the last window stays under the limit.
+// Callers must hold the shard mutex.
+func (s *shard) checkSlidingLog(key string, limit storage.Limit, cost int64, now time.Time) (*storage.Result, error) {
+	if limit.Limit > storage.MaxSlidingLogLimit {
+		return nil, storage.ErrLimitTooHigh
+	}
+
+	// Start a new log if the key is missing or used by another algorithm
+	e, ok := s.entries[key].(*logState)
+	if !ok {
+		e = &logState{}
+		s.entries[key] = e
+	}
+	e.window = limit.Window
+	e.trim(now)
+
+	// Denied requests are not recorded
+	allowed := e.total+cost <= limit.Limit
+	var retryAfter time.Duration
+	if allowed {
+		e.entries = append(e.entries, logEntry{at: now, cost: cost})
+		e.total += cost
+	} else {
+		retryAfter = e.availableAt(limit.Limit, cost, now).Sub(now)
+	}
+
+	return &storage.Result{
+		Allowed:    allowed,
+		Remaining:  max(0, limit.Limit-e.total),
+		ResetAt:    e.resetAt(now),
+		Limit:      limit.Limit,
+		RetryAfter: retryAfter,
+	}, nil
+}
+
+// statusSlidingLog counts the cost within the last window without recording a request.
+// Callers must hold the shard mutex.
+func (s *shard) statusSlidingLog(key string, limit storage.Limit, now time.Time) (*storage.Result, error) {
+	if limit.Limit > storage.MaxSlidingLogLimit {
+		return nil, storage.ErrLimitTooHigh
+	}
+
+	e, ok := s.entries[key].(*logState)
+	if !ok {
+		// Key doesn't exist so just give default result
+		e = &logState{}
+	}
+	e.window = limit.Window
+	e.trim(now)
+
+	remaining := max(0, limit.Limit-e.total)
+
+	return &storage.Result{
+		Allowed:   remaining > 0,
+		Remaining: remaining,
+		ResetAt:   e.resetAt(now),
+		Limit:     limit.Limit,
+	}, nil
+}
+
+// refundSlidingLog removes up to amount of cost recorded within the last window, newest requests first.
+// Callers must hold the shard mutex.
+func (s *shard) refundSlidingLog(key string, limit storage.Limit, amount int64, now time.Time) (*storage.Result, error) {
+	if limit.Limit > storage.MaxSlidingLogLimit {
+		return nil, storage.ErrLimitTooHigh
+	}
+
+	if e, ok := s.entries[key].(*logState); ok {
+		e.window = limit.Window
+		e.trim(now)
+		e.refund(amount)
+	}
+
+	return s.statusSlidingLog(key, limit, now)
+}
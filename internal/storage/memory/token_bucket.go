@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/algorithms/tokenbucket"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// bucketEntry is a token bucket along with the limit it was created for.
+type bucketEntry struct {
+	bucket *tokenbucket.TokenBucket
+	limit  storage.Limit
+}
+
+// expired reports whether the bucket has refilled completely, at which point it is equivalent to a new one.
+func (e *bucketEntry) expired(now time.Time) bool {
+	return !now.Before(e.bucket.FullAt(now))
+}
+
+// clone returns a copy of the bucket in its current state.
+func (e *bucketEntry) clone() entry {
+	return &bucketEntry{bucket: e.bucket.Clone(), limit: e.limit}
+}
+
+// checkTokenBucket takes cost tokens from the bucket if enough are available. Callers must hold the shard mutex.
+func (s *shard) checkTokenBucket(key string, limit storage.Limit, cost int64, now time.Time) *storage.Result {
+	limit.RefillRate = limit.Refill()
+
+	// Start a full bucket if the key is missing, used by another algorithm or configured differently
+	e, ok := s.entries[key].(*bucketEntry)
+	if !ok || e.limit != limit {
+		e = &bucketEntry{
+			bucket: tokenbucket.NewTokenBucketAt(limit.Limit, limit.RefillRate, limit.Window, now),
+			limit:  limit,
+		}
+		s.entries[key] = e
+	}
+
+	allowed, tokens := e.bucket.AllowAt(cost, now)
+
+	// A denied request has to wait until enough tokens have been refilled
+	var retryAfter time.Duration
+	if !allowed {
+		retryAfter = e.bucket.AvailableAt(cost, now).Sub(now)
+	}
+
+	return &storage.Result{
+		Allowed:    allowed,
+		Remaining:  tokens,
+		ResetAt:    e.bucket.FullAt(now),
+		Limit:      limit.Limit,
+		RetryAfter: retryAfter,
+	}
+}
+
+// statusTokenBucket reads the available tokens without taking any. Callers must hold the shard mutex.
+func (s *shard) statusTokenBucket(key string, limit storage.Limit, now time.Time) *storage.Result {
+	limit.RefillRate = limit.Refill()
+
+	e, ok := s.entries[key].(*bucketEntry)
+	if !ok || e.limit != limit {
+		// Key doesn't exist so the bucket would be full
+		return &storage.Result{
+			Allowed:   true,
+			Remaining: limit.Limit,
+			ResetAt:   now,
+			Limit:     limit.Limit,
+		}
+	}
+
+	tokens := e.bucket.TokensAt(now)
+
+	return &storage.Result{
+		Allowed:   tokens > 0,
+		Remaining: tokens,
+		ResetAt:   e.bucket.FullAt(now),
+		Limit:     limit.Limit,
+	}
+}
+
+// refundTokenBucket puts up to amount tokens back in the bucket, never above capacity. Callers must hold the shard mutex.
+func (s *shard) refundTokenBucket(key string, limit storage.Limit, amount int64, now time.Time) *storage.Result {
+	limit.RefillRate = limit.Refill()
+
+	// A missing bucket is already full
+	if e, ok := s.entries[key].(*bucketEntry); ok && e.limit == limit {
+		e.bucket.RefundAt(amount, now)
+	}
+
+	return s.statusTokenBucket(key, limit, now)
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"path"
+)
+
+// OverrideAction is what an override does to the keys it applies to.
+type OverrideAction string
+
+const (
+	// AlwaysAllow lets every request through without counting it, e.g. for internal health checkers.
+	AlwaysAllow OverrideAction = "allow"
+	// AlwaysDeny rejects every request without counting it, e.g. for abusive keys.
+	AlwaysDeny OverrideAction = "deny"
+	// ScaleLimit multiplies every limit that would otherwise apply by the override's Multiplier.
+	ScaleLimit OverrideAction = "scale"
+)
+
+// OverrideActions lists every supported override action.
+var OverrideActions = []OverrideAction{AlwaysAllow, AlwaysDeny, ScaleLimit}
+
+// Valid reports whether a is a known override action.
+func (a OverrideAction) Valid() bool {
+	for _, known := range OverrideActions {
+		if a == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Override exempts, blocks or rescales keys regardless of the policy or limit that would otherwise apply.
+type Override struct {
+	// Pattern is the exact key the override applies to, or a path.Match pattern such as "partner:*".
+	Pattern string
+	Action  OverrideAction
+	// Multiplier scales every limit and refill rate under ScaleLimit, e.g. 10 or 0.5. Zero for other actions.
+	Multiplier float64
+	// Reason records why the override exists, e.g. a ticket or partner name.
+	Reason string
+}
+
+// Matches reports whether the override applies to key, either exactly or through its pattern.
+func (o Override) Matches(key string) bool {
+	if o.Pattern == key {
+		return true
+	}
+	matched, err := path.Match(o.Pattern, key)
+	return err == nil && matched
+}
+
+// OverrideStorage persists overrides so every server sharing the backend applies them.
+type OverrideStorage interface {
+	// SaveOverride creates or replaces the override with the same pattern.
+	SaveOverride(ctx context.Context, override Override) error
+	// GetOverride returns ErrOverrideNotFound if no override has the pattern.
+	GetOverride(ctx context.Context, pattern string) (*Override, error)
+	// DeleteOverride returns ErrOverrideNotFound if no override has the pattern.
+	DeleteOverride(ctx context.Context, pattern string) error
+	// ListOverrides returns every override ordered by pattern.
+	ListOverrides(ctx context.Context) ([]Override, error)
+}
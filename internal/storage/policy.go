@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"path"
+)
+
+// Policy is a named limit defined on the server.
+// Clients reference it by name, or it applies to every key matching KeyPattern.
+type Policy struct {
+	// Name identifies the policy, e.g. "api.write".
+	Name string
+	// KeyPattern selects the keys the policy applies to, in path.Match syntax (e.g. "user:*").
+	// Empty means the policy only applies when referenced by name.
+	KeyPattern string
+	// Limit is enforced for every key the policy applies to.
+	Limit Limit
+	// Tiers are further limits enforced together with Limit, e.g. 1000 per hour on top of 20 per second.
+	// A request is allowed only if every tier allows it.
+	Tiers []Limit
+	// Shadow counts requests against the policy without ever denying them, to see who a new limit would block.
+	Shadow bool
+	// FailureMode is how the policy's checks are answered while the storage is unavailable.
+	// Empty means the default of the storage.
+	FailureMode FailureMode
+}
+
+// Limits returns Limit followed by every tier.
+func (p Policy) Limits() []Limit {
+	return append([]Limit{p.Limit}, p.Tiers...)
+}
+
+// Matches reports whether the policy applies to key through its pattern.
+func (p Policy) Matches(key string) bool {
+	if p.KeyPattern == "" {
+		return false
+	}
+	matched, err := path.Match(p.KeyPattern, key)
+	return err == nil && matched
+}
+
+// PolicyStorage persists policies so every server sharing the backend enforces the same limits.
+type PolicyStorage interface {
+	// SavePolicy creates or replaces the policy with the same name.
+	SavePolicy(ctx context.Context, policy Policy) error
+	// CreatePolicy returns ErrPolicyExists if a policy already has the name.
+	CreatePolicy(ctx context.Context, policy Policy) error
+	// UpdatePolicy returns ErrPolicyNotFound if no policy has the name.
+	UpdatePolicy(ctx context.Context, policy Policy) error
+	// GetPolicy returns ErrPolicyNotFound if no policy has the name.
+	GetPolicy(ctx context.Context, name string) (*Policy, error)
+	// DeletePolicy returns ErrPolicyNotFound if no policy has the name.
+	DeletePolicy(ctx context.Context, name string) error
+	// ListPolicies returns every policy ordered by name.
+	ListPolicies(ctx context.Context) ([]Policy, error)
+}
@@ -0,0 +1,70 @@
+package redis
+
+import (
+	"context"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"github.com/redis/go-redis/v9"
+)
+
+// scriptCall is one Lua script call and how to turn its output into a storage.Result.
+// Building the call apart from running it lets the same algorithm code run alone or in a pipeline.
+type scriptCall struct {
+	script *redis.Script
+	keys   []string
+	args   []any
+	parse  func(output []int64) *storage.Result
+
+	cmd *redis.Cmd
+}
+
+// run sends the call through c, which is either the client or a pipeline that has yet to be executed.
+func (call *scriptCall) run(ctx context.Context, c redis.Scripter) *scriptCall {
+	call.cmd = call.script.Run(ctx, c, call.keys, call.args...)
+	return call
+}
+
+// result parses the output of a call that has run.
+func (call *scriptCall) result() (*storage.Result, error) {
+	output, err := call.cmd.Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	return call.parse(output), nil
+}
+
+// CheckAndUpdateBatch checks every key in a single pipelined round trip.
+// Each script is still atomic on its own key, but the batch as a whole is not.
+func (rs *RedisStorage) CheckAndUpdateBatch(ctx context.Context, checks []storage.Check) ([]*storage.Result, error) {
+	// Build every call first so invalid checks fail before anything is consumed
+	calls := make([]*scriptCall, len(checks))
+	for i, check := range checks {
+		call, err := rs.checkCall(check.Key, check.Limit, check.Cost)
+		if err != nil {
+			return nil, err
+		}
+		calls[i] = call
+	}
+
+	// Exec reports only the first failure, so errors are read from each call below
+	pipe := rs.client.Pipeline()
+	for _, call := range calls {
+		call.run(ctx, pipe)
+	}
+	pipe.Exec(ctx)
+
+	results := make([]*storage.Result, len(calls))
+	for i, call := range calls {
+		// A pipelined EVALSHA can't fall back to EVAL, so rerun calls whose script was flushed since it was loaded
+		if redis.HasErrorPrefix(call.cmd.Err(), "NOSCRIPT") {
+			call.run(ctx, rs.client)
+		}
+
+		result, err := call.result()
+		if err != nil {
+			return nil, err
+		}
+		results[i] = result
+	}
+	return results, nil
+}
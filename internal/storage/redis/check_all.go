@@ -0,0 +1,71 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"github.com/redis/go-redis/v9"
+)
+
+// CheckAndUpdateAll checks every key in a single Lua script that applies the updates only if all of them are allowed.
+// Every key is touched by one script, so on Redis Cluster they must share a hash slot, or it returns
+// storage.ErrKeysNotColocated.
+func (rs *RedisStorage) CheckAndUpdateAll(ctx context.Context, checks []storage.Check) ([]*storage.Result, error) {
+	// Build the keys and the five arguments of each check
+	keys := make([]string, len(checks))
+	args := make([]any, 0, 1+5*len(checks))
+	args = append(args, rs.requestID())
+	for i, check := range checks {
+		algorithm, interval, err := checkAllInterval(check.Limit)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = rs.formatKey(check.Key)
+		args = append(args, string(algorithm), check.Limit.Limit, interval, check.Limit.Refill(), check.Cost)
+	}
+
+	output, err := checkAllScript.Run(ctx, rs.client, keys, args...).Int64Slice()
+	if redis.HasErrorPrefix(err, "CROSSSLOT") {
+		return nil, storage.ErrKeysNotColocated
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	results := make([]*storage.Result, len(checks))
+	for i, check := range checks {
+		values := output[4*i : 4*i+4]
+		results[i] = &storage.Result{
+			Allowed:    values[0] == 1,
+			Remaining:  values[1],
+			ResetAt:    now.Add(time.Duration(values[2]) * time.Microsecond),
+			Limit:      check.Limit.Limit,
+			RetryAfter: time.Duration(values[3]) * time.Microsecond,
+		}
+	}
+	return results, nil
+}
+
+// checkAllInterval returns the algorithm name and the interval checkAllScript expects for it,
+// in the same units as the algorithm's single-key script.
+func checkAllInterval(limit storage.Limit) (storage.Algorithm, int64, error) {
+	switch limit.Algorithm {
+	case "", storage.FixedWindow:
+		return storage.FixedWindow, limit.Window.Milliseconds(), nil
+	case storage.TokenBucket:
+		return storage.TokenBucket, limit.Window.Microseconds(), nil
+	case storage.GCRA:
+		return storage.GCRA, emissionMicroseconds(limit), nil
+	case storage.SlidingWindowLog:
+		if limit.Limit > storage.MaxSlidingLogLimit {
+			return "", 0, storage.ErrLimitTooHigh
+		}
+		return storage.SlidingWindowLog, limit.Window.Microseconds(), nil
+	case storage.SlidingWindowCounter:
+		return storage.SlidingWindowCounter, windowMicroseconds(limit), nil
+	default:
+		return "", 0, storage.ErrUnsupportedAlgorithm
+	}
+}
@@ -0,0 +1,72 @@
+package redis
+
+import (
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// checkFixedWindow increments the window counter by cost.
+// The increment, expiry and TTL read happen atomically in a single Lua script (see ADR-0003).
+func (rs *RedisStorage) checkFixedWindow(redisKey string, limit storage.Limit, cost int64) *scriptCall {
+	// Increment count by cost and fetch the remaining TTL
+	return &scriptCall{
+		script: fixedWindowCheckScript,
+		keys:   []string{redisKey},
+		args:   []any{cost, limit.Window.Milliseconds()},
+		parse: func(output []int64) *storage.Result {
+			count, ttl := output[0], time.Duration(output[1])*time.Millisecond
+			return buildFixedWindowResult(count, limit.Limit, ttl)
+		},
+	}
+}
+
+// statusFixedWindow reads the window counter without modifying it.
+func (rs *RedisStorage) statusFixedWindow(redisKey string, limit storage.Limit) *scriptCall {
+	// Get current count and TTL
+	return &scriptCall{
+		script: fixedWindowStatusScript,
+		keys:   []string{redisKey},
+		parse: func(output []int64) *storage.Result {
+			count, ttl := output[0], time.Duration(output[1])*time.Millisecond
+			return buildFixedWindowResult(count, limit.Limit, ttl)
+		},
+	}
+}
+
+// refundFixedWindow takes up to amount off the window counter, leaving an expired window alone.
+func (rs *RedisStorage) refundFixedWindow(redisKey string, limit storage.Limit, amount int64) *scriptCall {
+	// The refund script takes the status arguments plus the amount and reports the same output
+	call := rs.statusFixedWindow(redisKey, limit)
+	call.script, call.args = fixedWindowRefundScript, append(call.args, amount)
+	return call
+}
+
+// buildFixedWindowResult converts a counter value and its TTL into a storage.Result.
+// A negative TTL means the key does not exist (or has no expiry), so the window resets immediately.
+func buildFixedWindowResult(count, limit int64, ttl time.Duration) *storage.Result {
+	if ttl < 0 {
+		ttl = 0
+	}
+	allowed := count <= limit
+
+	// Calculate remaining tokens
+	remaining := limit - count
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	// A denied request has to wait for the next window
+	var retryAfter time.Duration
+	if !allowed {
+		retryAfter = ttl
+	}
+
+	return &storage.Result{
+		Allowed:    allowed,
+		Remaining:  remaining,
+		ResetAt:    time.Now().Add(ttl),
+		Limit:      limit,
+		RetryAfter: retryAfter,
+	}
+}
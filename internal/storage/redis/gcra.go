@@ -0,0 +1,60 @@
+package redis
+
+import (
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/algorithms/gcra"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// checkGCRA records a request of the given cost if it conforms, storing only the theoretical arrival time.
+func (rs *RedisStorage) checkGCRA(redisKey string, limit storage.Limit, cost int64) *scriptCall {
+	return &scriptCall{
+		script: gcraCheckScript,
+		keys:   []string{redisKey},
+		args:   []any{emissionMicroseconds(limit), limit.Limit, cost},
+		parse: func(output []int64) *storage.Result {
+			allowed, remaining := output[0] == 1, output[1]
+			untilTAT, retryAfter := time.Duration(output[2])*time.Microsecond, time.Duration(output[3])*time.Microsecond
+
+			return &storage.Result{
+				Allowed:    allowed,
+				Remaining:  remaining,
+				ResetAt:    time.Now().Add(untilTAT),
+				Limit:      limit.Limit,
+				RetryAfter: retryAfter,
+			}
+		},
+	}
+}
+
+// statusGCRA reads the remaining burst without recording a request.
+func (rs *RedisStorage) statusGCRA(redisKey string, limit storage.Limit) *scriptCall {
+	return &scriptCall{
+		script: gcraStatusScript,
+		keys:   []string{redisKey},
+		args:   []any{emissionMicroseconds(limit), limit.Limit},
+		parse: func(output []int64) *storage.Result {
+			remaining, untilTAT := output[0], time.Duration(output[1])*time.Microsecond
+
+			return &storage.Result{
+				Allowed:   remaining > 0,
+				Remaining: remaining,
+				ResetAt:   time.Now().Add(untilTAT),
+				Limit:     limit.Limit,
+			}
+		},
+	}
+}
+
+// refundGCRA moves the theoretical arrival time back by amount requests, never before now.
+func (rs *RedisStorage) refundGCRA(redisKey string, limit storage.Limit, amount int64) *scriptCall {
+	call := rs.statusGCRA(redisKey, limit)
+	call.script, call.args = gcraRefundScript, append(call.args, amount)
+	return call
+}
+
+// emissionMicroseconds returns the GCRA emission interval at the microsecond resolution of Redis TIME.
+func emissionMicroseconds(limit storage.Limit) int64 {
+	return max(1, gcra.EmissionInterval(limit.Limit, limit.Window).Microseconds())
+}
@@ -0,0 +1,93 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// AcquireLease grants a lease on key if fewer than limit.Limit unexpired leases are held
+func (rs *RedisStorage) AcquireLease(ctx context.Context, key string, limit storage.Limit) (*storage.Lease, error) {
+	// Build Redis key
+	redisKey := rs.formatLeaseKey(key)
+
+	// Add the lease if there is room and fetch when the oldest one expires
+	leaseID := storage.NewLeaseID()
+	output, err := leaseAcquireScript.Run(ctx, rs.client, []string{redisKey}, limit.Limit, limit.Window.Microseconds(), leaseID).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	acquired, held, oldestIn := output[0] == 1, output[1], time.Duration(output[2])*time.Microsecond
+
+	// A denied lease has to wait for the oldest one to be released or expire
+	if !acquired {
+		return &storage.Lease{
+			Result: storage.Result{
+				Allowed:    false,
+				Remaining:  0,
+				ResetAt:    time.Now().Add(oldestIn),
+				Limit:      limit.Limit,
+				RetryAfter: oldestIn,
+			},
+		}, nil
+	}
+
+	return &storage.Lease{
+		Result: storage.Result{
+			Allowed:   true,
+			Remaining: max(0, limit.Limit-held),
+			ResetAt:   time.Now().Add(oldestIn),
+			Limit:     limit.Limit,
+		},
+		ID:        leaseID,
+		ExpiresAt: time.Now().Add(limit.Window),
+	}, nil
+}
+
+// RenewLease extends a held lease to limit.Window from now
+func (rs *RedisStorage) RenewLease(ctx context.Context, key, leaseID string, limit storage.Limit) (*storage.Lease, error) {
+	// Build Redis key
+	redisKey := rs.formatLeaseKey(key)
+
+	output, err := leaseRenewScript.Run(ctx, rs.client, []string{redisKey}, limit.Window.Microseconds(), leaseID).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	renewed, held, oldestIn := output[0] == 1, output[1], time.Duration(output[2])*time.Microsecond
+	if !renewed {
+		return nil, storage.ErrLeaseNotFound
+	}
+
+	return &storage.Lease{
+		Result: storage.Result{
+			Allowed:   true,
+			Remaining: max(0, limit.Limit-held),
+			ResetAt:   time.Now().Add(oldestIn),
+			Limit:     limit.Limit,
+		},
+		ID:        leaseID,
+		ExpiresAt: time.Now().Add(limit.Window),
+	}, nil
+}
+
+// ReleaseLease frees a held lease
+func (rs *RedisStorage) ReleaseLease(ctx context.Context, key, leaseID string) error {
+	// Build Redis key
+	redisKey := rs.formatLeaseKey(key)
+
+	released, err := leaseReleaseScript.Run(ctx, rs.client, []string{redisKey}, leaseID).Int64()
+	if err != nil {
+		return err
+	}
+
+	if released == 0 {
+		return storage.ErrLeaseNotFound
+	}
+	return nil
+}
+
+// formatLeaseKey formats the key of the lease set for an identifier, hash tagged like formatKey
+func (rs *RedisStorage) formatLeaseKey(identifier string) string {
+	return rs.leasePrefix + rs.hashTagged(identifier)
+}
@@ -0,0 +1,99 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"github.com/redis/go-redis/v9"
+)
+
+// overrideRecord is the JSON form of a storage.Override kept in the override hash.
+type overrideRecord struct {
+	Action     storage.OverrideAction `json:"action"`
+	Multiplier float64                `json:"multiplier,omitempty"`
+	Reason     string                 `json:"reason,omitempty"`
+}
+
+// SaveOverride creates or replaces the override with the same pattern.
+func (rs *RedisStorage) SaveOverride(ctx context.Context, override storage.Override) error {
+	value, err := json.Marshal(overrideRecord{
+		Action:     override.Action,
+		Multiplier: override.Multiplier,
+		Reason:     override.Reason,
+	})
+	if err != nil {
+		return err
+	}
+
+	return rs.client.HSet(ctx, rs.overrideKey, override.Pattern, value).Err()
+}
+
+// GetOverride returns the override with the given pattern.
+func (rs *RedisStorage) GetOverride(ctx context.Context, pattern string) (*storage.Override, error) {
+	value, err := rs.client.HGet(ctx, rs.overrideKey, pattern).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, storage.ErrOverrideNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	override, err := decodeOverride(pattern, value)
+	if err != nil {
+		return nil, err
+	}
+	return &override, nil
+}
+
+// DeleteOverride removes the override with the given pattern.
+func (rs *RedisStorage) DeleteOverride(ctx context.Context, pattern string) error {
+	deleted, err := rs.client.HDel(ctx, rs.overrideKey, pattern).Result()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return storage.ErrOverrideNotFound
+	}
+	return nil
+}
+
+// ListOverrides returns every override ordered by pattern.
+func (rs *RedisStorage) ListOverrides(ctx context.Context) ([]storage.Override, error) {
+	values, err := rs.client.HGetAll(ctx, rs.overrideKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := make([]storage.Override, 0, len(values))
+	for pattern, value := range values {
+		override, err := decodeOverride(pattern, value)
+		if err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, override)
+	}
+	slices.SortFunc(overrides, func(a, b storage.Override) int {
+		return strings.Compare(a.Pattern, b.Pattern)
+	})
+	return overrides, nil
+}
+
+// decodeOverride converts an override hash value back into a storage.Override.
+func decodeOverride(pattern, value string) (storage.Override, error) {
+	var record overrideRecord
+	if err := json.Unmarshal([]byte(value), &record); err != nil {
+		return storage.Override{}, fmt.Errorf("failed to decode override %q: %w", pattern, err)
+	}
+
+	return storage.Override{
+		Pattern:    pattern,
+		Action:     record.Action,
+		Multiplier: record.Multiplier,
+		Reason:     record.Reason,
+	}, nil
+}
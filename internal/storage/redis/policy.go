@@ -0,0 +1,187 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"github.com/redis/go-redis/v9"
+)
+
+// policyRecord is the JSON form of a storage.Policy kept in the policy hash.
+type policyRecord struct {
+	KeyPattern string `json:"key_pattern,omitempty"`
+	limitRecord
+	Tiers       []limitRecord       `json:"tiers,omitempty"`
+	Shadow      bool                `json:"shadow,omitempty"`
+	FailureMode storage.FailureMode `json:"failure_mode,omitempty"`
+}
+
+// limitRecord is the JSON form of a storage.Limit.
+type limitRecord struct {
+	Algorithm  storage.Algorithm `json:"algorithm"`
+	Limit      int64             `json:"limit"`
+	WindowMs   int64             `json:"window_ms"`
+	RefillRate int64             `json:"refill_rate,omitempty"`
+	Period     storage.Period    `json:"period,omitempty"`
+	Timezone   string            `json:"timezone,omitempty"`
+}
+
+// SavePolicy creates or replaces the policy with the same name.
+func (rs *RedisStorage) SavePolicy(ctx context.Context, policy storage.Policy) error {
+	value, err := encodePolicy(policy)
+	if err != nil {
+		return err
+	}
+
+	return rs.client.HSet(ctx, rs.policyKey, policy.Name, value).Err()
+}
+
+// CreatePolicy adds the policy unless one with the same name exists.
+func (rs *RedisStorage) CreatePolicy(ctx context.Context, policy storage.Policy) error {
+	value, err := encodePolicy(policy)
+	if err != nil {
+		return err
+	}
+
+	created, err := rs.client.HSetNX(ctx, rs.policyKey, policy.Name, value).Result()
+	if err != nil {
+		return err
+	}
+
+	if !created {
+		return storage.ErrPolicyExists
+	}
+	return nil
+}
+
+// UpdatePolicy replaces the policy with the same name.
+func (rs *RedisStorage) UpdatePolicy(ctx context.Context, policy storage.Policy) error {
+	value, err := encodePolicy(policy)
+	if err != nil {
+		return err
+	}
+
+	updated, err := policyUpdateScript.Run(ctx, rs.client, []string{rs.policyKey}, policy.Name, value).Int64()
+	if err != nil {
+		return err
+	}
+
+	if updated == 0 {
+		return storage.ErrPolicyNotFound
+	}
+	return nil
+}
+
+// GetPolicy returns the policy with the given name.
+func (rs *RedisStorage) GetPolicy(ctx context.Context, name string) (*storage.Policy, error) {
+	value, err := rs.client.HGet(ctx, rs.policyKey, name).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, storage.ErrPolicyNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	policy, err := decodePolicy(name, value)
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// DeletePolicy removes the policy with the given name.
+func (rs *RedisStorage) DeletePolicy(ctx context.Context, name string) error {
+	deleted, err := rs.client.HDel(ctx, rs.policyKey, name).Result()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return storage.ErrPolicyNotFound
+	}
+	return nil
+}
+
+// ListPolicies returns every policy ordered by name.
+func (rs *RedisStorage) ListPolicies(ctx context.Context) ([]storage.Policy, error) {
+	values, err := rs.client.HGetAll(ctx, rs.policyKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make([]storage.Policy, 0, len(values))
+	for name, value := range values {
+		policy, err := decodePolicy(name, value)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	slices.SortFunc(policies, func(a, b storage.Policy) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return policies, nil
+}
+
+// encodePolicy converts a storage.Policy into a policy hash value.
+func encodePolicy(policy storage.Policy) ([]byte, error) {
+	record := policyRecord{
+		KeyPattern:  policy.KeyPattern,
+		limitRecord: encodeLimit(policy.Limit),
+		Shadow:      policy.Shadow,
+		FailureMode: policy.FailureMode,
+	}
+	for _, tier := range policy.Tiers {
+		record.Tiers = append(record.Tiers, encodeLimit(tier))
+	}
+	return json.Marshal(record)
+}
+
+// decodePolicy converts a policy hash value back into a storage.Policy.
+func decodePolicy(name, value string) (storage.Policy, error) {
+	var record policyRecord
+	if err := json.Unmarshal([]byte(value), &record); err != nil {
+		return storage.Policy{}, fmt.Errorf("failed to decode policy %q: %w", name, err)
+	}
+
+	policy := storage.Policy{
+		Name:        name,
+		KeyPattern:  record.KeyPattern,
+		Limit:       decodeLimit(record.limitRecord),
+		Shadow:      record.Shadow,
+		FailureMode: record.FailureMode,
+	}
+	for _, tier := range record.Tiers {
+		policy.Tiers = append(policy.Tiers, decodeLimit(tier))
+	}
+	return policy, nil
+}
+
+// encodeLimit converts a storage.Limit into its JSON form.
+func encodeLimit(limit storage.Limit) limitRecord {
+	return limitRecord{
+		Algorithm:  limit.Algorithm,
+		Limit:      limit.Limit,
+		WindowMs:   limit.Window.Milliseconds(),
+		RefillRate: limit.RefillRate,
+		Period:     limit.Period,
+		Timezone:   limit.Timezone,
+	}
+}
+
+// decodeLimit converts the JSON form of a limit back into a storage.Limit.
+func decodeLimit(record limitRecord) storage.Limit {
+	return storage.Limit{
+		Algorithm:  record.Algorithm,
+		Limit:      record.Limit,
+		Window:     time.Duration(record.WindowMs) * time.Millisecond,
+		RefillRate: record.RefillRate,
+		Period:     record.Period,
+		Timezone:   record.Timezone,
+	}
+}
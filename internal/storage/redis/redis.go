@@ -2,137 +2,176 @@ package redis
 
 import (
 	"context"
-	"strconv"
-	"time"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync/atomic"
 
 	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
 	"github.com/redis/go-redis/v9"
 )
 
-// RedisStorage implements storage.RateLimitStorage
+// RedisStorage implements storage.RateLimitStorage, storage.BatchStorage, storage.PolicyStorage, storage.OverrideStorage
+// and storage.LeaseStorage
 type RedisStorage struct {
-	client    *redis.Client
+	client    redis.UniversalClient
 	keyPrefix string
+	// policyKey is the hash holding every policy. It sits outside keyPrefix so no client key can reach it.
+	policyKey string
+	// overrideKey is the hash holding every override, kept outside keyPrefix like policyKey.
+	overrideKey string
+	// leasePrefix namespaces lease sets apart from rate limit state on the same key
+	leasePrefix string
+	// hashTags wraps keys without a hash tag in one. Only Redis Cluster needs it, so other setups keep their keys.
+	hashTags bool
+
+	// instanceID and sequence build unique sliding log members across replicas
+	instanceID string
+	sequence   atomic.Uint64
 }
 
-// TODO: Look into Lua scripts for optimizing Redis operations
-
-// NewRedisStorage
+// NewRedisStorage connects to a standalone Redis at addr and preloads the Lua scripts used for rate limiting.
 func NewRedisStorage(ctx context.Context, addr, keyPrefix string) (*RedisStorage, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr: addr,
+	return NewUniversalRedisStorage(ctx, &redis.UniversalOptions{Addrs: []string{addr}}, keyPrefix)
+}
 
-		PoolSize:     10,
-		MinIdleConns: 5,
-	})
+// NewUniversalRedisStorage connects to Redis as configured by options and preloads the Lua scripts used for rate limiting.
+// Like redis.NewUniversalClient, it uses Sentinel failover when MasterName is set, Redis Cluster when there are several
+// Addrs or IsClusterMode is set, and a standalone server otherwise. Pool sizes left at zero default to 10 connections
+// with 5 kept idle. keyPrefix must not contain a hash tag, or every key would share one Cluster slot.
+// On Redis Cluster every key is stored with a hash tag, e.g. "ratelimit:{user:1}"; elsewhere it is stored as is.
+func NewUniversalRedisStorage(ctx context.Context, options *redis.UniversalOptions, keyPrefix string) (*RedisStorage, error) {
+	// Copy the options so the caller's aren't changed
+	opts := *options
+	if opts.PoolSize == 0 {
+		opts.PoolSize = 10
+	}
+	if opts.MinIdleConns == 0 {
+		opts.MinIdleConns = 5
+	}
+	client := redis.NewUniversalClient(&opts)
+	_, isCluster := client.(*redis.ClusterClient)
 
 	response := client.Ping(ctx)
 	if err := response.Err(); err != nil {
+		client.Close()
 		return nil, err
 	}
 
+	// Load scripts up front so the first requests can use EVALSHA. On Redis Cluster they are loaded on every primary.
+	// Script.Run still falls back to EVAL if Redis later reports NOSCRIPT (e.g. after a restart or SCRIPT FLUSH).
+	for _, script := range scripts {
+		if err := script.Load(ctx, client).Err(); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to load script: %w", err)
+		}
+	}
+
+	instanceID := make([]byte, 8)
+	if _, err := rand.Read(instanceID); err != nil {
+		return nil, fmt.Errorf("failed to generate instance ID: %w", err)
+	}
+
 	return &RedisStorage{
-		client:    client,
-		keyPrefix: keyPrefix,
+		client:      client,
+		keyPrefix:   keyPrefix,
+		policyKey:   strings.TrimSuffix(keyPrefix, ":") + "-policies",
+		overrideKey: strings.TrimSuffix(keyPrefix, ":") + "-overrides",
+		leasePrefix: strings.TrimSuffix(keyPrefix, ":") + "-leases:",
+		hashTags:    isCluster,
+		instanceID:  hex.EncodeToString(instanceID),
 	}, nil
 }
 
-// CheckAndUpdate checks if a request is allowed and updates the counter
-func (rs *RedisStorage) CheckAndUpdate(ctx context.Context, key string, limit int64, window time.Duration, cost int64) (*storage.Result, error) {
-	// NOTE: This implementation has a small race condition between INCR and EXPIRE.
-	// See ADR-0001 for rationale on accepting this trade-off.
-	// Future version will use Lua scripts for true atomicity.
-
-	// Build Redis key
-	redisKey := rs.formatKey(key)
-
-	// Increments count by cost
-	count, err := rs.client.IncrBy(ctx, redisKey, cost).Result()
+// CheckAndUpdate checks if a request is allowed and updates the state for the key
+func (rs *RedisStorage) CheckAndUpdate(ctx context.Context, key string, limit storage.Limit, cost int64) (*storage.Result, error) {
+	call, err := rs.checkCall(key, limit, cost)
 	if err != nil {
 		return nil, err
 	}
+	return call.run(ctx, rs.client).result()
+}
 
-	var resetAt time.Time
-	if count == cost {
-		// If first request, set expiration
-		if err := rs.client.Expire(ctx, redisKey, window).Err(); err != nil {
-			return nil, err
-		}
-		resetAt = time.Now().Add(window)
-	} else {
-		// Calculate reset time
-		ttl, err := rs.client.TTL(ctx, redisKey).Result()
-		if err != nil {
-			return nil, err
-		}
-		resetAt = time.Now().Add(ttl)
+// GetStatus checks current status without modifying the state for the key
+func (rs *RedisStorage) GetStatus(ctx context.Context, key string, limit storage.Limit) (*storage.Result, error) {
+	call, err := rs.statusCall(key, limit)
+	if err != nil {
+		return nil, err
 	}
+	return call.run(ctx, rs.client).result()
+}
 
-	// Check the limit
-	allowed := count <= limit
-
-	// Calculate remaining tokens
-	remaining := limit - count
-	if remaining < 0 {
-		remaining = 0
+// Refund returns up to amount of consumed cost to the key within its current window
+func (rs *RedisStorage) Refund(ctx context.Context, key string, limit storage.Limit, amount int64) (*storage.Result, error) {
+	call, err := rs.refundCall(key, limit, amount)
+	if err != nil {
+		return nil, err
 	}
-
-	return &storage.Result{
-		Allowed:   allowed,
-		Remaining: remaining,
-		ResetAt:   resetAt,
-		Limit:     limit,
-	}, nil
+	return call.run(ctx, rs.client).result()
 }
 
-// GetStatus checks current status without modifying the counter
-func (rs *RedisStorage) GetStatus(ctx context.Context, key string, limit int64) (*storage.Result, error) {
-	// TODO: Store limit in Redis. Take out of parameter.
-
+// checkCall builds the script call that checks and updates key with the limit's algorithm.
+func (rs *RedisStorage) checkCall(key string, limit storage.Limit, cost int64) (*scriptCall, error) {
 	// Build Redis key
 	redisKey := rs.formatKey(key)
 
-	// Get current count
-	getOutput, err := rs.client.Get(ctx, redisKey).Result()
-	if err == redis.Nil {
-		// Key doesn't exist so just give default result
-		// TODO: Look into alternative approaches for determining ResetAt if needed
-		return &storage.Result{
-			Allowed:   true,
-			Remaining: limit,
-			ResetAt:   time.Now(),
-			Limit:     limit,
-		}, nil
-	} else if err != nil {
-		return nil, err
-	}
-	count, err := strconv.ParseInt(getOutput, 10, 64)
-	if err != nil {
-		count = 0 // TODO: Could swap to return nil, err. Should look into that
+	switch limit.Algorithm {
+	case "", storage.FixedWindow:
+		return rs.checkFixedWindow(redisKey, limit, cost), nil
+	case storage.TokenBucket:
+		return rs.checkTokenBucket(redisKey, limit, cost), nil
+	case storage.GCRA:
+		return rs.checkGCRA(redisKey, limit, cost), nil
+	case storage.SlidingWindowLog:
+		return rs.checkSlidingLog(redisKey, limit, cost)
+	case storage.SlidingWindowCounter:
+		return rs.checkSlidingCounter(redisKey, limit, cost), nil
+	default:
+		return nil, storage.ErrUnsupportedAlgorithm
 	}
+}
 
-	// Check the limit
-	allowed := count <= limit
+// statusCall builds the script call that reads key with the limit's algorithm.
+func (rs *RedisStorage) statusCall(key string, limit storage.Limit) (*scriptCall, error) {
+	// Build Redis key
+	redisKey := rs.formatKey(key)
 
-	// Calculate remaining tokens
-	remaining := limit - count
-	if remaining < 0 {
-		remaining = 0
+	switch limit.Algorithm {
+	case "", storage.FixedWindow:
+		return rs.statusFixedWindow(redisKey, limit), nil
+	case storage.TokenBucket:
+		return rs.statusTokenBucket(redisKey, limit), nil
+	case storage.GCRA:
+		return rs.statusGCRA(redisKey, limit), nil
+	case storage.SlidingWindowLog:
+		return rs.statusSlidingLog(redisKey, limit)
+	case storage.SlidingWindowCounter:
+		return rs.statusSlidingCounter(redisKey, limit), nil
+	default:
+		return nil, storage.ErrUnsupportedAlgorithm
 	}
+}
 
-	// Get time until reset
-	ttl, err := rs.client.TTL(ctx, redisKey).Result()
-	if err != nil {
-		return nil, err
-	}
-	resetAt := time.Now().Add(ttl)
+// refundCall builds the script call that refunds key with the limit's algorithm.
+func (rs *RedisStorage) refundCall(key string, limit storage.Limit, amount int64) (*scriptCall, error) {
+	// Build Redis key
+	redisKey := rs.formatKey(key)
 
-	return &storage.Result{
-		Allowed:   allowed,
-		Remaining: remaining,
-		ResetAt:   resetAt,
-		Limit:     limit,
-	}, nil
+	switch limit.Algorithm {
+	case "", storage.FixedWindow:
+		return rs.refundFixedWindow(redisKey, limit, amount), nil
+	case storage.TokenBucket:
+		return rs.refundTokenBucket(redisKey, limit, amount), nil
+	case storage.GCRA:
+		return rs.refundGCRA(redisKey, limit, amount), nil
+	case storage.SlidingWindowLog:
+		return rs.refundSlidingLog(redisKey, limit, amount)
+	case storage.SlidingWindowCounter:
+		return rs.refundSlidingCounter(redisKey, limit, amount), nil
+	default:
+		return nil, storage.ErrUnsupportedAlgorithm
+	}
 }
 
 // Reset clears the rate limiter for an identifier
@@ -153,12 +192,27 @@ func (rs *RedisStorage) Reset(ctx context.Context, key string) error {
 	return nil
 }
 
+// Ping checks that Redis is reachable, so a health check can tell a failed server from a failed request
+func (rs *RedisStorage) Ping(ctx context.Context) error {
+	return rs.client.Ping(ctx).Err()
+}
+
 // Close cleans up connections when shutting down
 func (rs *RedisStorage) Close() error {
 	return rs.client.Close()
 }
 
-// formatKey consistently formats Redis keys
+// formatKey consistently formats Redis keys.
+// On Redis Cluster an identifier without a hash tag is wrapped in one, e.g. "ratelimit:{user:1}", so it lands on the
+// same slot as the tier and calendar keys derived from it, e.g. "ratelimit:{user:1}:1h0m0s".
 func (rs *RedisStorage) formatKey(identifier string) string {
-	return rs.keyPrefix + identifier
+	return rs.keyPrefix + rs.hashTagged(identifier)
+}
+
+// hashTagged wraps identifier in a hash tag on Redis Cluster unless it already has one.
+func (rs *RedisStorage) hashTagged(identifier string) string {
+	if !rs.hashTags || storage.HashTag(identifier) != identifier {
+		return identifier
+	}
+	return "{" + identifier + "}"
 }
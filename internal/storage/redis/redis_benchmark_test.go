@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
 	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/redis"
 	"github.com/AaronBrownDev/distributed-rate-limiter/internal/usecase"
 )
@@ -23,17 +24,17 @@ func BenchmarkCheckRateLimit_512Goroutines(b *testing.B) {
 
 func benchmarkConcurrent(b *testing.B, parallelism int) {
 	ctx := context.Background()
-	storage, _ := redis.NewRedisStorage(ctx, "redis:6379", "bench:")
-	defer storage.Close()
+	redisStorage, _ := redis.NewRedisStorage(ctx, "redis:6379", "bench:")
+	defer redisStorage.Close()
 
-	service := usecase.NewRateLimiterService(storage)
+	service := usecase.NewRateLimiterService(redisStorage)
 
 	b.SetParallelism(parallelism)
 	b.ResetTimer()
 
 	b.RunParallel(func(pb *testing.PB) {
 		for pb.Next() {
-			service.CheckRateLimit(ctx, "bench-key", 1000000, 60*time.Second, 1)
+			service.CheckRateLimit(ctx, usecase.CheckRequest{Key: "bench-key", Limit: storage.Limit{Limit: 1000000, Window: 60 * time.Second}, Cost: 1})
 		}
 	})
 }
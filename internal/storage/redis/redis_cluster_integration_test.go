@@ -0,0 +1,142 @@
+//go:build integration
+// +build integration
+
+package redis_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/redis"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/storagetest"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// clusterOptions connects to the local Redis Cluster, whose nodes can be overridden with REDIS_CLUSTER_ADDRS.
+func clusterOptions() *goredis.UniversalOptions {
+	addrs := []string{"redis-cluster:7000", "redis-cluster:7001", "redis-cluster:7002"}
+	if env := os.Getenv("REDIS_CLUSTER_ADDRS"); env != "" {
+		addrs = strings.Split(env, ",")
+	}
+	return &goredis.UniversalOptions{Addrs: addrs, IsClusterMode: true}
+}
+
+func TestIntegrationCluster_Conformance(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	storagetest.Run(t, func(t *testing.T) (storage.RateLimitStorage, storagetest.Clock) {
+		s, err := redis.NewUniversalRedisStorage(context.Background(), clusterOptions(), "test:")
+		if err != nil {
+			t.Fatalf("failed to connect to Redis Cluster: %v", err)
+		}
+		return s, storagetest.RealClock{}
+	})
+}
+
+func TestIntegrationCluster_CheckAndUpdateAll(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ctx := context.Background()
+	redisStorage, err := redis.NewUniversalRedisStorage(ctx, clusterOptions(), "test:")
+	if err != nil {
+		t.Fatalf("failed to connect to Redis Cluster: %v", err)
+	}
+
+	limit := storage.Limit{Limit: 10, Window: 60 * time.Second}
+	tests := []struct {
+		name    string
+		keys    []string
+		wantErr error
+	}{
+		{
+			name: "key and its tiers",
+			keys: []string{"integration-test-cluster", storage.SubKey("integration-test-cluster", "1h0m0s")},
+		},
+		{
+			name: "shared hash tag",
+			keys: []string{"{integration-test-org}", "{integration-test-org}:user:1"},
+		},
+		{
+			// These keys hash to slots 984 and 13243
+			name:    "different slots",
+			keys:    []string{"integration-test-a", "integration-test-b"},
+			wantErr: storage.ErrKeysNotColocated,
+		},
+	}
+
+	t.Cleanup(func() {
+		defer redisStorage.Close()
+
+		for _, tt := range tests {
+			for _, key := range tt.keys {
+				redisStorage.Reset(context.Background(), key)
+			}
+		}
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks := make([]storage.Check, len(tt.keys))
+			for i, key := range tt.keys {
+				checks[i] = storage.Check{Key: key, Limit: limit, Cost: 1}
+			}
+
+			results, err := redisStorage.CheckAndUpdateAll(ctx, checks)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CheckAndUpdateAll() error = %v, want %v", err, tt.wantErr)
+			}
+			for i, result := range results {
+				if !result.Allowed || result.Remaining != 9 {
+					t.Errorf("result %d: got allowed=%v remaining=%d, want true and 9", i, result.Allowed, result.Remaining)
+				}
+			}
+		})
+	}
+}
+
+func TestIntegrationCluster_CheckAndUpdateBatch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ctx := context.Background()
+	redisStorage, err := redis.NewUniversalRedisStorage(ctx, clusterOptions(), "test:")
+	if err != nil {
+		t.Fatalf("failed to connect to Redis Cluster: %v", err)
+	}
+
+	// Keys on different slots are pipelined to their own nodes
+	keys := []string{"integration-test-batch-a", "integration-test-batch-b", "integration-test-batch-c"}
+	t.Cleanup(func() {
+		defer redisStorage.Close()
+
+		for _, key := range keys {
+			redisStorage.Reset(context.Background(), key)
+		}
+	})
+
+	limit := storage.Limit{Limit: 10, Window: 60 * time.Second}
+	checks := make([]storage.Check, len(keys))
+	for i, key := range keys {
+		checks[i] = storage.Check{Key: key, Limit: limit, Cost: int64(i + 1)}
+	}
+	results, err := redisStorage.CheckAndUpdateBatch(ctx, checks)
+	if err != nil {
+		t.Fatalf("CheckAndUpdateBatch() error = %v", err)
+	}
+
+	for i, result := range results {
+		if want := limit.Limit - int64(i+1); result.Remaining != want {
+			t.Errorf("result %d: got remaining=%d, want %d", i, result.Remaining, want)
+		}
+	}
+}
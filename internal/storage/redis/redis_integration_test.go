@@ -10,8 +10,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
 	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/redis"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/storagetest"
 	"github.com/AaronBrownDev/distributed-rate-limiter/internal/usecase"
+	goredis "github.com/redis/go-redis/v9"
 )
 
 func TestIntegration_RateLimiting(t *testing.T) {
@@ -21,7 +24,7 @@ func TestIntegration_RateLimiting(t *testing.T) {
 
 	ctx := context.Background()
 
-	storage, err := redis.NewRedisStorage(ctx, "redis:6379", "test:")
+	redisStorage, err := redis.NewRedisStorage(ctx, "redis:6379", "test:")
 	if err != nil {
 		t.Fatalf("failed to connect to Redis: %v", err)
 	}
@@ -30,15 +33,15 @@ func TestIntegration_RateLimiting(t *testing.T) {
 	key := "integration-test"
 
 	t.Cleanup(func() {
-		defer storage.Close()
+		defer redisStorage.Close()
 
 		cleanupCtx := context.Background()
-		if err = storage.Reset(cleanupCtx, key); err != nil {
+		if err = redisStorage.Reset(cleanupCtx, key); err != nil {
 			t.Logf("failed to delete the key %s: %v", key, err)
 		}
 	})
 
-	service := usecase.NewRateLimiterService(storage)
+	service := usecase.NewRateLimiterService(redisStorage)
 
 	// config
 	var limit int64 = 100000
@@ -54,7 +57,7 @@ func TestIntegration_RateLimiting(t *testing.T) {
 		wg.Add(1)
 		go func(reqNum int64) {
 			defer wg.Done()
-			result, err := service.CheckRateLimit(ctx, key, limit, 60*time.Second, 1)
+			result, err := service.CheckRateLimit(ctx, usecase.CheckRequest{Key: key, Limit: storage.Limit{Limit: limit, Window: 60 * time.Second}, Cost: 1})
 			if err != nil {
 				t.Errorf("request %d failed: %v", i, err)
 				errorCount.Add(1)
@@ -87,3 +90,176 @@ func TestIntegration_RateLimiting(t *testing.T) {
 	t.Logf("Summary: %d allowed, %d denied, %d errors out of %d requests",
 		allowedCount.Load(), deniedCount.Load(), errorCount.Load(), concurrentRequests)
 }
+
+func TestIntegration_CheckAndUpdate_RestoresMissingTTL(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ctx := context.Background()
+
+	redisStorage, err := redis.NewRedisStorage(ctx, "redis:6379", "test:")
+	if err != nil {
+		t.Fatalf("failed to connect to Redis: %v", err)
+	}
+
+	key := "integration-test-no-ttl"
+
+	// Simulate a counter left behind without an expiry
+	client := goredis.NewClient(&goredis.Options{Addr: "redis:6379"})
+	t.Cleanup(func() {
+		defer redisStorage.Close()
+		defer client.Close()
+
+		if err := redisStorage.Reset(context.Background(), key); err != nil {
+			t.Logf("failed to delete the key %s: %v", key, err)
+		}
+	})
+	if err := client.Set(ctx, "test:"+key, 5, 0).Err(); err != nil {
+		t.Fatalf("failed to seed key: %v", err)
+	}
+
+	result, err := redisStorage.CheckAndUpdate(ctx, key, storage.Limit{Limit: 10, Window: 60 * time.Second}, 1)
+	if err != nil {
+		t.Fatalf("CheckAndUpdate() error = %v", err)
+	}
+
+	if result.Remaining != 4 {
+		t.Errorf("expected 4 remaining, got %d", result.Remaining)
+	}
+
+	ttl, err := client.TTL(ctx, "test:"+key).Result()
+	if err != nil {
+		t.Fatalf("failed to read TTL: %v", err)
+	}
+	if ttl <= 0 || ttl > 60*time.Second {
+		t.Errorf("expected TTL in (0, 60s], got %v", ttl)
+	}
+	if time.Until(result.ResetAt) <= 0 {
+		t.Errorf("expected ResetAt in the future, got %v", result.ResetAt)
+	}
+}
+
+func TestIntegration_CheckAndUpdateBatch_ScriptFlushed(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ctx := context.Background()
+
+	redisStorage, err := redis.NewRedisStorage(ctx, "redis:6379", "test:")
+	if err != nil {
+		t.Fatalf("failed to connect to Redis: %v", err)
+	}
+
+	keys := []string{"integration-test-batch-a", "integration-test-batch-b"}
+
+	// Simulate a Redis restart dropping the preloaded scripts
+	client := goredis.NewClient(&goredis.Options{Addr: "redis:6379"})
+	t.Cleanup(func() {
+		defer redisStorage.Close()
+		defer client.Close()
+
+		for _, key := range keys {
+			if err := redisStorage.Reset(context.Background(), key); err != nil {
+				t.Logf("failed to delete the key %s: %v", key, err)
+			}
+		}
+	})
+	if err := client.ScriptFlush(ctx).Err(); err != nil {
+		t.Fatalf("failed to flush scripts: %v", err)
+	}
+
+	limit := storage.Limit{Limit: 10, Window: 60 * time.Second}
+	results, err := redisStorage.CheckAndUpdateBatch(ctx, []storage.Check{
+		{Key: keys[0], Limit: limit, Cost: 1},
+		{Key: keys[1], Limit: limit, Cost: 2},
+	})
+	if err != nil {
+		t.Fatalf("CheckAndUpdateBatch() error = %v", err)
+	}
+
+	if results[0].Remaining != 9 || results[1].Remaining != 8 {
+		t.Errorf("expected 9 and 8 remaining, got %d and %d", results[0].Remaining, results[1].Remaining)
+	}
+}
+
+func TestIntegration_Conformance(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	storagetest.Run(t, func(t *testing.T) (storage.RateLimitStorage, storagetest.Clock) {
+		s, err := redis.NewRedisStorage(context.Background(), "redis:6379", "test:")
+		if err != nil {
+			t.Fatalf("failed to connect to Redis: %v", err)
+		}
+		return s, storagetest.RealClock{}
+	})
+}
+
+func TestIntegration_TokenBucket_SharedAcrossInstances(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ctx := context.Background()
+
+	// Two storages stand in for two limiter replicas
+	first, err := redis.NewRedisStorage(ctx, "redis:6379", "test:")
+	if err != nil {
+		t.Fatalf("failed to connect to Redis: %v", err)
+	}
+	second, err := redis.NewRedisStorage(ctx, "redis:6379", "test:")
+	if err != nil {
+		t.Fatalf("failed to connect to Redis: %v", err)
+	}
+
+	key := "integration-test-token-bucket"
+	limit := storage.Limit{Algorithm: storage.TokenBucket, Limit: 4, Window: time.Second, RefillRate: 2}
+
+	t.Cleanup(func() {
+		defer first.Close()
+		defer second.Close()
+
+		first.Reset(context.Background(), key)
+	})
+
+	result, err := first.CheckAndUpdate(ctx, key, limit, 4)
+	if err != nil {
+		t.Fatalf("CheckAndUpdate() error = %v", err)
+	}
+	if !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("first replica: got allowed=%v remaining=%d, want true and 0", result.Allowed, result.Remaining)
+	}
+
+	result, err = second.CheckAndUpdate(ctx, key, limit, 1)
+	if err != nil {
+		t.Fatalf("CheckAndUpdate() error = %v", err)
+	}
+	if result.Allowed {
+		t.Errorf("second replica: expected bucket drained by first replica to deny")
+	}
+
+	// One refill period adds RefillRate tokens for both replicas
+	time.Sleep(limit.Window + 100*time.Millisecond)
+
+	result, err = second.CheckAndUpdate(ctx, key, limit, 1)
+	if err != nil {
+		t.Fatalf("CheckAndUpdate() error = %v", err)
+	}
+	if !result.Allowed || result.Remaining != 1 {
+		t.Errorf("after refill: got allowed=%v remaining=%d, want true and 1", result.Allowed, result.Remaining)
+	}
+
+	status, err := first.GetStatus(ctx, key, limit)
+	if err != nil {
+		t.Fatalf("GetStatus() error = %v", err)
+	}
+	if status.Remaining != 1 {
+		t.Errorf("status: got remaining=%d, want 1", status.Remaining)
+	}
+	if until := time.Until(status.ResetAt); until <= 0 || until > 2*limit.Window {
+		t.Errorf("status: expected bucket to be full within two periods, got %v", until)
+	}
+}
@@ -0,0 +1,922 @@
+package redis
+
+import "github.com/redis/go-redis/v9"
+
+// fixedWindowCheckScript increments the counter and reads its TTL in one atomic step.
+// The expiry is set whenever the key has none, which covers the first request of a
+// window as well as any key that was left without a TTL.
+//
+// KEYS[1] - counter key
+// ARGV[1] - cost
+// ARGV[2] - window in milliseconds
+//
+// Returns {count, ttl in milliseconds}.
+var fixedWindowCheckScript = redis.NewScript(`
+local count = redis.call('INCRBY', KEYS[1], ARGV[1])
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl < 0 then
+	ttl = tonumber(ARGV[2])
+	redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return {count, ttl}
+`)
+
+// fixedWindowStatusScript reads the counter and its TTL without modifying either.
+//
+// KEYS[1] - counter key
+//
+// Returns {count, ttl in milliseconds}, or {0, -2} if the key does not exist.
+var fixedWindowStatusScript = redis.NewScript(`
+local count = redis.call('GET', KEYS[1])
+if not count then
+	return {0, -2}
+end
+return {tonumber(count), redis.call('PTTL', KEYS[1])}
+`)
+
+// fixedWindowRefundScript takes up to amount off the counter, keeping its TTL. A missing or expired window is left alone.
+//
+// KEYS[1] - counter key
+// ARGV[1] - amount
+//
+// Returns {count, ttl in milliseconds}, or {0, -2} if the key does not exist.
+var fixedWindowRefundScript = redis.NewScript(`
+local count = tonumber(redis.call('GET', KEYS[1]))
+if not count then
+	return {0, -2}
+end
+
+local refund = math.min(tonumber(ARGV[1]), count)
+if refund > 0 then
+	count = redis.call('DECRBY', KEYS[1], refund)
+end
+return {count, redis.call('PTTL', KEYS[1])}
+`)
+
+// tokenBucketCheckScript refills the bucket using the Redis server clock and takes cost tokens if enough are available.
+// The bucket is a hash of the current tokens and the time of the last refill. It gains rate tokens
+// every whole period since the last refill, so every limiter instance sees the same refill schedule.
+// A bucket that is full again is deleted, since it is equivalent to a missing one.
+//
+// KEYS[1] - bucket key
+// ARGV[1] - capacity
+// ARGV[2] - refill rate (tokens per period)
+// ARGV[3] - refill period in microseconds
+// ARGV[4] - cost
+//
+// Returns {allowed (0 or 1), tokens, microseconds until full, microseconds until cost tokens are available}.
+var tokenBucketCheckScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local period = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'refilled_at')
+local tokens = tonumber(state[1])
+local refilled_at = tonumber(state[2])
+if tokens == nil or refilled_at == nil then
+	tokens = capacity
+	refilled_at = now
+end
+
+local elapsed = now - refilled_at
+if elapsed >= period then
+	local increments = math.floor(elapsed / period)
+	tokens = math.min(capacity, tokens + increments * rate)
+	refilled_at = refilled_at + increments * period
+end
+
+local allowed = 0
+if tokens >= cost then
+	tokens = tokens - cost
+	allowed = 1
+end
+
+local function until_available(wanted)
+	if tokens >= wanted then
+		return 0
+	end
+	return refilled_at + math.ceil((wanted - tokens) / rate) * period - now
+end
+
+local until_full = until_available(capacity)
+local retry_after = 0
+if allowed == 0 then
+	retry_after = until_available(math.min(cost, capacity))
+end
+
+if until_full > 0 then
+	redis.call('HSET', KEYS[1], 'tokens', tokens, 'refilled_at', refilled_at)
+	redis.call('PEXPIRE', KEYS[1], math.ceil(until_full / 1000))
+else
+	redis.call('DEL', KEYS[1])
+end
+
+return {allowed, tokens, until_full, retry_after}
+`)
+
+// tokenBucketStatusScript refills the bucket using the Redis server clock without storing the result.
+//
+// KEYS[1] - bucket key
+// ARGV[1] - capacity
+// ARGV[2] - refill rate (tokens per period)
+// ARGV[3] - refill period in microseconds
+//
+// Returns {tokens, microseconds until full}.
+var tokenBucketStatusScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local period = tonumber(ARGV[3])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'refilled_at')
+local tokens = tonumber(state[1])
+local refilled_at = tonumber(state[2])
+if tokens == nil or refilled_at == nil then
+	return {capacity, 0}
+end
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+local elapsed = now - refilled_at
+if elapsed >= period then
+	local increments = math.floor(elapsed / period)
+	tokens = math.min(capacity, tokens + increments * rate)
+	refilled_at = refilled_at + increments * period
+end
+
+local until_full = 0
+if tokens < capacity then
+	until_full = refilled_at + math.ceil((capacity - tokens) / rate) * period - now
+end
+
+return {tokens, until_full}
+`)
+
+// tokenBucketRefundScript refills the bucket using the Redis server clock and puts back up to amount tokens,
+// never above capacity. A missing bucket is already full and is left alone.
+//
+// KEYS[1] - bucket key
+// ARGV[1] - capacity
+// ARGV[2] - refill rate (tokens per period)
+// ARGV[3] - refill period in microseconds
+// ARGV[4] - amount
+//
+// Returns {tokens, microseconds until full}.
+var tokenBucketRefundScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local period = tonumber(ARGV[3])
+local amount = tonumber(ARGV[4])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'refilled_at')
+local tokens = tonumber(state[1])
+local refilled_at = tonumber(state[2])
+if tokens == nil or refilled_at == nil then
+	return {capacity, 0}
+end
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+local elapsed = now - refilled_at
+if elapsed >= period then
+	local increments = math.floor(elapsed / period)
+	tokens = math.min(capacity, tokens + increments * rate)
+	refilled_at = refilled_at + increments * period
+end
+tokens = math.min(capacity, tokens + amount)
+
+local until_full = 0
+if tokens < capacity then
+	until_full = refilled_at + math.ceil((capacity - tokens) / rate) * period - now
+end
+
+if until_full > 0 then
+	redis.call('HSET', KEYS[1], 'tokens', tokens, 'refilled_at', refilled_at)
+	redis.call('PEXPIRE', KEYS[1], math.ceil(until_full / 1000))
+else
+	redis.call('DEL', KEYS[1])
+end
+
+return {tokens, until_full}
+`)
+
+// gcraCheckScript records a request of the given cost if it conforms to the generic cell rate algorithm.
+// Only the theoretical arrival time (TAT) is stored, as microseconds on the Redis server clock.
+// The key expires when the TAT passes, since the whole burst is available again at that point.
+//
+// KEYS[1] - TAT key
+// ARGV[1] - emission interval in microseconds
+// ARGV[2] - burst (limit)
+// ARGV[3] - cost
+//
+// Returns {allowed (0 or 1), remaining, microseconds until the TAT, microseconds until allowed}.
+var gcraCheckScript = redis.NewScript(`
+local emission = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local tolerance = emission * burst
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+local tat = tonumber(redis.call('GET', KEYS[1]))
+if tat == nil or tat < now then
+	tat = now
+end
+
+local allowed = 0
+local retry_after = 0
+local new_tat = tat + emission * cost
+local allow_at = new_tat - tolerance
+if allow_at <= now then
+	allowed = 1
+	tat = new_tat
+	redis.call('SET', KEYS[1], string.format('%d', tat), 'PX', math.ceil((tat - now) / 1000))
+else
+	retry_after = allow_at - now
+end
+
+local remaining = math.max(0, math.floor((tolerance - (tat - now)) / emission))
+
+return {allowed, remaining, tat - now, retry_after}
+`)
+
+// gcraStatusScript reads the remaining burst without recording a request.
+//
+// KEYS[1] - TAT key
+// ARGV[1] - emission interval in microseconds
+// ARGV[2] - burst (limit)
+//
+// Returns {remaining, microseconds until the TAT}.
+var gcraStatusScript = redis.NewScript(`
+local emission = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+local tat = tonumber(redis.call('GET', KEYS[1]))
+if tat == nil then
+	return {burst, 0}
+end
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+if tat < now then
+	tat = now
+end
+
+local remaining = math.max(0, math.floor((emission * burst - (tat - now)) / emission))
+
+return {remaining, tat - now}
+`)
+
+// gcraRefundScript moves the theoretical arrival time back by amount requests, but never before now.
+// A missing TAT already has the whole burst available and is left alone.
+//
+// KEYS[1] - TAT key
+// ARGV[1] - emission interval in microseconds
+// ARGV[2] - burst (limit)
+// ARGV[3] - amount
+//
+// Returns {remaining, microseconds until the TAT}.
+var gcraRefundScript = redis.NewScript(`
+local emission = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local amount = tonumber(ARGV[3])
+
+local tat = tonumber(redis.call('GET', KEYS[1]))
+if tat == nil then
+	return {burst, 0}
+end
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+tat = math.max(now, tat - emission * amount)
+if tat > now then
+	redis.call('SET', KEYS[1], string.format('%d', tat), 'PX', math.ceil((tat - now) / 1000))
+else
+	redis.call('DEL', KEYS[1])
+end
+
+local remaining = math.max(0, math.floor((emission * burst - (tat - now)) / emission))
+
+return {remaining, tat - now}
+`)
+
+// slidingLogCheckScript records the request in a sorted set if the cost within the last window stays under the limit.
+// Each unit of cost is one member scored by its arrival time on the Redis server clock, so ZCARD is the
+// cost in the window. Expired members are trimmed first; denied requests are not recorded.
+//
+// KEYS[1] - log key
+// ARGV[1] - window in microseconds
+// ARGV[2] - limit
+// ARGV[3] - cost
+// ARGV[4] - unique request ID used to build member names
+//
+// Returns {allowed (0 or 1), count, microseconds until the oldest entry expires, microseconds until allowed}.
+var slidingLogCheckScript = redis.NewScript(`
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+
+local allowed = 0
+local retry_after = 0
+if count + cost <= limit then
+	allowed = 1
+	for i = 1, cost do
+		redis.call('ZADD', KEYS[1], now, ARGV[4] .. ':' .. i)
+	end
+	count = count + cost
+	redis.call('PEXPIRE', KEYS[1], math.ceil(window / 1000))
+else
+	-- Wait for enough of the oldest entries to expire, or all of them if cost can never fit
+	local need = math.min(count + cost - limit, count)
+	if need > 0 then
+		local entry = redis.call('ZRANGE', KEYS[1], need - 1, need - 1, 'WITHSCORES')
+		retry_after = tonumber(entry[2]) + window - now
+	end
+end
+
+local reset_in = 0
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset_in = tonumber(oldest[2]) + window - now
+end
+
+return {allowed, count, reset_in, retry_after}
+`)
+
+// slidingLogStatusScript counts the cost within the last window without recording a request.
+//
+// KEYS[1] - log key
+// ARGV[1] - window in microseconds
+//
+// Returns {count, microseconds until the oldest entry expires}.
+var slidingLogStatusScript = redis.NewScript(`
+local window = tonumber(ARGV[1])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+local cutoff = '(' .. string.format('%d', now - window)
+
+local count = redis.call('ZCOUNT', KEYS[1], cutoff, '+inf')
+
+local reset_in = 0
+local oldest = redis.call('ZRANGEBYSCORE', KEYS[1], cutoff, '+inf', 'WITHSCORES', 'LIMIT', 0, 1)
+if oldest[2] then
+	reset_in = tonumber(oldest[2]) + window - now
+end
+
+return {count, reset_in}
+`)
+
+// slidingLogRefundScript removes up to amount members recorded within the last window, newest first.
+//
+// KEYS[1] - log key
+// ARGV[1] - window in microseconds
+// ARGV[2] - amount
+//
+// Returns {count, microseconds until the oldest entry expires}.
+var slidingLogRefundScript = redis.NewScript(`
+local window = tonumber(ARGV[1])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+
+local refund = math.min(tonumber(ARGV[2]), count)
+if refund > 0 then
+	redis.call('ZREMRANGEBYRANK', KEYS[1], -refund, -1)
+	count = count - refund
+end
+
+local reset_in = 0
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset_in = tonumber(oldest[2]) + window - now
+end
+
+return {count, reset_in}
+`)
+
+// slidingCounterCheckScript adds cost to the current fixed window if the weighted count stays under the limit.
+// The hash holds the start of the current window and the counts of the current and previous windows.
+// The previous count is weighted by how much of it still overlaps the last window. Denied requests are not counted.
+//
+// KEYS[1] - counter key
+// ARGV[1] - window in microseconds
+// ARGV[2] - limit
+// ARGV[3] - cost
+//
+// Returns {allowed (0 or 1), count, microseconds until both windows are empty, microseconds until allowed}.
+var slidingCounterCheckScript = redis.NewScript(`
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+local start = now - (now % window)
+local elapsed = now - start
+
+-- Roll the counters forward to the fixed window containing now
+local state = redis.call('HMGET', KEYS[1], 'start', 'current', 'previous')
+local current = tonumber(state[2]) or 0
+local previous = tonumber(state[3]) or 0
+local stored = tonumber(state[1])
+if stored ~= start then
+	if stored == start - window then
+		previous = current
+	else
+		previous = 0
+	end
+	current = 0
+end
+
+local count = math.floor(previous * (window - elapsed) / window) + current
+
+local allowed = 0
+if count + cost <= limit then
+	allowed = 1
+	current = current + cost
+	count = count + cost
+	redis.call('HSET', KEYS[1], 'start', string.format('%d', start), 'current', current, 'previous', previous)
+	redis.call('PEXPIRE', KEYS[1], math.ceil((2 * window - elapsed) / 1000))
+end
+
+local reset_in = 0
+if current > 0 then
+	reset_in = 2 * window - elapsed
+elseif previous > 0 then
+	reset_in = window - elapsed
+end
+
+local retry_after = 0
+if allowed == 0 then
+	if cost > limit then
+		-- Never fits, so report when the counters are empty
+		retry_after = reset_in
+	elseif current + cost <= limit then
+		-- Fits in this window once enough of the previous window has slid out
+		retry_after = math.ceil(window * (1 - (limit - current - cost) / previous)) - elapsed
+	else
+		-- Has to wait for this window to become the previous one and slide out
+		retry_after = window - elapsed + math.ceil(window * (1 - (limit - cost) / current))
+	end
+end
+
+return {allowed, count, reset_in, retry_after}
+`)
+
+// slidingCounterStatusScript reads the weighted count without modifying it.
+//
+// KEYS[1] - counter key
+// ARGV[1] - window in microseconds
+//
+// Returns {count, microseconds until both windows are empty}.
+var slidingCounterStatusScript = redis.NewScript(`
+local window = tonumber(ARGV[1])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+local start = now - (now % window)
+local elapsed = now - start
+
+-- Roll the counters forward to the fixed window containing now
+local state = redis.call('HMGET', KEYS[1], 'start', 'current', 'previous')
+local current = tonumber(state[2]) or 0
+local previous = tonumber(state[3]) or 0
+local stored = tonumber(state[1])
+if stored ~= start then
+	if stored == start - window then
+		previous = current
+	else
+		previous = 0
+	end
+	current = 0
+end
+
+local count = math.floor(previous * (window - elapsed) / window) + current
+
+local reset_in = 0
+if current > 0 then
+	reset_in = 2 * window - elapsed
+elseif previous > 0 then
+	reset_in = window - elapsed
+end
+
+return {count, reset_in}
+`)
+
+// slidingCounterRefundScript takes up to amount off the current fixed window.
+// The previous window has ended, so its count is left alone.
+//
+// KEYS[1] - counter key
+// ARGV[1] - window in microseconds
+// ARGV[2] - amount
+//
+// Returns {count, microseconds until both windows are empty}.
+var slidingCounterRefundScript = redis.NewScript(`
+local window = tonumber(ARGV[1])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+local start = now - (now % window)
+local elapsed = now - start
+
+-- Roll the counters forward to the fixed window containing now
+local state = redis.call('HMGET', KEYS[1], 'start', 'current', 'previous')
+local current = tonumber(state[2]) or 0
+local previous = tonumber(state[3]) or 0
+local stored = tonumber(state[1])
+if stored ~= start then
+	if stored == start - window then
+		previous = current
+	else
+		previous = 0
+	end
+	current = 0
+end
+
+-- Only the current window can have cost from this window to refund
+local refund = math.min(tonumber(ARGV[2]), current)
+if refund > 0 then
+	current = current - refund
+	redis.call('HSET', KEYS[1], 'current', current)
+end
+
+local count = math.floor(previous * (window - elapsed) / window) + current
+
+local reset_in = 0
+if current > 0 then
+	reset_in = 2 * window - elapsed
+elseif previous > 0 then
+	reset_in = window - elapsed
+end
+
+return {count, reset_in}
+`)
+
+// checkAllScript checks several keys, each with its own algorithm, and applies the updates only if every check is allowed.
+// Each algorithm's check is split into an evaluation, which reads state and reports the verdict along with the key's
+// unchanged state, and a commit, which writes the update. Commits run only once every key has been evaluated,
+// so a denied batch leaves every key as it was. Every check uses the same Redis server clock reading.
+// The per-algorithm logic mirrors the single-key check scripts above.
+//
+// KEYS[i] - key of check i (keys must be distinct)
+// ARGV[1] - unique request ID used to build sliding log member names
+// ARGV[5i-3..5i+1] - algorithm, limit, interval, refill rate and cost of check i
+//
+// The interval is the window in milliseconds for fixed_window, the emission interval in microseconds
+// for gcra and the window in microseconds for the other algorithms.
+//
+// Returns {allowed (0 or 1), remaining, microseconds until reset, microseconds until allowed} for each check, in order.
+var checkAllScript = redis.NewScript(`
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+-- Each evaluation returns the verdict, {remaining, reset in, retry after} without the update,
+-- and a commit function that applies the update and returns {remaining, reset in}
+
+local function fixed_window(key, limit, window, rate, cost)
+	local count = tonumber(redis.call('GET', key)) or 0
+	local ttl = redis.call('PTTL', key)
+	local expires = ttl
+	if expires < 0 then
+		expires = window
+	end
+
+	local allowed = count + cost <= limit
+	local retry_after = 0
+	if not allowed then
+		retry_after = expires * 1000
+	end
+
+	local commit = function()
+		redis.call('INCRBY', key, cost)
+		if ttl < 0 then
+			redis.call('PEXPIRE', key, window)
+		end
+		return {math.max(0, limit - count - cost), expires * 1000}
+	end
+	return allowed, {math.max(0, limit - count), math.max(0, ttl) * 1000, retry_after}, commit
+end
+
+local function token_bucket(key, capacity, period, rate, cost)
+	local state = redis.call('HMGET', key, 'tokens', 'refilled_at')
+	local tokens = tonumber(state[1])
+	local refilled_at = tonumber(state[2])
+	if tokens == nil or refilled_at == nil then
+		tokens = capacity
+		refilled_at = now
+	end
+
+	local elapsed = now - refilled_at
+	if elapsed >= period then
+		local increments = math.floor(elapsed / period)
+		tokens = math.min(capacity, tokens + increments * rate)
+		refilled_at = refilled_at + increments * period
+	end
+
+	local function until_available(have, wanted)
+		if have >= wanted then
+			return 0
+		end
+		return refilled_at + math.ceil((wanted - have) / rate) * period - now
+	end
+
+	local allowed = tokens >= cost
+	local retry_after = 0
+	if not allowed then
+		retry_after = until_available(tokens, math.min(cost, capacity))
+	end
+
+	local commit = function()
+		local left = tokens - cost
+		local until_full = until_available(left, capacity)
+		if until_full > 0 then
+			redis.call('HSET', key, 'tokens', left, 'refilled_at', refilled_at)
+			redis.call('PEXPIRE', key, math.ceil(until_full / 1000))
+		else
+			redis.call('DEL', key)
+		end
+		return {left, until_full}
+	end
+	return allowed, {tokens, until_available(tokens, capacity), retry_after}, commit
+end
+
+local function gcra(key, burst, emission, rate, cost)
+	local tolerance = emission * burst
+	local tat = tonumber(redis.call('GET', key))
+	if tat == nil or tat < now then
+		tat = now
+	end
+
+	local function remaining(at)
+		return math.max(0, math.floor((tolerance - (at - now)) / emission))
+	end
+
+	local new_tat = tat + emission * cost
+	local allow_at = new_tat - tolerance
+	local allowed = allow_at <= now
+	local retry_after = 0
+	if not allowed then
+		retry_after = allow_at - now
+	end
+
+	local commit = function()
+		redis.call('SET', key, string.format('%d', new_tat), 'PX', math.ceil((new_tat - now) / 1000))
+		return {remaining(new_tat), new_tat - now}
+	end
+	return allowed, {remaining(tat), tat - now, retry_after}, commit
+end
+
+local function sliding_window_log(key, limit, window, rate, cost, member)
+	redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+	local count = redis.call('ZCARD', key)
+
+	local reset_in = 0
+	local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+	if oldest[2] then
+		reset_in = tonumber(oldest[2]) + window - now
+	end
+
+	local allowed = count + cost <= limit
+	local retry_after = 0
+	if not allowed then
+		-- Wait for enough of the oldest entries to expire, or all of them if cost can never fit
+		local need = math.min(count + cost - limit, count)
+		if need > 0 then
+			local entry = redis.call('ZRANGE', key, need - 1, need - 1, 'WITHSCORES')
+			retry_after = tonumber(entry[2]) + window - now
+		end
+	end
+
+	local commit = function()
+		for i = 1, cost do
+			redis.call('ZADD', key, now, member .. ':' .. i)
+		end
+		redis.call('PEXPIRE', key, math.ceil(window / 1000))
+		if count == 0 then
+			reset_in = window
+		end
+		return {limit - count - cost, reset_in}
+	end
+	return allowed, {math.max(0, limit - count), reset_in, retry_after}, commit
+end
+
+local function sliding_window_counter(key, limit, window, rate, cost)
+	local start = now - (now % window)
+	local elapsed = now - start
+
+	-- Roll the counters forward to the fixed window containing now
+	local state = redis.call('HMGET', key, 'start', 'current', 'previous')
+	local current = tonumber(state[2]) or 0
+	local previous = tonumber(state[3]) or 0
+	local stored = tonumber(state[1])
+	if stored ~= start then
+		if stored == start - window then
+			previous = current
+		else
+			previous = 0
+		end
+		current = 0
+	end
+
+	local function reset_in(counted)
+		if counted > 0 then
+			return 2 * window - elapsed
+		elseif previous > 0 then
+			return window - elapsed
+		end
+		return 0
+	end
+
+	local count = math.floor(previous * (window - elapsed) / window) + current
+	local allowed = count + cost <= limit
+	local retry_after = 0
+	if not allowed then
+		if cost > limit then
+			retry_after = reset_in(current)
+		elseif current + cost <= limit then
+			retry_after = math.ceil(window * (1 - (limit - current - cost) / previous)) - elapsed
+		else
+			retry_after = window - elapsed + math.ceil(window * (1 - (limit - cost) / current))
+		end
+	end
+
+	local commit = function()
+		redis.call('HSET', key, 'start', string.format('%d', start), 'current', current + cost, 'previous', previous)
+		redis.call('PEXPIRE', key, math.ceil((2 * window - elapsed) / 1000))
+		return {limit - count - cost, reset_in(current + cost)}
+	end
+	return allowed, {math.max(0, limit - count), reset_in(current), retry_after}, commit
+end
+
+local algorithms = {
+	fixed_window = fixed_window,
+	token_bucket = token_bucket,
+	gcra = gcra,
+	sliding_window_log = sliding_window_log,
+	sliding_window_counter = sliding_window_counter,
+}
+
+local all_allowed = true
+local verdicts, states, commits = {}, {}, {}
+for i, key in ipairs(KEYS) do
+	local base = 1 + (i - 1) * 5
+	local check = algorithms[ARGV[base + 1]]
+	verdicts[i], states[i], commits[i] = check(key, tonumber(ARGV[base + 2]), tonumber(ARGV[base + 3]),
+		tonumber(ARGV[base + 4]), tonumber(ARGV[base + 5]), ARGV[1] .. ':' .. i)
+	all_allowed = all_allowed and verdicts[i]
+end
+
+local output = {}
+for i = 1, #KEYS do
+	local allowed, remaining, reset_in, retry_after = 0, states[i][1], states[i][2], states[i][3]
+	if verdicts[i] then
+		allowed = 1
+	end
+	if all_allowed then
+		local updated = commits[i]()
+		remaining, reset_in = updated[1], updated[2]
+	end
+	table.insert(output, allowed)
+	table.insert(output, remaining)
+	table.insert(output, reset_in)
+	table.insert(output, retry_after)
+end
+return output
+`)
+
+// policyUpdateScript replaces a policy only if it already exists.
+//
+// KEYS[1] - policy hash
+// ARGV[1] - policy name
+// ARGV[2] - encoded policy
+//
+// Returns 1 if the policy was replaced, 0 if it does not exist.
+var policyUpdateScript = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+return 1
+`)
+
+// leaseAcquireScript adds a lease to the key's lease set if fewer than limit unexpired leases are held.
+// The set is scored by when each lease expires on the Redis server clock, so expired leases are trimmed
+// before counting and the leases of crashed clients are reclaimed. The key expires with its last lease.
+//
+// KEYS[1] - lease set key
+// ARGV[1] - limit
+// ARGV[2] - lease TTL in microseconds
+// ARGV[3] - lease ID
+//
+// Returns {acquired (0 or 1), leases held, microseconds until the oldest lease expires}.
+var leaseAcquireScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local ttl = tonumber(ARGV[2])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+local held = redis.call('ZCARD', KEYS[1])
+
+local acquired = 0
+if held < limit then
+	redis.call('ZADD', KEYS[1], now + ttl, ARGV[3])
+	held = held + 1
+	acquired = 1
+end
+
+local oldest_in = 0
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+if oldest[2] then
+	oldest_in = tonumber(oldest[2]) - now
+end
+local newest = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+if newest[2] then
+	redis.call('PEXPIRE', KEYS[1], math.ceil((tonumber(newest[2]) - now) / 1000))
+end
+
+return {acquired, held, oldest_in}
+`)
+
+// leaseRenewScript moves an unexpired lease's expiry to the lease TTL from now.
+//
+// KEYS[1] - lease set key
+// ARGV[1] - lease TTL in microseconds
+// ARGV[2] - lease ID
+//
+// Returns {renewed (0 or 1), leases held, microseconds until the oldest lease expires}.
+var leaseRenewScript = redis.NewScript(`
+local ttl = tonumber(ARGV[1])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+if not redis.call('ZSCORE', KEYS[1], ARGV[2]) then
+	return {0, 0, 0}
+end
+redis.call('ZADD', KEYS[1], 'XX', now + ttl, ARGV[2])
+local held = redis.call('ZCARD', KEYS[1])
+
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+local newest = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+redis.call('PEXPIRE', KEYS[1], math.ceil((tonumber(newest[2]) - now) / 1000))
+
+return {1, held, tonumber(oldest[2]) - now}
+`)
+
+// leaseReleaseScript removes an unexpired lease from the key's lease set.
+//
+// KEYS[1] - lease set key
+// ARGV[1] - lease ID
+//
+// Returns 1 if the lease was released, 0 if it was not held.
+var leaseReleaseScript = redis.NewScript(`
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+return redis.call('ZREM', KEYS[1], ARGV[1])
+`)
+
+// scripts lists every Lua script used by RedisStorage so they can be preloaded.
+var scripts = []*redis.Script{
+	fixedWindowCheckScript,
+	fixedWindowStatusScript,
+	fixedWindowRefundScript,
+	tokenBucketCheckScript,
+	tokenBucketStatusScript,
+	tokenBucketRefundScript,
+	gcraCheckScript,
+	gcraStatusScript,
+	gcraRefundScript,
+	slidingLogCheckScript,
+	slidingLogStatusScript,
+	slidingLogRefundScript,
+	slidingCounterCheckScript,
+	slidingCounterStatusScript,
+	slidingCounterRefundScript,
+	checkAllScript,
+	policyUpdateScript,
+	leaseAcquireScript,
+	leaseRenewScript,
+	leaseReleaseScript,
+}
@@ -0,0 +1,60 @@
+package redis
+
+import (
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// checkSlidingCounter adds cost to the current window if the weighted count of the current and previous windows stays under the limit.
+func (rs *RedisStorage) checkSlidingCounter(redisKey string, limit storage.Limit, cost int64) *scriptCall {
+	return &scriptCall{
+		script: slidingCounterCheckScript,
+		keys:   []string{redisKey},
+		args:   []any{windowMicroseconds(limit), limit.Limit, cost},
+		parse: func(output []int64) *storage.Result {
+			allowed, count := output[0] == 1, output[1]
+			resetIn, retryAfter := time.Duration(output[2])*time.Microsecond, time.Duration(output[3])*time.Microsecond
+
+			return &storage.Result{
+				Allowed:    allowed,
+				Remaining:  max(0, limit.Limit-count),
+				ResetAt:    time.Now().Add(resetIn),
+				Limit:      limit.Limit,
+				RetryAfter: retryAfter,
+			}
+		},
+	}
+}
+
+// statusSlidingCounter reads the weighted count without modifying it.
+func (rs *RedisStorage) statusSlidingCounter(redisKey string, limit storage.Limit) *scriptCall {
+	return &scriptCall{
+		script: slidingCounterStatusScript,
+		keys:   []string{redisKey},
+		args:   []any{windowMicroseconds(limit)},
+		parse: func(output []int64) *storage.Result {
+			count, resetIn := output[0], time.Duration(output[1])*time.Microsecond
+			remaining := max(0, limit.Limit-count)
+
+			return &storage.Result{
+				Allowed:   remaining > 0,
+				Remaining: remaining,
+				ResetAt:   time.Now().Add(resetIn),
+				Limit:     limit.Limit,
+			}
+		},
+	}
+}
+
+// refundSlidingCounter takes up to amount off the current window, leaving the previous window alone.
+func (rs *RedisStorage) refundSlidingCounter(redisKey string, limit storage.Limit, amount int64) *scriptCall {
+	call := rs.statusSlidingCounter(redisKey, limit)
+	call.script, call.args = slidingCounterRefundScript, append(call.args, amount)
+	return call
+}
+
+// windowMicroseconds returns the window at the microsecond resolution of Redis TIME.
+func windowMicroseconds(limit storage.Limit) int64 {
+	return max(1, limit.Window.Microseconds())
+}
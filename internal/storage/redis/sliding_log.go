@@ -0,0 +1,73 @@
+package redis
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// checkSlidingLog records the request in a sorted set if the cost within the last window stays under the limit.
+func (rs *RedisStorage) checkSlidingLog(redisKey string, limit storage.Limit, cost int64) (*scriptCall, error) {
+	// Guard against logs that would hold more entries than we are willing to store per key
+	if limit.Limit > storage.MaxSlidingLogLimit {
+		return nil, storage.ErrLimitTooHigh
+	}
+
+	return &scriptCall{
+		script: slidingLogCheckScript,
+		keys:   []string{redisKey},
+		args:   []any{limit.Window.Microseconds(), limit.Limit, cost, rs.requestID()},
+		parse: func(output []int64) *storage.Result {
+			allowed, count := output[0] == 1, output[1]
+			resetIn, retryAfter := time.Duration(output[2])*time.Microsecond, time.Duration(output[3])*time.Microsecond
+
+			return &storage.Result{
+				Allowed:    allowed,
+				Remaining:  max(0, limit.Limit-count),
+				ResetAt:    time.Now().Add(resetIn),
+				Limit:      limit.Limit,
+				RetryAfter: retryAfter,
+			}
+		},
+	}, nil
+}
+
+// statusSlidingLog counts the cost within the last window without recording a request.
+func (rs *RedisStorage) statusSlidingLog(redisKey string, limit storage.Limit) (*scriptCall, error) {
+	if limit.Limit > storage.MaxSlidingLogLimit {
+		return nil, storage.ErrLimitTooHigh
+	}
+
+	return &scriptCall{
+		script: slidingLogStatusScript,
+		keys:   []string{redisKey},
+		args:   []any{limit.Window.Microseconds()},
+		parse: func(output []int64) *storage.Result {
+			count, resetIn := output[0], time.Duration(output[1])*time.Microsecond
+			remaining := max(0, limit.Limit-count)
+
+			return &storage.Result{
+				Allowed:   remaining > 0,
+				Remaining: remaining,
+				ResetAt:   time.Now().Add(resetIn),
+				Limit:     limit.Limit,
+			}
+		},
+	}, nil
+}
+
+// refundSlidingLog removes up to amount of cost recorded within the last window, newest requests first.
+func (rs *RedisStorage) refundSlidingLog(redisKey string, limit storage.Limit, amount int64) (*scriptCall, error) {
+	call, err := rs.statusSlidingLog(redisKey, limit)
+	if err != nil {
+		return nil, err
+	}
+	call.script, call.args = slidingLogRefundScript, append(call.args, amount)
+	return call, nil
+}
+
+// requestID returns an ID that is unique across every RedisStorage sharing the same Redis.
+func (rs *RedisStorage) requestID() string {
+	return rs.instanceID + ":" + strconv.FormatUint(rs.sequence.Add(1), 36)
+}
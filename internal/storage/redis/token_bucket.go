@@ -0,0 +1,55 @@
+package redis
+
+import (
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// checkTokenBucket takes cost tokens from a bucket shared by every limiter instance.
+// Refills are computed from the Redis server clock, so instances with skewed clocks still agree.
+func (rs *RedisStorage) checkTokenBucket(redisKey string, limit storage.Limit, cost int64) *scriptCall {
+	return &scriptCall{
+		script: tokenBucketCheckScript,
+		keys:   []string{redisKey},
+		args:   []any{limit.Limit, limit.Refill(), limit.Window.Microseconds(), cost},
+		parse: func(output []int64) *storage.Result {
+			allowed, tokens := output[0] == 1, output[1]
+			untilFull, retryAfter := time.Duration(output[2])*time.Microsecond, time.Duration(output[3])*time.Microsecond
+
+			return &storage.Result{
+				Allowed:    allowed,
+				Remaining:  tokens,
+				ResetAt:    time.Now().Add(untilFull),
+				Limit:      limit.Limit,
+				RetryAfter: retryAfter,
+			}
+		},
+	}
+}
+
+// statusTokenBucket reads the available tokens without taking any.
+func (rs *RedisStorage) statusTokenBucket(redisKey string, limit storage.Limit) *scriptCall {
+	return &scriptCall{
+		script: tokenBucketStatusScript,
+		keys:   []string{redisKey},
+		args:   []any{limit.Limit, limit.Refill(), limit.Window.Microseconds()},
+		parse: func(output []int64) *storage.Result {
+			tokens, untilFull := output[0], time.Duration(output[1])*time.Microsecond
+
+			return &storage.Result{
+				Allowed:   tokens > 0,
+				Remaining: tokens,
+				ResetAt:   time.Now().Add(untilFull),
+				Limit:     limit.Limit,
+			}
+		},
+	}
+}
+
+// refundTokenBucket puts up to amount tokens back in the bucket, never above capacity.
+func (rs *RedisStorage) refundTokenBucket(redisKey string, limit storage.Limit, amount int64) *scriptCall {
+	call := rs.statusTokenBucket(redisKey, limit)
+	call.script, call.args = tokenBucketRefundScript, append(call.args, amount)
+	return call
+}
@@ -0,0 +1,151 @@
+package shard
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// DefaultHealthInterval is how often shards are health checked when no WithHealthInterval option is given.
+const DefaultHealthInterval = 5 * time.Second
+
+// Pinger is implemented by shards that can be health checked. Shards without it are always healthy.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Health reports the health of one shard.
+type Health struct {
+	Name        string              `json:"name"`
+	Healthy     bool                `json:"healthy"`
+	FailureMode storage.FailureMode `json:"failure_mode,omitempty"`
+	// Error is why the last health check failed. Empty while healthy.
+	Error string `json:"error,omitempty"`
+	// CheckedAt is when the shard was last health checked. Zero until the first check.
+	CheckedAt time.Time `json:"checked_at,omitzero"`
+}
+
+// shardState is a shard and the outcome of its last health check.
+type shardState struct {
+	Shard
+
+	mutex     sync.RWMutex
+	healthy   bool
+	lastErr   error
+	checkedAt time.Time
+}
+
+// isHealthy reports whether the shard passed its last health check.
+func (s *shardState) isHealthy() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.healthy
+}
+
+// Health reports the health of every shard in the order they were added.
+func (ss *ShardedStorage) Health() []Health {
+	ss.mutex.RLock()
+	defer ss.mutex.RUnlock()
+
+	health := make([]Health, len(ss.order))
+	for i, name := range ss.order {
+		shard := ss.shards[name]
+
+		shard.mutex.RLock()
+		health[i] = Health{Name: name, Healthy: shard.healthy, FailureMode: shard.FailureMode, CheckedAt: shard.checkedAt}
+		if shard.lastErr != nil {
+			health[i].Error = shard.lastErr.Error()
+		}
+		shard.mutex.RUnlock()
+	}
+	return health
+}
+
+// checkHealth pings every shard at once, so a slow shard doesn't delay the others.
+func (ss *ShardedStorage) checkHealth() {
+	ss.mutex.RLock()
+	shards := make([]*shardState, 0, len(ss.order))
+	for _, name := range ss.order {
+		shards = append(shards, ss.shards[name])
+	}
+	ss.mutex.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, shard := range shards {
+		pinger, ok := shard.Storage.(Pinger)
+		if !ok {
+			continue
+		}
+		wg.Go(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), ss.healthInterval)
+			defer cancel()
+			err := pinger.Ping(ctx)
+
+			shard.mutex.Lock()
+			defer shard.mutex.Unlock()
+			shard.healthy, shard.lastErr, shard.checkedAt = err == nil, err, ss.now()
+		})
+	}
+	wg.Wait()
+}
+
+// healthLoop health checks every shard periodically until Close is called.
+func (ss *ShardedStorage) healthLoop() {
+	defer close(ss.done)
+
+	ticker := time.NewTicker(ss.healthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ss.checkHealth()
+		case <-ss.stop:
+			return
+		}
+	}
+}
+
+// failResult answers a check on an unhealthy shard as its failure mode says.
+// A closed shard asks clients to retry after the next health check.
+func (ss *ShardedStorage) failResult(shard *shardState, limit storage.Limit) (*storage.Result, error) {
+	now := ss.now()
+	switch shard.FailureMode {
+	case storage.FailOpen:
+		return &storage.Result{Allowed: true, Remaining: limit.Limit, ResetAt: now, Limit: limit.Limit, Degraded: true}, nil
+	case storage.FailClosed:
+		return &storage.Result{Allowed: false, Remaining: 0, ResetAt: now.Add(ss.healthInterval), Limit: limit.Limit, RetryAfter: ss.healthInterval, Degraded: true}, nil
+	default:
+		return nil, storage.ErrStorageUnavailable
+	}
+}
+
+// failResults answers every check on an unhealthy shard as its failure mode says.
+func (ss *ShardedStorage) failResults(shard *shardState, checks []storage.Check) ([]*storage.Result, error) {
+	results := make([]*storage.Result, len(checks))
+	for i, check := range checks {
+		result, err := ss.failResult(shard, check.Limit)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+// failLease answers a lease request on an unhealthy shard as its failure mode says.
+// A lease granted by an open shard isn't held anywhere, so renewing or releasing it later fails.
+func (ss *ShardedStorage) failLease(shard *shardState, limit storage.Limit) (*storage.Lease, error) {
+	result, err := ss.failResult(shard, limit)
+	if err != nil {
+		return nil, err
+	}
+	lease := &storage.Lease{Result: *result}
+	if result.Allowed {
+		lease.ID, lease.ExpiresAt = storage.NewLeaseID(), result.ResetAt.Add(limit.Window)
+	}
+	return lease, nil
+}
@@ -0,0 +1,323 @@
+// Package shard spreads rate limit keys over several independent backends, such as standalone Redis servers.
+//
+// Every key is routed to one shard by rendezvous hashing, so adding a shard only moves the keys the new shard wins.
+// Each shard is health checked on its own, and a shard that fails its checks answers as its FailureMode says
+// without affecting the others.
+package shard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"github.com/cespare/xxhash/v2"
+	"github.com/dgryski/go-rendezvous"
+)
+
+// Shard is one backend of a ShardedStorage.
+type Shard struct {
+	// Name identifies the shard. Keys are routed by name, so renaming a shard moves its keys.
+	Name string
+	// Storage holds the keys routed to the shard.
+	Storage storage.RateLimitStorage
+	// FailureMode decides how checks on the shard are answered while it is unhealthy: storage.FailError,
+	// storage.FailOpen or storage.FailClosed. Empty means storage.FailError.
+	FailureMode storage.FailureMode
+}
+
+// ShardedStorage implements storage.RateLimitStorage, storage.BatchStorage and storage.LeaseStorage by routing
+// every key to one of its shards. Keys with the same storage.HashTag are routed to the same shard.
+// Policies and overrides are kept on the first shard, which must implement storage.PolicyStorage and
+// storage.OverrideStorage.
+type ShardedStorage struct {
+	storage.PolicyStorage
+	storage.OverrideStorage
+
+	healthInterval time.Duration
+	now            func() time.Time
+
+	mutex      sync.RWMutex
+	shards     map[string]*shardState
+	order      []string
+	rendezvous *rendezvous.Rendezvous
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Option configures a ShardedStorage.
+type Option func(*ShardedStorage)
+
+// WithHealthInterval sets how often every shard is health checked.
+func WithHealthInterval(d time.Duration) Option {
+	return func(ss *ShardedStorage) {
+		if d > 0 {
+			ss.healthInterval = d
+		}
+	}
+}
+
+// WithClock replaces time.Now as the source of the current time.
+func WithClock(now func() time.Time) Option {
+	return func(ss *ShardedStorage) {
+		ss.now = now
+	}
+}
+
+// NewShardedStorage routes keys over shards and starts health checking them.
+func NewShardedStorage(shards []Shard, opts ...Option) (*ShardedStorage, error) {
+	if len(shards) == 0 {
+		return nil, errors.New("no shards")
+	}
+	metadata, ok := shards[0].Storage.(interface {
+		storage.PolicyStorage
+		storage.OverrideStorage
+	})
+	if !ok {
+		return nil, fmt.Errorf("shard %s can't store policies and overrides", shards[0].Name)
+	}
+
+	ss := &ShardedStorage{
+		PolicyStorage:   metadata,
+		OverrideStorage: metadata,
+		healthInterval:  DefaultHealthInterval,
+		now:             time.Now,
+		shards:          make(map[string]*shardState, len(shards)),
+		rendezvous:      rendezvous.New(nil, xxhash.Sum64String),
+		stop:            make(chan struct{}),
+		done:            make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(ss)
+	}
+
+	for _, shard := range shards {
+		if err := ss.add(shard); err != nil {
+			return nil, err
+		}
+	}
+
+	go ss.healthLoop()
+
+	return ss, nil
+}
+
+// AddShard adds a shard while the storage is in use. Only the keys the new shard wins move to it,
+// and their state starts over there.
+func (ss *ShardedStorage) AddShard(shard Shard) error {
+	return ss.add(shard)
+}
+
+// add validates shard and places it among the shards.
+func (ss *ShardedStorage) add(shard Shard) error {
+	if shard.Name == "" || shard.Storage == nil {
+		return errors.New("shard needs a name and a storage")
+	}
+	if shard.FailureMode != "" && (!shard.FailureMode.Valid() || shard.FailureMode == storage.FailLocal) {
+		return fmt.Errorf("shard %s: unsupported failure mode %q", shard.Name, shard.FailureMode)
+	}
+
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	if _, ok := ss.shards[shard.Name]; ok {
+		return fmt.Errorf("duplicate shard %s", shard.Name)
+	}
+	ss.shards[shard.Name] = &shardState{Shard: shard, healthy: true}
+	ss.order = append(ss.order, shard.Name)
+	ss.rendezvous.Add(shard.Name)
+	return nil
+}
+
+// CheckAndUpdate checks and updates key on its shard.
+func (ss *ShardedStorage) CheckAndUpdate(ctx context.Context, key string, limit storage.Limit, cost int64) (*storage.Result, error) {
+	shard := ss.shardFor(key)
+	if !shard.isHealthy() {
+		return ss.failResult(shard, limit)
+	}
+	return shard.Storage.CheckAndUpdate(ctx, key, limit, cost)
+}
+
+// CheckAndUpdateAll checks every key and consumes cost on all of them only if every check is allowed.
+// Every key must be on the same shard, e.g. by sharing a hash tag, or it returns storage.ErrKeysNotColocated.
+func (ss *ShardedStorage) CheckAndUpdateAll(ctx context.Context, checks []storage.Check) ([]*storage.Result, error) {
+	groups := ss.group(checks)
+
+	// Keys on different shards can't be updated atomically
+	if len(groups) != 1 {
+		return nil, storage.ErrKeysNotColocated
+	}
+	shard := groups[0].shard
+	if !shard.isHealthy() {
+		return ss.failResults(shard, checks)
+	}
+	return shard.Storage.CheckAndUpdateAll(ctx, checks)
+}
+
+// CheckAndUpdateBatch checks every key on its own, sending each shard its keys in one call.
+func (ss *ShardedStorage) CheckAndUpdateBatch(ctx context.Context, checks []storage.Check) ([]*storage.Result, error) {
+	groups := ss.group(checks)
+
+	results := make([]*storage.Result, len(checks))
+	errs := make([]error, len(groups))
+	var wg sync.WaitGroup
+	for i, group := range groups {
+		wg.Go(func() {
+			groupResults, err := ss.checkGroup(ctx, group.shard, storage.Pick(checks, group.indices))
+			if err != nil {
+				errs[i] = fmt.Errorf("shard %s: %w", group.shard.Name, err)
+				return
+			}
+			// Every group writes to its own indices
+			for j, result := range groupResults {
+				results[group.indices[j]] = result
+			}
+		})
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// checkGroup checks the keys of one shard, in one call if the shard supports batches.
+func (ss *ShardedStorage) checkGroup(ctx context.Context, shard *shardState, checks []storage.Check) ([]*storage.Result, error) {
+	if !shard.isHealthy() {
+		return ss.failResults(shard, checks)
+	}
+	if bs, ok := shard.Storage.(storage.BatchStorage); ok {
+		return bs.CheckAndUpdateBatch(ctx, checks)
+	}
+
+	results := make([]*storage.Result, len(checks))
+	for i, check := range checks {
+		result, err := shard.Storage.CheckAndUpdate(ctx, check.Key, check.Limit, check.Cost)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+// GetStatus reads key on its shard.
+func (ss *ShardedStorage) GetStatus(ctx context.Context, key string, limit storage.Limit) (*storage.Result, error) {
+	shard := ss.shardFor(key)
+	if !shard.isHealthy() {
+		return ss.failResult(shard, limit)
+	}
+	return shard.Storage.GetStatus(ctx, key, limit)
+}
+
+// Refund returns consumed cost to key on its shard.
+func (ss *ShardedStorage) Refund(ctx context.Context, key string, limit storage.Limit, amount int64) (*storage.Result, error) {
+	shard := ss.shardFor(key)
+	if !shard.isHealthy() {
+		return ss.failResult(shard, limit)
+	}
+	return shard.Storage.Refund(ctx, key, limit, amount)
+}
+
+// Reset clears key on its shard. It returns storage.ErrStorageUnavailable while the shard is unhealthy.
+func (ss *ShardedStorage) Reset(ctx context.Context, key string) error {
+	shard := ss.shardFor(key)
+	if !shard.isHealthy() {
+		return storage.ErrStorageUnavailable
+	}
+	return shard.Storage.Reset(ctx, key)
+}
+
+// AcquireLease grants a lease on key on its shard.
+// It returns storage.ErrUnsupportedAlgorithm if the shard can't hold leases.
+func (ss *ShardedStorage) AcquireLease(ctx context.Context, key string, limit storage.Limit) (*storage.Lease, error) {
+	shard := ss.shardFor(key)
+	ls, ok := shard.Storage.(storage.LeaseStorage)
+	if !ok {
+		return nil, storage.ErrUnsupportedAlgorithm
+	}
+	if !shard.isHealthy() {
+		return ss.failLease(shard, limit)
+	}
+	return ls.AcquireLease(ctx, key, limit)
+}
+
+// RenewLease extends a lease on key on its shard. It returns storage.ErrStorageUnavailable while the shard is unhealthy.
+func (ss *ShardedStorage) RenewLease(ctx context.Context, key, leaseID string, limit storage.Limit) (*storage.Lease, error) {
+	shard := ss.shardFor(key)
+	ls, ok := shard.Storage.(storage.LeaseStorage)
+	if !ok {
+		return nil, storage.ErrUnsupportedAlgorithm
+	}
+	if !shard.isHealthy() {
+		return nil, storage.ErrStorageUnavailable
+	}
+	return ls.RenewLease(ctx, key, leaseID, limit)
+}
+
+// ReleaseLease frees a lease on key on its shard. It returns storage.ErrStorageUnavailable while the shard is unhealthy.
+func (ss *ShardedStorage) ReleaseLease(ctx context.Context, key, leaseID string) error {
+	shard := ss.shardFor(key)
+	ls, ok := shard.Storage.(storage.LeaseStorage)
+	if !ok {
+		return storage.ErrUnsupportedAlgorithm
+	}
+	if !shard.isHealthy() {
+		return storage.ErrStorageUnavailable
+	}
+	return ls.ReleaseLease(ctx, key, leaseID)
+}
+
+// Close stops health checking and closes every shard.
+func (ss *ShardedStorage) Close() error {
+	var errs []error
+	ss.closeOnce.Do(func() {
+		close(ss.stop)
+		<-ss.done
+
+		ss.mutex.RLock()
+		defer ss.mutex.RUnlock()
+		for _, name := range ss.order {
+			if err := ss.shards[name].Storage.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("shard %s: %w", name, err))
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// shardFor returns the shard key is routed to.
+func (ss *ShardedStorage) shardFor(key string) *shardState {
+	ss.mutex.RLock()
+	defer ss.mutex.RUnlock()
+
+	return ss.shards[ss.rendezvous.Lookup(storage.HashTag(key))]
+}
+
+// checkGroup is the checks routed to one shard.
+type checkGroup struct {
+	shard   *shardState
+	indices []int
+}
+
+// group splits checks by shard, keeping each group in check order.
+func (ss *ShardedStorage) group(checks []storage.Check) []*checkGroup {
+	var groups []*checkGroup
+	byShard := make(map[*shardState]*checkGroup)
+	for i, check := range checks {
+		shard := ss.shardFor(check.Key)
+		group, ok := byShard[shard]
+		if !ok {
+			group = &checkGroup{shard: shard}
+			byShard[shard] = group
+			groups = append(groups, group)
+		}
+		group.indices = append(group.indices, i)
+	}
+	return groups
+}
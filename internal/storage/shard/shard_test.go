@@ -0,0 +1,226 @@
+package shard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/memory"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/storagetest"
+)
+
+func TestShardedStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) (storage.RateLimitStorage, storagetest.Clock) {
+		clock := storagetest.NewFakeClock(time.Unix(0, 0))
+		ss, err := NewShardedStorage(memoryShards(3, clock), WithClock(clock.Now))
+		if err != nil {
+			t.Fatalf("NewShardedStorage() error = %v", err)
+		}
+		return ss, clock
+	})
+}
+
+func TestShardedStorage_AddShard(t *testing.T) {
+	clock := storagetest.NewFakeClock(time.Unix(0, 0))
+	ss, err := NewShardedStorage(memoryShards(3, clock))
+	if err != nil {
+		t.Fatalf("NewShardedStorage() error = %v", err)
+	}
+	defer ss.Close()
+
+	const keys = 10000
+	before := make([]string, keys)
+	for i := range keys {
+		before[i] = ss.shardFor(fmt.Sprintf("user:%d", i)).Name
+	}
+
+	if err := ss.AddShard(Shard{Name: "shard-3", Storage: memory.NewMemoryStorage(memory.WithClock(clock.Now))}); err != nil {
+		t.Fatalf("AddShard() error = %v", err)
+	}
+
+	moved := 0
+	for i := range keys {
+		after := ss.shardFor(fmt.Sprintf("user:%d", i)).Name
+		if after == before[i] {
+			continue
+		}
+		// Keys only move to the shard that was added
+		if after != "shard-3" {
+			t.Fatalf("key user:%d moved from %s to %s, want shard-3", i, before[i], after)
+		}
+		moved++
+	}
+	if share := float64(moved) / keys; share < 0.15 || share > 0.35 {
+		t.Errorf("%.2f of the keys moved, want about 0.25", share)
+	}
+
+	if err := ss.AddShard(Shard{Name: "shard-3", Storage: memory.NewMemoryStorage()}); err == nil {
+		t.Error("AddShard() with a duplicate name succeeded, want an error")
+	}
+}
+
+func TestShardedStorage_CheckAndUpdateAll(t *testing.T) {
+	ss, err := NewShardedStorage(memoryShards(3, storagetest.NewFakeClock(time.Unix(0, 0))))
+	if err != nil {
+		t.Fatalf("NewShardedStorage() error = %v", err)
+	}
+	defer ss.Close()
+
+	// Find a key on a different shard than user:0
+	other := ""
+	for i := range 100 {
+		key := fmt.Sprintf("user:%d", i)
+		if ss.shardFor(key) != ss.shardFor("user:0") {
+			other = key
+			break
+		}
+	}
+
+	tests := []struct {
+		name    string
+		keys    []string
+		wantErr error
+	}{
+		{name: "key and its tiers", keys: []string{"user:0", storage.SubKey("user:0", "1h0m0s")}},
+		{name: "keys with a shared hash tag", keys: []string{"{org:1}", "{org:1}:user:7"}},
+		{name: "keys on different shards", keys: []string{"user:0", other}, wantErr: storage.ErrKeysNotColocated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks := make([]storage.Check, len(tt.keys))
+			for i, key := range tt.keys {
+				checks[i] = storage.Check{Key: key, Limit: storage.Limit{Limit: 10, Window: time.Minute}, Cost: 1}
+			}
+
+			_, err := ss.CheckAndUpdateAll(context.Background(), checks)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckAndUpdateAll() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestShardedStorage_FailureMode(t *testing.T) {
+	limit := storage.Limit{Limit: 10, Window: time.Minute}
+
+	tests := []struct {
+		name          string
+		mode          storage.FailureMode
+		wantErr       error
+		wantAllowed   bool
+		wantRemaining int64
+	}{
+		{name: "error", mode: storage.FailError, wantErr: storage.ErrStorageUnavailable},
+		{name: "default is error", wantErr: storage.ErrStorageUnavailable},
+		{name: "open", mode: storage.FailOpen, wantAllowed: true, wantRemaining: 10},
+		{name: "closed", mode: storage.FailClosed, wantAllowed: false, wantRemaining: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := storagetest.NewFakeClock(time.Unix(0, 0))
+			down := &pingerStorage{MemoryStorage: memory.NewMemoryStorage(), err: errors.New("connection refused")}
+			shards := append(memoryShards(1, clock), Shard{Name: "down", Storage: down, FailureMode: tt.mode})
+			ss, err := NewShardedStorage(shards, WithClock(clock.Now))
+			if err != nil {
+				t.Fatalf("NewShardedStorage() error = %v", err)
+			}
+			defer ss.Close()
+			ss.checkHealth()
+
+			// Find one key on each shard
+			var upKey, downKey string
+			for i := 0; upKey == "" || downKey == ""; i++ {
+				key := fmt.Sprintf("user:%d", i)
+				if ss.shardFor(key).Name == "down" {
+					downKey = key
+				} else {
+					upKey = key
+				}
+			}
+
+			result, err := ss.CheckAndUpdate(context.Background(), downKey, limit, 1)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CheckAndUpdate() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (result.Allowed != tt.wantAllowed || result.Remaining != tt.wantRemaining) {
+				t.Errorf("CheckAndUpdate() = allowed %v, remaining %d, want %v and %d", result.Allowed, result.Remaining, tt.wantAllowed, tt.wantRemaining)
+			}
+
+			// The healthy shard is unaffected
+			result, err = ss.CheckAndUpdate(context.Background(), upKey, limit, 1)
+			if err != nil || !result.Allowed || result.Remaining != 9 {
+				t.Errorf("CheckAndUpdate() on the healthy shard = %+v, %v, want allowed with 9 remaining", result, err)
+			}
+
+			// The unhealthy shard recovers on its next health check
+			down.setErr(nil)
+			ss.checkHealth()
+			if _, err := ss.CheckAndUpdate(context.Background(), downKey, limit, 1); err != nil {
+				t.Errorf("CheckAndUpdate() after recovery error = %v", err)
+			}
+		})
+	}
+}
+
+func TestShardedStorage_Health(t *testing.T) {
+	clock := storagetest.NewFakeClock(time.Unix(0, 0))
+	down := &pingerStorage{MemoryStorage: memory.NewMemoryStorage(), err: errors.New("connection refused")}
+	shards := append(memoryShards(1, clock), Shard{Name: "down", Storage: down, FailureMode: storage.FailOpen})
+	ss, err := NewShardedStorage(shards, WithClock(clock.Now))
+	if err != nil {
+		t.Fatalf("NewShardedStorage() error = %v", err)
+	}
+	defer ss.Close()
+	ss.checkHealth()
+
+	want := []Health{
+		{Name: "shard-0", Healthy: true},
+		{Name: "down", Healthy: false, FailureMode: storage.FailOpen, Error: "connection refused", CheckedAt: clock.Now()},
+	}
+	got := ss.Health()
+	if len(got) != len(want) {
+		t.Fatalf("Health() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Health()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+// memoryShards returns n memory shards named shard-0 to shard-n-1 running on clock.
+func memoryShards(n int, clock *storagetest.FakeClock) []Shard {
+	shards := make([]Shard, n)
+	for i := range shards {
+		shards[i] = Shard{Name: fmt.Sprintf("shard-%d", i), Storage: memory.NewMemoryStorage(memory.WithClock(clock.Now))}
+	}
+	return shards
+}
+
+// pingerStorage is a memory storage whose health check fails with err.
+type pingerStorage struct {
+	*memory.MemoryStorage
+
+	mutex sync.Mutex
+	err   error
+}
+
+func (ps *pingerStorage) Ping(ctx context.Context) error {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	return ps.err
+}
+
+func (ps *pingerStorage) setErr(err error) {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	ps.err = err
+}
@@ -11,12 +11,48 @@ type Result struct {
 	Remaining int64
 	ResetAt   time.Time
 	Limit     int64
+	// RetryAfter is how long to wait before the same request would be allowed. Zero when allowed.
+	RetryAfter time.Duration
+	// Degraded reports that the storage was unavailable, so the result was decided by a FailureMode
+	// instead of the shared state.
+	Degraded bool
 }
 
 // RateLimitStorage is the interface for rate-limit backends (e.g., Redis, memory, SQL).
+// Backends return ErrUnsupportedAlgorithm for algorithms they do not implement.
 type RateLimitStorage interface {
-	CheckAndUpdate(ctx context.Context, key string, limit int64, window time.Duration, cost int64) (*Result, error)
-	GetStatus(ctx context.Context, key string, limit int64) (*Result, error)
+	CheckAndUpdate(ctx context.Context, key string, limit Limit, cost int64) (*Result, error)
+	// CheckAndUpdateAll atomically checks every key and consumes cost on all of them only if every check is allowed.
+	// Results are in check order. If any check is denied nothing is consumed, and each result holds its key's
+	// unchanged state with Allowed reporting that key's own verdict. Keys must be distinct.
+	CheckAndUpdateAll(ctx context.Context, checks []Check) ([]*Result, error)
+	GetStatus(ctx context.Context, key string, limit Limit) (*Result, error)
+	// Refund returns up to amount of cost consumed within the key's current window and reports the state after it.
+	// Remaining never goes above the limit, and a missing or expired key is left unchanged.
+	Refund(ctx context.Context, key string, limit Limit, amount int64) (*Result, error)
 	Reset(ctx context.Context, key string) error
 	Close() error
 }
+
+// Check is one key to check and update in a batch or an all-or-nothing check.
+type Check struct {
+	Key   string
+	Limit Limit
+	Cost  int64
+}
+
+// Pick returns the items at the given indices, such as the checks of a batch that belong to one request.
+func Pick[T any](items []T, indices []int) []T {
+	picked := make([]T, len(indices))
+	for i, index := range indices {
+		picked[i] = items[index]
+	}
+	return picked
+}
+
+// BatchStorage is implemented by backends that can check several keys in one round trip.
+// Each check is applied independently, so a denied key doesn't stop the others from being consumed.
+type BatchStorage interface {
+	// CheckAndUpdateBatch returns one result per check, in the same order.
+	CheckAndUpdateBatch(ctx context.Context, checks []Check) ([]*Result, error)
+}
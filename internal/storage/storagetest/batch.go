@@ -0,0 +1,102 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// batchTests hold for every backend that implements storage.BatchStorage.
+var batchTests = []struct {
+	name string
+	fn   func(t *testing.T, bs storage.BatchStorage, s storage.RateLimitStorage, key string)
+}{
+	{"ResultsInOrder", testBatchResultsInOrder},
+	{"DeniedKeyDoesNotStopOthers", testBatchDeniedKeyDoesNotStopOthers},
+	{"SameKeyTwice", testBatchSameKeyTwice},
+}
+
+// runBatchTests runs batchTests if the backend implements storage.BatchStorage.
+func runBatchTests(t *testing.T, newStorage Factory) {
+	for _, tt := range batchTests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newStorage(t)
+			bs, ok := s.(storage.BatchStorage)
+			if !ok {
+				s.Close()
+				t.Skip("batches not supported")
+			}
+			key := uniqueKey(t)
+
+			t.Cleanup(func() {
+				defer s.Close()
+
+				// Remove leftovers from shared backends; missing keys are fine
+				for _, k := range []string{key + "-a", key + "-b"} {
+					if err := s.Reset(context.Background(), k); err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+						t.Logf("failed to delete the key %s: %v", k, err)
+					}
+				}
+			})
+
+			tt.fn(t, bs, s, key)
+		})
+	}
+}
+
+func testBatchResultsInOrder(t *testing.T, bs storage.BatchStorage, s storage.RateLimitStorage, key string) {
+	ctx := context.Background()
+	checks := []storage.Check{
+		{Key: key + "-a", Limit: storage.Limit{Algorithm: storage.FixedWindow, Limit: 5, Window: window}, Cost: 1},
+		{Key: key + "-b", Limit: storage.Limit{Algorithm: storage.TokenBucket, Limit: 10, Window: window}, Cost: 3},
+	}
+
+	results := mustCheckBatch(t, bs, ctx, checks)
+	assertResult(t, results[0], true, 4, 5)
+	assertResult(t, results[1], true, 7, 10)
+}
+
+func testBatchDeniedKeyDoesNotStopOthers(t *testing.T, bs storage.BatchStorage, s storage.RateLimitStorage, key string) {
+	ctx := context.Background()
+	limit := storage.Limit{Algorithm: storage.FixedWindow, Limit: 2, Window: window}
+	mustCheck(t, s, ctx, key+"-a", limit, 2)
+
+	results := mustCheckBatch(t, bs, ctx, []storage.Check{
+		{Key: key + "-a", Limit: limit, Cost: 1},
+		{Key: key + "-b", Limit: limit, Cost: 1},
+	})
+	assertResult(t, results[0], false, 0, 2)
+	assertResult(t, results[1], true, 1, 2)
+
+	// The allowed key was consumed even though another key in the batch was denied
+	assertResult(t, mustStatus(t, s, ctx, key+"-b", limit), true, 1, 2)
+}
+
+func testBatchSameKeyTwice(t *testing.T, bs storage.BatchStorage, s storage.RateLimitStorage, key string) {
+	ctx := context.Background()
+	limit := storage.Limit{Algorithm: storage.FixedWindow, Limit: 1, Window: window}
+
+	// Checks run in order, so the second sees the first's cost
+	results := mustCheckBatch(t, bs, ctx, []storage.Check{
+		{Key: key + "-a", Limit: limit, Cost: 1},
+		{Key: key + "-a", Limit: limit, Cost: 1},
+	})
+	assertResult(t, results[0], true, 0, 1)
+	assertResult(t, results[1], false, 0, 1)
+}
+
+// mustCheckBatch calls CheckAndUpdateBatch and fails the test on error or a result count mismatch.
+func mustCheckBatch(t *testing.T, bs storage.BatchStorage, ctx context.Context, checks []storage.Check) []*storage.Result {
+	t.Helper()
+
+	results, err := bs.CheckAndUpdateBatch(ctx, checks)
+	if err != nil {
+		t.Fatalf("CheckAndUpdateBatch() error = %v", err)
+	}
+	if len(results) != len(checks) {
+		t.Fatalf("len(results) = %d, want %d", len(results), len(checks))
+	}
+	return results
+}
@@ -0,0 +1,96 @@
+package storagetest
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+func testCheckAllAllowed(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	ctx := context.Background()
+
+	results := mustCheckAll(t, s, ctx, []storage.Check{
+		{Key: key, Limit: limit, Cost: 1},
+		{Key: key + "-other", Limit: limit, Cost: 2},
+	})
+	assertResult(t, results[0], true, limit.Limit-1, limit.Limit)
+	assertResult(t, results[1], true, limit.Limit-2, limit.Limit)
+
+	// Both keys were consumed
+	assertResult(t, mustStatus(t, s, ctx, key, limit), true, limit.Limit-1, limit.Limit)
+	assertResult(t, mustStatus(t, s, ctx, key+"-other", limit), true, limit.Limit-2, limit.Limit)
+}
+
+func testCheckAllDeniedConsumesNothing(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	ctx := context.Background()
+
+	mustCheck(t, s, ctx, key+"-other", limit, limit.Limit)
+
+	results := mustCheckAll(t, s, ctx, []storage.Check{
+		{Key: key, Limit: limit, Cost: 1},
+		{Key: key + "-other", Limit: limit, Cost: 1},
+	})
+
+	// The first key would have allowed the request on its own but was left untouched
+	assertResult(t, results[0], true, limit.Limit, limit.Limit)
+	assertResult(t, results[1], false, 0, limit.Limit)
+	if results[1].RetryAfter <= 0 || results[1].RetryAfter > limit.Window+tolerance {
+		t.Errorf("denied check: RetryAfter = %v, want within (0, %v]", results[1].RetryAfter, limit.Window)
+	}
+
+	assertResult(t, mustStatus(t, s, ctx, key, limit), true, limit.Limit, limit.Limit)
+	assertResult(t, mustCheck(t, s, ctx, key, limit, limit.Limit), true, 0, limit.Limit)
+}
+
+func testCheckAllConcurrent(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	ctx := context.Background()
+
+	// The tighter key runs out first, after which the other key must stop being consumed
+	wide := storage.Limit{Algorithm: limit.Algorithm, Limit: 40, Window: time.Hour}
+	tight := storage.Limit{Algorithm: limit.Algorithm, Limit: 20, Window: time.Hour}
+	requests := 60
+
+	var wg sync.WaitGroup
+	allowedCount := atomic.Int64{}
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results, err := s.CheckAndUpdateAll(ctx, []storage.Check{
+				{Key: key, Limit: wide, Cost: 1},
+				{Key: key + "-other", Limit: tight, Cost: 1},
+			})
+			if err != nil {
+				t.Errorf("CheckAndUpdateAll() error = %v", err)
+				return
+			}
+			if results[0].Allowed && results[1].Allowed {
+				allowedCount.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowedCount.Load() != tight.Limit {
+		t.Errorf("expected exactly %d allowed, got %d", tight.Limit, allowedCount.Load())
+	}
+	assertResult(t, mustStatus(t, s, ctx, key, wide), true, wide.Limit-tight.Limit, wide.Limit)
+}
+
+// mustCheckAll calls CheckAndUpdateAll and fails the test on error or a result count mismatch.
+func mustCheckAll(t *testing.T, s storage.RateLimitStorage, ctx context.Context, checks []storage.Check) []*storage.Result {
+	t.Helper()
+
+	results, err := s.CheckAndUpdateAll(ctx, checks)
+	if err != nil {
+		t.Fatalf("CheckAndUpdateAll() error = %v", err)
+	}
+	if len(results) != len(checks) {
+		t.Fatalf("len(results) = %d, want %d", len(results), len(checks))
+	}
+	return results
+}
@@ -0,0 +1,53 @@
+package storagetest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the time source shared by the suite and the backend under test.
+type Clock interface {
+	// Now returns the current time as seen by the backend.
+	Now() time.Time
+	// Sleep lets d pass for the backend.
+	Sleep(d time.Duration)
+}
+
+// FakeClock is a Clock that only moves when Sleep is called.
+// Backends that accept an injected time source should use it so the suite runs instantly.
+type FakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+// NewFakeClock returns a FakeClock starting at start.
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Now returns the fake current time.
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Sleep advances the fake time by d without blocking.
+func (c *FakeClock) Sleep(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// RealClock is a Clock backed by the wall clock, for backends that keep their own time (e.g. Redis TTLs).
+type RealClock struct{}
+
+// Now returns time.Now().
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// Sleep blocks for d.
+func (RealClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
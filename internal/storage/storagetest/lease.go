@@ -0,0 +1,175 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// leaseTests hold for every backend that implements storage.LeaseStorage.
+var leaseTests = []struct {
+	name string
+	fn   func(t *testing.T, ls storage.LeaseStorage, s storage.RateLimitStorage, clock Clock, key string)
+}{
+	{"AcquireWithinLimit", testLeaseAcquireWithinLimit},
+	{"DeniesOverLimit", testLeaseDeniesOverLimit},
+	{"ReleaseFreesLease", testLeaseReleaseFreesLease},
+	{"ReleaseMissingLease", testLeaseReleaseMissingLease},
+	{"ExpiredLeaseReclaimed", testLeaseExpiredLeaseReclaimed},
+	{"RenewExtendsLease", testLeaseRenewExtendsLease},
+	{"SeparateFromRateLimit", testLeaseSeparateFromRateLimit},
+}
+
+// leaseLimit is the concurrency limit used by leaseTests.
+var leaseLimit = storage.Limit{Algorithm: storage.Concurrency, Limit: 2, Window: window}
+
+// runLeaseTests runs leaseTests if the backend implements storage.LeaseStorage.
+func runLeaseTests(t *testing.T, newStorage Factory) {
+	for _, tt := range leaseTests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, clock := newStorage(t)
+			ls, ok := s.(storage.LeaseStorage)
+			if !ok {
+				s.Close()
+				t.Skip("leases not supported")
+			}
+			key := uniqueKey(t)
+
+			t.Cleanup(func() {
+				defer s.Close()
+
+				// Lease sets expire on their own, so only rate limit state is left behind
+				if err := s.Reset(context.Background(), key); err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+					t.Logf("failed to delete the key %s: %v", key, err)
+				}
+			})
+
+			tt.fn(t, ls, s, clock, key)
+		})
+	}
+}
+
+func testLeaseAcquireWithinLimit(t *testing.T, ls storage.LeaseStorage, s storage.RateLimitStorage, clock Clock, key string) {
+	ctx := context.Background()
+
+	first := mustAcquire(t, ls, ctx, key, leaseLimit)
+	second := mustAcquire(t, ls, ctx, key, leaseLimit)
+	assertResult(t, &first.Result, true, 1, leaseLimit.Limit)
+	assertResult(t, &second.Result, true, 0, leaseLimit.Limit)
+
+	if first.ID == "" || first.ID == second.ID {
+		t.Errorf("lease IDs = %q and %q, want distinct and non-empty", first.ID, second.ID)
+	}
+	now := clock.Now()
+	assertResetAt(t, first.ExpiresAt, now, now.Add(leaseLimit.Window))
+}
+
+func testLeaseDeniesOverLimit(t *testing.T, ls storage.LeaseStorage, s storage.RateLimitStorage, clock Clock, key string) {
+	ctx := context.Background()
+
+	mustAcquire(t, ls, ctx, key, leaseLimit)
+	mustAcquire(t, ls, ctx, key, leaseLimit)
+
+	denied := mustAcquire(t, ls, ctx, key, leaseLimit)
+	assertResult(t, &denied.Result, false, 0, leaseLimit.Limit)
+	if denied.ID != "" {
+		t.Errorf("ID = %q, want empty for a denied lease", denied.ID)
+	}
+	if denied.RetryAfter <= 0 || denied.RetryAfter > leaseLimit.Window+tolerance {
+		t.Errorf("RetryAfter = %v, want within (0, %v]", denied.RetryAfter, leaseLimit.Window)
+	}
+}
+
+func testLeaseReleaseFreesLease(t *testing.T, ls storage.LeaseStorage, s storage.RateLimitStorage, clock Clock, key string) {
+	ctx := context.Background()
+
+	first := mustAcquire(t, ls, ctx, key, leaseLimit)
+	mustAcquire(t, ls, ctx, key, leaseLimit)
+
+	if err := ls.ReleaseLease(ctx, key, first.ID); err != nil {
+		t.Fatalf("ReleaseLease() error = %v", err)
+	}
+	assertResult(t, &mustAcquire(t, ls, ctx, key, leaseLimit).Result, true, 0, leaseLimit.Limit)
+}
+
+func testLeaseReleaseMissingLease(t *testing.T, ls storage.LeaseStorage, s storage.RateLimitStorage, clock Clock, key string) {
+	ctx := context.Background()
+
+	if err := ls.ReleaseLease(ctx, key, "unknown"); !errors.Is(err, storage.ErrLeaseNotFound) {
+		t.Errorf("ReleaseLease() unknown lease error = %v, want %v", err, storage.ErrLeaseNotFound)
+	}
+
+	lease := mustAcquire(t, ls, ctx, key, leaseLimit)
+	if err := ls.ReleaseLease(ctx, key, lease.ID); err != nil {
+		t.Fatalf("ReleaseLease() error = %v", err)
+	}
+	if err := ls.ReleaseLease(ctx, key, lease.ID); !errors.Is(err, storage.ErrLeaseNotFound) {
+		t.Errorf("ReleaseLease() released lease error = %v, want %v", err, storage.ErrLeaseNotFound)
+	}
+}
+
+func testLeaseExpiredLeaseReclaimed(t *testing.T, ls storage.LeaseStorage, s storage.RateLimitStorage, clock Clock, key string) {
+	ctx := context.Background()
+
+	lease := mustAcquire(t, ls, ctx, key, leaseLimit)
+	mustAcquire(t, ls, ctx, key, leaseLimit)
+
+	// Neither lease was renewed, as if their clients had crashed
+	clock.Sleep(leaseLimit.Window + tolerance)
+
+	assertResult(t, &mustAcquire(t, ls, ctx, key, leaseLimit).Result, true, 1, leaseLimit.Limit)
+	if _, err := ls.RenewLease(ctx, key, lease.ID, leaseLimit); !errors.Is(err, storage.ErrLeaseNotFound) {
+		t.Errorf("RenewLease() expired lease error = %v, want %v", err, storage.ErrLeaseNotFound)
+	}
+	if err := ls.ReleaseLease(ctx, key, lease.ID); !errors.Is(err, storage.ErrLeaseNotFound) {
+		t.Errorf("ReleaseLease() expired lease error = %v, want %v", err, storage.ErrLeaseNotFound)
+	}
+}
+
+func testLeaseRenewExtendsLease(t *testing.T, ls storage.LeaseStorage, s storage.RateLimitStorage, clock Clock, key string) {
+	ctx := context.Background()
+	limit := leaseLimit
+	limit.Limit = 1
+
+	lease := mustAcquire(t, ls, ctx, key, limit)
+
+	// Renewing before the lease expires keeps it held past its original expiry
+	clock.Sleep(limit.Window * 6 / 10)
+	renewed, err := ls.RenewLease(ctx, key, lease.ID, limit)
+	if err != nil {
+		t.Fatalf("RenewLease() error = %v", err)
+	}
+	assertResult(t, &renewed.Result, true, 0, limit.Limit)
+	if !renewed.ExpiresAt.After(lease.ExpiresAt) {
+		t.Errorf("ExpiresAt = %v after renewal, want after %v", renewed.ExpiresAt, lease.ExpiresAt)
+	}
+
+	clock.Sleep(limit.Window * 6 / 10)
+	assertResult(t, &mustAcquire(t, ls, ctx, key, limit).Result, false, 0, limit.Limit)
+
+	if err := ls.ReleaseLease(ctx, key, lease.ID); err != nil {
+		t.Errorf("ReleaseLease() error = %v", err)
+	}
+}
+
+func testLeaseSeparateFromRateLimit(t *testing.T, ls storage.LeaseStorage, s storage.RateLimitStorage, clock Clock, key string) {
+	ctx := context.Background()
+	limit := storage.Limit{Algorithm: storage.FixedWindow, Limit: 2, Window: window}
+
+	mustCheck(t, s, ctx, key, limit, limit.Limit)
+	assertResult(t, &mustAcquire(t, ls, ctx, key, leaseLimit).Result, true, 1, leaseLimit.Limit)
+	assertResult(t, mustStatus(t, s, ctx, key, limit), true, 0, limit.Limit)
+}
+
+// mustAcquire calls AcquireLease and fails the test on error.
+func mustAcquire(t *testing.T, ls storage.LeaseStorage, ctx context.Context, key string, limit storage.Limit) *storage.Lease {
+	t.Helper()
+
+	lease, err := ls.AcquireLease(ctx, key, limit)
+	if err != nil {
+		t.Fatalf("AcquireLease() error = %v", err)
+	}
+	return lease
+}
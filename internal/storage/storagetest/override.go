@@ -0,0 +1,141 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// overrideTests hold for every backend that implements storage.OverrideStorage.
+var overrideTests = []struct {
+	name string
+	fn   func(t *testing.T, store storage.OverrideStorage, pattern string)
+}{
+	{"SaveAndGet", testOverrideSaveAndGet},
+	{"SaveReplaces", testOverrideSaveReplaces},
+	{"GetMissing", testOverrideGetMissing},
+	{"Delete", testOverrideDelete},
+	{"DeleteMissing", testOverrideDeleteMissing},
+	{"ListOrderedByPattern", testOverrideListOrderedByPattern},
+}
+
+// runOverrideTests runs overrideTests if the backend implements storage.OverrideStorage.
+func runOverrideTests(t *testing.T, newStorage Factory) {
+	for _, tt := range overrideTests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newStorage(t)
+			store, ok := s.(storage.OverrideStorage)
+			if !ok {
+				s.Close()
+				t.Skip("overrides not supported")
+			}
+			pattern := uniqueKey(t)
+
+			t.Cleanup(func() {
+				defer s.Close()
+
+				// Remove leftovers from shared backends; missing overrides are fine
+				for _, p := range []string{pattern, pattern + ":a", pattern + ":*"} {
+					if err := store.DeleteOverride(context.Background(), p); err != nil && !errors.Is(err, storage.ErrOverrideNotFound) {
+						t.Logf("failed to delete the override %s: %v", p, err)
+					}
+				}
+			})
+
+			tt.fn(t, store, pattern)
+		})
+	}
+}
+
+func testOverrideSaveAndGet(t *testing.T, store storage.OverrideStorage, pattern string) {
+	ctx := context.Background()
+	want := storage.Override{Pattern: pattern + ":*", Action: storage.ScaleLimit, Multiplier: 2.5, Reason: "partner"}
+
+	mustSaveOverride(t, store, ctx, want)
+
+	got, err := store.GetOverride(ctx, pattern+":*")
+	if err != nil {
+		t.Fatalf("GetOverride() error = %v", err)
+	}
+	if *got != want {
+		t.Errorf("GetOverride() = %+v, want %+v", *got, want)
+	}
+}
+
+func testOverrideSaveReplaces(t *testing.T, store storage.OverrideStorage, pattern string) {
+	ctx := context.Background()
+
+	mustSaveOverride(t, store, ctx, storage.Override{Pattern: pattern, Action: storage.AlwaysAllow})
+	want := storage.Override{Pattern: pattern, Action: storage.AlwaysDeny, Reason: "abuse"}
+	mustSaveOverride(t, store, ctx, want)
+
+	got, err := store.GetOverride(ctx, pattern)
+	if err != nil {
+		t.Fatalf("GetOverride() error = %v", err)
+	}
+	if *got != want {
+		t.Errorf("GetOverride() = %+v, want %+v", *got, want)
+	}
+}
+
+func testOverrideGetMissing(t *testing.T, store storage.OverrideStorage, pattern string) {
+	if _, err := store.GetOverride(context.Background(), pattern); !errors.Is(err, storage.ErrOverrideNotFound) {
+		t.Errorf("GetOverride() error = %v, want %v", err, storage.ErrOverrideNotFound)
+	}
+}
+
+func testOverrideDelete(t *testing.T, store storage.OverrideStorage, pattern string) {
+	ctx := context.Background()
+
+	mustSaveOverride(t, store, ctx, storage.Override{Pattern: pattern, Action: storage.AlwaysDeny})
+
+	if err := store.DeleteOverride(ctx, pattern); err != nil {
+		t.Fatalf("DeleteOverride() error = %v", err)
+	}
+	if _, err := store.GetOverride(ctx, pattern); !errors.Is(err, storage.ErrOverrideNotFound) {
+		t.Errorf("GetOverride() after delete error = %v, want %v", err, storage.ErrOverrideNotFound)
+	}
+}
+
+func testOverrideDeleteMissing(t *testing.T, store storage.OverrideStorage, pattern string) {
+	if err := store.DeleteOverride(context.Background(), pattern); !errors.Is(err, storage.ErrOverrideNotFound) {
+		t.Errorf("DeleteOverride() error = %v, want %v", err, storage.ErrOverrideNotFound)
+	}
+}
+
+func testOverrideListOrderedByPattern(t *testing.T, store storage.OverrideStorage, pattern string) {
+	ctx := context.Background()
+
+	mustSaveOverride(t, store, ctx, storage.Override{Pattern: pattern + ":a", Action: storage.AlwaysDeny})
+	mustSaveOverride(t, store, ctx, storage.Override{Pattern: pattern + ":*", Action: storage.AlwaysAllow})
+
+	overrides, err := store.ListOverrides(ctx)
+	if err != nil {
+		t.Fatalf("ListOverrides() error = %v", err)
+	}
+
+	// Shared backends may hold other overrides, so only look at ours
+	var patterns []string
+	for i, override := range overrides {
+		if i > 0 && overrides[i-1].Pattern > override.Pattern {
+			t.Errorf("ListOverrides() not ordered: %q before %q", overrides[i-1].Pattern, override.Pattern)
+		}
+		if override.Pattern == pattern+":a" || override.Pattern == pattern+":*" {
+			patterns = append(patterns, override.Pattern)
+		}
+	}
+	if len(patterns) != 2 || patterns[0] != pattern+":*" || patterns[1] != pattern+":a" {
+		t.Errorf("ListOverrides() patterns = %v, want [%s:* %s:a]", patterns, pattern, pattern)
+	}
+}
+
+// mustSaveOverride calls SaveOverride and fails the test on error.
+func mustSaveOverride(t *testing.T, store storage.OverrideStorage, ctx context.Context, override storage.Override) {
+	t.Helper()
+
+	if err := store.SaveOverride(ctx, override); err != nil {
+		t.Fatalf("SaveOverride() error = %v", err)
+	}
+}
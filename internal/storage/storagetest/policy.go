@@ -0,0 +1,259 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// policyTests hold for every backend that implements storage.PolicyStorage.
+var policyTests = []struct {
+	name string
+	fn   func(t *testing.T, ps storage.PolicyStorage, name string)
+}{
+	{"SaveAndGet", testPolicySaveAndGet},
+	{"SaveCalendarPeriod", testPolicySaveCalendarPeriod},
+	{"SaveTiers", testPolicySaveTiers},
+	{"SaveReplaces", testPolicySaveReplaces},
+	{"CreateExisting", testPolicyCreateExisting},
+	{"UpdateExisting", testPolicyUpdateExisting},
+	{"UpdateMissing", testPolicyUpdateMissing},
+	{"GetMissing", testPolicyGetMissing},
+	{"Delete", testPolicyDelete},
+	{"DeleteMissing", testPolicyDeleteMissing},
+	{"ListOrderedByName", testPolicyListOrderedByName},
+}
+
+// runPolicyTests runs policyTests if the backend implements storage.PolicyStorage.
+func runPolicyTests(t *testing.T, newStorage Factory) {
+	for _, tt := range policyTests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newStorage(t)
+			ps, ok := s.(storage.PolicyStorage)
+			if !ok {
+				s.Close()
+				t.Skip("policies not supported")
+			}
+			name := uniqueKey(t)
+
+			t.Cleanup(func() {
+				defer s.Close()
+
+				// Remove leftovers from shared backends; missing policies are fine
+				for _, n := range []string{name, name + "-a", name + "-b"} {
+					if err := ps.DeletePolicy(context.Background(), n); err != nil && !errors.Is(err, storage.ErrPolicyNotFound) {
+						t.Logf("failed to delete the policy %s: %v", n, err)
+					}
+				}
+			})
+
+			tt.fn(t, ps, name)
+		})
+	}
+}
+
+func testPolicySaveAndGet(t *testing.T, ps storage.PolicyStorage, name string) {
+	ctx := context.Background()
+	want := storage.Policy{
+		Name:        name,
+		KeyPattern:  "user:*",
+		Limit:       storage.Limit{Algorithm: storage.TokenBucket, Limit: 100, Window: time.Minute, RefillRate: 10},
+		Shadow:      true,
+		FailureMode: storage.FailLocal,
+	}
+
+	mustSavePolicy(t, ps, ctx, want)
+
+	got, err := ps.GetPolicy(ctx, name)
+	if err != nil {
+		t.Fatalf("GetPolicy() error = %v", err)
+	}
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("GetPolicy() = %+v, want %+v", *got, want)
+	}
+}
+
+func testPolicySaveCalendarPeriod(t *testing.T, ps storage.PolicyStorage, name string) {
+	ctx := context.Background()
+	want := storage.Policy{
+		Name:       name,
+		KeyPattern: "org:*",
+		Limit:      storage.Limit{Algorithm: storage.FixedWindow, Limit: 100000, Period: storage.Monthly, Timezone: "America/New_York"},
+	}
+
+	mustSavePolicy(t, ps, ctx, want)
+
+	got, err := ps.GetPolicy(ctx, name)
+	if err != nil {
+		t.Fatalf("GetPolicy() error = %v", err)
+	}
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("GetPolicy() = %+v, want %+v", *got, want)
+	}
+}
+
+func testPolicySaveTiers(t *testing.T, ps storage.PolicyStorage, name string) {
+	ctx := context.Background()
+	tiers := []storage.Limit{
+		{Algorithm: storage.SlidingWindowCounter, Limit: 1000, Window: time.Hour},
+		{Algorithm: storage.FixedWindow, Limit: 10000, Period: storage.Daily, Timezone: "Europe/Berlin"},
+	}
+	want := storage.Policy{
+		Name:  name,
+		Limit: storage.Limit{Algorithm: storage.TokenBucket, Limit: 20, Window: time.Second},
+		Tiers: tiers,
+	}
+
+	mustSavePolicy(t, ps, ctx, want)
+
+	// The stored policy must not share the caller's tiers
+	tiers[0].Limit = 1
+
+	got, err := ps.GetPolicy(ctx, name)
+	if err != nil {
+		t.Fatalf("GetPolicy() error = %v", err)
+	}
+	want.Tiers = []storage.Limit{
+		{Algorithm: storage.SlidingWindowCounter, Limit: 1000, Window: time.Hour},
+		{Algorithm: storage.FixedWindow, Limit: 10000, Period: storage.Daily, Timezone: "Europe/Berlin"},
+	}
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("GetPolicy() = %+v, want %+v", *got, want)
+	}
+}
+
+func testPolicySaveReplaces(t *testing.T, ps storage.PolicyStorage, name string) {
+	ctx := context.Background()
+
+	mustSavePolicy(t, ps, ctx, storage.Policy{Name: name, Limit: storage.Limit{Algorithm: storage.FixedWindow, Limit: 5, Window: time.Second}})
+	want := storage.Policy{Name: name, Limit: storage.Limit{Algorithm: storage.GCRA, Limit: 10, Window: time.Minute}}
+	mustSavePolicy(t, ps, ctx, want)
+
+	got, err := ps.GetPolicy(ctx, name)
+	if err != nil {
+		t.Fatalf("GetPolicy() error = %v", err)
+	}
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("GetPolicy() = %+v, want %+v", *got, want)
+	}
+}
+
+func testPolicyCreateExisting(t *testing.T, ps storage.PolicyStorage, name string) {
+	ctx := context.Background()
+	want := storage.Policy{Name: name, Limit: storage.Limit{Algorithm: storage.FixedWindow, Limit: 5, Window: time.Second}}
+
+	if err := ps.CreatePolicy(ctx, want); err != nil {
+		t.Fatalf("CreatePolicy() error = %v", err)
+	}
+
+	// A second create fails and leaves the first policy in place
+	other := storage.Policy{Name: name, Limit: storage.Limit{Algorithm: storage.GCRA, Limit: 10, Window: time.Minute}}
+	if err := ps.CreatePolicy(ctx, other); !errors.Is(err, storage.ErrPolicyExists) {
+		t.Errorf("CreatePolicy() error = %v, want %v", err, storage.ErrPolicyExists)
+	}
+
+	got, err := ps.GetPolicy(ctx, name)
+	if err != nil {
+		t.Fatalf("GetPolicy() error = %v", err)
+	}
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("GetPolicy() = %+v, want %+v", *got, want)
+	}
+}
+
+func testPolicyUpdateExisting(t *testing.T, ps storage.PolicyStorage, name string) {
+	ctx := context.Background()
+
+	mustSavePolicy(t, ps, ctx, storage.Policy{Name: name, Limit: storage.Limit{Algorithm: storage.FixedWindow, Limit: 5, Window: time.Second}})
+	want := storage.Policy{Name: name, KeyPattern: "user:*", Limit: storage.Limit{Algorithm: storage.GCRA, Limit: 10, Window: time.Minute}}
+	if err := ps.UpdatePolicy(ctx, want); err != nil {
+		t.Fatalf("UpdatePolicy() error = %v", err)
+	}
+
+	got, err := ps.GetPolicy(ctx, name)
+	if err != nil {
+		t.Fatalf("GetPolicy() error = %v", err)
+	}
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("GetPolicy() = %+v, want %+v", *got, want)
+	}
+}
+
+func testPolicyUpdateMissing(t *testing.T, ps storage.PolicyStorage, name string) {
+	ctx := context.Background()
+	policy := storage.Policy{Name: name, Limit: storage.Limit{Algorithm: storage.FixedWindow, Limit: 5, Window: time.Second}}
+
+	if err := ps.UpdatePolicy(ctx, policy); !errors.Is(err, storage.ErrPolicyNotFound) {
+		t.Errorf("UpdatePolicy() error = %v, want %v", err, storage.ErrPolicyNotFound)
+	}
+
+	// A failed update must not create the policy
+	if _, err := ps.GetPolicy(ctx, name); !errors.Is(err, storage.ErrPolicyNotFound) {
+		t.Errorf("GetPolicy() error = %v, want %v", err, storage.ErrPolicyNotFound)
+	}
+}
+
+func testPolicyGetMissing(t *testing.T, ps storage.PolicyStorage, name string) {
+	if _, err := ps.GetPolicy(context.Background(), name); !errors.Is(err, storage.ErrPolicyNotFound) {
+		t.Errorf("GetPolicy() error = %v, want %v", err, storage.ErrPolicyNotFound)
+	}
+}
+
+func testPolicyDelete(t *testing.T, ps storage.PolicyStorage, name string) {
+	ctx := context.Background()
+
+	mustSavePolicy(t, ps, ctx, storage.Policy{Name: name, Limit: storage.Limit{Algorithm: storage.FixedWindow, Limit: 5, Window: time.Second}})
+
+	if err := ps.DeletePolicy(ctx, name); err != nil {
+		t.Fatalf("DeletePolicy() error = %v", err)
+	}
+	if _, err := ps.GetPolicy(ctx, name); !errors.Is(err, storage.ErrPolicyNotFound) {
+		t.Errorf("GetPolicy() after delete error = %v, want %v", err, storage.ErrPolicyNotFound)
+	}
+}
+
+func testPolicyDeleteMissing(t *testing.T, ps storage.PolicyStorage, name string) {
+	if err := ps.DeletePolicy(context.Background(), name); !errors.Is(err, storage.ErrPolicyNotFound) {
+		t.Errorf("DeletePolicy() error = %v, want %v", err, storage.ErrPolicyNotFound)
+	}
+}
+
+func testPolicyListOrderedByName(t *testing.T, ps storage.PolicyStorage, name string) {
+	ctx := context.Background()
+	limit := storage.Limit{Algorithm: storage.FixedWindow, Limit: 5, Window: time.Second}
+
+	mustSavePolicy(t, ps, ctx, storage.Policy{Name: name + "-b", Limit: limit})
+	mustSavePolicy(t, ps, ctx, storage.Policy{Name: name + "-a", Limit: limit})
+
+	policies, err := ps.ListPolicies(ctx)
+	if err != nil {
+		t.Fatalf("ListPolicies() error = %v", err)
+	}
+
+	// Shared backends may hold other policies, so only look at ours
+	var names []string
+	for i, policy := range policies {
+		if i > 0 && policies[i-1].Name > policy.Name {
+			t.Errorf("ListPolicies() not ordered: %q before %q", policies[i-1].Name, policy.Name)
+		}
+		if policy.Name == name+"-a" || policy.Name == name+"-b" {
+			names = append(names, policy.Name)
+		}
+	}
+	if len(names) != 2 || names[0] != name+"-a" || names[1] != name+"-b" {
+		t.Errorf("ListPolicies() names = %v, want [%s-a %s-b]", names, name, name)
+	}
+}
+
+// mustSavePolicy calls SavePolicy and fails the test on error.
+func mustSavePolicy(t *testing.T, ps storage.PolicyStorage, ctx context.Context, policy storage.Policy) {
+	t.Helper()
+
+	if err := ps.SavePolicy(ctx, policy); err != nil {
+		t.Fatalf("SavePolicy() error = %v", err)
+	}
+}
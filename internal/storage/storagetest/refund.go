@@ -0,0 +1,91 @@
+package storagetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+func testRefundReturnsCost(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	ctx := context.Background()
+
+	mustCheck(t, s, ctx, key, limit, 3)
+
+	assertResult(t, mustRefund(t, s, ctx, key, limit, 2), true, limit.Limit-1, limit.Limit)
+	assertResult(t, mustStatus(t, s, ctx, key, limit), true, limit.Limit-1, limit.Limit)
+}
+
+func testRefundNeverAboveLimit(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	ctx := context.Background()
+
+	mustCheck(t, s, ctx, key, limit, 1)
+
+	assertResult(t, mustRefund(t, s, ctx, key, limit, 10*limit.Limit), true, limit.Limit, limit.Limit)
+
+	// The extra refund was not banked for later
+	assertResult(t, mustCheck(t, s, ctx, key, limit, limit.Limit), true, 0, limit.Limit)
+	assertResult(t, mustCheck(t, s, ctx, key, limit, 1), false, 0, limit.Limit)
+}
+
+func testRefundUnknownKey(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	ctx := context.Background()
+
+	assertResult(t, mustRefund(t, s, ctx, key, limit, 3), true, limit.Limit, limit.Limit)
+
+	assertResult(t, mustCheck(t, s, ctx, key, limit, limit.Limit), true, 0, limit.Limit)
+	assertResult(t, mustCheck(t, s, ctx, key, limit, 1), false, 0, limit.Limit)
+}
+
+func testRefundExpiredWindow(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	ctx := context.Background()
+
+	mustCheck(t, s, ctx, key, limit, limit.Limit)
+	clock.Sleep(2*limit.Window + tolerance)
+
+	// Cost from a window that has ended is not carried into the next one
+	assertResult(t, mustRefund(t, s, ctx, key, limit, limit.Limit), true, limit.Limit, limit.Limit)
+	assertResult(t, mustCheck(t, s, ctx, key, limit, limit.Limit), true, 0, limit.Limit)
+	assertResult(t, mustCheck(t, s, ctx, key, limit, 1), false, 0, limit.Limit)
+}
+
+func testSlidingLogRefundNewestFirst(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	ctx := context.Background()
+
+	mustCheck(t, s, ctx, key, limit, 2)
+	clock.Sleep(limit.Window / 2)
+	mustCheck(t, s, ctx, key, limit, limit.Limit-2)
+
+	assertResult(t, mustRefund(t, s, ctx, key, limit, limit.Limit-2), true, limit.Limit-2, limit.Limit)
+
+	// Only the older request was left, so the whole limit is back once it ages out
+	clock.Sleep(limit.Window/2 + tolerance)
+	assertResult(t, mustStatus(t, s, ctx, key, limit), true, limit.Limit, limit.Limit)
+}
+
+func testSlidingCounterRefundLeavesPreviousWindow(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	ctx := context.Background()
+
+	mustCheck(t, s, ctx, key, limit, limit.Limit)
+
+	// Move just past the start of the next fixed window, where most of the previous window still counts
+	now := clock.Now()
+	clock.Sleep(now.Truncate(limit.Window).Add(limit.Window).Sub(now) + limit.Window/10)
+
+	before := mustStatus(t, s, ctx, key, limit)
+	if before.Remaining == limit.Limit {
+		t.Fatalf("remaining = %d before refund, want the previous window to still count", before.Remaining)
+	}
+	assertResult(t, mustRefund(t, s, ctx, key, limit, limit.Limit), before.Allowed, before.Remaining, limit.Limit)
+}
+
+// mustRefund calls Refund and fails the test on error.
+func mustRefund(t *testing.T, s storage.RateLimitStorage, ctx context.Context, key string, limit storage.Limit, amount int64) *storage.Result {
+	t.Helper()
+
+	result, err := s.Refund(ctx, key, limit, amount)
+	if err != nil {
+		t.Fatalf("Refund() error = %v", err)
+	}
+	return result
+}
@@ -0,0 +1,487 @@
+// Package storagetest provides a conformance suite for storage.RateLimitStorage implementations.
+//
+// Every backend should pass the suite so that callers see the same semantics regardless of storage:
+//
+//	func TestConformance(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) (storage.RateLimitStorage, storagetest.Clock) {
+//			clock := storagetest.NewFakeClock(time.Now())
+//			return memory.NewMemoryStorage(memory.WithClock(clock.Now)), clock
+//		})
+//	}
+//
+// The suite runs once per algorithm in storage.Algorithms. Algorithms a backend
+// reports as storage.ErrUnsupportedAlgorithm are skipped. Batch, policy, override and
+// lease tests run only for backends that also implement storage.BatchStorage,
+// storage.PolicyStorage, storage.OverrideStorage and storage.LeaseStorage.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// Factory returns a fresh backend for a single test along with the clock it runs on.
+// Backends that cannot take an injected clock should return RealClock.
+// The suite closes the backend when the test finishes.
+type Factory func(t *testing.T) (storage.RateLimitStorage, Clock)
+
+// window is the window length used by the suite. It is kept short so RealClock backends finish quickly.
+const window = time.Second
+
+// tolerance allows for network latency and clock skew when comparing times reported by the backend.
+const tolerance = 250 * time.Millisecond
+
+// testCase is a single conformance test. limit carries the algorithm under test.
+type testCase struct {
+	name string
+	fn   func(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string)
+}
+
+// commonTests hold for every algorithm.
+var commonTests = []testCase{
+	{"AllowsWithinLimit", testAllowsWithinLimit},
+	{"DeniesOverLimit", testDeniesOverLimit},
+	{"CostGreaterThanLimit", testCostGreaterThanLimit},
+	{"RecoversAfterIdle", testRecoversAfterIdle},
+	{"KeysAreIndependent", testKeysAreIndependent},
+	{"GetStatusUnknownKey", testGetStatusUnknownKey},
+	{"GetStatusDoesNotConsume", testGetStatusDoesNotConsume},
+	{"ResetMissingKey", testResetMissingKey},
+	{"ResetClearsState", testResetClearsState},
+	{"ConcurrentSameKey", testConcurrentSameKey},
+	{"ConcurrentManyKeys", testConcurrentManyKeys},
+	{"CheckAllAllowed", testCheckAllAllowed},
+	{"CheckAllDeniedConsumesNothing", testCheckAllDeniedConsumesNothing},
+	{"CheckAllConcurrent", testCheckAllConcurrent},
+	{"RefundReturnsCost", testRefundReturnsCost},
+	{"RefundNeverAboveLimit", testRefundNeverAboveLimit},
+	{"RefundUnknownKey", testRefundUnknownKey},
+	{"RefundExpiredWindow", testRefundExpiredWindow},
+}
+
+// algorithmTests hold only for the algorithm they are listed under.
+var algorithmTests = map[storage.Algorithm][]testCase{
+	storage.FixedWindow: {
+		{"DeniedRequestsCount", testFixedWindowDeniedRequestsCount},
+		{"ResetAtWithinWindow", testFixedWindowResetAtWithinWindow},
+		{"WindowExpiry", testFixedWindowExpiry},
+		{"ResetExpiredKey", testFixedWindowResetExpiredKey},
+	},
+	storage.GCRA: {
+		{"RetryAfterIsExact", testGCRARetryAfterIsExact},
+	},
+	storage.SlidingWindowLog: {
+		{"RollingWindow", testSlidingLogRollingWindow},
+		{"LimitTooHigh", testSlidingLogLimitTooHigh},
+		{"RefundNewestFirst", testSlidingLogRefundNewestFirst},
+	},
+	storage.SlidingWindowCounter: {
+		{"RetryAfterIsEnough", testSlidingCounterRetryAfterIsEnough},
+		{"RefundLeavesPreviousWindow", testSlidingCounterRefundLeavesPreviousWindow},
+	},
+}
+
+// Run runs every conformance test against backends created by newStorage.
+func Run(t *testing.T, newStorage Factory) {
+	for _, algorithm := range storage.Algorithms {
+		t.Run(string(algorithm), func(t *testing.T) {
+			limit := storage.Limit{Algorithm: algorithm, Limit: 5, Window: window}
+
+			tests := append(append([]testCase{}, commonTests...), algorithmTests[algorithm]...)
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					s, clock := newStorage(t)
+					key := uniqueKey(t)
+
+					t.Cleanup(func() {
+						defer s.Close()
+
+						// Remove leftovers from shared backends; missing keys are fine
+						cleanupCtx := context.Background()
+						for _, k := range []string{key, key + "-other"} {
+							if err := s.Reset(cleanupCtx, k); err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+								t.Logf("failed to delete the key %s: %v", k, err)
+							}
+						}
+					})
+
+					// Skip algorithms the backend does not implement
+					if _, err := s.GetStatus(context.Background(), key, limit); errors.Is(err, storage.ErrUnsupportedAlgorithm) {
+						t.Skipf("%s not supported", algorithm)
+					}
+
+					tt.fn(t, s, clock, limit, key)
+				})
+			}
+		})
+	}
+
+	t.Run("batch", func(t *testing.T) {
+		runBatchTests(t, newStorage)
+	})
+
+	t.Run("policies", func(t *testing.T) {
+		runPolicyTests(t, newStorage)
+	})
+
+	t.Run("overrides", func(t *testing.T) {
+		runOverrideTests(t, newStorage)
+	})
+
+	t.Run("leases", func(t *testing.T) {
+		runLeaseTests(t, newStorage)
+	})
+}
+
+func testAllowsWithinLimit(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	ctx := context.Background()
+
+	for i := int64(1); i <= limit.Limit; i++ {
+		result := mustCheck(t, s, ctx, key, limit, 1)
+		assertResult(t, result, true, limit.Limit-i, limit.Limit)
+	}
+}
+
+func testDeniesOverLimit(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	ctx := context.Background()
+
+	allowed := mustCheck(t, s, ctx, key, limit, limit.Limit)
+	assertResult(t, allowed, true, 0, limit.Limit)
+	if allowed.RetryAfter != 0 {
+		t.Errorf("allowed request: RetryAfter = %v, want 0", allowed.RetryAfter)
+	}
+
+	denied := mustCheck(t, s, ctx, key, limit, 1)
+	assertResult(t, denied, false, 0, limit.Limit)
+	if denied.RetryAfter <= 0 || denied.RetryAfter > limit.Window+tolerance {
+		t.Errorf("denied request: RetryAfter = %v, want within (0, %v]", denied.RetryAfter, limit.Window)
+	}
+}
+
+func testCostGreaterThanLimit(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	ctx := context.Background()
+
+	result := mustCheck(t, s, ctx, key, limit, limit.Limit+1)
+	if result.Allowed {
+		t.Errorf("allowed = true, want false")
+	}
+	if result.Remaining < 0 || result.Remaining > limit.Limit {
+		t.Errorf("remaining = %d, want between 0 and %d", result.Remaining, limit.Limit)
+	}
+}
+
+func testRecoversAfterIdle(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	ctx := context.Background()
+
+	assertResult(t, mustCheck(t, s, ctx, key, limit, limit.Limit), true, 0, limit.Limit)
+	assertResult(t, mustCheck(t, s, ctx, key, limit, 1), false, 0, limit.Limit)
+
+	// Two windows is enough for every algorithm to forget the past
+	clock.Sleep(2*limit.Window + tolerance)
+
+	assertResult(t, mustCheck(t, s, ctx, key, limit, 1), true, limit.Limit-1, limit.Limit)
+}
+
+func testKeysAreIndependent(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	ctx := context.Background()
+
+	assertResult(t, mustCheck(t, s, ctx, key, limit, limit.Limit), true, 0, limit.Limit)
+	assertResult(t, mustCheck(t, s, ctx, key+"-other", limit, 1), true, limit.Limit-1, limit.Limit)
+}
+
+func testGetStatusUnknownKey(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	status := mustStatus(t, s, context.Background(), key, limit)
+	assertResult(t, status, true, limit.Limit, limit.Limit)
+}
+
+func testGetStatusDoesNotConsume(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	ctx := context.Background()
+
+	mustCheck(t, s, ctx, key, limit, 3)
+
+	for i := 0; i < 3; i++ {
+		status := mustStatus(t, s, ctx, key, limit)
+		assertResult(t, status, true, limit.Limit-3, limit.Limit)
+	}
+}
+
+func testResetMissingKey(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	if err := s.Reset(context.Background(), key); !errors.Is(err, storage.ErrKeyNotFound) {
+		t.Errorf("Reset() error = %v, want %v", err, storage.ErrKeyNotFound)
+	}
+}
+
+func testResetClearsState(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	ctx := context.Background()
+
+	mustCheck(t, s, ctx, key, limit, limit.Limit)
+	if err := s.Reset(ctx, key); err != nil {
+		t.Fatalf("Reset() error = %v", err)
+	}
+	assertResult(t, mustCheck(t, s, ctx, key, limit, 1), true, limit.Limit-1, limit.Limit)
+}
+
+func testConcurrentSameKey(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	ctx := context.Background()
+
+	// A long window keeps refilling algorithms from adding capacity during the test
+	limit.Limit = 500
+	limit.Window = time.Hour
+	var requests int64 = 750
+
+	var wg sync.WaitGroup
+	allowedCount := atomic.Int64{}
+	deniedCount := atomic.Int64{}
+
+	for i := int64(0); i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := s.CheckAndUpdate(ctx, key, limit, 1)
+			if err != nil {
+				t.Errorf("CheckAndUpdate() error = %v", err)
+				return
+			}
+			if result.Allowed {
+				allowedCount.Add(1)
+			} else {
+				deniedCount.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowedCount.Load() != limit.Limit {
+		t.Errorf("expected exactly %d allowed, got %d", limit.Limit, allowedCount.Load())
+	}
+	if deniedCount.Load() != requests-limit.Limit {
+		t.Errorf("expected %d denied, got %d", requests-limit.Limit, deniedCount.Load())
+	}
+}
+
+func testConcurrentManyKeys(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	ctx := context.Background()
+
+	limit.Limit = 20
+	limit.Window = time.Hour
+	keys := 25
+
+	var wg sync.WaitGroup
+	allowedCounts := make([]atomic.Int64, keys)
+
+	for k := 0; k < keys; k++ {
+		subKey := fmt.Sprintf("%s-%d", key, k)
+		t.Cleanup(func() { s.Reset(context.Background(), subKey) })
+
+		for i := int64(0); i < 2*limit.Limit; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				result, err := s.CheckAndUpdate(ctx, subKey, limit, 1)
+				if err != nil {
+					t.Errorf("CheckAndUpdate() error = %v", err)
+					return
+				}
+				if result.Allowed {
+					allowedCounts[k].Add(1)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	for k := range allowedCounts {
+		if got := allowedCounts[k].Load(); got != limit.Limit {
+			t.Errorf("key %d: expected exactly %d allowed, got %d", k, limit.Limit, got)
+		}
+	}
+}
+
+func testFixedWindowDeniedRequestsCount(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	ctx := context.Background()
+
+	// Denied cost is still added to the counter, so a smaller request afterwards is denied too
+	assertResult(t, mustCheck(t, s, ctx, key, limit, limit.Limit-1), true, 1, limit.Limit)
+	assertResult(t, mustCheck(t, s, ctx, key, limit, 2), false, 0, limit.Limit)
+	assertResult(t, mustCheck(t, s, ctx, key, limit, 1), false, 0, limit.Limit)
+}
+
+func testFixedWindowResetAtWithinWindow(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	ctx := context.Background()
+
+	start := clock.Now()
+	first := mustCheck(t, s, ctx, key, limit, 1)
+	assertResetAt(t, first.ResetAt, start, start.Add(limit.Window))
+
+	// Later requests in the same window keep the original reset time
+	clock.Sleep(limit.Window / 2)
+	second := mustCheck(t, s, ctx, key, limit, 1)
+	assertResetAt(t, second.ResetAt, start, start.Add(limit.Window))
+	if d := second.ResetAt.Sub(first.ResetAt); d > tolerance || d < -tolerance {
+		t.Errorf("ResetAt moved within a window: first %v, second %v", first.ResetAt, second.ResetAt)
+	}
+
+	status := mustStatus(t, s, ctx, key, limit)
+	assertResetAt(t, status.ResetAt, start, start.Add(limit.Window))
+}
+
+func testFixedWindowExpiry(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	ctx := context.Background()
+
+	assertResult(t, mustCheck(t, s, ctx, key, limit, limit.Limit), true, 0, limit.Limit)
+
+	// The whole limit comes back exactly one window after the first request
+	clock.Sleep(limit.Window + tolerance)
+
+	assertResult(t, mustCheck(t, s, ctx, key, limit, 1), true, limit.Limit-1, limit.Limit)
+}
+
+func testFixedWindowResetExpiredKey(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	ctx := context.Background()
+
+	mustCheck(t, s, ctx, key, limit, 1)
+	clock.Sleep(limit.Window + tolerance)
+
+	if err := s.Reset(ctx, key); !errors.Is(err, storage.ErrKeyNotFound) {
+		t.Errorf("Reset() error = %v, want %v", err, storage.ErrKeyNotFound)
+	}
+}
+
+func testGCRARetryAfterIsExact(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	ctx := context.Background()
+	emission := limit.Window / time.Duration(limit.Limit)
+
+	mustCheck(t, s, ctx, key, limit, limit.Limit)
+
+	// A request of cost 2 needs two emission intervals to pass
+	denied := mustCheck(t, s, ctx, key, limit, 2)
+	if denied.Allowed {
+		t.Fatalf("allowed = true, want false")
+	}
+	if denied.RetryAfter > 2*emission || denied.RetryAfter < 2*emission-tolerance {
+		t.Errorf("RetryAfter = %v, want about %v", denied.RetryAfter, 2*emission)
+	}
+
+	// Waiting exactly RetryAfter is enough
+	clock.Sleep(denied.RetryAfter)
+	assertResult(t, mustCheck(t, s, ctx, key, limit, 2), true, 0, limit.Limit)
+}
+
+func testSlidingLogRollingWindow(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	ctx := context.Background()
+
+	assertResult(t, mustCheck(t, s, ctx, key, limit, 2), true, limit.Limit-2, limit.Limit)
+
+	// Halfway through, the first request still counts against the limit
+	clock.Sleep(limit.Window / 2)
+	assertResult(t, mustCheck(t, s, ctx, key, limit, limit.Limit-2), true, 0, limit.Limit)
+
+	// The next slot frees up when the first request ages out, not at a window boundary
+	denied := mustCheck(t, s, ctx, key, limit, 1)
+	if denied.Allowed {
+		t.Fatalf("allowed = true, want false")
+	}
+	if denied.RetryAfter > limit.Window/2 || denied.RetryAfter < limit.Window/2-tolerance {
+		t.Errorf("RetryAfter = %v, want about %v", denied.RetryAfter, limit.Window/2)
+	}
+
+	// Once the first request ages out only its cost comes back
+	clock.Sleep(limit.Window/2 + tolerance)
+	assertResult(t, mustCheck(t, s, ctx, key, limit, 2), true, 0, limit.Limit)
+	assertResult(t, mustCheck(t, s, ctx, key, limit, 1), false, 0, limit.Limit)
+}
+
+func testSlidingLogLimitTooHigh(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	ctx := context.Background()
+	limit.Limit = storage.MaxSlidingLogLimit + 1
+
+	if _, err := s.CheckAndUpdate(ctx, key, limit, 1); !errors.Is(err, storage.ErrLimitTooHigh) {
+		t.Errorf("CheckAndUpdate() error = %v, want %v", err, storage.ErrLimitTooHigh)
+	}
+	if _, err := s.GetStatus(ctx, key, limit); !errors.Is(err, storage.ErrLimitTooHigh) {
+		t.Errorf("GetStatus() error = %v, want %v", err, storage.ErrLimitTooHigh)
+	}
+	if _, err := s.Refund(ctx, key, limit, 1); !errors.Is(err, storage.ErrLimitTooHigh) {
+		t.Errorf("Refund() error = %v, want %v", err, storage.ErrLimitTooHigh)
+	}
+}
+
+func testSlidingCounterRetryAfterIsEnough(t *testing.T, s storage.RateLimitStorage, clock Clock, limit storage.Limit, key string) {
+	ctx := context.Background()
+
+	mustCheck(t, s, ctx, key, limit, limit.Limit)
+
+	// Where the request lands in the fixed window decides the wait, but it is never more than two windows
+	denied := mustCheck(t, s, ctx, key, limit, 1)
+	if denied.Allowed {
+		t.Fatalf("allowed = true, want false")
+	}
+	if denied.RetryAfter <= 0 || denied.RetryAfter > 2*limit.Window {
+		t.Errorf("RetryAfter = %v, want within (0, %v]", denied.RetryAfter, 2*limit.Window)
+	}
+
+	// Waiting RetryAfter is enough
+	clock.Sleep(denied.RetryAfter)
+	if result := mustCheck(t, s, ctx, key, limit, 1); !result.Allowed {
+		t.Errorf("allowed = false after waiting RetryAfter, want true")
+	}
+}
+
+// uniqueKey returns a key that will not collide with other tests or earlier runs against a shared backend.
+// The whole key is a hash tag, so keys derived from it are stored together by clustered backends.
+func uniqueKey(t *testing.T) string {
+	return fmt.Sprintf("{storagetest:%s:%d}", t.Name(), time.Now().UnixNano())
+}
+
+// mustCheck calls CheckAndUpdate and fails the test on error.
+func mustCheck(t *testing.T, s storage.RateLimitStorage, ctx context.Context, key string, limit storage.Limit, cost int64) *storage.Result {
+	t.Helper()
+
+	result, err := s.CheckAndUpdate(ctx, key, limit, cost)
+	if err != nil {
+		t.Fatalf("CheckAndUpdate() error = %v", err)
+	}
+	return result
+}
+
+// mustStatus calls GetStatus and fails the test on error.
+func mustStatus(t *testing.T, s storage.RateLimitStorage, ctx context.Context, key string, limit storage.Limit) *storage.Result {
+	t.Helper()
+
+	result, err := s.GetStatus(ctx, key, limit)
+	if err != nil {
+		t.Fatalf("GetStatus() error = %v", err)
+	}
+	return result
+}
+
+// assertResult compares the deterministic fields of a result.
+func assertResult(t *testing.T, result *storage.Result, allowed bool, remaining, limit int64) {
+	t.Helper()
+
+	if result.Allowed != allowed {
+		t.Errorf("allowed = %v, want %v", result.Allowed, allowed)
+	}
+	if result.Remaining != remaining {
+		t.Errorf("remaining = %d, want %d", result.Remaining, remaining)
+	}
+	if result.Limit != limit {
+		t.Errorf("limit = %d, want %d", result.Limit, limit)
+	}
+}
+
+// assertResetAt checks that resetAt falls between earliest and latest, give or take the tolerance.
+func assertResetAt(t *testing.T, resetAt, earliest, latest time.Time) {
+	t.Helper()
+
+	if resetAt.Before(earliest.Add(-tolerance)) || resetAt.After(latest.Add(tolerance)) {
+		t.Errorf("ResetAt = %v, want between %v and %v", resetAt, earliest, latest)
+	}
+}
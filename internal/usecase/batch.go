@@ -0,0 +1,212 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// MaxBatchSize is the most requests CheckRateLimitBatch accepts in one call.
+const MaxBatchSize = 100
+
+// BatchResult holds the results of CheckRateLimitBatch.
+type BatchResult struct {
+	// Results has one result per request, in request order.
+	Results []*Result
+	// Allowed is true when every request was allowed.
+	Allowed bool
+	// Denied is the index of the first denied request, or -1 when every request was allowed.
+	Denied int
+}
+
+// CheckRateLimitBatch checks several keys in one storage round trip when the backend supports it.
+// Every request is validated before any is checked. Each key is then checked on its own,
+// so allowed keys are consumed even when another key in the batch is denied.
+// The tiers of a policy are checked together as in CheckRateLimit, so they are consumed only if every tier allows
+// the request, at the cost of a storage call per request with tiers.
+func (rls *RateLimiterService) CheckRateLimitBatch(ctx context.Context, reqs []CheckRequest) (*BatchResult, error) {
+	checks, resolved, err := rls.resolveChecks(ctx, reqs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Call storage layer to check and update every key
+	results, err := rls.checkWithOverrides(ctx, checks, resolved, false)
+	if err != nil {
+		return nil, err
+	}
+	return newBatchResult(results, resolved, len(reqs)), nil
+}
+
+// CheckRateLimitAll checks several keys atomically and consumes cost on them only if every key allows it.
+// When a key denies the request nothing is consumed, and BatchResult.Denied points at the first such key.
+func (rls *RateLimiterService) CheckRateLimitAll(ctx context.Context, reqs []CheckRequest) (*BatchResult, error) {
+	checks, resolved, err := rls.resolveChecks(ctx, reqs)
+	if err != nil {
+		return nil, err
+	}
+
+	// The same key twice would be evaluated against state that doesn't include its own first check
+	seen := make(map[string]struct{}, len(checks))
+	for i, check := range checks {
+		if _, ok := seen[check.Key]; ok {
+			return nil, fmt.Errorf("requests[%d]: %w", resolved[i].request, ErrDuplicateKey)
+		}
+		seen[check.Key] = struct{}{}
+	}
+
+	// Call storage layer to check every key and update them together
+	results, err := rls.checkWithOverrides(ctx, checks, resolved, true)
+	if err != nil {
+		return nil, err
+	}
+	return newBatchResult(results, resolved, len(reqs)), nil
+}
+
+// resolution is the policy a storage check was resolved from and, for a calendar-aligned limit, when its period ends.
+type resolution struct {
+	policy    storage.Policy
+	periodEnd time.Time
+	// request is the index of the request the check belongs to and tier the index of its limit in policy.Limits().
+	request int
+	tier    int
+	// key is the request's key and shadow whether the check only records what it would have decided.
+	key    string
+	shadow bool
+	// override is the action of the override that applies to key, if any.
+	override storage.OverrideAction
+}
+
+// resolveChecks validates every request and resolves the storage checks for each one, one per tier of its limit.
+func (rls *RateLimiterService) resolveChecks(ctx context.Context, reqs []CheckRequest) ([]storage.Check, []resolution, error) {
+
+	// Validate input
+	if len(reqs) == 0 || len(reqs) > MaxBatchSize {
+		return nil, nil, ErrInvalidBatchSize
+	}
+	checks := make([]storage.Check, 0, len(reqs))
+	resolved := make([]resolution, 0, len(reqs))
+	for i, req := range reqs {
+		// A batch is already checked as a list, so it can't hold hierarchies
+		if len(req.Parents) > 0 {
+			return nil, nil, fmt.Errorf("requests[%d]: %w", i, ErrInvalidParents)
+		}
+		c, r, err := rls.resolveCheck(ctx, req, i)
+		if err != nil {
+			return nil, nil, fmt.Errorf("requests[%d]: %w", i, err)
+		}
+		checks, resolved = append(checks, c...), append(resolved, r...)
+	}
+	return checks, resolved, nil
+}
+
+// resolveCheck validates one request and resolves the storage checks it becomes, one per tier of its limit
+// and of the shadow policy checked alongside it.
+func (rls *RateLimiterService) resolveCheck(ctx context.Context, req CheckRequest, request int) ([]storage.Check, []resolution, error) {
+	if len(strings.TrimSpace(req.Key)) == 0 {
+		return nil, nil, ErrInvalidKey
+	}
+	if req.Cost <= 0 {
+		return nil, nil, ErrInvalidCost
+	}
+	policy, action, err := rls.resolvePolicy(ctx, req.Key, req.Policy, req.Limit, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	checks, resolved, err := rls.tierChecks(req.Key, policy, req.Cost, request)
+	if err != nil {
+		return nil, nil, err
+	}
+	// A shadow policy is checked alongside an enforced policy matching the same key instead of taking its place
+	if req.Policy == "" && policy.Name != "" && !policy.Shadow {
+		c, r, err := rls.shadowChecks(ctx, req.Key, req.Cost, request)
+		if err != nil {
+			return nil, nil, err
+		}
+		checks, resolved = append(checks, c...), append(resolved, r...)
+	}
+	for i := range resolved {
+		resolved[i].shadow = resolved[i].shadow || req.Shadow
+		resolved[i].override = action
+	}
+	return checks, resolved, nil
+}
+
+// newBatchResult pairs each storage result with its policy, reports the most restrictive tier of each request
+// and finds the first denied request.
+func newBatchResult(results []*storage.Result, resolved []resolution, requests int) *BatchResult {
+	tiers := make([][]*Result, requests)
+	for i, result := range results {
+		r := resolved[i]
+		tiers[r.request] = append(tiers[r.request], newResult(result, r))
+	}
+
+	batch := &BatchResult{Results: make([]*Result, requests), Allowed: true, Denied: -1}
+	for i := range tiers {
+		batch.Results[i] = tiers[i][binding(tiers[i])]
+		if !batch.Results[i].Allowed && batch.Allowed {
+			batch.Allowed, batch.Denied = false, i
+		}
+	}
+	return batch
+}
+
+// checkEach checks every request on its own: the tiers of a request together through checkTogether, and the
+// requests with a single limit in one batch.
+func (rls *RateLimiterService) checkEach(ctx context.Context, checks []storage.Check, resolved []resolution) ([]*storage.Result, error) {
+	tiers := make(map[int][]int)
+	for i, r := range resolved {
+		tiers[r.request] = append(tiers[r.request], i)
+	}
+
+	results := make([]*storage.Result, len(checks))
+	var single []int
+	for _, request := range slices.Sorted(maps.Keys(tiers)) {
+		indices := tiers[request]
+		if len(indices) == 1 {
+			single = append(single, indices[0])
+			continue
+		}
+		stored, err := rls.checkTogether(ctx, storage.Pick(checks, indices), storage.Pick(resolved, indices))
+		if err != nil {
+			return nil, err
+		}
+		for i, result := range stored {
+			results[indices[i]] = result
+		}
+	}
+	if len(single) == 0 {
+		return results, nil
+	}
+
+	stored, err := rls.checkBatch(ctx, storage.Pick(checks, single))
+	if err != nil {
+		return nil, err
+	}
+	for i, result := range stored {
+		results[single[i]] = result
+	}
+	return results, nil
+}
+
+// checkBatch uses the backend's batch support if it has any, otherwise it checks one key at a time.
+func (rls *RateLimiterService) checkBatch(ctx context.Context, checks []storage.Check) ([]*storage.Result, error) {
+	if bs, ok := rls.storage.(storage.BatchStorage); ok {
+		return bs.CheckAndUpdateBatch(ctx, checks)
+	}
+
+	results := make([]*storage.Result, len(checks))
+	for i, check := range checks {
+		result, err := rls.storage.CheckAndUpdate(ctx, check.Key, check.Limit, check.Cost)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = result
+	}
+	return results, nil
+}
@@ -0,0 +1,233 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// mockBatchStorage answers batches from a fixed list of results, one per check.
+type mockBatchStorage struct {
+	mockStorage
+	batchResults []*storage.Result
+	gotChecks    []storage.Check
+}
+
+func (m *mockBatchStorage) CheckAndUpdateBatch(ctx context.Context, checks []storage.Check) ([]*storage.Result, error) {
+	m.gotChecks = checks
+	return m.batchResults[:len(checks)], nil
+}
+
+func TestRateLimiter_CheckRateLimitBatch(t *testing.T) {
+	limit := storage.Limit{Limit: 10, Window: time.Second}
+	allowed := &storage.Result{Allowed: true, Remaining: 9, Limit: 10}
+	denied := &storage.Result{Allowed: false, Remaining: 0, Limit: 10}
+
+	tests := []struct {
+		name        string
+		inputReqs   []CheckRequest
+		mockResults []*storage.Result
+		wantErr     error
+		wantAllowed bool
+	}{
+		{
+			name: "all allowed",
+			inputReqs: []CheckRequest{
+				{Key: "user:1", Limit: limit, Cost: 1},
+				{Key: "org:1", Limit: limit, Cost: 1},
+			},
+			mockResults: []*storage.Result{allowed, allowed},
+			wantAllowed: true,
+		},
+		{
+			name: "one denied",
+			inputReqs: []CheckRequest{
+				{Key: "user:1", Limit: limit, Cost: 1},
+				{Key: "org:1", Limit: limit, Cost: 1},
+			},
+			mockResults: []*storage.Result{allowed, denied},
+			wantAllowed: false,
+		},
+		{
+			name:    "empty batch",
+			wantErr: ErrInvalidBatchSize,
+		},
+		{
+			name:      "too many requests",
+			inputReqs: make([]CheckRequest, MaxBatchSize+1),
+			wantErr:   ErrInvalidBatchSize,
+		},
+		{
+			name: "invalid request",
+			inputReqs: []CheckRequest{
+				{Key: "user:1", Limit: limit, Cost: 1},
+				{Key: "org:1", Limit: limit, Cost: 0},
+			},
+			wantErr: ErrInvalidCost,
+		},
+		{
+			name: "request with parents",
+			inputReqs: []CheckRequest{
+				{Key: "user:1", Limit: limit, Cost: 1, Parents: []Parent{{Key: "org:1", Limit: limit}}},
+			},
+			wantErr: ErrInvalidParents,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockBatchStorage{batchResults: tt.mockResults}
+
+			service := NewRateLimiterService(mock)
+			batch, err := service.CheckRateLimitBatch(context.Background(), tt.inputReqs)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CheckRateLimitBatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if mock.gotChecks != nil {
+					t.Errorf("storage called despite invalid batch")
+				}
+				return
+			}
+
+			if batch.Allowed != tt.wantAllowed {
+				t.Errorf("CheckRateLimitBatch() allowed = %v, wantAllowed %v", batch.Allowed, tt.wantAllowed)
+			}
+			if len(batch.Results) != len(tt.inputReqs) {
+				t.Fatalf("len(Results) = %d, want %d", len(batch.Results), len(tt.inputReqs))
+			}
+			for i, check := range mock.gotChecks {
+				if check.Key != tt.inputReqs[i].Key || check.Limit.Algorithm != storage.FixedWindow {
+					t.Errorf("checks[%d] = %+v, want key %s with default algorithm", i, check, tt.inputReqs[i].Key)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiter_CheckRateLimitBatch_WithoutBatchStorage(t *testing.T) {
+	mock := &mockStorage{checkAndUpdateResult: &storage.Result{Allowed: true, Remaining: 4, Limit: 5}}
+	service := NewRateLimiterService(mock)
+
+	batch, err := service.CheckRateLimitBatch(context.Background(), []CheckRequest{
+		{Key: "user:1", Limit: storage.Limit{Limit: 5, Window: time.Second}, Cost: 1},
+		{Key: "org:1", Limit: storage.Limit{Limit: 5, Window: time.Second}, Cost: 1},
+	})
+	if err != nil {
+		t.Fatalf("CheckRateLimitBatch() error = %v", err)
+	}
+	if !batch.Allowed || len(batch.Results) != 2 {
+		t.Errorf("CheckRateLimitBatch() = %+v, want 2 allowed results", batch)
+	}
+}
+
+func TestRateLimiter_CheckRateLimitBatch_Tiers(t *testing.T) {
+	ps := &mockPolicyStorage{policies: map[string]storage.Policy{
+		"users": {Name: "users", KeyPattern: "user:*", Limit: storage.Limit{Limit: 20, Window: time.Second}, Tiers: []storage.Limit{
+			{Limit: 1000, Window: time.Hour},
+		}},
+	}}
+	mock := &mockBatchStorage{
+		mockStorage: mockStorage{checkAllResults: []*storage.Result{
+			{Allowed: true, Remaining: 20, Limit: 20},
+			{Allowed: false, Remaining: 0, Limit: 1000, RetryAfter: time.Minute},
+		}},
+		batchResults: []*storage.Result{{Allowed: true, Remaining: 9, Limit: 10}},
+	}
+	service := NewRateLimiterService(mock, WithPolicies(ps))
+
+	batch, err := service.CheckRateLimitBatch(context.Background(), []CheckRequest{
+		{Key: "user:1", Cost: 1},
+		{Key: "org:1", Cost: 1, Limit: storage.Limit{Limit: 10, Window: time.Second}},
+	})
+	if err != nil {
+		t.Fatalf("CheckRateLimitBatch() error = %v", err)
+	}
+
+	// The tiers of a key are checked together, as in CheckRateLimit, so a denying tier consumes nothing
+	if len(mock.gotAllChecks) != 2 || mock.gotAllChecks[0].Key != "user:1" {
+		t.Errorf("storage checked %+v together, want the 2 tiers of user:1", mock.gotAllChecks)
+	}
+	if len(mock.gotChecks) != 1 || mock.gotChecks[0].Key != "org:1" {
+		t.Errorf("storage batch got %+v, want only org:1", mock.gotChecks)
+	}
+	if batch.Results[0].Allowed || batch.Results[0].Tier != 1 || !batch.Results[1].Allowed || batch.Denied != 0 {
+		t.Errorf("CheckRateLimitBatch() = %+v, want user:1 denied by its hourly tier and org:1 allowed", batch)
+	}
+}
+
+func TestRateLimiter_CheckRateLimitAll(t *testing.T) {
+	limit := storage.Limit{Limit: 10, Window: time.Second}
+	allowed := &storage.Result{Allowed: true, Remaining: 10, Limit: 10}
+	denied := &storage.Result{Allowed: false, Remaining: 0, Limit: 10, RetryAfter: time.Second}
+
+	tests := []struct {
+		name        string
+		inputReqs   []CheckRequest
+		mockResults []*storage.Result
+		wantErr     error
+		wantAllowed bool
+		wantDenied  int
+	}{
+		{
+			name: "all allowed",
+			inputReqs: []CheckRequest{
+				{Key: "user:1", Limit: limit, Cost: 1},
+				{Key: "org:1", Limit: limit, Cost: 1},
+			},
+			mockResults: []*storage.Result{allowed, allowed},
+			wantAllowed: true,
+			wantDenied:  -1,
+		},
+		{
+			name: "second key denies",
+			inputReqs: []CheckRequest{
+				{Key: "user:1", Limit: limit, Cost: 1},
+				{Key: "org:1", Limit: limit, Cost: 1},
+				{Key: "endpoint:1", Limit: limit, Cost: 1},
+			},
+			mockResults: []*storage.Result{allowed, denied, denied},
+			wantAllowed: false,
+			wantDenied:  1,
+		},
+		{
+			name: "duplicate key",
+			inputReqs: []CheckRequest{
+				{Key: "user:1", Limit: limit, Cost: 1},
+				{Key: "user:1", Limit: limit, Cost: 1},
+			},
+			wantErr: ErrDuplicateKey,
+		},
+		{
+			name:    "empty batch",
+			wantErr: ErrInvalidBatchSize,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockStorage{checkAllResults: tt.mockResults}
+
+			service := NewRateLimiterService(mock)
+			batch, err := service.CheckRateLimitAll(context.Background(), tt.inputReqs)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CheckRateLimitAll() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if batch.Allowed != tt.wantAllowed {
+				t.Errorf("CheckRateLimitAll() allowed = %v, wantAllowed %v", batch.Allowed, tt.wantAllowed)
+			}
+			if batch.Denied != tt.wantDenied {
+				t.Errorf("CheckRateLimitAll() denied = %d, wantDenied %d", batch.Denied, tt.wantDenied)
+			}
+		})
+	}
+}
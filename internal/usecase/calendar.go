@@ -0,0 +1,58 @@
+package usecase
+
+import (
+	"sync"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// locations caches loaded time zones by name, since time.LoadLocation reads the zone database each call.
+var locations sync.Map
+
+// loadLocation returns the named IANA time zone. An empty name is UTC.
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// calendarWindow maps a limit aligned to a calendar period onto the fixed window storage enforces.
+// The window ends with the current period, and each period is counted under its own key, e.g. "{user:1}:2026-10-01",
+// so a counter left over from the previous period is never reused. It also returns when the period ends.
+// A limit without a period is returned unchanged with a zero end.
+func (rls *RateLimiterService) calendarWindow(key string, limit storage.Limit) (string, storage.Limit, time.Time, error) {
+	if limit.Period == "" {
+		return key, limit, time.Time{}, nil
+	}
+
+	loc, err := loadLocation(limit.Timezone)
+	if err != nil {
+		return "", limit, time.Time{}, ErrInvalidTimezone
+	}
+	now := rls.now().In(loc)
+	start, end := limit.Period.Bounds(now)
+
+	limit.Algorithm = storage.FixedWindow
+	limit.Window = end.Sub(now)
+	return storage.SubKey(key, start.Format(time.DateOnly)), limit, end, nil
+}
+
+// atPeriodEnd makes a calendar-aligned result reset when its period ends, including for keys without a counter yet.
+// Results without a period end are returned unchanged.
+func atPeriodEnd(result *storage.Result, end time.Time) *storage.Result {
+	if !end.IsZero() {
+		result.ResetAt = end
+	}
+	return result
+}
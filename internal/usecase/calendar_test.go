@@ -0,0 +1,137 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+func TestRateLimiter_CheckRateLimit_CalendarPeriod(t *testing.T) {
+	// A Saturday afternoon in UTC, already Sunday in Tokyo
+	now := time.Date(2026, time.October, 17, 15, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		inputLimit  storage.Limit
+		wantErr     error
+		wantKey     string
+		wantResetAt time.Time
+	}{
+		{
+			name:        "daily in UTC",
+			inputLimit:  storage.Limit{Limit: 1000, Period: storage.Daily},
+			wantKey:     "{user:1}:2026-10-17",
+			wantResetAt: time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:        "weekly starts on Monday",
+			inputLimit:  storage.Limit{Limit: 1000, Period: storage.Weekly},
+			wantKey:     "{user:1}:2026-10-12",
+			wantResetAt: time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:        "monthly in New York",
+			inputLimit:  storage.Limit{Algorithm: storage.FixedWindow, Limit: 1000, Period: storage.Monthly, Timezone: "America/New_York"},
+			wantKey:     "{user:1}:2026-10-01",
+			wantResetAt: time.Date(2026, time.November, 1, 4, 0, 0, 0, time.UTC),
+		},
+		{
+			name:        "daily in Tokyo is a day ahead",
+			inputLimit:  storage.Limit{Limit: 1000, Period: storage.Daily, Timezone: "Asia/Tokyo"},
+			wantKey:     "{user:1}:2026-10-18",
+			wantResetAt: time.Date(2026, time.October, 18, 15, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "unknown period",
+			inputLimit: storage.Limit{Limit: 1000, Period: "year"},
+			wantErr:    ErrInvalidPeriod,
+		},
+		{
+			name:       "unknown timezone",
+			inputLimit: storage.Limit{Limit: 1000, Period: storage.Daily, Timezone: "Mars/Olympus_Mons"},
+			wantErr:    ErrInvalidTimezone,
+		},
+		{
+			name:       "period with another algorithm",
+			inputLimit: storage.Limit{Algorithm: storage.TokenBucket, Limit: 1000, Window: time.Hour, Period: storage.Daily},
+			wantErr:    ErrInvalidAlgorithm,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The storage result resets now, as it would for a key without a counter
+			mock := &mockStorage{
+				checkAndUpdateResult: &storage.Result{Allowed: true, Remaining: 999, Limit: 1000, ResetAt: now},
+			}
+
+			service := NewRateLimiterService(mock)
+			service.now = func() time.Time { return now }
+
+			result, err := service.CheckRateLimit(context.Background(), CheckRequest{Key: "user:1", Limit: tt.inputLimit, Cost: 1})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CheckRateLimit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if mock.gotKey != tt.wantKey {
+				t.Errorf("storage got key %q, want %q", mock.gotKey, tt.wantKey)
+			}
+			if mock.gotLimit.Algorithm != storage.FixedWindow {
+				t.Errorf("storage got algorithm %q, want %q", mock.gotLimit.Algorithm, storage.FixedWindow)
+			}
+			if want := tt.wantResetAt.Sub(now); mock.gotLimit.Window != want {
+				t.Errorf("storage got window %v, want %v", mock.gotLimit.Window, want)
+			}
+			if !result.ResetAt.Equal(tt.wantResetAt) {
+				t.Errorf("CheckRateLimit() reset at = %v, want %v", result.ResetAt, tt.wantResetAt)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_ResetLimit_CalendarPolicy(t *testing.T) {
+	now := time.Date(2026, time.October, 17, 15, 4, 5, 0, time.UTC)
+	ps := &mockPolicyStorage{policies: map[string]storage.Policy{
+		"users": {Name: "users", KeyPattern: "user:*", Limit: storage.Limit{Algorithm: storage.FixedWindow, Limit: 1000, Period: storage.Monthly}},
+	}}
+
+	tests := []struct {
+		name     string
+		inputKey string
+		wantKey  string
+	}{
+		{
+			name:     "matching calendar policy resets the current period",
+			inputKey: "user:1",
+			wantKey:  "{user:1}:2026-10-01",
+		},
+		{
+			name:     "no policy resets the key",
+			inputKey: "org:1",
+			wantKey:  "org:1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockStorage{}
+
+			service := NewRateLimiterService(mock, WithPolicies(ps))
+			service.now = func() time.Time { return now }
+
+			if err := service.ResetLimit(context.Background(), tt.inputKey); err != nil {
+				t.Fatalf("ResetLimit() error = %v", err)
+			}
+			if mock.gotKey != tt.wantKey {
+				t.Errorf("storage got key %q, want %q", mock.gotKey, tt.wantKey)
+			}
+		})
+	}
+}
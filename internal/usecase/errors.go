@@ -11,4 +11,42 @@ var (
 	ErrInvalidWindow = errors.New("input window is invalid")
 	// ErrInvalidCost will be returned if cost <= 0
 	ErrInvalidCost = errors.New("input cost is invalid")
+	// ErrInvalidAmount will be returned if a refund amount <= 0
+	ErrInvalidAmount = errors.New("input amount is invalid")
+	// ErrInvalidAlgorithm will be returned if the algorithm is not one of storage.Algorithms
+	ErrInvalidAlgorithm = errors.New("input algorithm is invalid")
+	// ErrInvalidRefillRate will be returned if refill rate < 0
+	ErrInvalidRefillRate = errors.New("input refill rate is invalid")
+	// ErrInvalidPeriod will be returned if a period is not one of storage.Periods
+	ErrInvalidPeriod = errors.New("input period is invalid")
+	// ErrInvalidTimezone will be returned if a timezone is not a known IANA time zone
+	ErrInvalidTimezone = errors.New("input timezone is invalid")
+	// ErrInvalidPolicyName will be returned if a policy name is empty
+	ErrInvalidPolicyName = errors.New("input policy name is invalid")
+	// ErrInvalidKeyPattern will be returned if a policy key pattern is not valid path.Match syntax
+	ErrInvalidKeyPattern = errors.New("input key pattern is invalid")
+	// ErrInvalidFailureMode will be returned if a policy's failure mode is not one of storage.FailureModes
+	ErrInvalidFailureMode = errors.New("input failure mode is invalid")
+	// ErrInvalidOverridePattern will be returned if an override pattern is empty or not valid path.Match syntax
+	ErrInvalidOverridePattern = errors.New("input override pattern is invalid")
+	// ErrInvalidOverrideAction will be returned if an override action is not one of storage.OverrideActions
+	ErrInvalidOverrideAction = errors.New("input override action is invalid")
+	// ErrInvalidMultiplier will be returned if a scale override's multiplier <= 0, or another override has one
+	ErrInvalidMultiplier = errors.New("input multiplier is invalid")
+	// ErrInvalidBatchSize will be returned if a batch is empty or holds more than MaxBatchSize requests
+	ErrInvalidBatchSize = errors.New("input batch size is invalid")
+	// ErrDuplicateKey will be returned if an all-or-nothing check names the same key twice
+	ErrDuplicateKey = errors.New("input keys are not unique")
+	// ErrInvalidTiers will be returned if a policy has more than MaxTiers tiers, repeats a window or period, or limits leases
+	ErrInvalidTiers = errors.New("input tiers are invalid")
+	// ErrInvalidParents will be returned if parents are empty, repeat a key, exceed MaxParents or are used in a batch
+	ErrInvalidParents = errors.New("input parents are invalid")
+	// ErrInvalidLeaseID will be returned if a lease ID is empty
+	ErrInvalidLeaseID = errors.New("input lease ID is invalid")
+	// ErrLeasesUnsupported will be returned if a lease is used but the service has no lease storage
+	ErrLeasesUnsupported = errors.New("leases are not supported by this server")
+	// ErrPoliciesUnsupported will be returned if a policy is used but the service has no policy storage
+	ErrPoliciesUnsupported = errors.New("policies are not supported by this server")
+	// ErrOverridesUnsupported will be returned if overrides are managed but the service has no override storage
+	ErrOverridesUnsupported = errors.New("overrides are not supported by this server")
 )
@@ -0,0 +1,62 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// MaxParents is the most ancestors a CheckRequest can declare.
+const MaxParents = 8
+
+// Parent is an ancestor whose limit is shared by its child keys, e.g. the org a user key belongs to.
+type Parent struct {
+	Key string
+	// Policy names the server-side policy to enforce on Key. Empty means match Key against policy patterns.
+	Policy string
+	// Limit is used only when no policy applies.
+	Limit storage.Limit
+}
+
+// checkHierarchy consumes the request's cost from its key and every parent atomically, or from none of them.
+// The result reports the most restrictive level: when denied, the denied level that frees up last,
+// otherwise the level with the least remaining.
+func (rls *RateLimiterService) checkHierarchy(ctx context.Context, req CheckRequest) (*Result, error) {
+
+	// Validate input
+	if len(req.Parents) > MaxParents {
+		return nil, ErrInvalidParents
+	}
+	checks, resolved, err := rls.resolveCheck(ctx, CheckRequest{Key: req.Key, Policy: req.Policy, Limit: req.Limit, Cost: req.Cost, Shadow: req.Shadow}, 0)
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{req.Key}
+	for i, parent := range req.Parents {
+		// Each level is its own counter, so a key can't appear twice in the chain
+		if len(strings.TrimSpace(parent.Key)) == 0 || slices.Contains(keys, parent.Key) {
+			return nil, fmt.Errorf("parents[%d]: %w", i, ErrInvalidParents)
+		}
+		c, r, err := rls.resolveCheck(ctx, CheckRequest{Key: parent.Key, Policy: parent.Policy, Limit: parent.Limit, Cost: req.Cost, Shadow: req.Shadow}, i+1)
+		if err != nil {
+			return nil, fmt.Errorf("parents[%d]: %w", i, err)
+		}
+		keys = append(keys, parent.Key)
+		checks, resolved = append(checks, c...), append(resolved, r...)
+	}
+
+	// Call storage layer to check every level and update them together
+	results, err := rls.checkWithOverrides(ctx, checks, resolved, true)
+	if err != nil {
+		return nil, err
+	}
+	batch := newBatchResult(results, resolved, len(keys))
+
+	level := binding(batch.Results)
+	result := batch.Results[level]
+	result.LimitedBy = keys[level]
+	return result, nil
+}
@@ -0,0 +1,135 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+func TestRateLimiter_CheckRateLimit_Parents(t *testing.T) {
+	user := storage.Limit{Limit: 100, Window: time.Minute}
+	org := storage.Limit{Limit: 1000, Window: time.Minute}
+	resetAt := time.Now().Add(time.Minute)
+
+	tests := []struct {
+		name          string
+		inputParents  []Parent
+		mockResults   []*storage.Result
+		wantErr       error
+		wantAllowed   bool
+		wantLimitedBy string
+		wantRemaining int64
+	}{
+		{
+			name:         "allowed reports the least remaining",
+			inputParents: []Parent{{Key: "org:1", Limit: org}},
+			mockResults: []*storage.Result{
+				{Allowed: true, Remaining: 99, Limit: 100, ResetAt: resetAt},
+				{Allowed: true, Remaining: 40, Limit: 1000, ResetAt: resetAt},
+			},
+			wantAllowed:   true,
+			wantLimitedBy: "org:1",
+			wantRemaining: 40,
+		},
+		{
+			name:         "tie goes to the later reset",
+			inputParents: []Parent{{Key: "org:1", Limit: org}},
+			mockResults: []*storage.Result{
+				{Allowed: true, Remaining: 40, Limit: 100, ResetAt: resetAt},
+				{Allowed: true, Remaining: 40, Limit: 1000, ResetAt: resetAt.Add(time.Second)},
+			},
+			wantAllowed:   true,
+			wantLimitedBy: "org:1",
+			wantRemaining: 40,
+		},
+		{
+			name:         "denied by parent",
+			inputParents: []Parent{{Key: "team:1", Limit: org}, {Key: "org:1", Limit: org}},
+			mockResults: []*storage.Result{
+				{Allowed: true, Remaining: 5, Limit: 100, ResetAt: resetAt},
+				{Allowed: true, Remaining: 500, Limit: 1000, ResetAt: resetAt},
+				{Allowed: false, Remaining: 0, Limit: 1000, ResetAt: resetAt, RetryAfter: 10 * time.Second},
+			},
+			wantAllowed:   false,
+			wantLimitedBy: "org:1",
+			wantRemaining: 0,
+		},
+		{
+			name:         "denied level that frees up last",
+			inputParents: []Parent{{Key: "org:1", Limit: org}},
+			mockResults: []*storage.Result{
+				{Allowed: false, Remaining: 0, Limit: 100, ResetAt: resetAt, RetryAfter: 30 * time.Second},
+				{Allowed: false, Remaining: 0, Limit: 1000, ResetAt: resetAt, RetryAfter: 10 * time.Second},
+			},
+			wantAllowed:   false,
+			wantLimitedBy: "user:1",
+			wantRemaining: 0,
+		},
+		{
+			name:         "parent repeats the key",
+			inputParents: []Parent{{Key: "user:1", Limit: org}},
+			wantErr:      ErrInvalidParents,
+		},
+		{
+			name:         "empty parent key",
+			inputParents: []Parent{{Key: " ", Limit: org}},
+			wantErr:      ErrInvalidParents,
+		},
+		{
+			name:         "too many parents",
+			inputParents: make([]Parent, MaxParents+1),
+			wantErr:      ErrInvalidParents,
+		},
+		{
+			name:         "invalid parent limit",
+			inputParents: []Parent{{Key: "org:1", Limit: storage.Limit{Limit: -1, Window: time.Minute}}},
+			wantErr:      ErrInvalidLimit,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockStorage{checkAllResults: tt.mockResults}
+
+			service := NewRateLimiterService(mock)
+			result, err := service.CheckRateLimit(context.Background(), CheckRequest{Key: "user:1", Limit: user, Cost: 2, Parents: tt.inputParents})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CheckRateLimit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if mock.gotAllChecks != nil {
+					t.Errorf("storage called despite invalid parents")
+				}
+				return
+			}
+
+			if result.Allowed != tt.wantAllowed {
+				t.Errorf("CheckRateLimit() allowed = %v, wantAllowed %v", result.Allowed, tt.wantAllowed)
+			}
+			if result.LimitedBy != tt.wantLimitedBy {
+				t.Errorf("CheckRateLimit() limited by = %q, want %q", result.LimitedBy, tt.wantLimitedBy)
+			}
+			if result.Remaining != tt.wantRemaining {
+				t.Errorf("CheckRateLimit() remaining = %d, want %d", result.Remaining, tt.wantRemaining)
+			}
+
+			// Every level is checked together with the request's cost
+			if len(mock.gotAllChecks) != len(tt.inputParents)+1 {
+				t.Fatalf("storage got %d checks, want %d", len(mock.gotAllChecks), len(tt.inputParents)+1)
+			}
+			for i, check := range mock.gotAllChecks {
+				wantKey := "user:1"
+				if i > 0 {
+					wantKey = tt.inputParents[i-1].Key
+				}
+				if check.Key != wantKey || check.Cost != 2 {
+					t.Errorf("checks[%d] = %+v, want key %s with cost 2", i, check, wantKey)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// LeaseRequest is a request to acquire or renew a concurrency lease on Key.
+type LeaseRequest struct {
+	Key string
+	// Policy names the concurrency policy to enforce. Empty means match Key against concurrency policy patterns.
+	Policy string
+	// Limit is used only when no policy applies. Limit.Limit caps the leases held at once,
+	// and Limit.Window is how long each lease lasts without a heartbeat.
+	Limit storage.Limit
+	// LeaseID is the lease to renew. Acquire ignores it.
+	LeaseID string
+}
+
+// Lease is a storage lease together with the policy that produced it.
+type Lease struct {
+	*storage.Lease
+	// Policy is the policy that was enforced. Its Name is empty when the request's own limit was used.
+	Policy storage.Policy
+	// Override is the action of the override that applied to the key, if any.
+	Override storage.OverrideAction
+}
+
+// Acquire validates input and grants a lease if the key has fewer than the limit's leases in flight.
+// The limit comes from the request's policy, a concurrency policy matching the key, or the request itself, in that order.
+// Keys with an allow or deny override are decided without storage, and a scale override multiplies the limit.
+func (rls *RateLimiterService) Acquire(ctx context.Context, req LeaseRequest) (*Lease, error) {
+	if rls.leases == nil {
+		return nil, ErrLeasesUnsupported
+	}
+
+	// Validate input
+	if len(strings.TrimSpace(req.Key)) == 0 {
+		return nil, ErrInvalidKey
+	}
+	policy, action, err := rls.resolveLeasePolicy(ctx, req.Key, req.Policy, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	limit := storageLimit(policy, policy.Limit)
+
+	// An allow or deny override decides without storage
+	if lease := rls.overrideLease(limit, storage.NewLeaseID(), action); lease != nil {
+		return &Lease{Lease: lease, Policy: policy, Override: action}, nil
+	}
+
+	// Call storage layer to acquire the lease
+	lease, err := rls.leases.AcquireLease(ctx, req.Key, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &Lease{Lease: lease, Policy: policy, Override: action}, nil
+}
+
+// Heartbeat validates input and extends a held lease by the limit's lease duration from now.
+// A deny override revokes the lease, which is released and reported as not held. An allow override renews any lease
+// without storage.
+func (rls *RateLimiterService) Heartbeat(ctx context.Context, req LeaseRequest) (*Lease, error) {
+	if rls.leases == nil {
+		return nil, ErrLeasesUnsupported
+	}
+
+	// Validate input
+	if len(strings.TrimSpace(req.Key)) == 0 {
+		return nil, ErrInvalidKey
+	}
+	if req.LeaseID == "" {
+		return nil, ErrInvalidLeaseID
+	}
+	policy, action, err := rls.resolveLeasePolicy(ctx, req.Key, req.Policy, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	limit := storageLimit(policy, policy.Limit)
+
+	switch action {
+	case storage.AlwaysDeny:
+		// The lease may have been acquired before the key was denied, so free its slot
+		_ = rls.leases.ReleaseLease(ctx, req.Key, req.LeaseID)
+		return nil, storage.ErrLeaseNotFound
+	case storage.AlwaysAllow:
+		return &Lease{Lease: rls.overrideLease(limit, req.LeaseID, action), Policy: policy, Override: action}, nil
+	}
+
+	// Call storage layer to renew the lease
+	lease, err := rls.leases.RenewLease(ctx, req.Key, req.LeaseID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &Lease{Lease: lease, Policy: policy, Override: action}, nil
+}
+
+// Release validates input and frees a held lease for another request.
+// Leases granted by an allow override aren't held anywhere, so releasing them always succeeds.
+func (rls *RateLimiterService) Release(ctx context.Context, key, leaseID string) error {
+	if rls.leases == nil {
+		return ErrLeasesUnsupported
+	}
+
+	// Validate input
+	if len(strings.TrimSpace(key)) == 0 {
+		return ErrInvalidKey
+	}
+	if leaseID == "" {
+		return ErrInvalidLeaseID
+	}
+
+	// Call storage layer to release the lease
+	err := rls.leases.ReleaseLease(ctx, key, leaseID)
+	if !errors.Is(err, storage.ErrLeaseNotFound) {
+		return err
+	}
+	if _, action, overrideErr := rls.applyOverride(ctx, key, storage.Policy{}); overrideErr == nil && action == storage.AlwaysAllow {
+		return nil
+	}
+	return err
+}
+
+// overrideLease answers a lease on a key with an allow or deny override without storage.
+// An allowed lease has the given ID and lasts the limit's window. It returns nil for any other lease.
+func (rls *RateLimiterService) overrideLease(limit storage.Limit, leaseID string, action storage.OverrideAction) *storage.Lease {
+	now := rls.now()
+	switch action {
+	case storage.AlwaysAllow:
+		return &storage.Lease{
+			Result:    storage.Result{Allowed: true, Remaining: limit.Limit, Limit: limit.Limit, ResetAt: now},
+			ID:        leaseID,
+			ExpiresAt: now.Add(limit.Window),
+		}
+	case storage.AlwaysDeny:
+		return &storage.Lease{Result: storage.Result{Allowed: false, Remaining: 0, Limit: limit.Limit, ResetAt: now}}
+	default:
+		return nil
+	}
+}
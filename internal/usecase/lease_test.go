@@ -0,0 +1,323 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+type mockLeaseStorage struct {
+	lease *storage.Lease
+	err   error
+
+	// gotLimit is the limit passed to the last AcquireLease or RenewLease call
+	gotLimit storage.Limit
+	// gotReleased is the lease passed to the last ReleaseLease call
+	gotReleased string
+}
+
+func (m *mockLeaseStorage) AcquireLease(ctx context.Context, key string, limit storage.Limit) (*storage.Lease, error) {
+	m.gotLimit = limit
+	return m.lease, m.err
+}
+
+func (m *mockLeaseStorage) RenewLease(ctx context.Context, key, leaseID string, limit storage.Limit) (*storage.Lease, error) {
+	m.gotLimit = limit
+	return m.lease, m.err
+}
+
+func (m *mockLeaseStorage) ReleaseLease(ctx context.Context, key, leaseID string) error {
+	m.gotReleased = leaseID
+	return m.err
+}
+
+func TestRateLimiter_Acquire(t *testing.T) {
+	reports := storage.Limit{Algorithm: storage.Concurrency, Limit: 2, Window: time.Minute}
+	users := storage.Limit{Algorithm: storage.FixedWindow, Limit: 10, Window: time.Second}
+
+	policies := map[string]storage.Policy{
+		"reports": {Name: "reports", KeyPattern: "report:*", Limit: reports},
+		"users":   {Name: "users", KeyPattern: "report:user-*", Limit: users},
+	}
+
+	tests := []struct {
+		name        string
+		inputKey    string
+		inputPolicy string
+		inputLimit  storage.Limit
+		wantErr     error
+		wantLimit   storage.Limit
+	}{
+		{
+			name:       "inline limit",
+			inputKey:   "export:1",
+			inputLimit: storage.Limit{Limit: 3, Window: 30 * time.Second},
+			wantLimit:  storage.Limit{Algorithm: storage.Concurrency, Limit: 3, Window: 30 * time.Second},
+		},
+		{
+			name:       "inline limit without lease duration",
+			inputKey:   "export:1",
+			inputLimit: storage.Limit{Limit: 3},
+			wantErr:    ErrInvalidWindow,
+		},
+		{
+			name:     "empty key",
+			inputKey: "",
+			wantErr:  ErrInvalidKey,
+		},
+		{
+			name:        "named concurrency policy",
+			inputKey:    "export:1",
+			inputPolicy: "reports",
+			wantLimit:   reports,
+		},
+		{
+			name:        "named rate limit policy",
+			inputKey:    "export:1",
+			inputPolicy: "users",
+			wantErr:     ErrInvalidAlgorithm,
+		},
+		{
+			name:      "rate limit patterns are ignored",
+			inputKey:  "report:user-7",
+			wantLimit: reports,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockLeaseStorage{lease: &storage.Lease{Result: storage.Result{Allowed: true}, ID: "lease-1"}}
+			service := NewRateLimiterService(&mockStorage{}, WithPolicies(&mockPolicyStorage{policies: policies}), WithLeases(mock))
+
+			lease, err := service.Acquire(context.Background(), LeaseRequest{
+				Key:    tt.inputKey,
+				Policy: tt.inputPolicy,
+				Limit:  tt.inputLimit,
+			})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Acquire() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if mock.gotLimit != tt.wantLimit {
+					t.Errorf("Acquire() limit = %+v, want %+v", mock.gotLimit, tt.wantLimit)
+				}
+				if lease.ID != "lease-1" {
+					t.Errorf("Acquire() lease ID = %q, want %q", lease.ID, "lease-1")
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiter_Heartbeat(t *testing.T) {
+	limit := storage.Limit{Limit: 2, Window: time.Minute}
+
+	tests := []struct {
+		name         string
+		inputKey     string
+		inputLeaseID string
+		mockError    error
+		wantErr      error
+	}{
+		{
+			name:         "valid",
+			inputKey:     "export:1",
+			inputLeaseID: "lease-1",
+		},
+		{
+			name:         "empty key",
+			inputKey:     "",
+			inputLeaseID: "lease-1",
+			wantErr:      ErrInvalidKey,
+		},
+		{
+			name:     "empty lease ID",
+			inputKey: "export:1",
+			wantErr:  ErrInvalidLeaseID,
+		},
+		{
+			name:         "expired lease",
+			inputKey:     "export:1",
+			inputLeaseID: "lease-1",
+			mockError:    storage.ErrLeaseNotFound,
+			wantErr:      storage.ErrLeaseNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockLeaseStorage{lease: &storage.Lease{Result: storage.Result{Allowed: true}}, err: tt.mockError}
+			service := NewRateLimiterService(&mockStorage{}, WithLeases(mock))
+
+			_, err := service.Heartbeat(context.Background(), LeaseRequest{Key: tt.inputKey, Limit: limit, LeaseID: tt.inputLeaseID})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Heartbeat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_Release(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		inputKey     string
+		inputLeaseID string
+		leases       bool
+		mockError    error
+		wantErr      error
+	}{
+		{
+			name:         "valid",
+			inputKey:     "export:1",
+			inputLeaseID: "lease-1",
+			leases:       true,
+		},
+		{
+			name:         "empty lease ID",
+			inputKey:     "export:1",
+			inputLeaseID: "",
+			leases:       true,
+			wantErr:      ErrInvalidLeaseID,
+		},
+		{
+			name:         "released lease",
+			inputKey:     "export:1",
+			inputLeaseID: "lease-1",
+			leases:       true,
+			mockError:    storage.ErrLeaseNotFound,
+			wantErr:      storage.ErrLeaseNotFound,
+		},
+		{
+			name:         "no lease storage",
+			inputKey:     "export:1",
+			inputLeaseID: "lease-1",
+			wantErr:      ErrLeasesUnsupported,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.leases {
+				opts = append(opts, WithLeases(&mockLeaseStorage{err: tt.mockError}))
+			}
+			service := NewRateLimiterService(&mockStorage{}, opts...)
+
+			err := service.Release(context.Background(), tt.inputKey, tt.inputLeaseID)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Release() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_LeaseOverrides(t *testing.T) {
+	limit := storage.Limit{Algorithm: storage.Concurrency, Limit: 4, Window: time.Minute}
+	overrides := &mockOverrideStorage{overrides: map[string]storage.Override{
+		"health:*": {Pattern: "health:*", Action: storage.AlwaysAllow},
+		"user:bad": {Pattern: "user:bad", Action: storage.AlwaysDeny},
+		"user:vip": {Pattern: "user:vip", Action: storage.ScaleLimit, Multiplier: 2.5},
+	}}
+
+	tests := []struct {
+		name         string
+		inputKey     string
+		wantAllowed  bool
+		wantStorage  bool
+		wantLimit    int64
+		wantOverride storage.OverrideAction
+		// wantHeartbeatErr is the error of renewing the lease, and wantReleased whether that freed it in storage
+		wantHeartbeatErr error
+		wantReleased     bool
+		// wantReleaseErr is the error of releasing a lease storage doesn't hold
+		wantReleaseErr error
+	}{
+		{
+			name:           "no override",
+			inputKey:       "user:1",
+			wantAllowed:    true,
+			wantStorage:    true,
+			wantLimit:      4,
+			wantReleaseErr: storage.ErrLeaseNotFound,
+		},
+		{
+			name:         "allow override skips storage",
+			inputKey:     "health:probe",
+			wantAllowed:  true,
+			wantLimit:    4,
+			wantOverride: storage.AlwaysAllow,
+		},
+		{
+			name:             "deny override skips storage",
+			inputKey:         "user:bad",
+			wantLimit:        4,
+			wantOverride:     storage.AlwaysDeny,
+			wantHeartbeatErr: storage.ErrLeaseNotFound,
+			wantReleased:     true,
+			wantReleaseErr:   storage.ErrLeaseNotFound,
+		},
+		{
+			name:           "scale override multiplies the limit",
+			inputKey:       "user:vip",
+			wantAllowed:    true,
+			wantStorage:    true,
+			wantLimit:      10,
+			wantOverride:   storage.ScaleLimit,
+			wantReleaseErr: storage.ErrLeaseNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			mock := &mockLeaseStorage{lease: &storage.Lease{Result: storage.Result{Allowed: true, Limit: 4}, ID: "lease-1"}}
+			service := NewRateLimiterService(&mockStorage{}, WithOverrides(overrides), WithLeases(mock))
+			req := LeaseRequest{Key: tt.inputKey, Limit: limit}
+
+			lease, err := service.Acquire(ctx, req)
+			if err != nil {
+				t.Fatalf("Acquire() error = %v", err)
+			}
+			if lease.Allowed != tt.wantAllowed || lease.Override != tt.wantOverride {
+				t.Errorf("Acquire() = allowed %v with override %q, want %v with %q", lease.Allowed, lease.Override, tt.wantAllowed, tt.wantOverride)
+			}
+			if lease.Allowed && lease.ID == "" {
+				t.Error("Acquire() granted a lease without an ID")
+			}
+			if called := mock.gotLimit != (storage.Limit{}); called != tt.wantStorage {
+				t.Errorf("Acquire() called storage = %v, want %v", called, tt.wantStorage)
+			}
+			if tt.wantStorage && mock.gotLimit.Limit != tt.wantLimit {
+				t.Errorf("Acquire() storage limit = %d, want %d", mock.gotLimit.Limit, tt.wantLimit)
+			}
+			if !tt.wantStorage && lease.Limit != tt.wantLimit {
+				t.Errorf("Acquire() limit = %d, want %d", lease.Limit, tt.wantLimit)
+			}
+
+			req.LeaseID = "lease-1"
+			mock.gotLimit = storage.Limit{}
+			_, err = service.Heartbeat(ctx, req)
+			if !errors.Is(err, tt.wantHeartbeatErr) {
+				t.Errorf("Heartbeat() error = %v, want %v", err, tt.wantHeartbeatErr)
+			}
+			if called := mock.gotLimit != (storage.Limit{}); called != tt.wantStorage {
+				t.Errorf("Heartbeat() called storage = %v, want %v", called, tt.wantStorage)
+			}
+			if released := mock.gotReleased != ""; released != tt.wantReleased {
+				t.Errorf("Heartbeat() released the lease = %v, want %v", released, tt.wantReleased)
+			}
+
+			mock.err = storage.ErrLeaseNotFound
+			if err := service.Release(ctx, tt.inputKey, "lease-1"); !errors.Is(err, tt.wantReleaseErr) {
+				t.Errorf("Release() error = %v, want %v", err, tt.wantReleaseErr)
+			}
+		})
+	}
+}
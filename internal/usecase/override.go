@@ -0,0 +1,230 @@
+package usecase
+
+import (
+	"context"
+	"math"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// SaveOverride validates the override and creates or replaces the one with the same pattern.
+func (rls *RateLimiterService) SaveOverride(ctx context.Context, override storage.Override) error {
+	if rls.overrides == nil {
+		return ErrOverridesUnsupported
+	}
+
+	// Validate input
+	if err := validateOverride(override); err != nil {
+		return err
+	}
+
+	if err := rls.overrides.store.SaveOverride(ctx, override); err != nil {
+		return err
+	}
+	rls.overrides.invalidate()
+	return nil
+}
+
+// DeleteOverride removes the override with the given pattern. Keys it applied to get their usual limits again.
+func (rls *RateLimiterService) DeleteOverride(ctx context.Context, pattern string) error {
+	if rls.overrides == nil {
+		return ErrOverridesUnsupported
+	}
+
+	// Validate input
+	if len(strings.TrimSpace(pattern)) == 0 {
+		return ErrInvalidOverridePattern
+	}
+
+	if err := rls.overrides.store.DeleteOverride(ctx, pattern); err != nil {
+		return err
+	}
+	rls.overrides.invalidate()
+	return nil
+}
+
+// GetOverride returns the override with the given pattern as currently stored.
+func (rls *RateLimiterService) GetOverride(ctx context.Context, pattern string) (*storage.Override, error) {
+	if rls.overrides == nil {
+		return nil, ErrOverridesUnsupported
+	}
+
+	// Validate input
+	if len(strings.TrimSpace(pattern)) == 0 {
+		return nil, ErrInvalidOverridePattern
+	}
+
+	return rls.overrides.store.GetOverride(ctx, pattern)
+}
+
+// ListOverrides returns every stored override ordered by pattern.
+func (rls *RateLimiterService) ListOverrides(ctx context.Context) ([]storage.Override, error) {
+	if rls.overrides == nil {
+		return nil, ErrOverridesUnsupported
+	}
+
+	return rls.overrides.store.ListOverrides(ctx)
+}
+
+// applyOverride finds the override for key and scales the policy by it if it is a scale override.
+// It returns the policy together with the override's action, which is empty when no override applies.
+func (rls *RateLimiterService) applyOverride(ctx context.Context, key string, policy storage.Policy) (storage.Policy, storage.OverrideAction, error) {
+	if rls.overrides == nil {
+		return policy, "", nil
+	}
+
+	overrides, err := rls.overrides.list(ctx)
+	if err != nil {
+		return storage.Policy{}, "", err
+	}
+	override, found := matchOverride(overrides, key)
+	if !found {
+		return policy, "", nil
+	}
+	if override.Action == storage.ScaleLimit {
+		policy = scalePolicy(policy, override.Multiplier)
+	}
+	return policy, override.Action, nil
+}
+
+// matchOverride returns the override naming key exactly, otherwise the one with the longest pattern matching key.
+// Ties go to the first override in the list.
+func matchOverride(overrides []storage.Override, key string) (storage.Override, bool) {
+	var best storage.Override
+	found := false
+	for _, override := range overrides {
+		if override.Pattern == key {
+			return override, true
+		}
+		if override.Matches(key) && (!found || len(override.Pattern) > len(best.Pattern)) {
+			best, found = override, true
+		}
+	}
+	return best, found
+}
+
+// scalePolicy multiplies the limit and refill rate of every tier of the policy, keeping each at least 1.
+func scalePolicy(policy storage.Policy, multiplier float64) storage.Policy {
+	scale := func(n int64) int64 {
+		if n == 0 {
+			return 0
+		}
+		return max(1, int64(math.Round(float64(n)*multiplier)))
+	}
+
+	policy.Limit.Limit, policy.Limit.RefillRate = scale(policy.Limit.Limit), scale(policy.Limit.RefillRate)
+	// Copy the tiers so the cached policy isn't changed
+	policy.Tiers = slices.Clone(policy.Tiers)
+	for i := range policy.Tiers {
+		policy.Tiers[i].Limit, policy.Tiers[i].RefillRate = scale(policy.Tiers[i].Limit), scale(policy.Tiers[i].RefillRate)
+	}
+	return policy
+}
+
+// overrideResult answers a check on a key with an allow or deny override without storage.
+// It returns nil for any other check, which storage has to answer.
+func (rls *RateLimiterService) overrideResult(check storage.Check, action storage.OverrideAction) *storage.Result {
+	switch action {
+	case storage.AlwaysAllow:
+		return &storage.Result{Allowed: true, Remaining: check.Limit.Limit, Limit: check.Limit.Limit, ResetAt: rls.now()}
+	case storage.AlwaysDeny:
+		return &storage.Result{Allowed: false, Remaining: 0, Limit: check.Limit.Limit, ResetAt: rls.now()}
+	default:
+		return nil
+	}
+}
+
+// checkWithOverrides answers checks on keys with an allow or deny override itself and sends the rest to storage:
+// together through checkTogether, or else request by request through checkEach. When checks go together and an override
+// denies, the rest are only read, so nothing is consumed.
+func (rls *RateLimiterService) checkWithOverrides(ctx context.Context, checks []storage.Check, resolved []resolution, together bool) ([]*storage.Result, error) {
+	results := make([]*storage.Result, len(checks))
+	var pending []int
+	denied := false
+	for i, check := range checks {
+		results[i] = rls.overrideResult(check, resolved[i].override)
+		switch {
+		case results[i] == nil:
+			pending = append(pending, i)
+		case !results[i].Allowed:
+			denied = true
+		}
+	}
+	if len(pending) == 0 {
+		return results, nil
+	}
+
+	var stored []*storage.Result
+	var err error
+	switch {
+	case together && denied:
+		stored, err = rls.readChecks(ctx, storage.Pick(checks, pending))
+	case together:
+		stored, err = rls.checkTogether(ctx, storage.Pick(checks, pending), storage.Pick(resolved, pending))
+	default:
+		stored, err = rls.checkEach(ctx, storage.Pick(checks, pending), storage.Pick(resolved, pending))
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, result := range stored {
+		results[pending[i]] = result
+	}
+	return results, nil
+}
+
+// readChecks reports each key's state without consuming anything, the way CheckAndUpdateAll reports a denied check:
+// Allowed is whether the key alone would allow its cost.
+func (rls *RateLimiterService) readChecks(ctx context.Context, checks []storage.Check) ([]*storage.Result, error) {
+	results := make([]*storage.Result, len(checks))
+	for i, check := range checks {
+		result, err := rls.storage.GetStatus(ctx, check.Key, check.Limit)
+		if err != nil {
+			return nil, err
+		}
+		result.Allowed = result.Remaining >= check.Cost
+		results[i] = result
+	}
+	return results, nil
+}
+
+// validateOverride checks the override's pattern, action and multiplier.
+func validateOverride(override storage.Override) error {
+	if len(strings.TrimSpace(override.Pattern)) == 0 {
+		return ErrInvalidOverridePattern
+	}
+	if _, err := path.Match(override.Pattern, ""); err != nil {
+		return ErrInvalidOverridePattern
+	}
+	if !override.Action.Valid() {
+		return ErrInvalidOverrideAction
+	}
+
+	// Only a scale override has a multiplier
+	if override.Action == storage.ScaleLimit {
+		if override.Multiplier <= 0 || math.IsInf(override.Multiplier, 0) || math.IsNaN(override.Multiplier) {
+			return ErrInvalidMultiplier
+		}
+	} else if override.Multiplier != 0 {
+		return ErrInvalidMultiplier
+	}
+	return nil
+}
+
+// overrideCache keeps a snapshot of every override.
+type overrideCache struct {
+	snapshot[storage.Override]
+	store storage.OverrideStorage
+}
+
+// newOverrideCache returns an empty override cache reading from store.
+func newOverrideCache(store storage.OverrideStorage) *overrideCache {
+	return &overrideCache{
+		snapshot: snapshot[storage.Override]{load: store.ListOverrides, ttl: DefaultPolicyRefresh, now: time.Now},
+		store:    store,
+	}
+}
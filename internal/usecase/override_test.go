@@ -0,0 +1,288 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+type mockOverrideStorage struct {
+	overrides map[string]storage.Override
+}
+
+func (m *mockOverrideStorage) SaveOverride(ctx context.Context, override storage.Override) error {
+	m.overrides[override.Pattern] = override
+	return nil
+}
+
+func (m *mockOverrideStorage) GetOverride(ctx context.Context, pattern string) (*storage.Override, error) {
+	override, ok := m.overrides[pattern]
+	if !ok {
+		return nil, storage.ErrOverrideNotFound
+	}
+	return &override, nil
+}
+
+func (m *mockOverrideStorage) DeleteOverride(ctx context.Context, pattern string) error {
+	if _, ok := m.overrides[pattern]; !ok {
+		return storage.ErrOverrideNotFound
+	}
+	delete(m.overrides, pattern)
+	return nil
+}
+
+func (m *mockOverrideStorage) ListOverrides(ctx context.Context) ([]storage.Override, error) {
+	var overrides []storage.Override
+	for _, pattern := range slices.Sorted(maps.Keys(m.overrides)) {
+		overrides = append(overrides, m.overrides[pattern])
+	}
+	return overrides, nil
+}
+
+func TestRateLimiter_CheckRateLimit_Overrides(t *testing.T) {
+	limit := storage.Limit{Algorithm: storage.TokenBucket, Limit: 10, Window: time.Second, RefillRate: 5}
+	overrides := &mockOverrideStorage{overrides: map[string]storage.Override{
+		"health:*":    {Pattern: "health:*", Action: storage.AlwaysAllow},
+		"user:*":      {Pattern: "user:*", Action: storage.ScaleLimit, Multiplier: 0.5},
+		"user:bad*":   {Pattern: "user:bad*", Action: storage.AlwaysDeny},
+		"user:bad-ok": {Pattern: "user:bad-ok", Action: storage.ScaleLimit, Multiplier: 3},
+		"user:tiny":   {Pattern: "user:tiny", Action: storage.ScaleLimit, Multiplier: 0.01},
+	}}
+
+	tests := []struct {
+		name         string
+		inputKey     string
+		wantAllowed  bool
+		wantStorage  bool
+		wantLimit    storage.Limit
+		wantOverride storage.OverrideAction
+	}{
+		{
+			name:        "no override",
+			inputKey:    "org:1",
+			wantAllowed: true,
+			wantStorage: true,
+			wantLimit:   limit,
+		},
+		{
+			name:         "allow override skips storage",
+			inputKey:     "health:probe",
+			wantAllowed:  true,
+			wantOverride: storage.AlwaysAllow,
+		},
+		{
+			name:         "longest pattern wins",
+			inputKey:     "user:bad-1",
+			wantAllowed:  false,
+			wantOverride: storage.AlwaysDeny,
+		},
+		{
+			name:         "scale override",
+			inputKey:     "user:1",
+			wantAllowed:  true,
+			wantStorage:  true,
+			wantLimit:    storage.Limit{Algorithm: storage.TokenBucket, Limit: 5, Window: time.Second, RefillRate: 3},
+			wantOverride: storage.ScaleLimit,
+		},
+		{
+			name:         "exact key wins over pattern",
+			inputKey:     "user:bad-ok",
+			wantAllowed:  true,
+			wantStorage:  true,
+			wantLimit:    storage.Limit{Algorithm: storage.TokenBucket, Limit: 30, Window: time.Second, RefillRate: 15},
+			wantOverride: storage.ScaleLimit,
+		},
+		{
+			name:         "scaled limit is at least 1",
+			inputKey:     "user:tiny",
+			wantAllowed:  true,
+			wantStorage:  true,
+			wantLimit:    storage.Limit{Algorithm: storage.TokenBucket, Limit: 1, Window: time.Second, RefillRate: 1},
+			wantOverride: storage.ScaleLimit,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockStorage{checkAndUpdateResult: &storage.Result{Allowed: true, Remaining: 1, Limit: 10}}
+
+			service := NewRateLimiterService(mock, WithOverrides(overrides))
+			result, err := service.CheckRateLimit(context.Background(), CheckRequest{Key: tt.inputKey, Limit: limit, Cost: 1})
+			if err != nil {
+				t.Fatalf("CheckRateLimit() error = %v", err)
+			}
+
+			if result.Allowed != tt.wantAllowed {
+				t.Errorf("CheckRateLimit() allowed = %v, wantAllowed %v", result.Allowed, tt.wantAllowed)
+			}
+			if result.Override != tt.wantOverride {
+				t.Errorf("CheckRateLimit() override = %q, want %q", result.Override, tt.wantOverride)
+			}
+			if called := mock.gotKey != ""; called != tt.wantStorage {
+				t.Fatalf("storage called = %v, want %v", called, tt.wantStorage)
+			}
+			if tt.wantStorage && mock.gotLimit != tt.wantLimit {
+				t.Errorf("limit = %+v, want %+v", mock.gotLimit, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_CheckRateLimitAll_DenyOverride(t *testing.T) {
+	limit := storage.Limit{Limit: 10, Window: time.Second}
+	overrides := &mockOverrideStorage{overrides: map[string]storage.Override{
+		"org:blocked": {Pattern: "org:blocked", Action: storage.AlwaysDeny},
+	}}
+	mock := &mockStorage{getStatusResult: &storage.Result{Allowed: true, Remaining: 10, Limit: 10}}
+
+	service := NewRateLimiterService(mock, WithOverrides(overrides))
+	batch, err := service.CheckRateLimitAll(context.Background(), []CheckRequest{
+		{Key: "user:1", Limit: limit, Cost: 1},
+		{Key: "org:blocked", Limit: limit, Cost: 1},
+	})
+	if err != nil {
+		t.Fatalf("CheckRateLimitAll() error = %v", err)
+	}
+
+	// The other key is only read, so nothing is consumed
+	if mock.gotAllChecks != nil {
+		t.Errorf("storage updated %+v, want nothing", mock.gotAllChecks)
+	}
+	if mock.gotKey != "user:1" {
+		t.Errorf("storage read %q, want %q", mock.gotKey, "user:1")
+	}
+	if batch.Allowed || batch.Denied != 1 {
+		t.Errorf("CheckRateLimitAll() allowed = %v, denied = %d, want false, 1", batch.Allowed, batch.Denied)
+	}
+	if !batch.Results[0].Allowed {
+		t.Errorf("CheckRateLimitAll() results[0] allowed = false, want true")
+	}
+}
+
+func TestRateLimiter_CheckRateLimit_DenyOverrideParent(t *testing.T) {
+	overrides := &mockOverrideStorage{overrides: map[string]storage.Override{
+		"org:blocked": {Pattern: "org:blocked", Action: storage.AlwaysDeny},
+	}}
+	mock := &mockStorage{getStatusResult: &storage.Result{Allowed: false, Remaining: 0, Limit: 10, RetryAfter: time.Minute}}
+
+	service := NewRateLimiterService(mock, WithOverrides(overrides))
+	result, err := service.CheckRateLimit(context.Background(), CheckRequest{
+		Key:     "user:1",
+		Limit:   storage.Limit{Limit: 10, Window: time.Second},
+		Cost:    1,
+		Parents: []Parent{{Key: "org:blocked", Limit: storage.Limit{Limit: 100, Window: time.Second}}},
+	})
+	if err != nil {
+		t.Fatalf("CheckRateLimit() error = %v", err)
+	}
+
+	// A deny override binds even over a level that frees up later
+	if result.Allowed || result.LimitedBy != "org:blocked" || result.Override != storage.AlwaysDeny {
+		t.Errorf("CheckRateLimit() allowed = %v, limited by = %q, override = %q, want false, org:blocked, deny",
+			result.Allowed, result.LimitedBy, result.Override)
+	}
+}
+
+func TestRateLimiter_SaveOverride(t *testing.T) {
+	tests := []struct {
+		name          string
+		inputOverride storage.Override
+		wantErr       error
+	}{
+		{
+			name:          "allow",
+			inputOverride: storage.Override{Pattern: "health:*", Action: storage.AlwaysAllow, Reason: "probes"},
+		},
+		{
+			name:          "scale",
+			inputOverride: storage.Override{Pattern: "partner:acme", Action: storage.ScaleLimit, Multiplier: 10},
+		},
+		{
+			name:          "empty pattern",
+			inputOverride: storage.Override{Pattern: " ", Action: storage.AlwaysDeny},
+			wantErr:       ErrInvalidOverridePattern,
+		},
+		{
+			name:          "bad pattern",
+			inputOverride: storage.Override{Pattern: "user:[", Action: storage.AlwaysDeny},
+			wantErr:       ErrInvalidOverridePattern,
+		},
+		{
+			name:          "unknown action",
+			inputOverride: storage.Override{Pattern: "user:1", Action: "throttle"},
+			wantErr:       ErrInvalidOverrideAction,
+		},
+		{
+			name:          "scale without multiplier",
+			inputOverride: storage.Override{Pattern: "user:1", Action: storage.ScaleLimit},
+			wantErr:       ErrInvalidMultiplier,
+		},
+		{
+			name:          "infinite multiplier",
+			inputOverride: storage.Override{Pattern: "user:1", Action: storage.ScaleLimit, Multiplier: math.Inf(1)},
+			wantErr:       ErrInvalidMultiplier,
+		},
+		{
+			name:          "deny with multiplier",
+			inputOverride: storage.Override{Pattern: "user:1", Action: storage.AlwaysDeny, Multiplier: 2},
+			wantErr:       ErrInvalidMultiplier,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			overrides := &mockOverrideStorage{overrides: map[string]storage.Override{}}
+			service := NewRateLimiterService(&mockStorage{}, WithOverrides(overrides))
+
+			err := service.SaveOverride(context.Background(), tt.inputOverride)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SaveOverride() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			_, stored := overrides.overrides[tt.inputOverride.Pattern]
+			if stored != (err == nil) {
+				t.Errorf("stored = %v, want %v", stored, err == nil)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_OverrideCache(t *testing.T) {
+	overrides := &mockOverrideStorage{overrides: map[string]storage.Override{}}
+	mock := &mockStorage{checkAndUpdateResult: &storage.Result{Allowed: true}}
+	service := NewRateLimiterService(mock, WithOverrides(overrides), WithPolicyRefresh(time.Hour))
+	ctx := context.Background()
+	req := CheckRequest{Key: "user:1", Limit: storage.Limit{Limit: 5, Window: time.Second}, Cost: 1}
+
+	if _, err := service.CheckRateLimit(ctx, req); err != nil {
+		t.Fatalf("CheckRateLimit() error = %v", err)
+	}
+
+	// Saving through the service takes effect immediately
+	if err := service.SaveOverride(ctx, storage.Override{Pattern: "user:1", Action: storage.AlwaysDeny}); err != nil {
+		t.Fatalf("SaveOverride() error = %v", err)
+	}
+	result, err := service.CheckRateLimit(ctx, req)
+	if err != nil {
+		t.Fatalf("CheckRateLimit() error = %v", err)
+	}
+	if result.Allowed {
+		t.Errorf("CheckRateLimit() after save allowed = true, want false")
+	}
+
+	if err := service.DeleteOverride(ctx, "user:1"); err != nil {
+		t.Fatalf("DeleteOverride() error = %v", err)
+	}
+	if result, err = service.CheckRateLimit(ctx, req); err != nil {
+		t.Fatalf("CheckRateLimit() error = %v", err)
+	}
+	if !result.Allowed {
+		t.Errorf("CheckRateLimit() after delete allowed = false, want true")
+	}
+}
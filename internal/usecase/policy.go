@@ -0,0 +1,348 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// DefaultPolicyRefresh is how long policies are cached when no WithPolicyRefresh option is given.
+const DefaultPolicyRefresh = 5 * time.Second
+
+// SavePolicy validates the policy and creates or replaces it in storage.
+func (rls *RateLimiterService) SavePolicy(ctx context.Context, policy storage.Policy) error {
+	if rls.policies == nil {
+		return ErrPoliciesUnsupported
+	}
+
+	// Validate input
+	policy, err := validatePolicy(policy)
+	if err != nil {
+		return err
+	}
+
+	if err := rls.policies.store.SavePolicy(ctx, policy); err != nil {
+		return err
+	}
+	rls.policies.invalidate()
+	return nil
+}
+
+// CreatePolicy validates the policy and adds it, failing with storage.ErrPolicyExists if the name is taken.
+func (rls *RateLimiterService) CreatePolicy(ctx context.Context, policy storage.Policy) (*storage.Policy, error) {
+	if rls.policies == nil {
+		return nil, ErrPoliciesUnsupported
+	}
+
+	// Validate input
+	policy, err := validatePolicy(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rls.policies.store.CreatePolicy(ctx, policy); err != nil {
+		return nil, err
+	}
+	rls.policies.invalidate()
+	return &policy, nil
+}
+
+// UpdatePolicy validates the policy and replaces the existing one, failing with storage.ErrPolicyNotFound if there is none.
+func (rls *RateLimiterService) UpdatePolicy(ctx context.Context, policy storage.Policy) (*storage.Policy, error) {
+	if rls.policies == nil {
+		return nil, ErrPoliciesUnsupported
+	}
+
+	// Validate input
+	policy, err := validatePolicy(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rls.policies.store.UpdatePolicy(ctx, policy); err != nil {
+		return nil, err
+	}
+	rls.policies.invalidate()
+	return &policy, nil
+}
+
+// DeletePolicy removes the named policy. Keys it applied to fall back to other policies or the caller's limit.
+func (rls *RateLimiterService) DeletePolicy(ctx context.Context, name string) error {
+	if rls.policies == nil {
+		return ErrPoliciesUnsupported
+	}
+
+	// Validate input
+	if len(strings.TrimSpace(name)) == 0 {
+		return ErrInvalidPolicyName
+	}
+
+	if err := rls.policies.store.DeletePolicy(ctx, name); err != nil {
+		return err
+	}
+	rls.policies.invalidate()
+	return nil
+}
+
+// GetPolicy returns the named policy as currently stored.
+func (rls *RateLimiterService) GetPolicy(ctx context.Context, name string) (*storage.Policy, error) {
+	if rls.policies == nil {
+		return nil, ErrPoliciesUnsupported
+	}
+
+	// Validate input
+	if len(strings.TrimSpace(name)) == 0 {
+		return nil, ErrInvalidPolicyName
+	}
+
+	return rls.policies.store.GetPolicy(ctx, name)
+}
+
+// ListPolicies returns every stored policy ordered by name.
+func (rls *RateLimiterService) ListPolicies(ctx context.Context) ([]storage.Policy, error) {
+	if rls.policies == nil {
+		return nil, ErrPoliciesUnsupported
+	}
+
+	return rls.policies.store.ListPolicies(ctx)
+}
+
+// resolvePolicy picks the rate limit policy that applies to a key and applies the key's override to it.
+// A named policy wins, then the most specific policy whose pattern matches the key, then the caller's own limit,
+// which is returned as a policy without a name. It also returns the action of the override, if any.
+func (rls *RateLimiterService) resolvePolicy(ctx context.Context, key, policyName string, limit storage.Limit, requireWindow bool) (storage.Policy, storage.OverrideAction, error) {
+	policy, found, err := rls.findPolicy(ctx, key, policyName, false)
+	if err != nil {
+		return storage.Policy{}, "", err
+	}
+	if !found {
+		// Concurrency limits are enforced with leases, not checks
+		if limit.Algorithm == storage.Concurrency {
+			return storage.Policy{}, "", ErrInvalidAlgorithm
+		}
+		if policy, err = inlinePolicy(limit, requireWindow); err != nil {
+			return storage.Policy{}, "", err
+		}
+	}
+	return rls.applyOverride(ctx, key, policy)
+}
+
+// resolveLeasePolicy picks the concurrency policy that applies to a key in the same order as resolvePolicy
+// and applies the key's override to it. It also returns the action of the override, if any.
+func (rls *RateLimiterService) resolveLeasePolicy(ctx context.Context, key, policyName string, limit storage.Limit) (storage.Policy, storage.OverrideAction, error) {
+	policy, found, err := rls.findPolicy(ctx, key, policyName, true)
+	if err != nil {
+		return storage.Policy{}, "", err
+	}
+	if !found {
+		limit.Algorithm = storage.Concurrency
+		if policy, err = inlinePolicy(limit, true); err != nil {
+			return storage.Policy{}, "", err
+		}
+	}
+	return rls.applyOverride(ctx, key, policy)
+}
+
+// findPolicy returns the named policy, or else the most specific policy whose pattern matches key.
+// Concurrency policies apply only to leases and every other policy only to rate limit checks,
+// so a key can have one of each. A shadow policy is returned only when no enforced policy matches key.
+func (rls *RateLimiterService) findPolicy(ctx context.Context, key, policyName string, concurrency bool) (storage.Policy, bool, error) {
+	if rls.policies == nil {
+		if policyName != "" {
+			return storage.Policy{}, false, ErrPoliciesUnsupported
+		}
+		return storage.Policy{}, false, nil
+	}
+
+	if policyName != "" {
+		policy, err := rls.policies.get(ctx, policyName)
+		if err != nil {
+			return storage.Policy{}, false, err
+		}
+		if (policy.Limit.Algorithm == storage.Concurrency) != concurrency {
+			return storage.Policy{}, false, fmt.Errorf("policy %s: %w", policyName, ErrInvalidAlgorithm)
+		}
+		return *policy, true, nil
+	}
+
+	// Matching policies override the caller's limit so clients can't raise their own
+	policies, err := rls.policies.list(ctx)
+	if err != nil {
+		return storage.Policy{}, false, err
+	}
+	if concurrency {
+		policy, found := matchPolicy(policies, key, concurrencyPolicy)
+		return policy, found, nil
+	}
+	// A shadow policy only takes the place of the caller's limit, never of an enforced policy
+	policy, found := matchPolicy(policies, key, enforcedPolicy)
+	if !found {
+		policy, found = matchPolicy(policies, key, shadowPolicy)
+	}
+	return policy, found, nil
+}
+
+// findShadowPolicy returns the most specific shadow policy whose pattern matches key. It is checked alongside the
+// enforced policy matching the same key.
+func (rls *RateLimiterService) findShadowPolicy(ctx context.Context, key string) (storage.Policy, bool, error) {
+	if rls.policies == nil {
+		return storage.Policy{}, false, nil
+	}
+
+	policies, err := rls.policies.list(ctx)
+	if err != nil {
+		return storage.Policy{}, false, err
+	}
+	policy, found := matchPolicy(policies, key, shadowPolicy)
+	return policy, found, nil
+}
+
+// inlinePolicy validates a limit sent with the request and wraps it in an unnamed policy.
+func inlinePolicy(limit storage.Limit, requireWindow bool) (storage.Policy, error) {
+	limit, err := validateLimit(limit, requireWindow)
+	if err != nil {
+		return storage.Policy{}, err
+	}
+	return storage.Policy{Limit: limit}, nil
+}
+
+// policyKind is what a policy limits. A key is matched against each kind on its own, so it can have one of each.
+type policyKind int
+
+const (
+	enforcedPolicy policyKind = iota
+	shadowPolicy
+	concurrencyPolicy
+)
+
+// kindOf returns the kind of the policy. Concurrency policies have no shadow mode.
+func kindOf(policy storage.Policy) policyKind {
+	switch {
+	case policy.Limit.Algorithm == storage.Concurrency:
+		return concurrencyPolicy
+	case policy.Shadow:
+		return shadowPolicy
+	default:
+		return enforcedPolicy
+	}
+}
+
+// matchPolicy returns the policy with the longest pattern matching key among the policies of one kind.
+// Ties go to the first policy in the list.
+func matchPolicy(policies []storage.Policy, key string, kind policyKind) (storage.Policy, bool) {
+	var best storage.Policy
+	found := false
+	for _, policy := range policies {
+		if kindOf(policy) != kind {
+			continue
+		}
+		if policy.Matches(key) && (!found || len(policy.KeyPattern) > len(best.KeyPattern)) {
+			best, found = policy, true
+		}
+	}
+	return best, found
+}
+
+// validatePolicy checks the policy name, pattern, limit and tiers and fills in the default algorithms.
+func validatePolicy(policy storage.Policy) (storage.Policy, error) {
+	if len(strings.TrimSpace(policy.Name)) == 0 {
+		return policy, ErrInvalidPolicyName
+	}
+	if _, err := path.Match(policy.KeyPattern, ""); err != nil {
+		return policy, ErrInvalidKeyPattern
+	}
+	if policy.FailureMode != "" && !policy.FailureMode.Valid() {
+		return policy, ErrInvalidFailureMode
+	}
+
+	limit, err := validateLimit(policy.Limit, true)
+	if err != nil {
+		return policy, err
+	}
+	policy.Limit = limit
+	return validateTiers(policy)
+}
+
+// snapshot keeps every item of one kind loaded from storage so checks don't read them each time.
+// Changes made through another server show up once the snapshot is older than ttl.
+// A failed reload keeps the last snapshot, so checks go on while storage is unavailable.
+type snapshot[T any] struct {
+	load func(ctx context.Context) ([]T, error)
+	ttl  time.Duration
+	now  func() time.Time
+
+	mutex    sync.Mutex
+	items    []T
+	loadedAt time.Time
+	loaded   bool
+}
+
+// list returns every item, reloading them from storage if the snapshot is stale.
+func (s *snapshot[T]) list(ctx context.Context) ([]T, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now()
+	if s.loaded && now.Sub(s.loadedAt) < s.ttl {
+		return s.items, nil
+	}
+
+	items, err := s.load(ctx)
+	if err != nil && s.loaded {
+		// Keep serving the last snapshot while storage is unavailable, trying again once it is ttl old
+		s.loadedAt = now
+		return s.items, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.items, s.loadedAt, s.loaded = items, now, true
+	return items, nil
+}
+
+// invalidate forces the next lookup to reload from storage.
+func (s *snapshot[T]) invalidate() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.loaded = false
+}
+
+// policyCache keeps a snapshot of every policy.
+type policyCache struct {
+	snapshot[storage.Policy]
+	store storage.PolicyStorage
+}
+
+// newPolicyCache returns an empty policy cache reading from ps.
+func newPolicyCache(ps storage.PolicyStorage) *policyCache {
+	return &policyCache{
+		snapshot: snapshot[storage.Policy]{load: ps.ListPolicies, ttl: DefaultPolicyRefresh, now: time.Now},
+		store:    ps,
+	}
+}
+
+// get returns the named policy, asking storage directly if it isn't in the snapshot yet.
+func (c *policyCache) get(ctx context.Context, name string) (*storage.Policy, error) {
+	policies, err := c.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range policies {
+		if policies[i].Name == name {
+			return &policies[i], nil
+		}
+	}
+
+	policy, err := c.store.GetPolicy(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	c.invalidate()
+	return policy, nil
+}
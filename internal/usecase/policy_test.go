@@ -0,0 +1,398 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+type mockPolicyStorage struct {
+	policies map[string]storage.Policy
+	lists    int
+	listErr  error
+}
+
+func (m *mockPolicyStorage) SavePolicy(ctx context.Context, policy storage.Policy) error {
+	m.policies[policy.Name] = policy
+	return nil
+}
+
+func (m *mockPolicyStorage) CreatePolicy(ctx context.Context, policy storage.Policy) error {
+	if _, ok := m.policies[policy.Name]; ok {
+		return storage.ErrPolicyExists
+	}
+	m.policies[policy.Name] = policy
+	return nil
+}
+
+func (m *mockPolicyStorage) UpdatePolicy(ctx context.Context, policy storage.Policy) error {
+	if _, ok := m.policies[policy.Name]; !ok {
+		return storage.ErrPolicyNotFound
+	}
+	m.policies[policy.Name] = policy
+	return nil
+}
+
+func (m *mockPolicyStorage) GetPolicy(ctx context.Context, name string) (*storage.Policy, error) {
+	policy, ok := m.policies[name]
+	if !ok {
+		return nil, storage.ErrPolicyNotFound
+	}
+	return &policy, nil
+}
+
+func (m *mockPolicyStorage) DeletePolicy(ctx context.Context, name string) error {
+	if _, ok := m.policies[name]; !ok {
+		return storage.ErrPolicyNotFound
+	}
+	delete(m.policies, name)
+	return nil
+}
+
+func (m *mockPolicyStorage) ListPolicies(ctx context.Context) ([]storage.Policy, error) {
+	m.lists++
+	if m.listErr != nil {
+		return nil, m.listErr
+	}
+	var policies []storage.Policy
+	for _, name := range slices.Sorted(maps.Keys(m.policies)) {
+		policies = append(policies, m.policies[name])
+	}
+	return policies, nil
+}
+
+func TestRateLimiter_CheckRateLimit_Policies(t *testing.T) {
+	write := storage.Limit{Algorithm: storage.TokenBucket, Limit: 100, Window: time.Minute}
+	users := storage.Limit{Algorithm: storage.FixedWindow, Limit: 10, Window: time.Second}
+	vip := storage.Limit{Algorithm: storage.GCRA, Limit: 1000, Window: time.Second}
+	inline := storage.Limit{Algorithm: storage.FixedWindow, Limit: 5000, Window: time.Second}
+	reports := storage.Limit{Algorithm: storage.Concurrency, Limit: 2, Window: time.Minute}
+
+	policies := map[string]storage.Policy{
+		"api.write": {Name: "api.write", Limit: write},
+		"reports":   {Name: "reports", KeyPattern: "user:vip-7*", Limit: reports},
+		"users":     {Name: "users", KeyPattern: "user:*", Limit: users},
+		"vip":       {Name: "vip", KeyPattern: "user:vip-*", Limit: vip},
+	}
+
+	tests := []struct {
+		name        string
+		inputKey    string
+		inputPolicy string
+		inputLimit  storage.Limit
+		wantErr     error
+		wantLimit   storage.Limit
+	}{
+		{
+			name:        "named policy",
+			inputKey:    "client:1",
+			inputPolicy: "api.write",
+			wantLimit:   write,
+		},
+		{
+			name:        "named policy ignores inline limit",
+			inputKey:    "client:1",
+			inputPolicy: "api.write",
+			inputLimit:  inline,
+			wantLimit:   write,
+		},
+		{
+			name:        "unknown policy",
+			inputKey:    "client:1",
+			inputPolicy: "missing",
+			wantErr:     storage.ErrPolicyNotFound,
+		},
+		{
+			name:       "pattern overrides inline limit",
+			inputKey:   "user:42",
+			inputLimit: inline,
+			wantLimit:  users,
+		},
+		{
+			name:      "longest pattern wins",
+			inputKey:  "user:vip-7",
+			wantLimit: vip,
+		},
+		{
+			name:        "named concurrency policy",
+			inputKey:    "client:1",
+			inputPolicy: "reports",
+			wantErr:     ErrInvalidAlgorithm,
+		},
+		{
+			name:       "inline concurrency limit",
+			inputKey:   "client:1",
+			inputLimit: reports,
+			wantErr:    ErrInvalidAlgorithm,
+		},
+		{
+			name:       "no match uses inline limit",
+			inputKey:   "client:1",
+			inputLimit: inline,
+			wantLimit:  inline,
+		},
+		{
+			name:     "no match and no inline limit",
+			inputKey: "client:1",
+			wantErr:  ErrInvalidLimit,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockStorage{checkAndUpdateResult: &storage.Result{Allowed: true}}
+			service := NewRateLimiterService(mock, WithPolicies(&mockPolicyStorage{policies: policies}))
+
+			_, err := service.CheckRateLimit(context.Background(), CheckRequest{
+				Key:    tt.inputKey,
+				Policy: tt.inputPolicy,
+				Limit:  tt.inputLimit,
+				Cost:   1,
+			})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckRateLimit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && mock.gotLimit != tt.wantLimit {
+				t.Errorf("CheckRateLimit() limit = %+v, want %+v", mock.gotLimit, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_GetStatus_PolicyWithoutLimit(t *testing.T) {
+	users := storage.Limit{Algorithm: storage.SlidingWindowLog, Limit: 10, Window: time.Second}
+	mock := &mockStorage{getStatusResult: &storage.Result{Allowed: true}}
+	ps := &mockPolicyStorage{policies: map[string]storage.Policy{
+		"users": {Name: "users", KeyPattern: "user:*", Limit: users},
+	}}
+	service := NewRateLimiterService(mock, WithPolicies(ps))
+
+	if _, err := service.GetStatus(context.Background(), StatusRequest{Key: "user:1"}); err != nil {
+		t.Fatalf("GetStatus() error = %v", err)
+	}
+	if mock.gotLimit != users {
+		t.Errorf("GetStatus() limit = %+v, want %+v", mock.gotLimit, users)
+	}
+}
+
+func TestRateLimiter_PolicyWithoutPolicyStorage(t *testing.T) {
+	service := NewRateLimiterService(&mockStorage{})
+
+	_, err := service.CheckRateLimit(context.Background(), CheckRequest{Key: "client:1", Policy: "api.write", Cost: 1})
+	if !errors.Is(err, ErrPoliciesUnsupported) {
+		t.Errorf("CheckRateLimit() error = %v, want %v", err, ErrPoliciesUnsupported)
+	}
+	if err := service.SavePolicy(context.Background(), storage.Policy{Name: "api.write"}); !errors.Is(err, ErrPoliciesUnsupported) {
+		t.Errorf("SavePolicy() error = %v, want %v", err, ErrPoliciesUnsupported)
+	}
+}
+
+func TestRateLimiter_SavePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  storage.Policy
+		wantErr error
+	}{
+		{
+			name:   "valid",
+			policy: storage.Policy{Name: "api.write", KeyPattern: "write:*", Limit: storage.Limit{Limit: 100, Window: time.Minute}},
+		},
+		{
+			name:    "empty name",
+			policy:  storage.Policy{Name: " ", Limit: storage.Limit{Limit: 100, Window: time.Minute}},
+			wantErr: ErrInvalidPolicyName,
+		},
+		{
+			name:    "bad pattern",
+			policy:  storage.Policy{Name: "api.write", KeyPattern: "write:[", Limit: storage.Limit{Limit: 100, Window: time.Minute}},
+			wantErr: ErrInvalidKeyPattern,
+		},
+		{
+			name:    "missing window",
+			policy:  storage.Policy{Name: "api.write", Limit: storage.Limit{Limit: 100}},
+			wantErr: ErrInvalidWindow,
+		},
+		{
+			name:   "failure mode",
+			policy: storage.Policy{Name: "api.write", Limit: storage.Limit{Limit: 100, Window: time.Minute}, FailureMode: storage.FailOpen},
+		},
+		{
+			name:    "unknown failure mode",
+			policy:  storage.Policy{Name: "api.write", Limit: storage.Limit{Limit: 100, Window: time.Minute}, FailureMode: "sometimes"},
+			wantErr: ErrInvalidFailureMode,
+		},
+		{
+			name: "valid tiers",
+			policy: storage.Policy{Name: "api.write", Limit: storage.Limit{Limit: 20, Window: time.Second}, Tiers: []storage.Limit{
+				{Limit: 1000, Window: time.Hour},
+				{Limit: 10000, Period: storage.Daily},
+			}},
+		},
+		{
+			name:    "too many tiers",
+			policy:  storage.Policy{Name: "api.write", Limit: storage.Limit{Limit: 20, Window: time.Second}, Tiers: make([]storage.Limit, MaxTiers+1)},
+			wantErr: ErrInvalidTiers,
+		},
+		{
+			name: "tier repeats a window",
+			policy: storage.Policy{Name: "api.write", Limit: storage.Limit{Limit: 20, Window: time.Second}, Tiers: []storage.Limit{
+				{Limit: 1000, Window: time.Hour},
+				{Algorithm: storage.GCRA, Limit: 2000, Window: time.Hour},
+			}},
+			wantErr: ErrInvalidTiers,
+		},
+		{
+			name: "tier repeats the limit's window",
+			policy: storage.Policy{Name: "api.write", Limit: storage.Limit{Limit: 20, Window: time.Second}, Tiers: []storage.Limit{
+				{Limit: 30, Window: time.Second},
+			}},
+			wantErr: ErrInvalidTiers,
+		},
+		{
+			name: "invalid tier",
+			policy: storage.Policy{Name: "api.write", Limit: storage.Limit{Limit: 20, Window: time.Second}, Tiers: []storage.Limit{
+				{Limit: 0, Window: time.Hour},
+			}},
+			wantErr: ErrInvalidLimit,
+		},
+		{
+			name: "tiers on a concurrency policy",
+			policy: storage.Policy{Name: "reports", Limit: storage.Limit{Algorithm: storage.Concurrency, Limit: 2, Window: time.Minute}, Tiers: []storage.Limit{
+				{Limit: 10, Window: time.Hour},
+			}},
+			wantErr: ErrInvalidTiers,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ps := &mockPolicyStorage{policies: map[string]storage.Policy{}}
+			service := NewRateLimiterService(&mockStorage{}, WithPolicies(ps))
+
+			err := service.SavePolicy(context.Background(), tt.policy)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("SavePolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			// Saved policies get the default algorithm filled in
+			if err == nil && ps.policies[tt.policy.Name].Limit.Algorithm != storage.FixedWindow {
+				t.Errorf("saved algorithm = %q, want %q", ps.policies[tt.policy.Name].Limit.Algorithm, storage.FixedWindow)
+			}
+			for i, tier := range ps.policies[tt.policy.Name].Tiers {
+				if tier.Algorithm != storage.FixedWindow {
+					t.Errorf("saved tiers[%d] algorithm = %q, want %q", i, tier.Algorithm, storage.FixedWindow)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiter_PolicyCache(t *testing.T) {
+	ps := &mockPolicyStorage{policies: map[string]storage.Policy{}}
+	mock := &mockStorage{checkAndUpdateResult: &storage.Result{Allowed: true}}
+	service := NewRateLimiterService(mock, WithPolicies(ps), WithPolicyRefresh(time.Hour))
+	ctx := context.Background()
+	req := CheckRequest{Key: "user:1", Limit: storage.Limit{Limit: 5, Window: time.Second}, Cost: 1}
+
+	// Repeated checks reuse the snapshot
+	for i := 0; i < 3; i++ {
+		if _, err := service.CheckRateLimit(ctx, req); err != nil {
+			t.Fatalf("CheckRateLimit() error = %v", err)
+		}
+	}
+	if ps.lists != 1 {
+		t.Errorf("ListPolicies() called %d times, want 1", ps.lists)
+	}
+
+	// Saving through the service takes effect immediately
+	users := storage.Limit{Algorithm: storage.FixedWindow, Limit: 10, Window: time.Second}
+	if err := service.SavePolicy(ctx, storage.Policy{Name: "users", KeyPattern: "user:*", Limit: users}); err != nil {
+		t.Fatalf("SavePolicy() error = %v", err)
+	}
+	if _, err := service.CheckRateLimit(ctx, req); err != nil {
+		t.Fatalf("CheckRateLimit() error = %v", err)
+	}
+	if mock.gotLimit != users {
+		t.Errorf("limit after save = %+v, want %+v", mock.gotLimit, users)
+	}
+}
+
+func TestRateLimiter_PolicyFailureMode(t *testing.T) {
+	users := storage.Policy{Name: "users", KeyPattern: "user:*", Limit: storage.Limit{Limit: 10, Window: time.Second}, FailureMode: storage.FailLocal}
+	ps := &mockPolicyStorage{policies: map[string]storage.Policy{"users": users}}
+	mock := &mockStorage{checkAndUpdateResult: &storage.Result{Allowed: true}}
+	service := NewRateLimiterService(mock, WithPolicies(ps), WithPolicyRefresh(time.Nanosecond))
+	ctx := context.Background()
+	req := CheckRequest{Key: "user:1", Cost: 1}
+
+	// The policy's failure mode is passed to storage with its limit
+	if _, err := service.CheckRateLimit(ctx, req); err != nil {
+		t.Fatalf("CheckRateLimit() error = %v", err)
+	}
+	if mock.gotLimit.FailureMode != storage.FailLocal {
+		t.Errorf("failure mode = %q, want %q", mock.gotLimit.FailureMode, storage.FailLocal)
+	}
+
+	// Policies that can't be reloaded are served from the last snapshot
+	ps.listErr = storage.ErrStorageUnavailable
+	if _, err := service.CheckRateLimit(ctx, req); err != nil {
+		t.Fatalf("CheckRateLimit() while policies can't be listed error = %v", err)
+	}
+	if mock.gotLimit.Limit != 10 {
+		t.Errorf("limit while policies can't be listed = %d, want 10", mock.gotLimit.Limit)
+	}
+}
+
+func TestRateLimiter_PolicyManagement(t *testing.T) {
+	ps := &mockPolicyStorage{policies: map[string]storage.Policy{}}
+	mock := &mockStorage{checkAndUpdateResult: &storage.Result{Allowed: true}}
+	service := NewRateLimiterService(mock, WithPolicies(ps), WithPolicyRefresh(time.Hour))
+	ctx := context.Background()
+	req := CheckRequest{Key: "client:1", Policy: "api.write", Cost: 1}
+
+	created, err := service.CreatePolicy(ctx, storage.Policy{Name: "api.write", Limit: storage.Limit{Limit: 100, Window: time.Minute}})
+	if err != nil {
+		t.Fatalf("CreatePolicy() error = %v", err)
+	}
+	if created.Limit.Algorithm != storage.FixedWindow {
+		t.Errorf("created algorithm = %q, want %q", created.Limit.Algorithm, storage.FixedWindow)
+	}
+	if _, err := service.CreatePolicy(ctx, *created); !errors.Is(err, storage.ErrPolicyExists) {
+		t.Errorf("CreatePolicy() twice error = %v, want %v", err, storage.ErrPolicyExists)
+	}
+
+	// Updates apply to the next check on this server
+	updated := storage.Limit{Algorithm: storage.GCRA, Limit: 10, Window: time.Second}
+	if _, err := service.UpdatePolicy(ctx, storage.Policy{Name: "api.write", Limit: updated}); err != nil {
+		t.Fatalf("UpdatePolicy() error = %v", err)
+	}
+	if _, err := service.CheckRateLimit(ctx, req); err != nil {
+		t.Fatalf("CheckRateLimit() error = %v", err)
+	}
+	if mock.gotLimit != updated {
+		t.Errorf("limit after update = %+v, want %+v", mock.gotLimit, updated)
+	}
+	if _, err := service.UpdatePolicy(ctx, storage.Policy{Name: "missing", Limit: updated}); !errors.Is(err, storage.ErrPolicyNotFound) {
+		t.Errorf("UpdatePolicy() missing error = %v, want %v", err, storage.ErrPolicyNotFound)
+	}
+	if _, err := service.UpdatePolicy(ctx, storage.Policy{Name: "api.write"}); !errors.Is(err, ErrInvalidLimit) {
+		t.Errorf("UpdatePolicy() invalid error = %v, want %v", err, ErrInvalidLimit)
+	}
+
+	// Deleted policies can no longer be referenced
+	if err := service.DeletePolicy(ctx, "api.write"); err != nil {
+		t.Fatalf("DeletePolicy() error = %v", err)
+	}
+	if _, err := service.CheckRateLimit(ctx, req); !errors.Is(err, storage.ErrPolicyNotFound) {
+		t.Errorf("CheckRateLimit() after delete error = %v, want %v", err, storage.ErrPolicyNotFound)
+	}
+	if _, err := service.GetPolicy(ctx, ""); !errors.Is(err, ErrInvalidPolicyName) {
+		t.Errorf("GetPolicy() empty name error = %v, want %v", err, ErrInvalidPolicyName)
+	}
+}
@@ -2,6 +2,7 @@ package usecase
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"time"
 
@@ -9,53 +10,209 @@ import (
 )
 
 type RateLimiterService struct {
-	storage storage.RateLimitStorage
+	storage   storage.RateLimitStorage
+	policies  *policyCache
+	overrides *overrideCache
+	leases    storage.LeaseStorage
+	now       func() time.Time
 }
 
-func NewRateLimiterService(storage storage.RateLimitStorage) *RateLimiterService {
-	return &RateLimiterService{
+// Option configures a RateLimiterService.
+type Option func(*RateLimiterService)
+
+// WithPolicies enables server-side policies kept in ps.
+func WithPolicies(ps storage.PolicyStorage) Option {
+	return func(rls *RateLimiterService) {
+		if ps != nil {
+			rls.policies = newPolicyCache(ps)
+		}
+	}
+}
+
+// WithOverrides enables the allow, deny and scale overrides kept in overrides.
+func WithOverrides(overrides storage.OverrideStorage) Option {
+	return func(rls *RateLimiterService) {
+		if overrides != nil {
+			rls.overrides = newOverrideCache(overrides)
+		}
+	}
+}
+
+// WithPolicyRefresh sets how long policies and overrides are cached before being reloaded from storage.
+// It must come after WithPolicies and WithOverrides.
+func WithPolicyRefresh(d time.Duration) Option {
+	return func(rls *RateLimiterService) {
+		if d < 0 {
+			return
+		}
+		if rls.policies != nil {
+			rls.policies.ttl = d
+		}
+		if rls.overrides != nil {
+			rls.overrides.ttl = d
+		}
+	}
+}
+
+// WithLeases enables concurrency leases kept in ls.
+func WithLeases(ls storage.LeaseStorage) Option {
+	return func(rls *RateLimiterService) {
+		rls.leases = ls
+	}
+}
+
+func NewRateLimiterService(storage storage.RateLimitStorage, opts ...Option) *RateLimiterService {
+	rls := &RateLimiterService{
 		storage: storage,
+		now:     time.Now,
+	}
+	for _, opt := range opts {
+		opt(rls)
 	}
+	return rls
 }
 
-// CheckRateLimit validates input and checks if a request is allowed and updates the counter
-func (rls *RateLimiterService) CheckRateLimit(ctx context.Context, key string, limit int64, window time.Duration, cost int64) (*storage.Result, error) {
+// CheckRequest is a request to consume Cost from the limit on Key.
+type CheckRequest struct {
+	Key string
+	// Policy names the server-side policy to enforce. Empty means match Key against policy patterns.
+	Policy string
+	// Limit is used only when no policy applies.
+	Limit storage.Limit
+	Cost  int64
+	// Parents are the key's ancestors, nearest first. Cost is consumed from every level or from none.
+	Parents []Parent
+	// Shadow counts the request without ever denying it, as if every policy it is checked against were a shadow policy.
+	Shadow bool
+}
+
+// StatusRequest is a request to read the limit on Key without consuming it.
+type StatusRequest struct {
+	Key string
+	// Policy names the server-side policy to read. Empty means match Key against policy patterns.
+	Policy string
+	// Limit is used only when no policy applies.
+	Limit storage.Limit
+}
+
+// RefundRequest is a request to give back up to Amount of cost consumed from the limit on Key.
+type RefundRequest struct {
+	Key string
+	// Policy names the server-side policy the cost was consumed from. Empty means match Key against policy patterns.
+	Policy string
+	// Limit is used only when no policy applies.
+	Limit  storage.Limit
+	Amount int64
+}
+
+// Result is a storage result together with the policy that produced it.
+type Result struct {
+	*storage.Result
+	// Policy is the policy that was enforced. Its Name is empty when the request's own limit was used.
+	Policy storage.Policy
+	// LimitedBy is the key of the level a check with parents reports on. Empty for other results.
+	LimitedBy string
+	// Tier is the index in Policy.Limits() of the tier the result reports on: the most restrictive one.
+	Tier int
+	// ShadowDenied reports that a shadow check would have denied the request. The request was allowed anyway.
+	ShadowDenied bool
+	// Override is the action of the override that applied to the key, if any.
+	Override storage.OverrideAction
+}
+
+// CheckRateLimit validates input and checks if a request is allowed and updates the counter.
+// The limit comes from the request's policy, a policy matching the key, or the request itself, in that order.
+// With parents, the key and every parent are checked together and the most restrictive level is reported.
+// A policy with tiers is checked the same way, reporting the most restrictive tier.
+// Shadow requests and policies are counted as usual but always allowed, recording what they would have decided.
+// A shadow policy matching the key is checked alongside the enforced policy matching it, if any.
+// Keys with an allow or deny override are decided without storage, and a scale override multiplies the limit.
+func (rls *RateLimiterService) CheckRateLimit(ctx context.Context, req CheckRequest) (*Result, error) {
 
 	// Validate input
-	if len(strings.TrimSpace(key)) == 0 {
+	if len(strings.TrimSpace(req.Key)) == 0 {
 		return nil, ErrInvalidKey
 	}
-	if limit <= 0 {
-		return nil, ErrInvalidLimit
+	if req.Cost <= 0 {
+		return nil, ErrInvalidCost
+	}
+	if len(req.Parents) > 0 {
+		return rls.checkHierarchy(ctx, req)
 	}
-	if window <= 0 {
-		return nil, ErrInvalidWindow
+	checks, resolved, err := rls.resolveCheck(ctx, req, 0)
+	if err != nil {
+		return nil, err
 	}
-	if cost <= 0 {
-		return nil, ErrInvalidCost
+
+	// Tiers are checked together so a request denied by one tier consumes none of them
+	if len(checks) > 1 {
+		results, err := rls.checkWithOverrides(ctx, checks, resolved, true)
+		if err != nil {
+			return nil, err
+		}
+		return newBatchResult(results, resolved, 1).Results[0], nil
+	}
+
+	// An allow or deny override decides without storage
+	if result := rls.overrideResult(checks[0], resolved[0].override); result != nil {
+		return newResult(result, resolved[0]), nil
 	}
 
 	// Call storage layer to check and update rate limit
-	return rls.storage.CheckAndUpdate(ctx, key, limit, window, cost)
+	result, err := rls.storage.CheckAndUpdate(ctx, checks[0].Key, checks[0].Limit, req.Cost)
+	if err != nil {
+		return nil, err
+	}
+	return newResult(result, resolved[0]), nil
 }
 
 // GetStatus validates input and checks current status without modifying the counter
-func (rls *RateLimiterService) GetStatus(ctx context.Context, key string, limit int64) (*storage.Result, error) {
+func (rls *RateLimiterService) GetStatus(ctx context.Context, req StatusRequest) (*Result, error) {
 
 	// Validate input
-	if len(strings.TrimSpace(key)) == 0 {
+	if len(strings.TrimSpace(req.Key)) == 0 {
+		return nil, ErrInvalidKey
+	}
+	// A fixed window's length is kept in the key's TTL, so only the other algorithms need it here
+	requireWindow := req.Limit.Algorithm != "" && req.Limit.Algorithm != storage.FixedWindow
+	policy, action, err := rls.resolvePolicy(ctx, req.Key, req.Policy, req.Limit, requireWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	// Call storage layer to get the status of every tier
+	return rls.eachTier(req.Key, policy, action, func(key string, limit storage.Limit) (*storage.Result, error) {
+		return rls.storage.GetStatus(ctx, key, limit)
+	})
+}
+
+// Refund validates input and returns up to the requested amount to the key within its current window.
+// Remaining never goes above the limit, and cost from a window that has already ended is not refunded.
+func (rls *RateLimiterService) Refund(ctx context.Context, req RefundRequest) (*Result, error) {
+
+	// Validate input
+	if len(strings.TrimSpace(req.Key)) == 0 {
 		return nil, ErrInvalidKey
 	}
-	if limit <= 0 {
-		return nil, ErrInvalidLimit
+	if req.Amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	// Like GetStatus, a fixed window doesn't need its length to find the current window
+	requireWindow := req.Limit.Algorithm != "" && req.Limit.Algorithm != storage.FixedWindow
+	policy, action, err := rls.resolvePolicy(ctx, req.Key, req.Policy, req.Limit, requireWindow)
+	if err != nil {
+		return nil, err
 	}
 
-	// TODO: limit parameter might be removed in the future
-	// Call storage layer to get status
-	return rls.storage.GetStatus(ctx, key, limit)
+	// Call storage layer to refund the cost to every tier
+	return rls.eachTier(req.Key, policy, action, func(key string, limit storage.Limit) (*storage.Result, error) {
+		return rls.storage.Refund(ctx, key, limit, req.Amount)
+	})
 }
 
-// ResetLimit validates input and clears the rate limiter for the given key
+// ResetLimit validates input and clears the rate limiter for the given key.
+// When a policy matches the key, every tier is cleared, and calendar-aligned tiers only for their current period.
+// A shadow policy checked alongside it is cleared too.
 func (rls *RateLimiterService) ResetLimit(ctx context.Context, key string) error {
 
 	// Validate input
@@ -63,6 +220,71 @@ func (rls *RateLimiterService) ResetLimit(ctx context.Context, key string) error
 		return ErrInvalidKey
 	}
 
-	// Call storage layer to reset the limit
-	return rls.storage.Reset(ctx, key)
+	// Tiers and calendar periods are counted under their own keys
+	policy, _, err := rls.findPolicy(ctx, key, "", false)
+	if err != nil {
+		return err
+	}
+	checks, _, err := rls.tierChecks(key, policy, 0, 0)
+	if err != nil {
+		return err
+	}
+	if policy.Name != "" && !policy.Shadow {
+		shadow, _, err := rls.shadowChecks(ctx, key, 0, 0)
+		if err != nil {
+			return err
+		}
+		checks = append(checks, shadow...)
+	}
+
+	// Call storage layer to reset every tier. A tier whose window has passed has nothing to reset,
+	// which only matters if no tier had anything.
+	found := false
+	for _, check := range checks {
+		err := rls.storage.Reset(ctx, check.Key)
+		if errors.Is(err, storage.ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+	}
+	if !found {
+		return storage.ErrKeyNotFound
+	}
+	return nil
+}
+
+// validateLimit checks the limit for the selected algorithm and fills in the default algorithm.
+func validateLimit(limit storage.Limit, requireWindow bool) (storage.Limit, error) {
+	if limit.Algorithm == "" {
+		limit.Algorithm = storage.FixedWindow
+	}
+	if !limit.Algorithm.Valid() {
+		return limit, ErrInvalidAlgorithm
+	}
+	if limit.Limit <= 0 {
+		return limit, ErrInvalidLimit
+	}
+	// A calendar period sets its own window
+	if limit.Period != "" {
+		if !limit.Period.Valid() {
+			return limit, ErrInvalidPeriod
+		}
+		if limit.Algorithm != storage.FixedWindow {
+			return limit, ErrInvalidAlgorithm
+		}
+		if _, err := loadLocation(limit.Timezone); err != nil {
+			return limit, ErrInvalidTimezone
+		}
+		requireWindow = false
+	}
+	if limit.Window < 0 || (requireWindow && limit.Window == 0) {
+		return limit, ErrInvalidWindow
+	}
+	if limit.RefillRate < 0 {
+		return limit, ErrInvalidRefillRate
+	}
+	return limit, nil
 }
@@ -15,17 +15,52 @@ type mockStorage struct {
 	getStatusResult      *storage.Result
 	getStatusError       error
 	resetError           error
+	checkAllResults      []*storage.Result
+	refundResult         *storage.Result
+	refundError          error
+
+	// gotKey is the key passed to the last CheckAndUpdate, GetStatus, Refund or Reset call
+	gotKey string
+	// gotLimit is the limit passed to the last CheckAndUpdate, GetStatus or Refund call
+	gotLimit storage.Limit
+	// gotAmount is the amount passed to the last Refund call
+	gotAmount int64
+	// gotAllChecks are the checks passed to the last CheckAndUpdateAll call
+	gotAllChecks []storage.Check
+	// gotResets are the keys passed to every Reset call
+	gotResets []string
 }
 
-func (m *mockStorage) CheckAndUpdate(ctx context.Context, key string, limit int64, window time.Duration, cost int64) (*storage.Result, error) {
+func (m *mockStorage) CheckAndUpdate(ctx context.Context, key string, limit storage.Limit, cost int64) (*storage.Result, error) {
+	m.gotKey = key
+	m.gotLimit = limit
 	return m.checkAndUpdateResult, m.checkAndUpdateError
 }
 
-func (m *mockStorage) GetStatus(ctx context.Context, key string, limit int64) (*storage.Result, error) {
+func (m *mockStorage) CheckAndUpdateAll(ctx context.Context, checks []storage.Check) ([]*storage.Result, error) {
+	m.gotAllChecks = checks
+	if m.checkAndUpdateError != nil {
+		return nil, m.checkAndUpdateError
+	}
+	return m.checkAllResults[:len(checks)], nil
+}
+
+func (m *mockStorage) GetStatus(ctx context.Context, key string, limit storage.Limit) (*storage.Result, error) {
+	m.gotKey = key
+	m.gotLimit = limit
 	return m.getStatusResult, m.getStatusError
 }
 
+func (m *mockStorage) Refund(ctx context.Context, key string, limit storage.Limit, amount int64) (*storage.Result, error) {
+	m.gotKey = key
+	m.gotLimit = limit
+	m.gotAmount = amount
+	return m.refundResult, m.refundError
+}
+
 func (m *mockStorage) Reset(ctx context.Context, key string) error {
+	m.gotKey = key
+	m.gotResets = append(m.gotResets, key)
 	return m.resetError
 }
 func (m *mockStorage) Close() error {
@@ -35,15 +70,17 @@ func (m *mockStorage) Close() error {
 func TestRateLimiter_CheckRateLimit(t *testing.T) {
 
 	tests := []struct {
-		name        string
-		inputKey    string
-		inputLimit  int64
-		inputWindow time.Duration
-		inputCost   int64
-		mockResult  *storage.Result
-		mockError   error
-		wantErr     error
-		wantAllowed bool
+		name           string
+		inputKey       string
+		inputAlgorithm storage.Algorithm
+		inputLimit     int64
+		inputWindow    time.Duration
+		inputRefill    int64
+		inputCost      int64
+		mockResult     *storage.Result
+		mockError      error
+		wantErr        error
+		wantAllowed    bool
 	}{
 		{
 			name:        "valid request",
@@ -92,6 +129,49 @@ func TestRateLimiter_CheckRateLimit(t *testing.T) {
 			inputCost:   -1,
 			wantErr:     ErrInvalidCost,
 		},
+		{
+			name:           "valid token bucket",
+			inputKey:       "ratelimit:0001",
+			inputAlgorithm: storage.TokenBucket,
+			inputLimit:     10,
+			inputWindow:    time.Second,
+			inputRefill:    2,
+			inputCost:      1,
+			mockResult: &storage.Result{
+				Allowed:   true,
+				Remaining: 9,
+				Limit:     10,
+			},
+			wantAllowed: true,
+		},
+		{
+			name:           "unknown algorithm",
+			inputKey:       "ratelimit:0001",
+			inputAlgorithm: "leaky_bucket",
+			inputLimit:     10,
+			inputWindow:    time.Second,
+			inputCost:      1,
+			wantErr:        ErrInvalidAlgorithm,
+		},
+		{
+			name:           "negative refill rate",
+			inputKey:       "ratelimit:0001",
+			inputAlgorithm: storage.TokenBucket,
+			inputLimit:     10,
+			inputWindow:    time.Second,
+			inputRefill:    -1,
+			inputCost:      1,
+			wantErr:        ErrInvalidRefillRate,
+		},
+		{
+			name:        "unsupported by storage",
+			inputKey:    "ratelimit:0001",
+			inputLimit:  10,
+			inputWindow: time.Second,
+			inputCost:   1,
+			mockError:   storage.ErrUnsupportedAlgorithm,
+			wantErr:     storage.ErrUnsupportedAlgorithm,
+		},
 	}
 
 	for _, tt := range tests {
@@ -103,7 +183,13 @@ func TestRateLimiter_CheckRateLimit(t *testing.T) {
 
 			service := NewRateLimiterService(mock)
 
-			result, err := service.CheckRateLimit(context.Background(), tt.inputKey, tt.inputLimit, tt.inputWindow, tt.inputCost)
+			limit := storage.Limit{
+				Algorithm:  tt.inputAlgorithm,
+				Limit:      tt.inputLimit,
+				Window:     tt.inputWindow,
+				RefillRate: tt.inputRefill,
+			}
+			result, err := service.CheckRateLimit(context.Background(), CheckRequest{Key: tt.inputKey, Limit: limit, Cost: tt.inputCost})
 
 			if !errors.Is(err, tt.wantErr) {
 				t.Errorf("CheckRateLimit() error = %v, wantErr %v", err, tt.wantErr)
@@ -119,13 +205,15 @@ func TestRateLimiter_CheckRateLimit(t *testing.T) {
 func TestRateLimiter_GetStatus(t *testing.T) {
 
 	tests := []struct {
-		name        string
-		inputKey    string
-		inputLimit  int64
-		mockResult  *storage.Result
-		mockError   error
-		wantErr     error
-		wantAllowed bool
+		name           string
+		inputKey       string
+		inputAlgorithm storage.Algorithm
+		inputLimit     int64
+		inputWindow    time.Duration
+		mockResult     *storage.Result
+		mockError      error
+		wantErr        error
+		wantAllowed    bool
 	}{
 		{
 			name:       "valid",
@@ -152,6 +240,26 @@ func TestRateLimiter_GetStatus(t *testing.T) {
 			inputLimit: -1,
 			wantErr:    ErrInvalidLimit,
 		},
+		{
+			name:           "sliding window requires window",
+			inputKey:       "ratelimit:0001",
+			inputAlgorithm: storage.SlidingWindowLog,
+			inputLimit:     10,
+			wantErr:        ErrInvalidWindow,
+		},
+		{
+			name:           "valid gcra",
+			inputKey:       "ratelimit:0001",
+			inputAlgorithm: storage.GCRA,
+			inputLimit:     10,
+			inputWindow:    time.Minute,
+			mockResult: &storage.Result{
+				Allowed:   true,
+				Remaining: 10,
+				Limit:     10,
+			},
+			wantAllowed: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -163,7 +271,12 @@ func TestRateLimiter_GetStatus(t *testing.T) {
 
 			service := NewRateLimiterService(mock)
 
-			result, err := service.GetStatus(context.Background(), tt.inputKey, tt.inputLimit)
+			limit := storage.Limit{
+				Algorithm: tt.inputAlgorithm,
+				Limit:     tt.inputLimit,
+				Window:    tt.inputWindow,
+			}
+			result, err := service.GetStatus(context.Background(), StatusRequest{Key: tt.inputKey, Limit: limit})
 
 			if !errors.Is(err, tt.wantErr) {
 				t.Errorf("GetStatus() error = %v, wantErr %v", err, tt.wantErr)
@@ -176,6 +289,105 @@ func TestRateLimiter_GetStatus(t *testing.T) {
 	}
 }
 
+func TestRateLimiter_Refund(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		inputKey       string
+		inputAlgorithm storage.Algorithm
+		inputLimit     int64
+		inputWindow    time.Duration
+		inputAmount    int64
+		mockResult     *storage.Result
+		mockError      error
+		wantErr        error
+		wantRemaining  int64
+	}{
+		{
+			name:        "valid fixed window without window",
+			inputKey:    "ratelimit:0001",
+			inputLimit:  10,
+			inputAmount: 2,
+			mockResult: &storage.Result{
+				Allowed:   true,
+				Remaining: 7,
+				Limit:     10,
+			},
+			wantRemaining: 7,
+		},
+		{
+			name:        "empty key",
+			inputKey:    "",
+			inputLimit:  10,
+			inputAmount: 1,
+			wantErr:     ErrInvalidKey,
+		},
+		{
+			name:        "zero amount",
+			inputKey:    "ratelimit:0001",
+			inputLimit:  10,
+			inputAmount: 0,
+			wantErr:     ErrInvalidAmount,
+		},
+		{
+			name:        "negative amount",
+			inputKey:    "ratelimit:0001",
+			inputLimit:  10,
+			inputAmount: -3,
+			wantErr:     ErrInvalidAmount,
+		},
+		{
+			name:           "token bucket requires window",
+			inputKey:       "ratelimit:0001",
+			inputAlgorithm: storage.TokenBucket,
+			inputLimit:     10,
+			inputAmount:    1,
+			wantErr:        ErrInvalidWindow,
+		},
+		{
+			name:           "storage error",
+			inputKey:       "ratelimit:0001",
+			inputAlgorithm: storage.SlidingWindowLog,
+			inputLimit:     storage.MaxSlidingLogLimit + 1,
+			inputWindow:    time.Minute,
+			inputAmount:    1,
+			mockError:      storage.ErrLimitTooHigh,
+			wantErr:        storage.ErrLimitTooHigh,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockStorage{
+				refundResult: tt.mockResult,
+				refundError:  tt.mockError,
+			}
+
+			service := NewRateLimiterService(mock)
+
+			limit := storage.Limit{
+				Algorithm: tt.inputAlgorithm,
+				Limit:     tt.inputLimit,
+				Window:    tt.inputWindow,
+			}
+			result, err := service.Refund(context.Background(), RefundRequest{Key: tt.inputKey, Limit: limit, Amount: tt.inputAmount})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Refund() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err == nil {
+				if result.Remaining != tt.wantRemaining {
+					t.Errorf("Refund() remaining = %d, want %d", result.Remaining, tt.wantRemaining)
+				}
+				if mock.gotAmount != tt.inputAmount {
+					t.Errorf("storage got amount %d, want %d", mock.gotAmount, tt.inputAmount)
+				}
+			}
+		})
+	}
+}
+
 func TestRateLimiter_ResetLimit(t *testing.T) {
 
 	tests := []struct {
@@ -0,0 +1,123 @@
+package usecase
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"slices"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// shadowDecisions counts the decisions of shadow checks by policy and outcome, e.g. "api.write:denied".
+// It is published through expvar as ratelimiter_shadow_decisions.
+var shadowDecisions = expvar.NewMap("ratelimiter_shadow_decisions")
+
+// inlinePolicyName stands in for the name of a limit sent with the request in shadow counters and logs.
+const inlinePolicyName = "inline"
+
+// shadowSuffix is the sub key a shadow policy checked alongside an enforced one is counted under,
+// e.g. "{user:1}:shadow", so the two policies don't share a counter.
+const shadowSuffix = "shadow"
+
+// shadowChecks builds the storage checks of the shadow policy matching key, if any, to run alongside the enforced
+// policy that matched it. The key's scale override applies to it as it does to the enforced policy.
+func (rls *RateLimiterService) shadowChecks(ctx context.Context, key string, cost int64, request int) ([]storage.Check, []resolution, error) {
+	policy, found, err := rls.findShadowPolicy(ctx, key)
+	if err != nil || !found {
+		return nil, nil, err
+	}
+	policy, _, err = rls.applyOverride(ctx, key, policy)
+	if err != nil {
+		return nil, nil, err
+	}
+	checks, resolved, err := rls.tierChecks(storage.SubKey(key, shadowSuffix), policy, cost, request)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Decisions are still logged under the request's key
+	for i := range resolved {
+		resolved[i].key = key
+	}
+	return checks, resolved, nil
+}
+
+// newResult pairs a storage result with the policy it was checked against, applying its period end and shadow mode.
+func newResult(result *storage.Result, r resolution) *Result {
+	return applyShadow(&Result{Result: atPeriodEnd(result, r.periodEnd), Policy: r.policy, Tier: r.tier, Override: r.override}, r.key, r.shadow)
+}
+
+// applyShadow records the decision of a shadow check and lets the request through if it would have been denied.
+// Results of enforced checks are returned unchanged.
+func applyShadow(result *Result, key string, shadow bool) *Result {
+	if !shadow {
+		return result
+	}
+
+	name := result.Policy.Name
+	if name == "" {
+		name = inlinePolicyName
+	}
+	if result.Allowed {
+		shadowDecisions.Add(name+":allowed", 1)
+		return result
+	}
+	shadowDecisions.Add(name+":denied", 1)
+	log.Printf("shadow: %s would be denied by policy %s (limit %d, remaining %d, retry after %v)",
+		key, name, result.Limit, result.Remaining, result.RetryAfter)
+
+	// Copy the storage result so the caller sees an allowed request without changing what storage returned
+	allowed := *result.Result
+	allowed.Allowed, allowed.RetryAfter = true, 0
+	result.Result, result.ShadowDenied = &allowed, true
+	return result
+}
+
+// binding returns the index of the most restrictive result and marks it ShadowDenied if a shadow check among the
+// results would have denied the request, and Degraded if storage couldn't decide any of them.
+func binding(results []*Result) int {
+	best := mostRestrictive(results)
+	results[best].ShadowDenied = slices.ContainsFunc(results, func(r *Result) bool { return r.ShadowDenied })
+	if !results[best].Degraded && slices.ContainsFunc(results, func(r *Result) bool { return r.Degraded }) {
+		// Copy the storage result so what storage returned isn't changed
+		degraded := *results[best].Result
+		degraded.Degraded = true
+		results[best].Result = &degraded
+	}
+	return best
+}
+
+// checkTogether updates every enforced check together, so one denial stops them all, and checks shadow ones on their
+// own, so a shadow limit never stops an enforced one from being consumed.
+func (rls *RateLimiterService) checkTogether(ctx context.Context, checks []storage.Check, resolved []resolution) ([]*storage.Result, error) {
+	var enforced, shadow []int
+	for i, r := range resolved {
+		if r.shadow {
+			shadow = append(shadow, i)
+		} else {
+			enforced = append(enforced, i)
+		}
+	}
+	if len(shadow) == 0 {
+		return rls.storage.CheckAndUpdateAll(ctx, checks)
+	}
+
+	results := make([]*storage.Result, len(checks))
+	if len(enforced) > 0 {
+		enforcedResults, err := rls.storage.CheckAndUpdateAll(ctx, storage.Pick(checks, enforced))
+		if err != nil {
+			return nil, err
+		}
+		for i, result := range enforcedResults {
+			results[enforced[i]] = result
+		}
+	}
+	shadowResults, err := rls.checkBatch(ctx, storage.Pick(checks, shadow))
+	if err != nil {
+		return nil, err
+	}
+	for i, result := range shadowResults {
+		results[shadow[i]] = result
+	}
+	return results, nil
+}
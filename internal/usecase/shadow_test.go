@@ -0,0 +1,168 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/memory"
+)
+
+func TestRateLimiter_CheckRateLimit_Shadow(t *testing.T) {
+	limit := storage.Limit{Limit: 10, Window: time.Minute}
+	ps := &mockPolicyStorage{policies: map[string]storage.Policy{
+		"users": {Name: "users", KeyPattern: "user:*", Limit: limit, Shadow: true},
+	}}
+
+	tests := []struct {
+		name             string
+		inputKey         string
+		inputShadow      bool
+		mockAllowed      bool
+		wantAllowed      bool
+		wantShadowDenied bool
+		wantCounter      string
+	}{
+		{
+			name:             "shadow request would be denied",
+			inputKey:         "org:1",
+			inputShadow:      true,
+			mockAllowed:      false,
+			wantAllowed:      true,
+			wantShadowDenied: true,
+			wantCounter:      "inline:denied",
+		},
+		{
+			name:        "shadow request allowed",
+			inputKey:    "org:1",
+			inputShadow: true,
+			mockAllowed: true,
+			wantAllowed: true,
+			wantCounter: "inline:allowed",
+		},
+		{
+			name:             "shadow policy would deny",
+			inputKey:         "user:1",
+			mockAllowed:      false,
+			wantAllowed:      true,
+			wantShadowDenied: true,
+			wantCounter:      "users:denied",
+		},
+		{
+			name:        "enforced request denied",
+			inputKey:    "org:1",
+			mockAllowed: false,
+			wantAllowed: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored := &storage.Result{Allowed: tt.mockAllowed, Remaining: 0, Limit: 10, RetryAfter: time.Second}
+			mock := &mockStorage{checkAndUpdateResult: stored}
+
+			service := NewRateLimiterService(mock, WithPolicies(ps))
+
+			before := shadowCount(tt.wantCounter)
+			result, err := service.CheckRateLimit(context.Background(), CheckRequest{Key: tt.inputKey, Limit: limit, Cost: 1, Shadow: tt.inputShadow})
+			if err != nil {
+				t.Fatalf("CheckRateLimit() error = %v", err)
+			}
+
+			if result.Allowed != tt.wantAllowed {
+				t.Errorf("CheckRateLimit() allowed = %v, wantAllowed %v", result.Allowed, tt.wantAllowed)
+			}
+			if result.ShadowDenied != tt.wantShadowDenied {
+				t.Errorf("CheckRateLimit() shadow denied = %v, want %v", result.ShadowDenied, tt.wantShadowDenied)
+			}
+			if tt.wantShadowDenied && result.RetryAfter != 0 {
+				t.Errorf("CheckRateLimit() retry after = %v, want 0", result.RetryAfter)
+			}
+			if stored.Allowed != tt.mockAllowed {
+				t.Errorf("storage result changed to allowed = %v", stored.Allowed)
+			}
+			if tt.wantCounter != "" && shadowCount(tt.wantCounter) != before+1 {
+				t.Errorf("counter %s = %d, want %d", tt.wantCounter, shadowCount(tt.wantCounter), before+1)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_CheckRateLimit_ShadowParent(t *testing.T) {
+	ps := &mockPolicyStorage{policies: map[string]storage.Policy{
+		"orgs": {Name: "orgs", KeyPattern: "org:*", Limit: storage.Limit{Limit: 1000, Window: time.Minute}, Shadow: true},
+	}}
+	mock := &mockStorage{
+		checkAllResults:      []*storage.Result{{Allowed: true, Remaining: 50, Limit: 100}},
+		checkAndUpdateResult: &storage.Result{Allowed: false, Remaining: 0, Limit: 1000, RetryAfter: time.Second},
+	}
+
+	service := NewRateLimiterService(mock, WithPolicies(ps))
+	result, err := service.CheckRateLimit(context.Background(), CheckRequest{
+		Key:     "user:1",
+		Limit:   storage.Limit{Limit: 100, Window: time.Minute},
+		Cost:    1,
+		Parents: []Parent{{Key: "org:1"}},
+	})
+	if err != nil {
+		t.Fatalf("CheckRateLimit() error = %v", err)
+	}
+
+	// The shadow level is checked on its own so its denial can't stop the enforced level
+	if len(mock.gotAllChecks) != 1 || mock.gotAllChecks[0].Key != "user:1" {
+		t.Errorf("storage checked %+v together, want only user:1", mock.gotAllChecks)
+	}
+	if !result.Allowed || !result.ShadowDenied {
+		t.Errorf("CheckRateLimit() allowed = %v, shadow denied = %v, want both true", result.Allowed, result.ShadowDenied)
+	}
+	if result.LimitedBy != "org:1" {
+		t.Errorf("CheckRateLimit() limited by = %q, want %q", result.LimitedBy, "org:1")
+	}
+}
+
+func TestRateLimiter_CheckRateLimit_ShadowAlongside(t *testing.T) {
+	ps := &mockPolicyStorage{policies: map[string]storage.Policy{
+		"users":     {Name: "users", KeyPattern: "user:*", Limit: storage.Limit{Limit: 1, Window: time.Minute}},
+		"new-users": {Name: "new-users", KeyPattern: "user:1*", Limit: storage.Limit{Limit: 5, Window: time.Minute}, Shadow: true},
+	}}
+	store := memory.NewMemoryStorage()
+	service := NewRateLimiterService(store, WithPolicies(ps))
+	req := CheckRequest{Key: "user:1", Cost: 1}
+
+	// The longer shadow pattern doesn't take the enforced policy's place
+	first, err := service.CheckRateLimit(context.Background(), req)
+	if err != nil {
+		t.Fatalf("CheckRateLimit() error = %v", err)
+	}
+	if !first.Allowed {
+		t.Fatalf("first CheckRateLimit() allowed = false, want true")
+	}
+	second, err := service.CheckRateLimit(context.Background(), req)
+	if err != nil {
+		t.Fatalf("CheckRateLimit() error = %v", err)
+	}
+	if second.Allowed || second.Policy.Name != "users" {
+		t.Errorf("second CheckRateLimit() allowed = %v by %q, want denied by users", second.Allowed, second.Policy.Name)
+	}
+
+	// The shadow policy still counts every request under its own key
+	status, err := store.GetStatus(context.Background(), storage.SubKey("user:1", shadowSuffix), storage.Limit{Algorithm: storage.FixedWindow, Limit: 5, Window: time.Minute})
+	if err != nil {
+		t.Fatalf("GetStatus() error = %v", err)
+	}
+	if status.Remaining != 3 {
+		t.Errorf("shadow remaining = %d, want 3", status.Remaining)
+	}
+}
+
+// shadowCount reads a shadow decision counter, which is zero until first recorded.
+func shadowCount(name string) int64 {
+	if name == "" {
+		return 0
+	}
+	if v, ok := shadowDecisions.Get(name).(interface{ Value() int64 }); ok {
+		return v.Value()
+	}
+	return 0
+}
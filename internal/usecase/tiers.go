@@ -0,0 +1,129 @@
+package usecase
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+)
+
+// MaxTiers is the most tiers a policy can have on top of its Limit.
+const MaxTiers = 8
+
+// tierChecks builds one storage check per limit of the policy. Every tier after the first is counted under its own
+// key, e.g. "{user:1}:1h0m0s", so tiers sharing an algorithm don't share a counter.
+func (rls *RateLimiterService) tierChecks(key string, policy storage.Policy, cost int64, request int) ([]storage.Check, []resolution, error) {
+	limits := policy.Limits()
+	checks := make([]storage.Check, len(limits))
+	resolved := make([]resolution, len(limits))
+	for i, limit := range limits {
+		tierKey := key
+		if i > 0 {
+			tierKey = storage.SubKey(key, tierName(limit))
+		}
+		tierKey, limit, periodEnd, err := rls.calendarWindow(tierKey, storageLimit(policy, limit))
+		if err != nil {
+			return nil, nil, err
+		}
+		checks[i] = storage.Check{Key: tierKey, Limit: limit, Cost: cost}
+		resolved[i] = resolution{policy: policy, periodEnd: periodEnd, request: request, tier: i, key: key, shadow: policy.Shadow}
+	}
+	return checks, resolved, nil
+}
+
+// storageLimit returns limit as storage checks it for the policy, carrying the policy's failure mode.
+func storageLimit(policy storage.Policy, limit storage.Limit) storage.Limit {
+	limit.FailureMode = policy.FailureMode
+	return limit
+}
+
+// eachTier calls fn with the storage key and limit of every tier of the policy and reports the most restrictive result.
+// Keys with an allow or deny override are answered without calling fn.
+func (rls *RateLimiterService) eachTier(key string, policy storage.Policy, action storage.OverrideAction, fn func(key string, limit storage.Limit) (*storage.Result, error)) (*Result, error) {
+	checks, resolved, err := rls.tierChecks(key, policy, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	// Reading or refunding a shadow limit reports it as it is, without recording a decision
+	for i := range resolved {
+		resolved[i].shadow, resolved[i].override = false, action
+	}
+
+	results := make([]*storage.Result, len(checks))
+	for i, check := range checks {
+		if results[i] = rls.overrideResult(check, action); results[i] != nil {
+			continue
+		}
+		results[i], err = fn(check.Key, check.Limit)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return newBatchResult(results, resolved, 1).Results[0], nil
+}
+
+// mostRestrictive returns the index of the result that binds: when any is denied, a deny override or else the denied
+// result that frees up last, otherwise the one with the least remaining. Ties go to the later reset, then to the first result.
+func mostRestrictive(results []*Result) int {
+	best := 0
+	for i, result := range results[1:] {
+		current := results[best]
+		switch {
+		case result.Allowed != current.Allowed:
+			// A denied result always binds over an allowed one
+			if !result.Allowed {
+				best = i + 1
+			}
+		case !result.Allowed && (result.Override == storage.AlwaysDeny) != (current.Override == storage.AlwaysDeny):
+			// A deny override never frees up, so it binds over any other denial
+			if result.Override == storage.AlwaysDeny {
+				best = i + 1
+			}
+		case !result.Allowed:
+			if result.RetryAfter > current.RetryAfter {
+				best = i + 1
+			}
+		case result.Remaining < current.Remaining || (result.Remaining == current.Remaining && result.ResetAt.After(current.ResetAt)):
+			best = i + 1
+		}
+	}
+	return best
+}
+
+// tierName identifies a tier by its period or window, which validateTiers keeps unique within a policy.
+func tierName(limit storage.Limit) string {
+	if limit.Period != "" {
+		return string(limit.Period)
+	}
+	return limit.Window.String()
+}
+
+// validateTiers checks every tier of the policy and fills in their default algorithms.
+func validateTiers(policy storage.Policy) (storage.Policy, error) {
+	if len(policy.Tiers) == 0 {
+		return policy, nil
+	}
+	// Leases can only be counted against a single limit
+	if len(policy.Tiers) > MaxTiers || policy.Limit.Algorithm == storage.Concurrency {
+		return policy, ErrInvalidTiers
+	}
+
+	// Copy the tiers so the caller's slice isn't changed
+	policy.Tiers = slices.Clone(policy.Tiers)
+	names := []string{tierName(policy.Limit)}
+	for i, tier := range policy.Tiers {
+		tier, err := validateLimit(tier, true)
+		if err != nil {
+			return policy, fmt.Errorf("tiers[%d]: %w", i, err)
+		}
+		if tier.Algorithm == storage.Concurrency {
+			return policy, fmt.Errorf("tiers[%d]: %w", i, ErrInvalidAlgorithm)
+		}
+		if slices.Contains(names, tierName(tier)) {
+			return policy, fmt.Errorf("tiers[%d]: %w", i, ErrInvalidTiers)
+		}
+		names = append(names, tierName(tier))
+		policy.Tiers[i] = tier
+	}
+	return policy, nil
+}
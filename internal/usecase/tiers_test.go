@@ -0,0 +1,183 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/memory"
+	"github.com/AaronBrownDev/distributed-rate-limiter/internal/storage/storagetest"
+)
+
+func TestRateLimiter_CheckRateLimit_Tiers(t *testing.T) {
+	now := time.Date(2026, time.October, 17, 15, 4, 5, 0, time.UTC)
+	resetAt := now.Add(time.Second)
+	ps := &mockPolicyStorage{policies: map[string]storage.Policy{
+		"users": {Name: "users", KeyPattern: "user:*", Limit: storage.Limit{Algorithm: storage.TokenBucket, Limit: 20, Window: time.Second}, Tiers: []storage.Limit{
+			{Algorithm: storage.SlidingWindowCounter, Limit: 1000, Window: time.Hour},
+			{Algorithm: storage.FixedWindow, Limit: 10000, Period: storage.Daily},
+		}},
+	}}
+
+	tests := []struct {
+		name          string
+		mockResults   []*storage.Result
+		mockError     error
+		wantErr       error
+		wantAllowed   bool
+		wantTier      int
+		wantRemaining int64
+		wantDegraded  bool
+	}{
+		{
+			name: "allowed reports the least remaining",
+			mockResults: []*storage.Result{
+				{Allowed: true, Remaining: 19, Limit: 20, ResetAt: resetAt},
+				{Allowed: true, Remaining: 3, Limit: 1000, ResetAt: resetAt},
+				{Allowed: true, Remaining: 9000, Limit: 10000, ResetAt: resetAt},
+			},
+			wantAllowed:   true,
+			wantTier:      1,
+			wantRemaining: 3,
+		},
+		{
+			name: "denied by the daily tier",
+			mockResults: []*storage.Result{
+				{Allowed: true, Remaining: 19, Limit: 20, ResetAt: resetAt},
+				{Allowed: true, Remaining: 3, Limit: 1000, ResetAt: resetAt},
+				{Allowed: false, Remaining: 0, Limit: 10000, ResetAt: resetAt, RetryAfter: time.Hour},
+			},
+			wantAllowed:   false,
+			wantTier:      2,
+			wantRemaining: 0,
+		},
+		{
+			name: "denied tier that frees up last",
+			mockResults: []*storage.Result{
+				{Allowed: false, Remaining: 0, Limit: 20, ResetAt: resetAt, RetryAfter: time.Second},
+				{Allowed: false, Remaining: 0, Limit: 1000, ResetAt: resetAt, RetryAfter: time.Minute},
+				{Allowed: true, Remaining: 9000, Limit: 10000, ResetAt: resetAt},
+			},
+			wantAllowed:   false,
+			wantTier:      1,
+			wantRemaining: 0,
+		},
+		{
+			name: "degraded tier marks the result",
+			mockResults: []*storage.Result{
+				{Allowed: true, Remaining: 19, Limit: 20, ResetAt: resetAt},
+				{Allowed: true, Remaining: 3, Limit: 1000, ResetAt: resetAt},
+				{Allowed: true, Remaining: 10000, Limit: 10000, ResetAt: resetAt, Degraded: true},
+			},
+			wantAllowed:   true,
+			wantTier:      1,
+			wantRemaining: 3,
+			wantDegraded:  true,
+		},
+		{
+			name:      "storage error",
+			mockError: storage.ErrUnsupportedAlgorithm,
+			wantErr:   storage.ErrUnsupportedAlgorithm,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockStorage{checkAllResults: tt.mockResults, checkAndUpdateError: tt.mockError}
+
+			service := NewRateLimiterService(mock, WithPolicies(ps))
+			service.now = func() time.Time { return now }
+
+			result, err := service.CheckRateLimit(context.Background(), CheckRequest{Key: "user:1", Cost: 1})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CheckRateLimit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			// Every tier is checked in one call under its own key
+			wantKeys := []string{"user:1", "{user:1}:1h0m0s", "{user:1}:day:2026-10-17"}
+			var gotKeys []string
+			for _, check := range mock.gotAllChecks {
+				gotKeys = append(gotKeys, check.Key)
+			}
+			if !reflect.DeepEqual(gotKeys, wantKeys) {
+				t.Errorf("storage got keys %v, want %v", gotKeys, wantKeys)
+			}
+			if err != nil {
+				return
+			}
+
+			if result.Allowed != tt.wantAllowed {
+				t.Errorf("CheckRateLimit() allowed = %v, wantAllowed %v", result.Allowed, tt.wantAllowed)
+			}
+			if result.Tier != tt.wantTier {
+				t.Errorf("CheckRateLimit() tier = %d, want %d", result.Tier, tt.wantTier)
+			}
+			if result.Remaining != tt.wantRemaining {
+				t.Errorf("CheckRateLimit() remaining = %d, want %d", result.Remaining, tt.wantRemaining)
+			}
+			if result.Degraded != tt.wantDegraded {
+				t.Errorf("CheckRateLimit() degraded = %v, want %v", result.Degraded, tt.wantDegraded)
+			}
+			if result.Policy.Name != "users" {
+				t.Errorf("CheckRateLimit() policy = %q, want %q", result.Policy.Name, "users")
+			}
+		})
+	}
+}
+
+func TestRateLimiter_ResetLimit_Tiers(t *testing.T) {
+	now := time.Date(2026, time.October, 17, 15, 4, 5, 0, time.UTC)
+	ps := &mockPolicyStorage{policies: map[string]storage.Policy{
+		"users": {Name: "users", KeyPattern: "user:*", Limit: storage.Limit{Limit: 20, Window: time.Second}, Tiers: []storage.Limit{
+			{Limit: 10000, Period: storage.Monthly},
+		}},
+	}}
+	mock := &mockStorage{}
+
+	service := NewRateLimiterService(mock, WithPolicies(ps))
+	service.now = func() time.Time { return now }
+
+	if err := service.ResetLimit(context.Background(), "user:1"); err != nil {
+		t.Fatalf("ResetLimit() error = %v", err)
+	}
+	if want := []string{"user:1", "{user:1}:month:2026-10-01"}; !reflect.DeepEqual(mock.gotResets, want) {
+		t.Errorf("storage reset keys %v, want %v", mock.gotResets, want)
+	}
+}
+
+func TestRateLimiter_ResetLimit_ExpiredTier(t *testing.T) {
+	ctx := context.Background()
+	clock := storagetest.NewFakeClock(time.Unix(0, 0))
+	ps := &mockPolicyStorage{policies: map[string]storage.Policy{
+		"users": {Name: "users", KeyPattern: "user:*", Limit: storage.Limit{Limit: 5, Window: time.Second}, Tiers: []storage.Limit{
+			{Limit: 2, Window: time.Hour},
+		}},
+	}}
+	service := NewRateLimiterService(memory.NewMemoryStorage(memory.WithClock(clock.Now)), WithPolicies(ps))
+	service.now = clock.Now
+
+	// Use up the hourly tier, then let the per-second tier expire
+	for range 2 {
+		if _, err := service.CheckRateLimit(ctx, CheckRequest{Key: "user:1", Cost: 1}); err != nil {
+			t.Fatalf("CheckRateLimit() error = %v", err)
+		}
+	}
+	clock.Sleep(2 * time.Second)
+
+	if err := service.ResetLimit(ctx, "user:1"); err != nil {
+		t.Fatalf("ResetLimit() error = %v", err)
+	}
+	result, err := service.CheckRateLimit(ctx, CheckRequest{Key: "user:1", Cost: 1})
+	if err != nil || !result.Allowed {
+		t.Errorf("CheckRateLimit() after ResetLimit() = %+v, %v, want allowed", result, err)
+	}
+
+	// With nothing left to reset, the key isn't found
+	if err := service.ResetLimit(ctx, "user:2"); !errors.Is(err, storage.ErrKeyNotFound) {
+		t.Errorf("ResetLimit() of an unused key error = %v, want %v", err, storage.ErrKeyNotFound)
+	}
+}